@@ -0,0 +1,436 @@
+package lvs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Api serves a rest api managing an Ipvs:
+	//   GET    /services                                   list services
+	//   PUT    /services                                   sync all services (?force=true removes protected ones)
+	//   POST   /services                                   add a service
+	//   GET    /services/{type}/{host}/{port}              get a service
+	//   PUT    /services/{type}/{host}/{port}              edit a service, and sync its servers when given
+	//   DELETE /services/{type}/{host}/{port}              remove a service
+	//   POST   /services/{type}/{host}/{port}/servers      add a server
+	//   PUT    /services/{type}/{host}/{port}/servers/{host}/{port}
+	//   DELETE /services/{type}/{host}/{port}/servers/{host}/{port}
+	//   GET    /services/{type}/{host}/{port}/servers/{host}/{port}/history
+	//   GET    /schema                                     the ConfigSchema
+	//
+	// Documents sent to the api are validated against the ConfigSchema,
+	// failing with every error found, each with the JSON Pointer to the
+	// offending value.
+	//
+	// Every response carries the table's version in an X-Lvs-Version header,
+	// which changes with every change made through the api, and its
+	// Checksum once the request is handled in an X-Lvs-Checksum header, which
+	// only changes with the table itself. GET /services
+	// with ?watch={version} waits (up to ?timeout= seconds, 30 by default)
+	// for the version to differ before answering, so followers can mirror
+	// the table, see Follower
+	Api struct {
+		Ipvs *Ipvs
+		// Lvs serializes the changes, with those made through it elsewhere
+		Lvs *Lvs
+
+		versionMu sync.Mutex // guards version and changed, so reads don't wait for changes
+		version   uint64
+		changed   chan struct{} // closed and replaced whenever version changes
+	}
+
+	apiError struct {
+		Error  string       `json:"error"`
+		Errors SchemaErrors `json:"errors,omitempty"`
+	}
+
+	// checksumWriter adds the table's checksum to the response's headers
+	// when they are written, after the request changed the table
+	checksumWriter struct {
+		http.ResponseWriter
+		ipvs *Ipvs
+	}
+)
+
+// NewApi returns the rest api for ipvs (DefaultIpvs when nil), wrap it with
+// AuthMiddleware before exposing it on the network. Use NewClientApi for a
+// table also changed through an Lvs client, other than DefaultLvs
+func NewApi(ipvs *Ipvs) *Api {
+	if ipvs == nil || ipvs == DefaultIpvs {
+		return NewClientApi(DefaultLvs)
+	}
+	return NewClientApi(&Lvs{ipvs: ipvs})
+}
+
+// NewClientApi returns the rest api for the client's table (DefaultLvs when
+// nil), its changes taking the client's lock
+func NewClientApi(l *Lvs) *Api {
+	if l == nil {
+		l = DefaultLvs
+	}
+	// start from the clock so followers notice a restarted api
+	return &Api{Ipvs: l.ipvs, Lvs: l, version: uint64(time.Now().UnixNano())}
+}
+
+func (a *Api) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "schema" {
+		writeJson(rw, http.StatusOK, ConfigSchema())
+		return
+	}
+	if parts[0] != "services" {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+
+	if len(parts) == 1 && req.URL.Query().Get("watch") != "" {
+		a.watch(req)
+	}
+
+	if req.Method == "GET" || req.Method == "HEAD" {
+		// reads are served from the services committed by the last change,
+		// so they neither wait for one in progress nor see it half-applied
+		rw.Header().Set("X-Lvs-Version", strconv.FormatUint(a.currentVersion(), 10))
+		a.handle(rw, req, a.Ipvs.view(), parts)
+		return
+	}
+	a.Lvs.Do(func(ipvs *Ipvs) error {
+		rw.Header().Set("X-Lvs-Version", strconv.FormatUint(a.currentVersion(), 10))
+		a.handle(rw, req, ipvs, parts)
+		return nil
+	})
+	// once committed, conservatively counting failed changes too, they may
+	// have been partially applied
+	a.bump()
+}
+
+// handle serves req with ipvs, the committed view for reads
+func (a *Api) handle(rw http.ResponseWriter, req *http.Request, ipvs *Ipvs, parts []string) {
+	rw = checksumWriter{ResponseWriter: rw, ipvs: ipvs}
+	switch len(parts) {
+	case 1:
+		a.services(rw, req, ipvs)
+	case 4:
+		a.service(rw, req, ipvs, parts[1:])
+	case 5, 7:
+		if parts[4] != "servers" {
+			writeError(rw, http.StatusNotFound, NotFound)
+			return
+		}
+		a.server(rw, req, ipvs, parts[1:4], parts[5:])
+	case 8:
+		if parts[4] != "servers" || parts[7] != "history" {
+			writeError(rw, http.StatusNotFound, NotFound)
+			return
+		}
+		a.history(rw, req, ipvs, parts[1:4], parts[5:7])
+	default:
+		writeError(rw, http.StatusNotFound, NotFound)
+	}
+}
+
+// watch waits until the version differs from the one being watched, the
+// request's timeout passes or the client goes away
+func (a *Api) watch(req *http.Request) {
+	version, err := strconv.ParseUint(req.URL.Query().Get("watch"), 10, 64)
+	if err != nil {
+		return
+	}
+	timeout := 30 * time.Second
+	if seconds, err := strconv.Atoi(req.URL.Query().Get("timeout")); err == nil && seconds >= 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	a.versionMu.Lock()
+	if a.changed == nil {
+		a.changed = make(chan struct{})
+	}
+	current, changed := a.version, a.changed
+	a.versionMu.Unlock()
+	if current != version {
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-req.Context().Done():
+	}
+}
+
+func (a *Api) currentVersion() uint64 {
+	a.versionMu.Lock()
+	defer a.versionMu.Unlock()
+	return a.version
+}
+
+// bump changes the version, waking up watchers
+func (a *Api) bump() {
+	a.versionMu.Lock()
+	defer a.versionMu.Unlock()
+	a.version++
+	if a.changed != nil {
+		close(a.changed)
+	}
+	a.changed = make(chan struct{})
+}
+
+func (a *Api) services(rw http.ResponseWriter, req *http.Request, ipvs *Ipvs) {
+	switch req.Method {
+	case "GET", "HEAD":
+		writeJson(rw, http.StatusOK, ipvs.Services)
+	case "PUT":
+		services := make([]Service, 0, 0)
+		if err := decodeBody(req, SchemaServices, &services); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		for j := range services {
+			services[j] = services[j].WithDefaultWeights()
+		}
+		// weights synced through the api are attributed to its client
+		force := req.URL.Query().Get("force") == "true"
+		if err := ipvs.sync(services, force, apiCause(req)); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		writeJson(rw, http.StatusOK, ipvs.Services)
+	case "POST":
+		service := Service{}
+		if err := decodeBody(req, SchemaService, &service); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		service = service.WithDefaultWeights()
+		if err := ipvs.AddService(service); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		writeJson(rw, http.StatusCreated, service)
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (a *Api) service(rw http.ResponseWriter, req *http.Request, ipvs *Ipvs, key []string) {
+	service := findService(ipvs, key)
+	if service == nil {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+
+	switch req.Method {
+	case "GET", "HEAD":
+		writeJson(rw, http.StatusOK, service)
+	case "PUT":
+		edit := Service{}
+		if err := decodeBody(req, SchemaService, &edit); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		edit.Type, edit.Host, edit.Port = service.Type, service.Host, service.Port
+		edit = edit.WithDefaultWeights()
+		servers := edit.Servers
+		if servers == nil {
+			edit.Servers = service.Servers
+		}
+		if err := edit.Validate(); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		// the servers are synced like Sync does, -E only edits the service
+		edit.Servers = service.Servers
+		if err := ipvs.EditService(edit); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		service = findService(ipvs, key)
+		if servers != nil {
+			if err := service.syncServers(servers, apiCause(req)); err != nil {
+				writeError(rw, statusFor(err), err)
+				return
+			}
+			if err := ipvs.writeState(); err != nil {
+				writeError(rw, statusFor(err), err)
+				return
+			}
+		}
+		writeJson(rw, http.StatusOK, service)
+	case "DELETE":
+		if err := ipvs.RemoveService(service.Type, service.Host, service.Port); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+func (a *Api) server(rw http.ResponseWriter, req *http.Request, ipvs *Ipvs, key, serverKey []string) {
+	service := findService(ipvs, key)
+	if service == nil {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+
+	if len(serverKey) == 0 {
+		if req.Method != "POST" {
+			writeError(rw, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		server := Server{}
+		if err := decodeBody(req, SchemaServer, &server); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		server = server.WithDefaultWeight()
+		if err := service.AddServer(server); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		writeJson(rw, http.StatusCreated, server)
+		return
+	}
+
+	port, _ := strconv.Atoi(serverKey[1])
+	server := service.FindServer(serverKey[0], port)
+	if server == nil {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+
+	switch req.Method {
+	case "GET", "HEAD":
+		writeJson(rw, http.StatusOK, server)
+	case "PUT":
+		edit := Server{}
+		if err := decodeBody(req, SchemaServer, &edit); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+		edit.Host, edit.Port = server.Host, server.Port
+		if !edit.WeightSet {
+			// an edit without a weight leaves it alone
+			edit.Weight = server.Weight
+		}
+		if _, err := service.editServer(edit, apiCause(req)); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		writeJson(rw, http.StatusOK, edit)
+	case "DELETE":
+		if err := service.RemoveServer(server.Host, server.Port); err != nil {
+			writeError(rw, statusFor(err), err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+	}
+}
+
+// history lists the weight changes of a server
+func (a *Api) history(rw http.ResponseWriter, req *http.Request, ipvs *Ipvs, key, serverKey []string) {
+	service := findService(ipvs, key)
+	if service == nil {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+	if req.Method != "GET" && req.Method != "HEAD" {
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	port, _ := strconv.Atoi(serverKey[1])
+	if service.FindServer(serverKey[0], port) == nil {
+		writeError(rw, http.StatusNotFound, NotFound)
+		return
+	}
+	writeJson(rw, http.StatusOK, service.WeightHistory(serverKey[0], port))
+}
+
+// findService looks up a service from a {type}/{host}/{port} path
+func findService(ipvs *Ipvs, key []string) *Service {
+	port, err := strconv.Atoi(key[2])
+	if err != nil {
+		return nil
+	}
+	return ipvs.FindService(key[0], key[1], port)
+}
+
+// statusFor maps validation errors to a bad request, anything else is
+// assumed to be a failure applying the rules
+func statusFor(err error) int {
+	if _, ok := err.(ServerConflict); ok {
+		return http.StatusConflict
+	}
+	if _, ok := err.(UnreachableServer); ok {
+		return http.StatusBadRequest
+	}
+	if _, ok := err.(WeightOutOfBounds); ok {
+		return http.StatusBadRequest
+	}
+	switch err {
+	case InvalidServiceType, InvalidServiceScheduler, InvalidServerForwarder, InvalidServerPort, InvalidHost, InvalidFwmark, ErrInvalidCharacters:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict, DuplicateService, OverlappingService, ErrProtectedService:
+		return http.StatusConflict
+	}
+	// ipvsadm's failures wrap their output, see mapIpvsadmError
+	switch {
+	case errors.Is(err, ErrNoSuchService), errors.Is(err, ErrNoSuchDest):
+		return http.StatusNotFound
+	case errors.Is(err, ErrServiceExists), errors.Is(err, ErrDestExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (w checksumWriter) WriteHeader(status int) {
+	w.Header().Set("X-Lvs-Checksum", w.ipvs.Checksum())
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// decodeBody decodes the request's body into v, once validated against the
+// schema at ref
+func decodeBody(req *http.Request, ref string, v interface{}) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if err = ValidateSchema(body, ref); err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func writeJson(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
+
+// apiCause attributes the weight changes made for req to its client
+func apiCause(req *http.Request) WeightChange {
+	return WeightChange{Source: WeightSourceApi, Who: req.RemoteAddr}
+}
+
+func writeError(rw http.ResponseWriter, status int, err error) {
+	msg := http.StatusText(status)
+	if err != nil {
+		msg = err.Error()
+	}
+	errs, _ := err.(SchemaErrors)
+	writeJson(rw, status, apiError{Error: msg, Errors: errs})
+}
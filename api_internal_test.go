@@ -0,0 +1,103 @@
+package lvs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApi(t *testing.T) {
+	defer useFakeBackend()()
+
+	api := NewApi(&Ipvs{})
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("POST", "/services", strings.NewReader(`{"host":"10.0.0.1","port":80,"type":"tcp"}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("failed to add service - %d %s", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("POST", "/services/tcp/10.0.0.1/80/servers", strings.NewReader(`{"host":"10.0.1.1","port":80}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("failed to add server - %d %s", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("GET", "/services/tcp/10.0.0.1/80/servers/10.0.1.1/80", nil))
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `"host":"10.0.1.1"`) || !strings.Contains(rw.Body.String(), `"weight":1`) {
+		t.Errorf("failed to get server with the default weight - %d %s", rw.Code, rw.Body)
+	}
+
+	// an explicit 0 drains, an edit without a weight leaves it alone
+	for _, body := range []string{`{"weight":0}`, `{"upper_threshold":10}`} {
+		rw = httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("PUT", "/services/tcp/10.0.0.1/80/servers/10.0.1.1/80", strings.NewReader(body)))
+		if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `"weight":0`) {
+			t.Errorf("expected the server to be drained after %s - %d %s", body, rw.Code, rw.Body)
+		}
+	}
+
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("DELETE", "/services/tcp/10.0.0.2/80", nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("expected missing service to 404, got %d", rw.Code)
+	}
+}
+
+func TestApiEditServiceServers(t *testing.T) {
+	simulator := NewSimulator()
+	api := NewApi(NewIpvs(WithRunner(simulator)))
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("POST", "/services", strings.NewReader(`{"host":"10.0.0.1","port":80,"type":"tcp","scheduler":"rr","servers":[{"host":"10.0.1.1","port":80},{"host":"10.0.1.2","port":80}]}`)))
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("failed to add service - %d %s", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("PUT", "/services/tcp/10.0.0.1/80", strings.NewReader(`{"scheduler":"wrr","servers":[{"host":"10.0.1.2","port":80,"weight":3},{"host":"10.0.1.3","port":80}]}`)))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("failed to edit service - %d %s", rw.Code, rw.Body)
+	}
+	applied := simulator.Services()[0]
+	if applied.Scheduler != "wrr" || len(applied.Servers) != 2 || applied.FindServer("10.0.1.2", 80).Weight != 3 || applied.FindServer("10.0.1.3", 80) == nil {
+		t.Errorf("expected the servers synced to the table - %+v", applied)
+	}
+	if !api.Ipvs.Services[0].Equal(applied) {
+		t.Errorf("the recorded service differs from the table - %+v", api.Ipvs.Services[0])
+	}
+
+	// without servers, they are left as they are
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("PUT", "/services/tcp/10.0.0.1/80", strings.NewReader(`{"scheduler":"rr"}`)))
+	if rw.Code != http.StatusOK || len(simulator.Services()[0].Servers) != 2 || len(api.Ipvs.Services[0].Servers) != 2 {
+		t.Errorf("expected the servers left as they are - %d %s", rw.Code, rw.Body)
+	}
+}
+
+func TestApiClientLock(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	api := NewClientApi(client)
+	done := make(chan int, 1)
+	code := 0
+	client.Do(func(i *Ipvs) error {
+		go func() {
+			rw := httptest.NewRecorder()
+			api.ServeHTTP(rw, httptest.NewRequest("POST", "/services", strings.NewReader(`{"host":"10.0.0.1","port":80,"type":"tcp"}`)))
+			done <- rw.Code
+		}()
+		select {
+		case code = <-done:
+			t.Error("expected the change to wait for the client's lock")
+		case <-time.After(50 * time.Millisecond):
+		}
+		return nil
+	})
+	if code == 0 {
+		code = <-done
+	}
+	if code != http.StatusCreated || len(client.Services()) != 1 {
+		t.Errorf("failed to add service - %d", code)
+	}
+}
@@ -0,0 +1,107 @@
+package lvs
+
+import (
+	"net/http"
+	"strings"
+)
+
+type (
+	// Role is the access level granted to an api client
+	Role int
+
+	// Authenticator identifies the client making a request, returning false
+	// when the client could not be identified
+	Authenticator interface {
+		Authenticate(req *http.Request) (Role, bool)
+	}
+
+	// Authorizer decides whether a role may perform a request
+	Authorizer interface {
+		Authorize(role Role, req *http.Request) bool
+	}
+
+	// TokenAuthenticator maps bearer tokens to roles
+	TokenAuthenticator map[string]Role
+
+	// CertAuthenticator maps the common name of a verified tls client
+	// certificate to a role, for use with mtls listeners
+	CertAuthenticator map[string]Role
+
+	// MultiAuthenticator tries each Authenticator in order
+	MultiAuthenticator []Authenticator
+
+	// RoleAuthorizer lets read only clients use safe methods, and admins
+	// anything
+	RoleAuthorizer struct{}
+
+	authMiddleware struct {
+		authn Authenticator
+		authz Authorizer
+		next  http.Handler
+	}
+)
+
+const (
+	RoleNone Role = iota
+	RoleReadOnly
+	RoleAdmin
+)
+
+// AuthMiddleware rejects requests that fail authentication (401) or
+// authorization (403) before they reach next. A nil authz uses RoleAuthorizer
+func AuthMiddleware(authn Authenticator, authz Authorizer, next http.Handler) http.Handler {
+	if authz == nil {
+		authz = RoleAuthorizer{}
+	}
+	return authMiddleware{authn: authn, authz: authz, next: next}
+}
+
+func (m authMiddleware) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	role, ok := m.authn.Authenticate(req)
+	if !ok {
+		writeError(rw, http.StatusUnauthorized, nil)
+		return
+	}
+	if !m.authz.Authorize(role, req) {
+		writeError(rw, http.StatusForbidden, nil)
+		return
+	}
+	m.next.ServeHTTP(rw, req)
+}
+
+func (t TokenAuthenticator) Authenticate(req *http.Request) (Role, bool) {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return RoleNone, false
+	}
+	role, ok := t[strings.TrimPrefix(header, "Bearer ")]
+	return role, ok
+}
+
+func (c CertAuthenticator) Authenticate(req *http.Request) (Role, bool) {
+	// only chains verified against the client ca can be trusted
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return RoleNone, false
+	}
+	role, ok := c[req.TLS.VerifiedChains[0][0].Subject.CommonName]
+	return role, ok
+}
+
+func (m MultiAuthenticator) Authenticate(req *http.Request) (Role, bool) {
+	for i := range m {
+		if role, ok := m[i].Authenticate(req); ok {
+			return role, ok
+		}
+	}
+	return RoleNone, false
+}
+
+func (r RoleAuthorizer) Authorize(role Role, req *http.Request) bool {
+	switch role {
+	case RoleAdmin:
+		return true
+	case RoleReadOnly:
+		return req.Method == "GET" || req.Method == "HEAD"
+	}
+	return false
+}
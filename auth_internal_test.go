@@ -0,0 +1,34 @@
+package lvs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	handler := AuthMiddleware(TokenAuthenticator{"ro": RoleReadOnly, "admin": RoleAdmin}, nil, ok)
+
+	tests := []struct {
+		method, token string
+		status        int
+	}{
+		{"GET", "", http.StatusUnauthorized},
+		{"GET", "bogus", http.StatusUnauthorized},
+		{"GET", "ro", http.StatusOK},
+		{"DELETE", "ro", http.StatusForbidden},
+		{"DELETE", "admin", http.StatusOK},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/services", nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.Code != test.status {
+			t.Errorf("%s with token '%s' - expected %d, got %d", test.method, test.token, test.status, rw.Code)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package lvs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Batcher coalesces the changes made within Window of the first pending
+	// change into a single `ipvsadm -R`, rather than running ipvsadm for every
+	// change. Useful when a discovery source floods updates
+	Batcher struct {
+		Lvs     *Lvs          // defaults to DefaultLvs
+		Window  time.Duration // defaults to 200ms
+		OnError func(error)   // called with errors from flushes run by the timer
+
+		mu      sync.Mutex
+		lvsOnce sync.Once
+		pending map[string]batchChange
+		order   []string
+		timer   *time.Timer
+	}
+
+	batchChange struct {
+		service Service
+		remove  bool
+	}
+)
+
+// AddService queues service to be added, or to replace the service with
+// the same key (including its servers)
+func (b *Batcher) AddService(service Service) error {
+	if err := service.Validate(); err != nil {
+		return err
+	}
+	service, err := service.Normalize()
+	if err != nil {
+		return err
+	}
+	b.queue(service.key(), batchChange{service: service})
+	return nil
+}
+
+// RemoveService queues the service to be removed
+func (b *Batcher) RemoveService(netType, host string, port int) {
+	service := Service{Type: netType, Host: host, Port: port}
+	b.queue(service.key(), batchChange{service: service, remove: true})
+}
+
+func (b *Batcher) queue(key string, change batchChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]batchChange)
+	}
+	if _, ok := b.pending[key]; !ok {
+		b.order = append(b.order, key)
+	}
+	b.pending[key] = change
+
+	if b.timer == nil {
+		window := b.Window
+		if window <= 0 {
+			window = 200 * time.Millisecond
+		}
+		b.timer = time.AfterFunc(window, func() {
+			if err := b.Flush(); err != nil && b.OnError != nil {
+				b.OnError(err)
+			}
+		})
+	}
+}
+
+// Flush applies the pending changes now
+func (b *Batcher) Flush() error {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	changes := make([]batchChange, 0, len(b.order))
+	for _, key := range b.order {
+		changes = append(changes, b.pending[key])
+	}
+	b.pending, b.order = nil, nil
+	b.mu.Unlock()
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return b.lvs().Do(func(i *Ipvs) error {
+		return i.applyBatch(changes)
+	})
+}
+
+// lvs returns the client, defaulting Lvs once as the timer flushes
+// concurrently with callers
+func (b *Batcher) lvs() *Lvs {
+	b.lvsOnce.Do(func() {
+		if b.Lvs == nil {
+			b.Lvs = DefaultLvs
+		}
+	})
+	return b.Lvs
+}
+
+// applyBatch applies changes with a single `ipvsadm -R`, only updating
+// i.Services once it succeeded
+func (i *Ipvs) applyBatch(changes []batchChange) error {
+	defer i.changing()()
+	unlock, err := i.exec.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	in := make([]string, 0, 0)
+	for _, change := range changes {
+		change.service.exec = i.exec
+		applied, err := change.service.resolve()
+		if err != nil {
+			return err
+		}
+		flag, hostPort := ServiceTypeFlag[applied.Type], applied.getHostPort()
+		current := i.FindService(change.service.Type, change.service.Host, change.service.Port)
+		switch {
+		case change.remove:
+			if current != nil {
+				in = append(in, fmt.Sprintf("-D %s %s\n", flag, hostPort))
+			}
+		case current == nil:
+			in = append(in, applied.String())
+		default:
+			if !current.sameAttributes(applied) {
+				in = append(in, strings.Replace(strings.SplitAfter(applied.String(), "\n")[0], "-A", "-E", 1))
+			}
+			for _, server := range applied.Servers {
+				existing := current.FindServer(server.Host, server.Port)
+				if existing == nil {
+					in = append(in, fmt.Sprintf("-a %s %s -r %s\n", flag, hostPort, server.String()))
+				} else if !existing.sameAttributes(server) {
+					in = append(in, fmt.Sprintf("-e %s %s -r %s\n", flag, hostPort, server.String()))
+				}
+			}
+			for _, server := range current.Servers {
+				if applied.FindServer(server.Host, server.Port) == nil {
+					in = append(in, fmt.Sprintf("-d %s %s -r %s\n", flag, hostPort, server.getHostPort()))
+				}
+			}
+		}
+	}
+	if len(in) > 0 {
+		if err := i.exec.executeStdin(strings.Join(in, ""), "ipvsadm", "-R"); err != nil {
+			return err
+		}
+	}
+
+	now := stampNow()
+	for _, change := range changes {
+		change.service.exec = i.exec
+		current := i.FindService(change.service.Type, change.service.Host, change.service.Port)
+		switch {
+		case change.remove:
+			for j := range i.Services {
+				if &i.Services[j] == current {
+					i.Services = append(i.Services[:j], i.Services[j+1:]...)
+					break
+				}
+			}
+		case current == nil:
+			i.Services = append(i.Services, stampApplied(nil, change.service, now))
+		default:
+			for _, server := range change.service.Servers {
+				if previous := current.FindServer(server.Host, server.Port); previous != nil {
+					i.exec.recordWeight(WeightChange{}, *current, server, previous.Weight, server.Weight)
+				}
+			}
+			*current = stampApplied(current, change.service, now)
+		}
+	}
+	return i.writeState()
+}
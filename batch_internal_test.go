@@ -0,0 +1,74 @@
+package lvs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatcherFlush(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1}}})
+	client.AddService(Service{Host: "10.0.0.1", Port: 53, Type: "udp"})
+	fakeExecuted = nil
+
+	batcher := &Batcher{Lvs: client, Window: time.Hour}
+	batcher.AddService(Service{Host: "10.0.0.2", Port: 80})
+	batcher.AddService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 5}, {Host: "10.0.1.3", Port: 80, Weight: 1}}})
+	batcher.RemoveService("udp", "10.0.0.1", 53)
+	batcher.AddService(Service{Host: "10.0.0.2", Port: 80, Scheduler: "sh"})
+	if err := batcher.Flush(); err != nil {
+		t.Fatalf("failed to flush - %v", err)
+	}
+
+	if len(fakeExecuted) != 0 || len(fakeStdin) != 1 {
+		t.Fatalf("expected a single restore, got %q and %q", fakeExecuted, fakeStdin)
+	}
+	for _, line := range []string{
+		"-A -t 10.0.0.2:80 -s sh",
+		"-E -t 10.0.0.1:80 -s rr",
+		"-e -t 10.0.0.1:80 -r 10.0.1.1:80 -g -y 0 -x 0 -w 5",
+		"-a -t 10.0.0.1:80 -r 10.0.1.3:80",
+		"-d -t 10.0.0.1:80 -r 10.0.1.2:80",
+		"-D -u 10.0.0.1:53",
+	} {
+		if !strings.Contains(fakeStdin[0], line) {
+			t.Errorf("missing %q from %q", line, fakeStdin[0])
+		}
+	}
+
+	services := client.Services()
+	if len(services) != 2 || services[0].Scheduler != "rr" || len(services[0].Servers) != 2 || services[1].Scheduler != "sh" {
+		t.Errorf("unexpected services - %+v", services)
+	}
+
+	fakeStdin = nil
+	if err := batcher.Flush(); err != nil || len(fakeStdin) != 0 {
+		t.Errorf("empty flush ran a restore - %q, %v", fakeStdin, err)
+	}
+}
+
+func TestBatcherWindow(t *testing.T) {
+	simulator := NewSimulator()
+	client := New(WithRunner(simulator))
+	flushed := make(chan error, 1)
+	batcher := &Batcher{Lvs: client, Window: 20 * time.Millisecond, OnError: func(err error) { flushed <- err }}
+
+	for port := 8000; port < 8010; port++ {
+		batcher.AddService(Service{Host: "10.0.0.1", Port: port})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(simulator.Services()) != 10 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(simulator.Services()) != 10 || len(client.Services()) != 10 {
+		t.Errorf("expected 10 services once the window passed, got %+v", simulator.Services())
+	}
+	select {
+	case err := <-flushed:
+		t.Errorf("unexpected flush error - %v", err)
+	default:
+	}
+}
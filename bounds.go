@@ -0,0 +1,58 @@
+package lvs
+
+import (
+	"fmt"
+)
+
+type (
+	// WeightBounds bound the weights of a service's servers, however they
+	// are written (config, api, health checks, latency weighting), so a
+	// typo or a runaway controller can't have a single server take all the
+	// traffic. Weight 0 (quiesced) is always allowed
+	WeightBounds struct {
+		Min int `json:"min,omitempty"` // of servers in rotation
+		Max int `json:"max,omitempty"` // 0 is unbounded
+		// Clamp brings weights out of bounds to the nearest bound rather
+		// than refusing them with a WeightOutOfBounds
+		Clamp bool `json:"clamp,omitempty"`
+	}
+
+	// WeightOutOfBounds is returned rather than writing a server weight
+	// outside of its service's WeightBounds
+	WeightOutOfBounds struct {
+		Service  string // type and host:port of the service
+		Server   Server
+		Min, Max int
+	}
+)
+
+func (e WeightOutOfBounds) Error() string {
+	if e.Max > 0 {
+		return fmt.Sprintf("weight %d of server %s of %s is out of bounds [%d, %d]", e.Server.Weight, e.Server.getHostPort(), e.Service, e.Min, e.Max)
+	}
+	return fmt.Sprintf("weight %d of server %s of %s is below %d", e.Server.Weight, e.Server.getHostPort(), e.Service, e.Min)
+}
+
+// boundWeight checks server's weight against the service's WeightBounds,
+// clamping it when they say so
+func (s Service) boundWeight(server Server) (Server, error) {
+	b := s.WeightBounds
+	if b == nil || server.Weight == 0 {
+		return server, nil
+	}
+	bounded := server.Weight
+	if bounded < b.Min {
+		bounded = b.Min
+	}
+	if b.Max > 0 && bounded > b.Max {
+		bounded = b.Max
+	}
+	if bounded == server.Weight {
+		return server, nil
+	}
+	if !b.Clamp {
+		return server, WeightOutOfBounds{Service: s.Type + " " + s.getHostPort(), Server: server, Min: b.Min, Max: b.Max}
+	}
+	server.Weight = bounded
+	return server, nil
+}
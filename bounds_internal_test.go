@@ -0,0 +1,50 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestWeightBounds(t *testing.T) {
+	ipvs := NewIpvs(WithRunner(NewSimulator()))
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "wrr", WeightBounds: &WeightBounds{Min: 2, Max: 10},
+		Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 20}}}
+	err := ipvs.AddService(service)
+	if bounds, ok := err.(WeightOutOfBounds); !ok || bounds.Server.Weight != 20 || err.Error() != "weight 20 of server 10.0.1.1:80 of tcp 10.0.0.1:80 is out of bounds [2, 10]" {
+		t.Fatalf("expected the weight to be refused - %v", err)
+	}
+	if statusFor(err) != 400 {
+		t.Errorf("expected a bad request - %d", statusFor(err))
+	}
+
+	service.Servers[0].Weight = 5
+	if err := ipvs.AddService(service); err != nil {
+		t.Fatal(err)
+	}
+	current := ipvs.FindService("tcp", "10.0.0.1", 80)
+	if err := current.AddServer(Server{Host: "10.0.1.2", Port: 80, Weight: 1}); err == nil {
+		t.Error("expected a weight below the minimum to be refused")
+	}
+	if err := current.EditServer(Server{Host: "10.0.1.1", Port: 80, Weight: 11}); err == nil {
+		t.Error("expected an edit above the maximum to be refused")
+	}
+	if err := current.EditServer(Server{Host: "10.0.1.1", Port: 80, Weight: 0}); err != nil {
+		t.Errorf("expected quiescing to be allowed - %v", err)
+	}
+
+	// clamped rather than refused
+	service.WeightBounds.Clamp = true
+	service.Servers = []Server{{Host: "10.0.1.1", Port: 80, Weight: 50}, {Host: "10.0.1.2", Port: 80, Weight: 1}}
+	if err := ipvs.Sync([]Service{service}); err != nil {
+		t.Fatal(err)
+	}
+	current = ipvs.FindService("tcp", "10.0.0.1", 80)
+	if current.Servers[0].Weight != 10 || current.Servers[1].Weight != 2 {
+		t.Errorf("expected the weights to be clamped - %+v", current.Servers)
+	}
+	if drift, err := ipvs.Drift([]Service{service}); err != nil || len(drift) != 0 {
+		t.Errorf("expected clamped weights to be in sync - %v %v", drift, err)
+	}
+	if err := current.EditServer(Server{Host: "10.0.1.2", Port: 80, Weight: 100}); err != nil || current.Servers[1].Weight != 10 {
+		t.Errorf("expected the edit to be clamped - %v %+v", err, current.Servers)
+	}
+}
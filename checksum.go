@@ -0,0 +1,55 @@
+package lvs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ChecksumVersion prefixes checksums, it changes whenever the way they
+	// are computed does so checksums of different versions never match
+	ChecksumVersion = "v1"
+)
+
+// Checksum returns a stable hash of the table's services, such as
+// "v1:3f5a...", so drift between the intended and the actual table can be
+// detected by comparing checksums rather than tables. Services and servers
+// are hashed in canonical form (see NormalizeHost) and in a fixed order,
+// so the same table always has the same checksum however it was written
+// down. Only what ipvsadm applies is hashed, names, MinServers and Protected aren't
+func (i Ipvs) Checksum() string {
+	return checksum(i.Services)
+}
+
+// Checksum returns the checksum of the client's table as of the last
+// completed change, see Ipvs.Checksum
+func (l *Lvs) Checksum() string {
+	return checksum(l.ipvs.Committed())
+}
+
+func checksum(services []Service) string {
+	rules := make([]string, len(services))
+	for j, service := range services {
+		service.Host = canonicalHost(service.Host)
+		if ServiceTypeFlag[service.Type] == "-f" {
+			if mark, err := strconv.ParseUint(service.Host, 0, 32); err == nil {
+				service.Host = strconv.FormatUint(mark, 10)
+			}
+		}
+		servers := make([]string, len(service.Servers))
+		for k, server := range service.Servers {
+			server.Host = canonicalHost(server.Host)
+			servers[k] = server.String()
+		}
+		sort.Strings(servers)
+		service.Servers = nil
+		rules[j] = service.String() + strings.Join(servers, "\n")
+	}
+	sort.Strings(rules)
+
+	hash := sha256.Sum256([]byte(strings.Join(rules, "\n")))
+	return ChecksumVersion + ":" + hex.EncodeToString(hash[:])
+}
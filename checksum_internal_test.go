@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	a := Ipvs{Services: []Service{
+		{Type: "tcp", Host: "::1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1}}},
+		{Type: "fwmark", Host: "0x10", Scheduler: "wrr"},
+	}}
+	b := Ipvs{Services: []Service{
+		{Type: "fwmark", Host: "16", Scheduler: "wrr"},
+		{Type: "tcp", Host: "0::1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.2", Port: 80, Weight: 1}, {Host: "10.0.1.1", Port: 80, Weight: 1}}},
+	}}
+	if !strings.HasPrefix(a.Checksum(), ChecksumVersion+":") {
+		t.Errorf("checksum isn't version stamped - %s", a.Checksum())
+	}
+	if a.Checksum() != b.Checksum() {
+		t.Errorf("the same table has different checksums - %s %s", a.Checksum(), b.Checksum())
+	}
+	b.Services[1].Servers[0].Weight = 2
+	if a.Checksum() == b.Checksum() {
+		t.Errorf("different tables have the same checksum")
+	}
+}
+
+func TestChecksumReported(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	var checksum string
+	client.Subscribe(EventHandlerFunc(func(e Event) { checksum = e.Checksum }))
+	if err := client.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	if checksum == "" || checksum != client.Checksum() {
+		t.Errorf("event has the wrong checksum - %q", checksum)
+	}
+
+	api := NewClientApi(client)
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("DELETE", "/services/tcp/10.0.0.1/80", nil))
+	if rw.Header().Get("X-Lvs-Checksum") != (Ipvs{}).Checksum() {
+		t.Errorf("response has the wrong checksum - %q", rw.Header().Get("X-Lvs-Checksum"))
+	}
+}
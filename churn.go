@@ -0,0 +1,173 @@
+package lvs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// ChurnGuard pauses Sync when discovery replaces an abnormal share of
+	// the servers within a short window, such as a bad deploy or a broken
+	// feed swapping the whole pool, until an operator confirms it or the
+	// feed settles on the same services for Stable. See WithChurnGuard
+	ChurnGuard struct {
+		Window     time.Duration // defaults to 1m
+		MaxPercent int           // of the servers applied when the window started, defaults to 50
+		// Stable is how long the same services must keep being synced
+		// for syncing to resume on its own, 0 waits for Confirm
+		Stable time.Duration
+
+		mu        sync.Mutex
+		removals  []churnRemoval
+		paused    bool
+		confirmed bool
+		wanted    string // checksum of the services synced while paused
+		since     time.Time
+		events    []Event
+	}
+
+	// churnRemoval is a sync of a ChurnGuard's window
+	churnRemoval struct {
+		time    time.Time
+		removed int
+		total   int // servers applied before the sync
+	}
+)
+
+var (
+	// ErrChurnPaused is returned by Sync, before changing anything, while
+	// a ChurnGuard is paused
+	ErrChurnPaused = errors.New("sync is paused on abnormal churn until confirmed")
+)
+
+const (
+	// published when a ChurnGuard pauses syncing, and once it resumes
+	EventChurnDetected = "churn-detected"
+	EventChurnResolved = "churn-resolved"
+)
+
+// WithChurnGuard has Sync check the servers it removes against g, failing
+// with ErrChurnPaused while it is paused
+func WithChurnGuard(g *ChurnGuard) Option {
+	return func(i *Ipvs) {
+		i.churn = g
+	}
+}
+
+// Confirm lets the next Sync through while paused, an operator vouching
+// for the churn
+func (g *ChurnGuard) Confirm() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		g.confirmed = true
+	}
+}
+
+// Paused reports whether syncing is paused
+func (g *ChurnGuard) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+// check fails while paused, and pauses when going from current to wanted
+// removes more than MaxPercent of the servers within Window. Servers of
+// removed services count as removed too. The removals only count toward
+// the window once the returned record is called, after the sync applied
+func (g *ChurnGuard) check(current, wanted []Service) (record func(), err error) {
+	record = func() {}
+	if g == nil {
+		return record, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+
+	if g.paused {
+		reason := ""
+		if checksum := checksum(wanted); g.confirmed {
+			reason = "confirmed"
+		} else if checksum != g.wanted {
+			g.wanted, g.since = checksum, now
+		} else if g.Stable > 0 && now.Sub(g.since) >= g.Stable {
+			reason = "stable for " + g.Stable.String()
+		}
+		if reason == "" {
+			return record, ErrChurnPaused
+		}
+		g.paused, g.confirmed, g.removals = false, false, nil
+		g.events = append(g.events, Event{Type: EventChurnResolved, Message: reason})
+		return record, nil
+	}
+
+	window, maxPercent := g.Window, g.MaxPercent
+	if window <= 0 {
+		window = time.Minute
+	}
+	if maxPercent <= 0 {
+		maxPercent = 50
+	}
+	for len(g.removals) > 0 && now.Sub(g.removals[0].time) > window {
+		g.removals = g.removals[1:]
+	}
+	removal := churnRemoval{time: now, removed: removedServers(current, wanted)}
+	for j := range current {
+		removal.total += len(current[j].Servers)
+	}
+	removed, total := removal.removed, removal.total
+	for _, previous := range g.removals {
+		removed += previous.removed
+	}
+	if len(g.removals) > 0 {
+		total = g.removals[0].total
+	}
+	if total > 0 && removed*100 > total*maxPercent {
+		g.paused, g.wanted, g.since = true, checksum(wanted), now
+		message := fmt.Sprintf("%d of %d servers removed within %s", removed, total, window)
+		g.events = append(g.events, Event{Type: EventChurnDetected, Message: message})
+		return record, ErrChurnPaused
+	}
+	if removal.removed > 0 {
+		record = func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.removals = append(g.removals, removal)
+		}
+	}
+	return record, nil
+}
+
+// takeEvents returns the events to publish since the last call
+func (g *ChurnGuard) takeEvents() []Event {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	events := g.events
+	g.events = nil
+	return events
+}
+
+// removedServers counts the servers of current that wanted doesn't have
+func removedServers(current, wanted []Service) int {
+	removed := 0
+	for j := range current {
+		var service *Service
+		for k := range wanted {
+			if wanted[k].key() == current[j].key() {
+				service = &wanted[k]
+				break
+			}
+		}
+		for _, server := range current[j].Servers {
+			if service == nil || service.FindServer(server.Host, server.Port) == nil {
+				removed++
+			}
+		}
+	}
+	return removed
+}
@@ -0,0 +1,92 @@
+package lvs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChurnGuard(t *testing.T) {
+	pool := func(hosts ...string) []Service {
+		service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr"}
+		for _, host := range hosts {
+			service.Servers = append(service.Servers, Server{Host: host, Port: 80, Weight: 1})
+		}
+		return []Service{service}
+	}
+	guard := &ChurnGuard{}
+	client := New(WithRunner(NewSimulator()), WithChurnGuard(guard))
+	events := []Event{}
+	client.Subscribe(EventHandlerFunc(func(e Event) {
+		if e.Type == EventChurnDetected || e.Type == EventChurnResolved {
+			events = append(events, e)
+		}
+	}))
+
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.5")); err != nil {
+		t.Fatalf("expected replacing a server to be allowed - %v", err)
+	}
+	// 3 of the 4 servers replaced within the window
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.6", "10.0.1.7", "10.0.1.5")); err != ErrChurnPaused {
+		t.Fatalf("expected the churn to pause syncing - %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventChurnDetected || events[0].Message != "3 of 4 servers removed within 1m0s" {
+		t.Errorf("unexpected events - %+v", events)
+	}
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.5")); err != ErrChurnPaused || !guard.Paused() {
+		t.Errorf("expected syncing to stay paused - %v", err)
+	}
+	if servers := client.Services()[0].Servers; servers[3].Host != "10.0.1.5" || servers[1].Host != "10.0.1.2" {
+		t.Errorf("the table changed while paused - %+v", servers)
+	}
+
+	guard.Confirm()
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.6", "10.0.1.7", "10.0.1.5")); err != nil || guard.Paused() {
+		t.Fatalf("expected a confirmed sync to go through - %v", err)
+	}
+	if len(events) != 2 || events[1].Type != EventChurnResolved || events[1].Message != "confirmed" {
+		t.Errorf("unexpected events - %+v", events)
+	}
+
+	// resuming once the feed is stable
+	guard.Stable = 10 * time.Millisecond
+	if err := client.Sync(pool("10.0.2.1", "10.0.2.2")); err != ErrChurnPaused {
+		t.Fatalf("expected the churn to pause syncing - %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := client.Sync(pool("10.0.2.1", "10.0.2.3")); err != ErrChurnPaused {
+		t.Errorf("expected a changing feed to stay paused - %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := client.Sync(pool("10.0.2.1", "10.0.2.3")); err != nil {
+		t.Errorf("expected a stable feed to resume syncing - %v", err)
+	}
+	if events[len(events)-1].Message != "stable for 10ms" {
+		t.Errorf("unexpected events - %+v", events)
+	}
+}
+
+func TestChurnGuardFailedSync(t *testing.T) {
+	pool := func(hosts ...string) []Service {
+		service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr"}
+		for _, host := range hosts {
+			service.Servers = append(service.Servers, Server{Host: host, Port: 80, Weight: 1})
+		}
+		return []Service{service}
+	}
+	protected := Service{Type: "tcp", Host: "10.0.0.2", Port: 80, Scheduler: "rr", Protected: true}
+	client := New(WithRunner(NewSimulator()), WithChurnGuard(&ChurnGuard{}))
+
+	if err := client.Sync(append(pool("10.0.1.1", "10.0.1.2", "10.0.1.3", "10.0.1.4"), protected)); err != nil {
+		t.Fatal(err)
+	}
+	// fails on the protected service, its removals mustn't count
+	if err := client.Sync(pool("10.0.1.1", "10.0.1.2")); err != ErrProtectedService {
+		t.Fatalf("expected the protected service to fail the sync - %v", err)
+	}
+	if err := client.Sync(append(pool("10.0.1.1", "10.0.1.2"), protected)); err != nil {
+		t.Errorf("expected the failed sync not to count toward the window - %v", err)
+	}
+}
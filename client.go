@@ -0,0 +1,190 @@
+package lvs
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Lvs is a client for one ipvs table, eg. the host's or a network
+	// namespace's. Each client has its own configuration and lock, so one
+	// process can manage several tables at once
+	Lvs struct {
+		ipvs   *Ipvs
+		mu     sync.Mutex
+		events eventHandlers
+	}
+)
+
+var (
+	// DefaultLvs manages DefaultIpvs, the package level functions use it
+	DefaultLvs = &Lvs{ipvs: DefaultIpvs}
+)
+
+// New returns a client for the ipvs table selected by opts
+func New(opts ...Option) *Lvs {
+	return &Lvs{ipvs: NewIpvs(opts...)}
+}
+
+// Do runs fn with exclusive access to the client's Ipvs, use it for anything
+// not wrapped by the client's own methods. Its changes are only seen by
+// Services and Checksum once fn returns
+func (l *Lvs) Do(fn func(*Ipvs) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.ipvs.commit()
+	return fn(l.ipvs)
+}
+
+// DoTimeout runs fn like Do, bounding each backend command it runs by
+// timeout instead of the client's usual timeout
+func (l *Lvs) DoTimeout(timeout time.Duration, fn func(*Ipvs) error) error {
+	return l.Do(func(i *Ipvs) error {
+		previous := i.exec.timeout
+		i.exec.timeout = timeout
+		defer func() { i.exec.timeout = previous }()
+		return fn(i)
+	})
+}
+
+// DoEnv runs fn like Do, adding vars ("NAME=value") to the environment of
+// the backend commands it runs
+func (l *Lvs) DoEnv(vars []string, fn func(*Ipvs) error) error {
+	return l.Do(func(i *Ipvs) error {
+		previous := i.exec.env
+		i.exec.env = append(append([]string{}, previous...), vars...)
+		defer func() { i.exec.env = previous }()
+		return fn(i)
+	})
+}
+
+// Services returns a copy of the services known to the client as of the last
+// completed change, without waiting for one in progress (see Ipvs.Committed)
+func (l *Lvs) Services() []Service {
+	return copyServices(l.ipvs.Committed())
+}
+
+// Load verifies that ipvsadm can be used for the client's table
+func (l *Lvs) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.ipvs.exec.run([]string{"which", "ipvsadm"}); err != nil {
+		return IpvsadmMissing
+	}
+
+	// NYI
+	// populate the ipvsadm command with what was stored in the backup
+	return nil
+}
+
+func (l *Lvs) SetTimeouts() error {
+	return l.Do(func(i *Ipvs) error { return i.SetTimeouts() })
+}
+
+func (l *Lvs) StartDaemon() (error, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ipvs.StartDaemon()
+}
+
+func (l *Lvs) StopDaemon() (error, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ipvs.StopDaemon()
+}
+
+func (l *Lvs) AddService(service Service) error {
+	changed := false
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		changed, err = i.AddServiceChanged(service)
+		return err
+	})
+	if changed {
+		l.publish(serviceEvent(EventServiceCreated, service, nil))
+	}
+	return err
+}
+
+func (l *Lvs) EditService(service Service) error {
+	return l.Do(func(i *Ipvs) error { return i.EditService(service) })
+}
+
+func (l *Lvs) RemoveService(netType, host string, port int) error {
+	changed := false
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		changed, err = i.RemoveServiceChanged(netType, host, port)
+		return err
+	})
+	if changed {
+		l.publish(serviceEvent(EventServiceRemoved, Service{Type: netType, Host: host, Port: port}, nil))
+	}
+	return err
+}
+
+func (l *Lvs) Clear() error {
+	return l.Do(func(i *Ipvs) error { return i.Clear() })
+}
+
+func (l *Lvs) ClearForce() error {
+	return l.Do(func(i *Ipvs) error { return i.ClearForce() })
+}
+
+func (l *Lvs) Restore(services []Service) error {
+	return l.Do(func(i *Ipvs) error { return i.Restore(services) })
+}
+
+func (l *Lvs) Save() error {
+	return l.Do(func(i *Ipvs) error { return i.Save() })
+}
+
+func (l *Lvs) Sync(services []Service) error {
+	var events []Event
+	err := l.Do(func(i *Ipvs) error {
+		defer func() { events = i.churn.takeEvents() }()
+		return i.Sync(services)
+	})
+	for _, event := range events {
+		l.publish(event)
+	}
+	if err == nil {
+		l.publish(Event{Type: EventSyncApplied})
+	}
+	return err
+}
+
+func (l *Lvs) SyncForce(services []Service) error {
+	var events []Event
+	err := l.Do(func(i *Ipvs) error {
+		defer func() { events = i.churn.takeEvents() }()
+		return i.SyncForce(services)
+	})
+	for _, event := range events {
+		l.publish(event)
+	}
+	if err == nil {
+		l.publish(Event{Type: EventSyncApplied})
+	}
+	return err
+}
+
+func (l *Lvs) ApplyConfig(path string) error {
+	return l.Do(func(i *Ipvs) error { return i.ApplyConfig(path) })
+}
+
+func (l *Lvs) Drain() error {
+	return l.Do(func(i *Ipvs) error { return i.Drain() })
+}
+
+func (l *Lvs) Zero() error {
+	return l.Do(func(i *Ipvs) error { return i.Zero() })
+}
+
+// OpCounts returns how many ipvsadm changes were run for the client's table,
+// and how many were skipped as they were already applied
+func (l *Lvs) OpCounts() OpCounts {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ipvs.OpCounts()
+}
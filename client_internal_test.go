@@ -0,0 +1,69 @@
+package lvs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClients(t *testing.T) {
+	defer useFakeBackend()()
+
+	blue, green := New(WithNetns("blue")), New(WithNetns("green"))
+	if err := blue.AddService(Service{Host: "10.0.0.1", Port: 80}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	if err := green.AddService(Service{Host: "10.0.0.2", Port: 80}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+
+	if services := blue.Services(); len(services) != 1 || services[0].Host != "10.0.0.1" {
+		t.Errorf("wrong services for blue - %+v", services)
+	}
+	if services := green.Services(); len(services) != 1 || services[0].Host != "10.0.0.2" {
+		t.Errorf("wrong services for green - %+v", services)
+	}
+	if len(fakeExecuted) != 2 || !strings.Contains(fakeExecuted[0], "/blue ") || !strings.Contains(fakeExecuted[1], "/green ") {
+		t.Errorf("commands not run in each client's namespace - %q", fakeExecuted)
+	}
+}
+
+// deadlineRunner blocks commands until their context is done, unless they
+// have no deadline or a long one
+type deadlineRunner struct {
+	*Simulator
+	deadlines []bool // whether each command had a deadline
+}
+
+func (r *deadlineRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	deadline, ok := ctx.Deadline()
+	r.deadlines = append(r.deadlines, ok)
+	if !ok || time.Until(deadline) > time.Minute {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTimeout(t *testing.T) {
+	runner := &deadlineRunner{Simulator: NewSimulator()}
+	client := New(WithRunner(runner), WithTimeout(10*time.Millisecond))
+	execute := func(i *Ipvs) error { return i.exec.execute("ipvsadm", "-C") }
+
+	if err := client.Do(execute); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if err := client.DoTimeout(-1, execute); err != nil {
+		t.Errorf("per call timeout should override the client's - %v", err)
+	}
+	if err := client.DoTimeout(time.Hour, execute); err != nil {
+		t.Errorf("per call timeout should override the client's - %v", err)
+	}
+	if err := client.Do(execute); err != ErrTimeout {
+		t.Errorf("expected the client's timeout after DoTimeout, got %v", err)
+	}
+	if len(runner.deadlines) != 4 || !runner.deadlines[0] || runner.deadlines[1] || !runner.deadlines[2] || !runner.deadlines[3] {
+		t.Errorf("expected only the negative timeout to run without a deadline - %v", runner.deadlines)
+	}
+}
@@ -0,0 +1,58 @@
+package lvs
+
+type (
+	// CloneOption overrides something of a cloned service, see Clone
+	CloneOption func(*Service)
+)
+
+// CloneHost gives the clone another vip (or fwmark)
+func CloneHost(host string) CloneOption {
+	return func(s *Service) {
+		s.Host = host
+	}
+}
+
+// ClonePort gives the clone another port
+func ClonePort(port int) CloneOption {
+	return func(s *Service) {
+		s.Port = port
+	}
+}
+
+// CloneName renames the clone, it is otherwise left without a name as names
+// label a single service
+func CloneName(name string) CloneOption {
+	return func(s *Service) {
+		s.Name = name
+	}
+}
+
+// CloneServerPort moves every server of the clone to port, eg. for a
+// parallel environment on the same servers
+func CloneServerPort(port int) CloneOption {
+	return func(s *Service) {
+		for j := range s.Servers {
+			s.Servers[j].Port = port
+		}
+	}
+}
+
+// Clone deep copies the service, servers included, and applies overrides,
+// eg. to stand up a parallel environment on another vip:
+// service.Clone(CloneHost("10.0.0.2"), CloneName("web-staging")). The clone
+// isn't applied, it has none of the times the Ipvs keeps and isn't
+// Protected
+func (s Service) Clone(overrides ...CloneOption) Service {
+	clone := copyServices([]Service{s})[0]
+	clone.Name, clone.Protected = "", false
+	clone.Requires = append([]string(nil), s.Requires...)
+	clone.LastApplied, clone.LastChecked, clone.LastStateChange = nil, nil, nil
+	for j := range clone.Servers {
+		clone.Servers[j].LastApplied, clone.Servers[j].LastChecked, clone.Servers[j].LastStateChange = nil, nil, nil
+	}
+	clone.exec = nil
+	for _, override := range overrides {
+		override(&clone)
+	}
+	return clone
+}
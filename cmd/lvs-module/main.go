@@ -0,0 +1,165 @@
+// lvs-module is an ansible module managing a single lvs service.
+//
+// Ansible passes the path of a json file holding the module arguments as the
+// only argument (when it is missing they are read from stdin):
+//
+//	{"state": "present", "host": "10.0.0.1", "port": 80, "type": "tcp",
+//	 "scheduler": "wlc", "servers": [{"host": "10.0.1.1", "port": 80}]}
+//
+// state is either present (default) or absent, and check mode is honored.
+// The result is written to stdout as json, or as yaml or a table for people
+// and other automation with --output:
+//
+//	lvs-module --output table args.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+type (
+	args struct {
+		lvs.Service
+		State     string `json:"state"`
+		CheckMode bool   `json:"_ansible_check_mode"`
+	}
+
+	result struct {
+		Changed bool         `json:"changed"`
+		Failed  bool         `json:"failed,omitempty"`
+		Msg     string       `json:"msg,omitempty"`
+		Service *lvs.Service `json:"service,omitempty"`
+	}
+)
+
+var (
+	output = flag.String("output", "json", "format of the result: json, yaml or table")
+)
+
+func main() {
+	flag.Parse()
+	if outputs[*output] == nil {
+		fmt.Fprintf(os.Stderr, "--output must be json, yaml or table, not '%s'\n", *output)
+		os.Exit(2)
+	}
+
+	var in []byte
+	var err error
+	if flag.NArg() > 0 {
+		in, err = os.ReadFile(flag.Arg(0))
+	} else {
+		in, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		exit(result{Failed: true, Msg: err.Error()})
+	}
+
+	a, err := parseArgs(in)
+	if err != nil {
+		exit(result{Failed: true, Msg: err.Error()})
+	}
+	exit(run(lvs.DefaultIpvs, a))
+}
+
+// parseArgs reads the module arguments, state defaulting to present and
+// the weight of servers to lvs.DefaultWeight
+func parseArgs(in []byte) (args, error) {
+	a := args{State: "present"}
+	if err := json.Unmarshal(in, &a); err != nil {
+		return a, fmt.Errorf("invalid module arguments: %v", err)
+	}
+	a.Service = a.Service.WithDefaultWeights()
+	return a, nil
+}
+
+// run applies a to ipvs, returning the result to report
+func run(ipvs *lvs.Ipvs, a args) result {
+	changed, err := apply(ipvs, a)
+	if err != nil {
+		return result{Changed: changed, Failed: true, Msg: err.Error()}
+	}
+	return result{Changed: changed, Service: &a.Service}
+}
+
+// apply brings the service to the requested state, only reporting what would
+// change in check mode
+func apply(ipvs *lvs.Ipvs, a args) (bool, error) {
+	if err := ipvs.Save(); err != nil {
+		return false, err
+	}
+	current := ipvs.FindService(a.Type, a.Host, a.Port)
+
+	switch a.State {
+	case "absent":
+		if current == nil || a.CheckMode {
+			return current != nil, nil
+		}
+		return ipvs.RemoveServiceChanged(a.Type, a.Host, a.Port)
+	case "present":
+		if err := a.Service.Validate(); err != nil {
+			return false, err
+		}
+		if current == nil {
+			if a.CheckMode {
+				return true, nil
+			}
+			return ipvs.AddServiceChanged(a.Service)
+		}
+		if current.Equal(a.Service) || a.CheckMode {
+			return !current.Equal(a.Service), nil
+		}
+		return update(ipvs, *current, a.Service)
+	}
+	return false, fmt.Errorf("state must be present or absent, not '%s'", a.State)
+}
+
+// update edits an existing service and its servers to match wanted
+func update(ipvs *lvs.Ipvs, current, wanted lvs.Service) (bool, error) {
+	edit := wanted
+	edit.Servers = current.Servers
+	changed, err := ipvs.EditServiceChanged(edit)
+	if err != nil {
+		return changed, err
+	}
+
+	service := ipvs.FindService(wanted.Type, wanted.Host, wanted.Port)
+	for i := range wanted.Servers {
+		var serverChanged bool
+		if service.FindServer(wanted.Servers[i].Host, wanted.Servers[i].Port) == nil {
+			serverChanged, err = service.AddServerChanged(wanted.Servers[i])
+		} else {
+			serverChanged, err = service.EditServerChanged(wanted.Servers[i])
+		}
+		changed = changed || serverChanged
+		if err != nil {
+			return changed, err
+		}
+	}
+	for i := range current.Servers {
+		if wanted.FindServer(current.Servers[i].Host, current.Servers[i].Port) != nil {
+			continue
+		}
+		serverChanged, err := service.RemoveServerChanged(current.Servers[i].Host, current.Servers[i].Port)
+		changed = changed || serverChanged
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+func exit(r result) {
+	if err := outputs[*output](os.Stdout, r); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if r.Failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
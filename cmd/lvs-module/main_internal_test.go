@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		state string
+		check bool
+		fails bool
+	}{
+		{name: "defaults to present", in: `{"host": "10.0.0.1", "port": 80}`, state: "present"},
+		{name: "absent", in: `{"state": "absent", "host": "10.0.0.1", "port": 80}`, state: "absent"},
+		{name: "check mode", in: `{"host": "10.0.0.1", "port": 80, "_ansible_check_mode": true}`, state: "present", check: true},
+		{name: "invalid json", in: `{"host": `, fails: true},
+		{name: "wrong type", in: `{"port": "80"}`, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := parseArgs([]byte(test.in))
+			if test.fails {
+				if err == nil {
+					t.Errorf("expected the arguments to be refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse arguments - %v", err)
+			}
+			if a.State != test.state || a.CheckMode != test.check || a.Host != "10.0.0.1" || a.Port != 80 {
+				t.Errorf("unexpected arguments %+v", a)
+			}
+		})
+	}
+}
+
+func TestParseArgsWeight(t *testing.T) {
+	a, err := parseArgs([]byte(`{"host": "10.0.0.1", "port": 80, "servers": [{"host": "10.0.1.1"}, {"host": "10.0.1.2", "weight": 0}]}`))
+	if err != nil {
+		t.Fatalf("failed to parse arguments - %v", err)
+	}
+	if a.Servers[0].Weight != lvs.DefaultWeight || a.Servers[1].Weight != 0 {
+		t.Errorf("expected an omitted weight to default and an explicit 0 to drain - %+v", a.Servers)
+	}
+}
+
+func TestRun(t *testing.T) {
+	service := func(weights ...int) lvs.Service {
+		s := lvs.Service{Host: "10.0.0.1", Port: 80, Type: "tcp", Scheduler: "wlc"}
+		for i, weight := range weights {
+			s.Servers = append(s.Servers, lvs.Server{Host: fmt.Sprintf("10.0.1.%d", i+1), Port: 80, Forwarder: "g", Weight: weight})
+		}
+		return s
+	}
+	sim := lvs.NewSimulator()
+	ipvs := lvs.NewIpvs(lvs.WithRunner(sim))
+
+	// each step runs against the table the previous ones left
+	tests := []struct {
+		name    string
+		args    args
+		changed bool
+		failed  bool
+		servers int // servers of the service in the table, -1 when absent
+		weight  int // weight of its first server
+	}{
+		{name: "add in check mode", args: args{Service: service(1), State: "present", CheckMode: true}, changed: true, servers: -1},
+		{name: "add", args: args{Service: service(1), State: "present"}, changed: true, servers: 1, weight: 1},
+		{name: "add again", args: args{Service: service(1), State: "present"}, servers: 1, weight: 1},
+		{name: "edit in check mode", args: args{Service: service(2, 1), State: "present", CheckMode: true}, changed: true, servers: 1, weight: 1},
+		{name: "edit weight and add a server", args: args{Service: service(2, 1), State: "present"}, changed: true, servers: 2, weight: 2},
+		{name: "remove a server", args: args{Service: service(2), State: "present"}, changed: true, servers: 1, weight: 2},
+		{name: "invalid service", args: args{Service: lvs.Service{Host: "10.0.0.1", Port: 80, Type: "sctp"}, State: "present"}, failed: true, servers: 1, weight: 2},
+		{name: "invalid state", args: args{Service: service(2), State: "gone"}, failed: true, servers: 1, weight: 2},
+		{name: "remove in check mode", args: args{Service: service(), State: "absent", CheckMode: true}, changed: true, servers: 1, weight: 2},
+		{name: "remove", args: args{Service: service(), State: "absent"}, changed: true, servers: -1},
+		{name: "remove again", args: args{Service: service(), State: "absent"}, servers: -1},
+	}
+	for _, test := range tests {
+		r := run(ipvs, test.args)
+		if r.Changed != test.changed || r.Failed != test.failed {
+			t.Errorf("%s: expected changed %t and failed %t, got %+v", test.name, test.changed, test.failed, r)
+		}
+		if r.Failed && r.Msg == "" {
+			t.Errorf("%s: expected a failure message", test.name)
+		}
+		services := sim.Services()
+		switch {
+		case test.servers < 0 && len(services) != 0:
+			t.Errorf("%s: expected no service, got %v", test.name, services)
+		case test.servers >= 0 && (len(services) != 1 || len(services[0].Servers) != test.servers || services[0].Servers[0].Weight != test.weight):
+			t.Errorf("%s: expected a service with %d servers, the first weighing %d, got %v", test.name, test.servers, test.weight, services)
+		}
+	}
+}
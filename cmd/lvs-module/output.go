@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+var (
+	// outputs write a result in the format named by --output
+	outputs = map[string]func(io.Writer, result) error{
+		"json":  writeJSON,
+		"yaml":  writeYAML,
+		"table": writeTable,
+	}
+)
+
+func writeJSON(w io.Writer, r result) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// writeYAML writes r as yaml, with lvs's yaml codec so both have the same
+// fields. Keys are sorted and strings double quoted
+func writeYAML(w io.Writer, r result) error {
+	codec, err := lvs.LookupCodec("yaml")
+	if err != nil {
+		return err
+	}
+	data, err := codec.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// writeTable writes r as aligned columns, the service and its servers
+// laid out like `ipvsadm -L -n`
+func writeTable(w io.Writer, r result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHANGED\tFAILED\tMSG")
+	fmt.Fprintf(tw, "%t\t%t\t%s\n", r.Changed, r.Failed, r.Msg)
+	if r.Service != nil {
+		s := *r.Service
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(tw, "TYPE\tADDRESS\tSCHEDULER\tPERSISTENCE\tNAME")
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", serviceType(s), hostPort(s.Host, s.Port), lvs.ServiceSchedulerFlag[s.Scheduler], s.Persistence, s.Name)
+		if len(s.Servers) > 0 {
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			fmt.Fprintln(tw, "  -> SERVER\tFORWARDER\tWEIGHT\tUPPER\tLOWER")
+			for _, server := range s.Servers {
+				fmt.Fprintf(tw, "  -> %s\t%s\t%d\t%d\t%d\n", hostPort(server.Host, server.Port), lvs.ServerForwarderFlag[server.Forwarder], server.Weight, server.UpperThreshold, server.LowerThreshold)
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func serviceType(s lvs.Service) string {
+	if s.Type == "" {
+		return "tcp"
+	}
+	return s.Type
+}
+
+func hostPort(host string, port int) string {
+	if port == 0 {
+		return host
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return host + ":" + strconv.Itoa(port)
+}
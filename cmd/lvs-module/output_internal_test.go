@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+func TestWriteYAML(t *testing.T) {
+	service := lvs.Service{Host: "10.0.0.1", Port: 80, Type: "tcp", Scheduler: "wlc", Name: "web: #1\nsecond line",
+		Servers: []lvs.Server{{Host: "10.0.1.1", Port: 80, Forwarder: "g", Weight: 1}, {Host: "10.0.1.2", Port: 80, Forwarder: "g", Weight: 2}}}
+	out := &strings.Builder{}
+	if err := writeYAML(out, result{Changed: true, Msg: "key: value # not a comment", Service: &service}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `changed: true
+msg: "key: value # not a comment"
+service:
+  host: "10.0.0.1"
+  name: "web: #1\nsecond line"
+  netmask: ""
+  persistence: 0
+  port: 80
+  scheduler: "wlc"
+  servers:
+    - forwarder: "g"
+      host: "10.0.1.1"
+      lower_threshold: 0
+      port: 80
+      upper_threshold: 0
+      weight: 1
+    - forwarder: "g"
+      host: "10.0.1.2"
+      lower_threshold: 0
+      port: 80
+      upper_threshold: 0
+      weight: 2
+  type: "tcp"
+`
+	if out.String() != expected {
+		t.Errorf("unexpected yaml\n%s\nexpected\n%s", out, expected)
+	}
+
+	out.Reset()
+	if err := writeYAML(out, result{Failed: true, Msg: "failed:\n\t- \"quoted\""}); err != nil {
+		t.Fatal(err)
+	}
+	if expected = "changed: false\nfailed: true\nmsg: \"failed:\\n\\t- \\\"quoted\\\"\"\n"; out.String() != expected {
+		t.Errorf("unexpected yaml\n%s\nexpected\n%s", out, expected)
+	}
+}
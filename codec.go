@@ -0,0 +1,114 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Codec serializes configs (see LoadConfig and SaveConfig) and the
+	// ToJson/FromJson pairs. Register one with RegisterCodec to support
+	// another format, such as HCL, CUE or protobuf
+	Codec interface {
+		Marshal(v interface{}) ([]byte, error)
+		Unmarshal(data []byte, v interface{}) error
+	}
+
+	jsonCodec struct{}
+	gobCodec  struct{}
+)
+
+var (
+	UnknownCodec = errors.New("Unknown Codec")
+
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"json": jsonCodec{},
+		"gob":  gobCodec{},
+		"yaml": yamlCodec{},
+	}
+)
+
+// RegisterCodec makes c available as name, which is also the extension of
+// the config files it reads and writes (eg. "yaml" for config.yaml).
+// Registering an existing name replaces its codec
+func RegisterCodec(name string, c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[strings.ToLower(name)] = c
+}
+
+// LookupCodec returns the codec registered as name
+func LookupCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[strings.ToLower(name)]
+	if !ok {
+		return nil, UnknownCodec
+	}
+	return c, nil
+}
+
+// Codecs returns the names of the registered codecs
+func Codecs() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// codecFor returns the codec for path's extension, json when no codec is
+// registered for it
+func codecFor(path string) Codec {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "yml" {
+		ext = "yaml"
+	}
+	if c, err := LookupCodec(ext); err == nil {
+		return c
+	}
+	return jsonCodec{}
+}
+
+// marshal and unmarshal use the codec registered as name
+func marshal(name string, v interface{}) ([]byte, error) {
+	c, err := LookupCodec(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal(v)
+}
+
+func unmarshal(name string, data []byte, v interface{}) error {
+	c, err := LookupCodec(name)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(data, v)
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	return toGob(v)
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,53 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// prefixCodec is json behind a marker, to tell it was used
+type prefixCodec struct{}
+
+func (prefixCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	return append([]byte("prefixed:"), data...), err
+}
+
+func (prefixCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(bytes.TrimPrefix(data, []byte("prefixed:")), v)
+}
+
+func TestCodecs(t *testing.T) {
+	RegisterCodec("prefixed", prefixCodec{})
+	if _, err := LookupCodec("hcl"); err != UnknownCodec {
+		t.Errorf("expected an unknown codec, got %v", err)
+	}
+
+	config := Ipvs{Tcp: 900, Services: []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}}
+	dir := t.TempDir()
+	for _, name := range []string{"config.prefixed", "config.gob", "config.json", "config.yaml", "config.yml", "config.conf"} {
+		path := filepath.Join(dir, name)
+		if err := config.SaveConfig(path); err != nil {
+			t.Fatalf("failed to save %s - %v", name, err)
+		}
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("failed to load %s - %v", name, err)
+		}
+		if loaded.Tcp != 900 || len(loaded.Services) != 1 || !loaded.Services[0].Equal(config.Services[0]) {
+			t.Errorf("unexpected config from %s - %+v", name, loaded)
+		}
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "config.prefixed"))
+	if !bytes.HasPrefix(data, []byte("prefixed:")) {
+		t.Errorf("registered codec wasn't used - %s", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "config.conf"))
+	if !json.Valid(data) {
+		t.Errorf("unknown extensions aren't json - %s", data)
+	}
+}
@@ -0,0 +1,152 @@
+package lvs
+
+import (
+	"os"
+	"time"
+)
+
+type (
+	// Watcher reapplies a config file to an Ipvs whenever it changes
+	Watcher struct {
+		Path     string        // path to the Ipvs config, see LoadConfig
+		Interval time.Duration // how often the file is checked without inotify, defaults to 1s
+		Debounce time.Duration // how long the file must be left alone before it is applied
+		Ipvs     *Ipvs
+		OnError  func(error) // called when the config fails to apply
+	}
+
+	fileState struct {
+		modified time.Time
+		size     int64
+	}
+)
+
+// LoadConfig reads and validates an Ipvs config, decoded by the codec
+// registered for its extension (see RegisterCodec) or as json
+func LoadConfig(path string) (*Ipvs, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Ipvs{}
+	if err = codecFor(path).Unmarshal(bytes, config); err != nil {
+		return nil, err
+	}
+	for j := range config.Services {
+		config.Services[j] = config.Services[j].WithDefaultWeights()
+		if err = config.Services[j].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// SaveConfig writes the Ipvs config to path atomically, encoded like
+// LoadConfig decodes it
+func (i Ipvs) SaveConfig(path string) error {
+	bytes, err := codecFor(path).Marshal(i)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, bytes, 0644)
+}
+
+// ApplyConfig loads the config at path and syncs it to the host, an invalid
+// config leaves the applied rules untouched
+func (i *Ipvs) ApplyConfig(path string) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	return i.applyConfig(config)
+}
+
+// applyConfig syncs config's settings, services and resources to the host
+func (i *Ipvs) applyConfig(config *Ipvs) error {
+	var err error
+	previous := i.Resources
+	timeouts := config.Tcp != i.Tcp || config.Tcpfin != i.Tcpfin || config.Udp != i.Udp
+	i.MulticastInterface = config.MulticastInterface
+	i.Syncid = config.Syncid
+	i.SyncVersion = config.SyncVersion
+	i.Tcp, i.Tcpfin, i.Udp = config.Tcp, config.Tcpfin, config.Udp
+	i.Resources = config.Resources
+	if timeouts {
+		if err = i.SetTimeouts(); err != nil {
+			return err
+		}
+	}
+	if err = i.Sync(config.Services); err != nil {
+		return err
+	}
+	return i.removeResources(previous, config.Resources)
+}
+
+// Run applies the config file, then reapplies it until stop is closed
+// whenever it has changed and then settled for the Debounce duration.
+// Changes are watched with inotify on linux, falling back to checking the
+// file every Interval elsewhere
+func (w *Watcher) Run(stop <-chan struct{}) {
+	w.apply()
+	changes, err := watchFile(w.Path, stop)
+	if err != nil {
+		changes = w.poll(stop)
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case <-changes:
+			settled = time.After(w.Debounce)
+		case <-settled:
+			settled = nil
+			w.apply()
+		}
+	}
+}
+
+func (w *Watcher) apply() {
+	if err := w.Ipvs.ApplyConfig(w.Path); err != nil && w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+// poll sends on the returned channel whenever the config file's size or
+// modification time changes, checking it every Interval until stop is
+// closed
+func (w *Watcher) poll(stop <-chan struct{}) <-chan struct{} {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	changes, last := make(chan struct{}, 1), statFile(w.Path)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if state := statFile(w.Path); state != last {
+				last = state
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modified: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(config string) {
+		t.Helper()
+		if err := writeAtomic(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("services:\n  - host: 10.0.0.1\n    port: 80\n")
+
+	simulator := NewSimulator()
+	var mu sync.Mutex
+	errs := make([]error, 0, 0)
+	w := &Watcher{Path: path, Debounce: 10 * time.Millisecond, Ipvs: NewIpvs(WithRunner(simulator)), OnError: func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.Run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	waitFor := func(what string, ok func([]Service) bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if ok(simulator.Services()) {
+				return
+			}
+		}
+		t.Fatalf("%s - %v", what, simulator.Services())
+	}
+	waitFor("expected the config to be applied on start", func(services []Service) bool {
+		return len(services) == 1 && services[0].Port == 80
+	})
+
+	// replaced like editors and config management do
+	write("services:\n  - host: 10.0.0.1\n    port: 443\n")
+	waitFor("expected the changed config to be applied", func(services []Service) bool {
+		return len(services) == 1 && services[0].Port == 443
+	})
+
+	write("services:\n  - host: 10.0.0.1\n    port: 443\n    scheduler: fastest\n")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		mu.Lock()
+		failed := len(errs) > 0
+		mu.Unlock()
+		if failed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the invalid config to be reported")
+		}
+	}
+	if services := simulator.Services(); len(services) != 1 || services[0].Port != 443 {
+		t.Errorf("invalid config shouldn't be applied - %v", services)
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	changes := (&Watcher{Path: path, Interval: time.Millisecond}).poll(stop)
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the change to be polled")
+	}
+}
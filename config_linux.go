@@ -0,0 +1,55 @@
+package lvs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watchFile sends on the returned channel whenever path is written,
+// created, replaced or removed, watching its directory with inotify so
+// editors and tools replacing the file are seen too. It stops once stop is
+// closed
+func watchFile(path string, stop <-chan struct{}) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err = syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// non blocking, so closing it interrupts the read below
+	file := os.NewFile(uintptr(fd), "inotify")
+	name := []byte(filepath.Base(path))
+	changes := make(chan struct{}, 1)
+	go func() {
+		<-stop
+		file.Close()
+	}()
+	go func() {
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + syscall.SizeofInotifyEvent
+				offset = start + int(event.Len)
+				if bytes.Equal(bytes.TrimRight(buf[start:offset], "\x00"), name) {
+					select {
+					case changes <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
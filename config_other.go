@@ -0,0 +1,12 @@
+//go:build !linux
+
+package lvs
+
+import (
+	"errors"
+)
+
+// watchFile is only supported on linux, Watcher polls on other platforms
+func watchFile(path string, stop <-chan struct{}) (<-chan struct{}, error) {
+	return nil, errors.New("watching files is only supported on linux")
+}
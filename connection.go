@@ -0,0 +1,149 @@
+package lvs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Connection is an entry in the ipvs connection table
+	Connection struct {
+		Type            string        `json:"type"`
+		Expires         time.Duration `json:"expires"`
+		State           string        `json:"state"`
+		SourceHost      string        `json:"source_host"`
+		SourcePort      int           `json:"source_port"`
+		VirtualHost     string        `json:"virtual_host"`
+		VirtualPort     int           `json:"virtual_port"`
+		DestinationHost string        `json:"destination_host"`
+		DestinationPort int           `json:"destination_port"`
+
+		// only set when listed with ListPersistentConnections
+		PersistenceEngine string `json:"persistence_engine,omitempty"`
+		PersistenceData   string `json:"persistence_data,omitempty"`
+	}
+)
+
+// Connections reads the connection table from the host
+func (i Ipvs) Connections() ([]Connection, error) {
+	out, err := i.exec.run([]string{"ipvsadm", "-L", "-c", "-n"})
+	if err != nil {
+		return nil, err
+	}
+	return parseConnections(string(out)), nil
+}
+
+// ListPersistentConnections reads the connection table along with the
+// persistence engine data of each entry (`ipvsadm -L -c -n --persistent-conn`),
+// so sticky mappings can be audited
+func (i Ipvs) ListPersistentConnections() ([]Connection, error) {
+	out, err := i.exec.run([]string{"ipvsadm", "-L", "-c", "-n", "--persistent-conn"})
+	if err != nil {
+		return nil, err
+	}
+	return parseConnections(string(out)), nil
+}
+
+// ConnectionsBySource returns the connections made by the client ip
+func (i Ipvs) ConnectionsBySource(ip string) ([]Connection, error) {
+	return i.filterConnections(func(c Connection) bool {
+		return c.SourceHost == ip
+	})
+}
+
+// ConnectionsToServer returns the connections scheduled to server
+func (i Ipvs) ConnectionsToServer(server Server) ([]Connection, error) {
+	return i.filterConnections(func(c Connection) bool {
+		return c.DestinationHost == server.Host && c.DestinationPort == server.Port
+	})
+}
+
+func (i Ipvs) filterConnections(match func(Connection) bool) ([]Connection, error) {
+	connections, err := i.Connections()
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]Connection, 0, 0)
+	for j := range connections {
+		if match(connections[j]) {
+			matched = append(matched, connections[j])
+		}
+	}
+	return matched, nil
+}
+
+// parseConnections parses the output of `ipvsadm -L -c -n`, with or without
+// --persistent-conn
+func parseConnections(out string) []Connection {
+	connections := make([]Connection, 0, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		netType, ok := statsServiceType[fields[0]]
+		if !ok {
+			continue
+		}
+		connection := Connection{
+			Type:    netType,
+			Expires: parseExpires(fields[1]),
+			State:   fields[2],
+		}
+		connection.SourceHost, connection.SourcePort = parseHostPort(fields[3])
+		connection.VirtualHost, connection.VirtualPort = parseHostPort(fields[4])
+		connection.DestinationHost, connection.DestinationPort = parseHostPort(fields[5])
+		if len(fields) > 6 {
+			connection.PersistenceEngine = fields[6]
+		}
+		if len(fields) > 7 {
+			connection.PersistenceData = fields[7]
+		}
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+// parseExpires parses the mm:ss expiry ipvsadm prints
+func parseExpires(expires string) time.Duration {
+	var total time.Duration
+	for _, part := range strings.Split(expires, ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + time.Duration(value)
+	}
+	return total * time.Second
+}
+
+// IsTemplate reports whether the entry is a persistence template, which
+// ipvs uses to stick a client to a server rather than to track a connection
+func (c Connection) IsTemplate() bool {
+	return c.State == "NONE"
+}
+
+// PersistenceTemplates returns the persistence templates in the connection
+// table
+func (i Ipvs) PersistenceTemplates() ([]Connection, error) {
+	return i.filterConnections(Connection.IsTemplate)
+}
+
+// ExpireTemplates moves clients stuck to a server elsewhere. ipvsadm can't
+// delete connection entries, so the server is quiesced (weight 0) with
+// expire_quiescent_template enabled, which expires every template pointing at
+// it the next time it is used
+func (s *Service) ExpireTemplates(host string, port int) error {
+	server := s.FindServer(host, port)
+	if server == nil {
+		return NotFound
+	}
+	err := s.exec.execute("sysctl", "-w", "net.ipv4.vs.expire_quiescent_template=1")
+	if err != nil {
+		return err
+	}
+	quiesced := *server
+	quiesced.Weight = 0
+	return s.EditServer(quiesced)
+}
@@ -0,0 +1,82 @@
+package lvs
+
+import (
+	"testing"
+	"time"
+)
+
+const connectionOutput = `IPVS connection entries
+pro expire state       source             virtual            destination
+TCP 14:56  ESTABLISHED 10.0.2.5:51234     10.0.0.1:80        10.0.1.1:80
+TCP 01:30  FIN_WAIT    10.0.2.6:40000     10.0.0.1:80        10.0.1.2:80
+TCP 05:43  NONE        10.0.2.5:0         10.0.0.1:80        10.0.1.1:80
+`
+
+func TestConnections(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte(connectionOutput)
+
+	connections, err := DefaultIpvs.Connections()
+	if err != nil {
+		t.Fatalf("failed to read connections - %v", err)
+	}
+	if len(connections) != 3 {
+		t.Fatalf("expected 3 connections, got %d", len(connections))
+	}
+	if connections[0].Expires != 14*time.Minute+56*time.Second || connections[0].State != "ESTABLISHED" || connections[0].SourcePort != 51234 {
+		t.Errorf("connection parsed wrong - %+v", connections[0])
+	}
+
+	bySource, _ := DefaultIpvs.ConnectionsBySource("10.0.2.5")
+	if len(bySource) != 2 {
+		t.Errorf("expected 2 connections from 10.0.2.5, got %d", len(bySource))
+	}
+	toServer, _ := DefaultIpvs.ConnectionsToServer(Server{Host: "10.0.1.2", Port: 80})
+	if len(toServer) != 1 || toServer[0].SourceHost != "10.0.2.6" {
+		t.Errorf("wrong connections to 10.0.1.2 - %+v", toServer)
+	}
+}
+
+func TestExpireTemplates(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte(connectionOutput)
+
+	templates, _ := DefaultIpvs.PersistenceTemplates()
+	if len(templates) != 1 || templates[0].DestinationHost != "10.0.1.1" {
+		t.Errorf("wrong persistence templates - %+v", templates)
+	}
+
+	service := Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 5}}}
+	if err := service.ExpireTemplates("10.0.1.9", 80); err != NotFound {
+		t.Errorf("expected NotFound for a missing server, got %v", err)
+	}
+	if err := service.ExpireTemplates("10.0.1.1", 80); err != nil {
+		t.Fatalf("failed to expire templates - %v", err)
+	}
+	if service.Servers[0].Weight != 0 || len(fakeExecuted) != 2 {
+		t.Errorf("server should be quiesced - %+v, %q", service.Servers[0], fakeExecuted)
+	}
+}
+
+func TestListPersistentConnections(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte(`IPVS connection entries
+pro expire state       source             virtual            destination        pe name pe_data
+UDP 04:58  NONE        10.0.2.5:0         10.0.0.1:5060      10.0.1.1:5060      sip     call-1234
+UDP 04:58  UDP         10.0.2.5:5060      10.0.0.1:5060      10.0.1.1:5060
+`)
+
+	connections, err := DefaultIpvs.ListPersistentConnections()
+	if err != nil {
+		t.Fatalf("failed to list persistent connections - %v", err)
+	}
+	if len(connections) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(connections))
+	}
+	if connections[0].PersistenceEngine != "sip" || connections[0].PersistenceData != "call-1234" {
+		t.Errorf("persistence engine data not parsed - %+v", connections[0])
+	}
+	if connections[1].PersistenceEngine != "" {
+		t.Errorf("unexpected persistence engine - %+v", connections[1])
+	}
+}
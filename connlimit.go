@@ -0,0 +1,110 @@
+package lvs
+
+import (
+	"sort"
+)
+
+// WithConnLimit returns s with max connections shared between its servers
+// in rotation as their UpperThreshold (-x), in proportion to their weights.
+// Thresholds add up to max, except that every server in rotation gets at
+// least 1 as 0 means unlimited. Servers out of rotation (weight 0) keep
+// their threshold
+func (s Service) WithConnLimit(max int) Service {
+	limits := connLimits(max, s.Servers)
+	servers := make([]Server, len(s.Servers))
+	for j := range s.Servers {
+		servers[j] = s.Servers[j]
+		if limits[j] > 0 {
+			servers[j].UpperThreshold = limits[j]
+		}
+	}
+	s.Servers = servers
+	return s
+}
+
+// connLimits splits max between servers by weight with the largest
+// remainder method, 0 for the servers out of rotation
+func connLimits(max int, servers []Server) []int {
+	limits := make([]int, len(servers))
+	total := 0
+	for _, server := range servers {
+		if server.Weight > 0 {
+			total += server.Weight
+		}
+	}
+	if max <= 0 || total == 0 {
+		return limits
+	}
+
+	remainders := make([]int, 0, len(servers))
+	left := max
+	for j, server := range servers {
+		if server.Weight <= 0 {
+			continue
+		}
+		share := max * server.Weight
+		limits[j] = share / total
+		left -= limits[j]
+		remainders = append(remainders, j)
+	}
+	sort.SliceStable(remainders, func(a, b int) bool {
+		return max*servers[remainders[a]].Weight%total > max*servers[remainders[b]].Weight%total
+	})
+	for _, j := range remainders {
+		if left <= 0 {
+			break
+		}
+		limits[j]++
+		left--
+	}
+	for _, j := range remainders {
+		if limits[j] < 1 {
+			limits[j] = 1
+		}
+	}
+	return limits
+}
+
+// limitConns sets the threshold server gets from the service's MaxConns,
+// sharing it with the other servers
+func (s Service) limitConns(server Server) Server {
+	if s.MaxConns <= 0 {
+		return server
+	}
+	servers := make([]Server, 0, len(s.Servers)+1)
+	for _, other := range s.Servers {
+		if !other.is(server.Host, server.Port) {
+			servers = append(servers, other)
+		}
+	}
+	servers = append(servers, server)
+	if limit := connLimits(s.MaxConns, servers)[len(servers)-1]; limit > 0 {
+		server.UpperThreshold = limit
+	}
+	return server
+}
+
+// applyConnLimit updates the thresholds of the servers to share the
+// service's MaxConns, after its servers or their weights changed
+func (s *Service) applyConnLimit() error {
+	if s.MaxConns <= 0 {
+		return nil
+	}
+	limited := s.WithConnLimit(s.MaxConns)
+	applied, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	for j := range s.Servers {
+		if s.Servers[j].UpperThreshold == limited.Servers[j].UpperThreshold {
+			continue
+		}
+		err = s.exec.execute("ipvsadm", append([]string{"-e", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-r"}, limited.Servers[j].Args()...)...)
+		if err != nil {
+			return err
+		}
+		s.Servers[j].UpperThreshold = limited.Servers[j].UpperThreshold
+		s.Servers[j].LastApplied = stampNow()
+	}
+	return nil
+}
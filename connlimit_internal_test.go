@@ -0,0 +1,90 @@
+package lvs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func thresholds(servers []Server) []int {
+	limits := make([]int, len(servers))
+	for j := range servers {
+		limits[j] = servers[j].UpperThreshold
+	}
+	return limits
+}
+
+func TestWithConnLimit(t *testing.T) {
+	tests := []struct {
+		max      int
+		weights  []int
+		expected []int
+	}{
+		{1000, []int{1, 1, 2}, []int{250, 250, 500}},
+		{100, []int{1, 1, 1}, []int{34, 33, 33}},
+		{100, []int{1, 0, 3}, []int{25, 7, 75}}, // out of rotation keeps its threshold
+		{2, []int{1, 1, 1}, []int{1, 1, 1}},
+	}
+	for _, test := range tests {
+		service := Service{}
+		for _, weight := range test.weights {
+			service.Servers = append(service.Servers, Server{Weight: weight, UpperThreshold: 7})
+		}
+		if limits := thresholds(service.WithConnLimit(test.max).Servers); !reflect.DeepEqual(limits, test.expected) {
+			t.Errorf("%d over %v gave %v, expected %v", test.max, test.weights, limits, test.expected)
+		}
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+	err := ipvs.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, MaxConns: 900, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 2},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits := thresholds(simulator.Services()[0].Servers); !reflect.DeepEqual(limits, []int{300, 600}) {
+		t.Errorf("unexpected thresholds %v", limits)
+	}
+
+	service := &ipvs.Services[0]
+	if err := service.AddServer(Server{Host: "10.0.1.3", Port: 80, Weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if limits := thresholds(simulator.Services()[0].Servers); !reflect.DeepEqual(limits, []int{225, 450, 225}) {
+		t.Errorf("thresholds not redistributed on add - %v", limits)
+	}
+	if err := service.EditServer(Server{Host: "10.0.1.2", Port: 80, Weight: 0, UpperThreshold: 450}); err != nil {
+		t.Fatal(err)
+	}
+	if limits := thresholds(simulator.Services()[0].Servers); !reflect.DeepEqual(limits, []int{450, 450, 450}) {
+		t.Errorf("thresholds not redistributed on drain - %v", limits)
+	}
+	if err := service.RemoveServer("10.0.1.3", 80); err != nil {
+		t.Fatal(err)
+	}
+	if limits := thresholds(simulator.Services()[0].Servers); !reflect.DeepEqual(limits, []int{900, 450}) || !reflect.DeepEqual(thresholds(service.Servers), limits) {
+		t.Errorf("thresholds not redistributed on remove - %v", limits)
+	}
+}
+
+func TestConnLimitConverged(t *testing.T) {
+	ipvs := NewIpvs(WithRunner(NewSimulator()))
+	services := []Service{{Host: "10.0.0.1", Port: 80, Scheduler: "wrr", MaxConns: 900, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 2},
+	}}}
+	if err := ipvs.Sync(services); err != nil {
+		t.Fatal(err)
+	}
+	if converged, err := ipvs.Converged(services); err != nil || !converged {
+		t.Errorf("expected the synced service to have converged - %v", err)
+	}
+
+	services[0].MaxConns = 600
+	if drift, err := ipvs.Drift(services); err != nil || !reflect.DeepEqual(drift, []string{"changed tcp 10.0.0.1:80"}) {
+		t.Errorf("unexpected drift %q - %v", drift, err)
+	}
+}
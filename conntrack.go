@@ -0,0 +1,70 @@
+package lvs
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// WithConntrackFlush deletes the conntrack entries of masqueraded (nat)
+// servers when they are removed, along with their service or not (by
+// RemoveServer, RemoveService, Sync or Clear), so existing flows fail fast
+// instead of black holing to a dead backend. Needs the conntrack command
+// and only matters with net.ipv4.vs.conntrack enabled
+func WithConntrackFlush() Option {
+	return func(i *Ipvs) {
+		i.exec.flushConntrack = true
+	}
+}
+
+func (e *executor) flushesConntrack() bool {
+	return e != nil && e.flushConntrack
+}
+
+// flushConntracks deletes the conntrack entries of flows from the
+// (resolved) service to its masqueraded servers, if the Ipvs flushes them
+func (s Service) flushConntracks() error {
+	if !s.exec.flushesConntrack() {
+		return nil
+	}
+	for _, server := range s.Servers {
+		if server.Forwarder != "m" {
+			continue
+		}
+		if err := s.flushConntrack(server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushConntrack deletes the conntrack entries of flows from the (resolved)
+// service to server
+func (s Service) flushConntrack(server Server) error {
+	args := []string{"-D"}
+	if ip := net.ParseIP(server.Host); ip != nil && ip.To4() == nil {
+		// conntrack only looks at ipv4 entries otherwise
+		args = append(args, "-f", "ipv6")
+	}
+	if s.Type != "fwmark" {
+		protocol := s.Type
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		args = append(args, "-p", protocol, "--orig-dst", s.Host)
+		if s.Port != 0 {
+			args = append(args, "--orig-port-dst", strconv.Itoa(s.Port))
+		}
+	}
+	args = append(args, "--reply-src", server.Host)
+	if server.Port != 0 {
+		args = append(args, "--reply-port-src", strconv.Itoa(server.Port))
+	}
+
+	err := s.exec.execute("conntrack", args...)
+	// conntrack fails when there was nothing to delete
+	if err != nil && strings.Contains(err.Error(), "0 flow entries") {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package lvs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConntrackFlush(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs(WithConntrackFlush())
+	err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 8080, Forwarder: "m"},
+		{Host: "10.0.1.2", Port: 80, Forwarder: "g"},
+	}})
+	if err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	fakeExecuted = nil
+
+	service := ipvs.FindService("tcp", "10.0.0.1", 80)
+	service.RemoveServer("10.0.1.1", 8080)
+	service.RemoveServer("10.0.1.2", 80)
+
+	expected := []string{
+		"ipvsadm -d -t 10.0.0.1:80 -r 10.0.1.1:8080",
+		"conntrack -D -p tcp --orig-dst 10.0.0.1 --orig-port-dst 80 --reply-src 10.0.1.1 --reply-port-src 8080",
+		"ipvsadm -d -t 10.0.0.1:80 -r 10.0.1.2:80",
+	}
+	if len(fakeExecuted) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, fakeExecuted)
+	}
+	for i := range expected {
+		if fakeExecuted[i] != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], fakeExecuted[i])
+		}
+	}
+}
+
+func TestConntrackFlushRemovals(t *testing.T) {
+	defer useFakeBackend()()
+
+	services := []Service{
+		{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 8080, Forwarder: "m"}, {Host: "10.0.1.2", Port: 80, Forwarder: "g"}}},
+		{Host: "2001:db8::1", Port: 443, Servers: []Server{{Host: "2001:db8::2", Port: 8443, Forwarder: "m"}}},
+		{Type: "udp", Host: "10.0.0.1", Port: 53, Servers: []Server{{Host: "10.0.1.3", Port: 5353, Forwarder: "m"}}},
+	}
+	ipv4 := "conntrack -D -p tcp --orig-dst 10.0.0.1 --orig-port-dst 80 --reply-src 10.0.1.1 --reply-port-src 8080"
+	ipv6 := "conntrack -D -f ipv6 -p tcp --orig-dst 2001:db8::1 --orig-port-dst 443 --reply-src 2001:db8::2 --reply-port-src 8443"
+	udp := "conntrack -D -p udp --orig-dst 10.0.0.1 --orig-port-dst 53 --reply-src 10.0.1.3 --reply-port-src 5353"
+	flushed := func() []string {
+		commands := make([]string, 0, 0)
+		for _, command := range fakeExecuted {
+			if strings.HasPrefix(command, "conntrack") {
+				commands = append(commands, command)
+			}
+		}
+		fakeExecuted = nil
+		return commands
+	}
+	add := func(ipvs *Ipvs) {
+		t.Helper()
+		for _, service := range services {
+			if err := ipvs.AddService(service); err != nil {
+				t.Fatalf("failed to add service - %v", err)
+			}
+		}
+		fakeExecuted = nil
+	}
+
+	ipvs := NewIpvs(WithConntrackFlush())
+	add(ipvs)
+	if err := ipvs.RemoveService("tcp", "10.0.0.1", 80); err != nil {
+		t.Fatalf("failed to remove service - %v", err)
+	}
+	if err := ipvs.RemoveService("tcp", "2001:db8::1", 443); err != nil {
+		t.Fatalf("failed to remove service - %v", err)
+	}
+	if commands := flushed(); !reflect.DeepEqual(commands, []string{ipv4, ipv6}) {
+		t.Errorf("expected RemoveService to flush its masqueraded servers - %q", commands)
+	}
+
+	// Sync and Clear remove what the table has, whatever ipvs knew
+	fakeRunOutput = []byte(FormatServices(services, FormatSave))
+	if err := ipvs.Sync(services[1:2]); err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+	if commands := flushed(); !reflect.DeepEqual(commands, []string{ipv4, udp}) {
+		t.Errorf("expected Sync to flush the servers of the services it removes - %q", commands)
+	}
+
+	if err := ipvs.Clear(); err != nil {
+		t.Fatalf("failed to clear - %v", err)
+	}
+	if commands := flushed(); !reflect.DeepEqual(commands, []string{ipv4, ipv6, udp}) {
+		t.Errorf("expected Clear to flush every masqueraded server - %q", commands)
+	}
+
+	// without the option nothing is flushed
+	ipvs = NewIpvs()
+	add(ipvs)
+	ipvs.RemoveService("tcp", "10.0.0.1", 80)
+	if commands := flushed(); len(commands) != 0 {
+		t.Errorf("expected nothing to be flushed - %q", commands)
+	}
+}
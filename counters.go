@@ -0,0 +1,43 @@
+package lvs
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type (
+	// savedCounters are the last seen counters of a MetricsExporter or a
+	// Snapshotter, saved to their CountersPath
+	savedCounters struct {
+		Time     time.Time        `json:"time"`
+		Counters map[string]Stats `json:"counters"`
+	}
+)
+
+// loadCounters reads the counters saved at path, nil when there are none
+// yet. Kernel counters that were zeroed since (eg. by a reboot) are then
+// seen going backwards, as for counters zeroed while running
+func loadCounters(path string) (map[string]Stats, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	saved := savedCounters{}
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return nil, time.Time{}, err
+	}
+	return saved.Counters, saved.Time, nil
+}
+
+// saveCounters writes counters seen at t to path
+func saveCounters(path string, counters map[string]Stats, t time.Time) error {
+	data, err := json.Marshal(savedCounters{Time: t, Counters: counters})
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, data, 0644)
+}
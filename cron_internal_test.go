@@ -0,0 +1,108 @@
+package lvs
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, time.February, 28, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.February, 28, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.February, 28, 10, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, time.February, 28, 10, 25, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2024, time.February, 28, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, time.February, 29, 2, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)}, // either day field
+		{"@hourly", time.Date(2024, time.February, 28, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		cron, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("failed to parse %q - %v", test.expr, err)
+			continue
+		}
+		if next := cron.Next(from); !next.Equal(test.next) {
+			t.Errorf("expected %q to be next at %v, got %v", test.expr, test.next, next)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCron(expr); err != InvalidCronExpression {
+			t.Errorf("expected InvalidCronExpression for %q, got %v", expr, err)
+		}
+	}
+}
+
+func TestHealthSchedule(t *testing.T) {
+	now := time.Date(2024, time.February, 28, 10, 7, 30, 0, time.UTC)
+	schedule := HealthSchedule{Interval: time.Minute, Splay: 30 * time.Second}
+	spread := make(map[time.Duration]bool)
+	for _, key := range []string{"-t 10.0.0.1:80 10.0.1.1:80", "-t 10.0.0.1:80 10.0.1.2:80", "-t 10.0.0.1:80 10.0.1.3:80"} {
+		first := schedule.first(key, now, 5*time.Second)
+		if first.Before(now) || !first.Before(now.Add(30*time.Second)) {
+			t.Errorf("first check should be within the splay - %v", first)
+		}
+		spread[first.Sub(now)] = true
+		if next := schedule.next(key, first, 5*time.Second); next.Sub(first) != time.Minute {
+			t.Errorf("expected checks a minute apart, got %v", next.Sub(first))
+		}
+	}
+	if len(spread) < 2 {
+		t.Errorf("servers should be spread over the splay - %v", spread)
+	}
+
+	schedule = HealthSchedule{Cron: "@hourly", Splay: time.Minute}
+	first := schedule.first("-t 10.0.0.1:80 10.0.1.1:80", now, 5*time.Second)
+	if hour := time.Date(2024, time.February, 28, 11, 0, 0, 0, time.UTC); first.Before(hour) || !first.Before(hour.Add(time.Minute)) {
+		t.Errorf("expected the check on the hour, within the splay - %v", first)
+	}
+	if next := schedule.next("-t 10.0.0.1:80 10.0.1.1:80", first, 5*time.Second); next.Sub(first) != time.Hour {
+		t.Errorf("expected the next check an hour later, got %v", next)
+	}
+
+	checker := &HealthChecker{Schedules: map[string]HealthSchedule{"web": {Cron: "* * *"}}}
+	if err := checker.Validate(); err != InvalidCronExpression {
+		t.Errorf("expected InvalidCronExpression, got %v", err)
+	}
+}
+
+func TestHealthCheckerSchedules(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	client.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Name: "web", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}})
+	client.AddService(Service{Type: "tcp", Host: "10.0.0.2", Port: 80, Servers: []Server{{Host: "10.0.1.2", Port: 80, Weight: 1}}})
+
+	// the checks run concurrently
+	mu := sync.Mutex{}
+	checked := make(map[string]string)
+	check := func(name string) HealthCheck {
+		return checkFunc(func(service Service, server Server) error {
+			mu.Lock()
+			defer mu.Unlock()
+			checked[server.Host] = name
+			return nil
+		})
+	}
+	checker := &HealthChecker{Lvs: client, Check: check("default"), Schedules: map[string]HealthSchedule{
+		"web": {Check: check("heavy")},
+	}}
+	checker.check(func(service Service, server Server) bool { return true })
+	if checked["10.0.1.1"] != "heavy" || checked["10.0.1.2"] != "default" {
+		t.Errorf("expected each service's check - %v", checked)
+	}
+
+	checked = make(map[string]string)
+	checker.check(func(service Service, server Server) bool { return service.Name == "web" })
+	if len(checked) != 1 || checked["10.0.1.1"] != "heavy" {
+		t.Errorf("only the due servers should be checked - %v", checked)
+	}
+	if len(checker.Health()) != 2 {
+		t.Errorf("servers that weren't due should keep their health - %+v", checker.Health())
+	}
+}
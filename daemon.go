@@ -0,0 +1,124 @@
+package lvs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+type (
+	// Daemon wires process signals to an Ipvs for programs embedding the
+	// package: SIGHUP reloads the config, SIGTERM/SIGINT drain and stop
+	Daemon struct {
+		ConfigPath   string        // json config applied on start and on SIGHUP
+		Store        Store         // without a ConfigPath, its services are synced on start and on SIGHUP
+		Ipvs         *Ipvs         // defaults to DefaultIpvs
+		DrainTimeout time.Duration // how long servers are drained before stopping, 0 skips draining
+		// Orphans is what happens on start to the servers in the table
+		// that Store's services don't have, see OrphanPolicy
+		Orphans OrphanPolicy
+
+		OnOrphans func([]Orphan) // called on start with the orphans found, if any
+
+		OnStart  func() error // called once the config is applied, an error aborts Run
+		OnReload func(error)  // called after every reload with its result
+		OnStop   func()       // called after draining, right before Run returns
+	}
+)
+
+// Run applies the config and blocks handling signals until the process is
+// told to terminate or stop is closed
+func (d *Daemon) Run(stop <-chan struct{}) error {
+	if d.Ipvs == nil {
+		d.Ipvs = DefaultIpvs
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	if err := d.reload(true); err != nil {
+		return err
+	}
+	if err := firstErr(d.Ipvs.StartDaemon()); err != nil {
+		return err
+	}
+	if d.OnStart != nil {
+		if err := d.OnStart(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stop:
+			return d.shutdown()
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				return d.shutdown()
+			}
+			err := d.reload(false)
+			if d.OnReload != nil {
+				d.OnReload(err)
+			}
+		}
+	}
+}
+
+// reload applies the config, or syncs Store's services after collecting
+// the orphans when starting
+func (d *Daemon) reload(starting bool) error {
+	if d.ConfigPath != "" {
+		return d.Ipvs.ApplyConfig(d.ConfigPath)
+	}
+	if d.Store == nil {
+		return nil
+	}
+	services, err := d.Store.Load()
+	if err != nil || services == nil {
+		return err
+	}
+	if starting {
+		var orphans []Orphan
+		if services, orphans, err = d.Ipvs.CollectOrphans(services, d.Orphans); err != nil {
+			return err
+		}
+		if len(orphans) > 0 && d.OnOrphans != nil {
+			d.OnOrphans(orphans)
+		}
+		if len(orphans) > 0 && d.Orphans == OrphanAdopt {
+			if err = d.Store.Save(services); err != nil {
+				return err
+			}
+		}
+	}
+	return d.Ipvs.Sync(services)
+}
+
+// shutdown quiesces every server so no new connections are scheduled, waits
+// for existing ones to finish up (unless draining failed) and stops the sync
+// daemon
+func (d *Daemon) shutdown() error {
+	var err error
+	if d.DrainTimeout > 0 {
+		if err = d.Ipvs.Drain(); err == nil {
+			time.Sleep(d.DrainTimeout)
+		}
+	}
+	if stopErr := firstErr(d.Ipvs.StopDaemon()); err == nil {
+		err = stopErr
+	}
+	if d.OnStop != nil {
+		d.OnStop()
+	}
+	return err
+}
+
+// firstErr returns the primary sync daemon's error, else the backup's
+func firstErr(primary, backup error) error {
+	if primary != nil {
+		return primary
+	}
+	return backup
+}
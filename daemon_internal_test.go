@@ -0,0 +1,108 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failRunner records commands like orderRunner, failing those containing
+// fail
+type failRunner struct {
+	orderRunner
+	fail string
+}
+
+func (r failRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	command := strings.Join(append([]string{exe}, args...), " ")
+	if r.fail != "" && strings.Contains(command, r.fail) {
+		*r.commands = append(*r.commands, command)
+		return errors.New("exit status 255: " + r.fail + " failed")
+	}
+	return r.orderRunner.Execute(ctx, exe, args...)
+}
+
+func newTestDaemon(fail string) (*Daemon, *[]string) {
+	commands := make([]string, 0, 0)
+	ipvs := NewIpvs(WithRunner(failRunner{orderRunner: orderRunner{Simulator: NewSimulator(), commands: &commands}, fail: fail}))
+	ipvs.MulticastInterface = "eth0"
+	store := &MemoryStore{}
+	store.Save([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Forwarder: "m", Weight: 1}}}})
+	return &Daemon{Store: store, Ipvs: ipvs, DrainTimeout: time.Millisecond}, &commands
+}
+
+// commandIndex returns the index of the first command starting with prefix
+func commandIndex(commands []string, prefix string) int {
+	for j := range commands {
+		if strings.HasPrefix(commands[j], prefix) {
+			return j
+		}
+	}
+	return -1
+}
+
+func TestDaemonRun(t *testing.T) {
+	d, commands := newTestDaemon("")
+	stop := make(chan struct{})
+	steps := make([]string, 0, 0)
+	d.OnStart = func() error {
+		steps = append(steps, "start")
+		return syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	}
+	d.OnReload = func(err error) {
+		if err != nil {
+			t.Errorf("failed to reload - %v", err)
+		}
+		steps = append(steps, "reload")
+		close(stop)
+	}
+	d.OnStop = func() { steps = append(steps, "stop") }
+
+	if err := d.Run(stop); err != nil {
+		t.Fatalf("failed to run - %v", err)
+	}
+	if strings.Join(steps, " ") != "start reload stop" {
+		t.Errorf("unexpected steps - %v", steps)
+	}
+	order := []string{
+		"ipvsadm -A -t 10.0.0.1:80",
+		"ipvsadm --start-daemon primary --mcast-interface eth0",
+		"ipvsadm --start-daemon backup --mcast-interface eth0",
+		"ipvsadm -e -t 10.0.0.1:80 -r 10.0.1.1:80 -m -y 0 -x 0 -w 0",
+		"ipvsadm --stop-daemon primary",
+		"ipvsadm --stop-daemon backup",
+	}
+	for j := 1; j < len(order); j++ {
+		if before, after := commandIndex(*commands, order[j-1]), commandIndex(*commands, order[j]); before < 0 || after < before {
+			t.Errorf("expected %q before %q - %q", order[j-1], order[j], *commands)
+		}
+	}
+}
+
+func TestDaemonRunErrors(t *testing.T) {
+	// the backup daemon failing to start aborts Run
+	d, _ := newTestDaemon("--start-daemon backup")
+	d.OnStart = func() error {
+		t.Error("OnStart shouldn't be called")
+		return nil
+	}
+	if err := d.Run(make(chan struct{})); err == nil || !strings.Contains(err.Error(), "--start-daemon backup") {
+		t.Errorf("expected the backup daemon's error, got %v", err)
+	}
+
+	// servers that failed to drain aren't waited for
+	d, commands := newTestDaemon("-e -t")
+	d.DrainTimeout = time.Hour
+	stop := make(chan struct{})
+	close(stop)
+	if err := d.Run(stop); err == nil {
+		t.Errorf("expected the drain's error")
+	}
+	if commandIndex(*commands, "ipvsadm --stop-daemon backup") < 0 {
+		t.Errorf("expected the sync daemon stopped anyway - %q", *commands)
+	}
+}
@@ -0,0 +1,129 @@
+package lvs
+
+import (
+	"errors"
+)
+
+type (
+	// Resource is something services depend on that isn't an ipvs rule,
+	// such as a vip on an interface or the mangle rule marking packets for
+	// a fwmark service, created and deleted with commands run like ipvsadm
+	// (eg. in the netns or over ssh). Services list the resources they
+	// need in Requires, and Sync applies them first
+	Resource struct {
+		Name string `json:"name"`
+		// Apply creates the resource, eg.
+		// ["ip", "addr", "add", "10.0.0.1/32", "dev", "lo"]
+		Apply []string `json:"apply"`
+		// Check succeeds when the resource already exists, Apply is then
+		// skipped. Without it Apply must be safe to run again
+		Check []string `json:"check,omitempty"`
+		// Remove deletes the resource once ApplyConfig no longer has it
+		Remove []string `json:"remove,omitempty"`
+		// Requires are the names of the resources applied before it
+		Requires []string `json:"requires,omitempty"`
+	}
+
+	// applyStep is a resource or a service to apply, see applyOrder
+	applyStep struct {
+		resource *Resource
+		service  int // index of the service when resource is nil
+	}
+)
+
+var (
+	UnknownDependency = errors.New("Unknown Dependency, expected the name of a resource or service")
+	DependencyCycle   = errors.New("Dependency Cycle")
+)
+
+// applyOrder returns the order to apply resources and services in, each
+// after what it Requires (a resource, or a service by Name or key) and
+// otherwise in the order given. Resources no service requires come first
+func applyOrder(resources []Resource, services []Service) ([]applyStep, error) {
+	nodes := make([]applyStep, 0, len(resources)+len(services))
+	byName := make(map[string]int)
+	requires := make([][]string, 0, cap(nodes))
+	for j := range resources {
+		byName[resources[j].Name] = len(nodes)
+		nodes = append(nodes, applyStep{resource: &resources[j]})
+		requires = append(requires, resources[j].Requires)
+	}
+	for j := range services {
+		if services[j].Name != "" {
+			byName[services[j].Name] = len(nodes)
+		}
+		byName[services[j].key()] = len(nodes)
+		nodes = append(nodes, applyStep{service: j})
+		requires = append(requires, services[j].Requires)
+	}
+
+	// Kahn's algorithm, taking the first node ready each time
+	pending := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+	for j := range nodes {
+		for _, name := range requires[j] {
+			k, ok := byName[name]
+			if !ok {
+				return nil, UnknownDependency
+			}
+			pending[j]++
+			dependents[k] = append(dependents[k], j)
+		}
+	}
+	order := make([]applyStep, 0, len(nodes))
+	done := make([]bool, len(nodes))
+	for len(order) < len(nodes) {
+		next := -1
+		for j := range nodes {
+			if !done[j] && pending[j] == 0 {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			return nil, DependencyCycle
+		}
+		done[next] = true
+		order = append(order, nodes[next])
+		for _, j := range dependents[next] {
+			pending[j]--
+		}
+	}
+	return order, nil
+}
+
+// applyResource runs the resource's Apply, unless its Check succeeds
+func (i *Ipvs) applyResource(r Resource) error {
+	if len(r.Check) > 0 {
+		if _, err := i.exec.run(r.Check); err == nil {
+			return nil
+		}
+	}
+	if len(r.Apply) == 0 {
+		return nil
+	}
+	return i.exec.execute(r.Apply[0], r.Apply[1:]...)
+}
+
+// removeResources runs the Remove of the resources in previous no longer in
+// resources, in the reverse of their apply order
+func (i *Ipvs) removeResources(previous, resources []Resource) error {
+	kept := make(map[string]bool)
+	for j := range resources {
+		kept[resources[j].Name] = true
+	}
+	order, err := applyOrder(previous, nil)
+	if err != nil {
+		return err
+	}
+	for j := len(order) - 1; j >= 0; j-- {
+		r := order[j].resource
+		if kept[r.Name] || len(r.Remove) == 0 {
+			continue
+		}
+		if err := i.exec.execute(r.Remove[0], r.Remove[1:]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
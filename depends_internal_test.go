@@ -0,0 +1,88 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// orderRunner records the commands run against a Simulator, failing the
+// ones named "false"
+type orderRunner struct {
+	*Simulator
+	commands *[]string
+}
+
+func (r orderRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	*r.commands = append(*r.commands, strings.Join(append([]string{exe}, args...), " "))
+	return r.Simulator.Execute(ctx, exe, args...)
+}
+
+func (r orderRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	if exe == "false" {
+		return nil, nil, errors.New("exit status 1")
+	}
+	return r.Simulator.RunOutput(ctx, exe, args...)
+}
+
+func TestSyncDependencies(t *testing.T) {
+	commands := make([]string, 0, 0)
+	ipvs := NewIpvs(WithRunner(orderRunner{Simulator: NewSimulator(), commands: &commands}))
+	ipvs.Resources = []Resource{
+		{Name: "mark", Apply: []string{"iptables", "-t", "mangle", "-A", "PREROUTING", "-d", "10.0.0.1", "-j", "MARK", "--set-mark", "1"}, Check: []string{"false"}},
+		{Name: "vip", Apply: []string{"ip", "addr", "add", "10.0.0.1/32", "dev", "lo"}, Check: []string{"true"}},
+	}
+	err := ipvs.Sync([]Service{
+		{Type: "fwmark", Host: "1", Scheduler: "rr", Requires: []string{"mark", "web"}},
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Name: "web", Requires: []string{"vip"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+	expected := []string{
+		"iptables -t mangle -A PREROUTING -d 10.0.0.1 -j MARK --set-mark 1",
+		"ipvsadm -A -t 10.0.0.1:80 -s rr",
+		"ipvsadm -A -f 1 -s rr",
+	}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(commands, "\n"))
+	}
+
+	if err := ipvs.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Requires: []string{"nothing"}}}); err != UnknownDependency {
+		t.Errorf("expected UnknownDependency, got %v", err)
+	}
+	cycle := []Service{
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Requires: []string{"-t 10.0.0.2:80"}},
+		{Type: "tcp", Host: "10.0.0.2", Port: 80, Requires: []string{"-t 10.0.0.1:80"}},
+	}
+	if err := ipvs.Sync(cycle); err != DependencyCycle {
+		t.Errorf("expected DependencyCycle, got %v", err)
+	}
+}
+
+func TestApplyConfigRemovesResources(t *testing.T) {
+	commands := make([]string, 0, 0)
+	ipvs := NewIpvs(WithRunner(orderRunner{Simulator: NewSimulator(), commands: &commands}))
+	path := filepath.Join(t.TempDir(), "lvs.json")
+	config := `{"resources":[{"name":"vip","apply":["ip","addr","add","10.0.0.1/32","dev","lo"],"remove":["ip","addr","del","10.0.0.1/32","dev","lo"]}],"services":[{"type":"tcp","host":"10.0.0.1","port":80,"requires":["vip"]}]}`
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ipvs.ApplyConfig(path); err != nil {
+		t.Fatalf("failed to apply - %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"services":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commands = commands[:0]
+	if err := ipvs.ApplyConfig(path); err != nil {
+		t.Fatalf("failed to apply - %v", err)
+	}
+	expected := []string{"ipvsadm -D -t 10.0.0.1:80", "ip addr del 10.0.0.1/32 dev lo"}
+	if strings.Join(commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected the resource removed after the service - %q", commands)
+	}
+}
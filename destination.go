@@ -0,0 +1,121 @@
+package lvs
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	InvalidServiceKey = errors.New("Invalid Service Key, expected a type and host:port such as \"-t 10.0.0.1:80\"")
+
+	// serviceKeyTypes are the types keys start with, ipvsadm's flags or
+	// the names services are written with
+	serviceKeyTypes = map[string]string{
+		"-t": "tcp", "tcp": "tcp",
+		"-u": "udp", "udp": "udp",
+		"-f": "fwmark", "fwmark": "fwmark",
+	}
+)
+
+// ParseServiceKey returns the service (without servers) identified by key,
+// its type and host:port (or fwmark) as in ipvsadm, eg. "-t 10.0.0.1:80",
+// "udp [2001:db8::1]:53" or "-f 1"
+func ParseServiceKey(key string) (Service, error) {
+	fields := strings.Fields(key)
+	if len(fields) != 2 {
+		return Service{}, InvalidServiceKey
+	}
+	netType, ok := serviceKeyTypes[fields[0]]
+	if !ok {
+		return Service{}, InvalidServiceKey
+	}
+	host, port, err := parseServiceAddress(netType, fields[1])
+	if err != nil {
+		return Service{}, InvalidServiceKey
+	}
+	if netType == "fwmark" {
+		return Service{Type: netType, Host: host}, nil
+	}
+	if port == 0 {
+		return Service{}, InvalidServiceKey
+	}
+	return Service{Type: netType, Host: host, Port: port}, nil
+}
+
+// destinationService returns the service identified by key. When it isn't
+// known, or known doesn't find what the caller needs in it, the table is
+// read back first (see Save), so controllers can work on keys alone with a
+// fresh Ipvs. NotFound when the table doesn't have it either
+func (i *Ipvs) destinationService(key string, known func(*Service) bool) (*Service, error) {
+	id, err := ParseServiceKey(key)
+	if err != nil {
+		return nil, err
+	}
+	service := i.FindService(id.Type, id.Host, id.Port)
+	if service == nil || !known(service) {
+		if err = i.Save(); err != nil {
+			return nil, err
+		}
+		service = i.FindService(id.Type, id.Host, id.Port)
+	}
+	if service == nil {
+		return nil, NotFound
+	}
+	return service, nil
+}
+
+// AddDestination adds server to the service identified by key (see
+// ParseServiceKey) like Service.AddServer, for controllers keeping keys
+// rather than services
+func (i *Ipvs) AddDestination(key string, server Server) error {
+	defer i.changing()()
+	service, err := i.destinationService(key, func(*Service) bool { return true })
+	if err != nil {
+		return err
+	}
+	return service.AddServer(server)
+}
+
+// EditDestination edits the server of the service identified by key like
+// Service.EditServer
+func (i *Ipvs) EditDestination(key string, server Server) error {
+	defer i.changing()()
+	has := func(service *Service) bool {
+		return service.FindServer(server.Host, service.withDefaults(server).Port) != nil
+	}
+	service, err := i.destinationService(key, has)
+	if err != nil {
+		return err
+	}
+	if !has(service) {
+		return NotFound
+	}
+	return service.EditServer(server)
+}
+
+// RemoveDestination removes the server at host and port from the service
+// identified by key like Service.RemoveServer
+func (i *Ipvs) RemoveDestination(key, host string, port int) error {
+	defer i.changing()()
+	has := func(service *Service) bool { return service.FindServer(host, port) != nil }
+	service, err := i.destinationService(key, has)
+	if err != nil {
+		return err
+	}
+	if !has(service) {
+		return NotFound
+	}
+	return service.RemoveServer(host, port)
+}
+
+func (l *Lvs) AddDestination(key string, server Server) error {
+	return l.Do(func(i *Ipvs) error { return i.AddDestination(key, server) })
+}
+
+func (l *Lvs) EditDestination(key string, server Server) error {
+	return l.Do(func(i *Ipvs) error { return i.EditDestination(key, server) })
+}
+
+func (l *Lvs) RemoveDestination(key, host string, port int) error {
+	return l.Do(func(i *Ipvs) error { return i.RemoveDestination(key, host, port) })
+}
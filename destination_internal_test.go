@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestParseServiceKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		service Service
+		err     error
+	}{
+		{"-t 10.0.0.1:80", Service{Type: "tcp", Host: "10.0.0.1", Port: 80}, nil},
+		{"udp [2001:db8::1]:53", Service{Type: "udp", Host: "2001:db8::1", Port: 53}, nil},
+		{"-f 1", Service{Type: "fwmark", Host: "1"}, nil},
+		{"-t 10.0.0.1", Service{}, InvalidServiceKey},
+		{"sctp 10.0.0.1:80", Service{}, InvalidServiceKey},
+		{"10.0.0.1:80", Service{}, InvalidServiceKey},
+	}
+	for _, test := range tests {
+		service, err := ParseServiceKey(test.key)
+		if err != test.err || service.Type != test.service.Type || service.Host != test.service.Host || service.Port != test.service.Port {
+			t.Errorf("expected %+v (%v) for %q, got %+v (%v)", test.service, test.err, test.key, service, err)
+		}
+	}
+}
+
+func TestDestinations(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	if err := client.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.AddDestination("-t 10.0.0.1:80", Server{Host: "10.0.1.1", Weight: 2}); err != nil {
+		t.Fatalf("failed to add destination - %v", err)
+	}
+	if err := client.EditDestination("tcp 10.0.0.1:80", Server{Host: "10.0.1.1", Port: 80, Weight: 5}); err != nil {
+		t.Fatalf("failed to edit destination - %v", err)
+	}
+	services := client.Services()
+	if len(services[0].Servers) != 1 || services[0].Servers[0].Port != 80 || services[0].Servers[0].Weight != 5 {
+		t.Errorf("unexpected servers - %+v", services[0].Servers)
+	}
+
+	if err := client.AddDestination("-t 10.0.0.2:80", Server{Host: "10.0.1.1", Port: 80}); err != NotFound {
+		t.Errorf("expected NotFound for an unknown service, got %v", err)
+	}
+	if err := client.EditDestination("-t 10.0.0.1:80", Server{Host: "10.0.1.2", Port: 80}); err != NotFound {
+		t.Errorf("expected NotFound for an unknown server, got %v", err)
+	}
+	if err := client.RemoveDestination("-t 10.0.0.1:80", "10.0.1.1", 80); err != nil {
+		t.Fatalf("failed to remove destination - %v", err)
+	}
+	if services := client.Services(); len(services[0].Servers) != 0 {
+		t.Errorf("destination wasn't removed - %+v", services[0].Servers)
+	}
+}
+
+func TestDestinationsFreshIpvs(t *testing.T) {
+	simulator := NewSimulator()
+	if err := New(WithRunner(simulator)).AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// each client knows nothing of the table until it needs it
+	if err := New(WithRunner(simulator)).AddDestination("-t 10.0.0.1:80", Server{Host: "10.0.1.2", Port: 80, Weight: 1}); err != nil {
+		t.Errorf("failed to add destination - %v", err)
+	}
+	if err := New(WithRunner(simulator)).EditDestination("-t 10.0.0.1:80", Server{Host: "10.0.1.1", Port: 80, Weight: 7}); err != nil {
+		t.Errorf("failed to edit destination - %v", err)
+	}
+	if err := New(WithRunner(simulator)).RemoveDestination("-t 10.0.0.1:80", "10.0.1.2", 80); err != nil {
+		t.Errorf("failed to remove destination - %v", err)
+	}
+	if services := simulator.Services(); len(services[0].Servers) != 1 || services[0].Servers[0].Weight != 7 {
+		t.Errorf("unexpected servers - %+v", services[0].Servers)
+	}
+
+	// a server added behind a client's back is found too
+	client := New(WithRunner(simulator))
+	if err := client.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(WithRunner(simulator)).AddDestination("-t 10.0.0.1:80", Server{Host: "10.0.1.3", Port: 80, Weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveDestination("-t 10.0.0.1:80", "10.0.1.3", 80); err != nil {
+		t.Errorf("failed to remove a destination added elsewhere - %v", err)
+	}
+
+	if err := New(WithRunner(simulator)).AddDestination("-t 10.0.0.2:80", Server{Host: "10.0.1.1", Port: 80}); err != NotFound {
+		t.Errorf("expected NotFound for a service the table doesn't have, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+// Package lvs is a small wrapper around ipvsadm for managing the Linux
+// Virtual Server table from go.
+//
+// Services and their real servers can be changed one at a time through an
+// Ipvs, or a whole table can be declared and applied with Sync, which only
+// issues the changes needed. An Lvs client adds locking and per table
+// configuration (network namespace, remote runner, timeouts) on top.
+package lvs
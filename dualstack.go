@@ -0,0 +1,141 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+)
+
+type (
+	// DualStackService is an ipv4 and an ipv6 service managed as one: same
+	// port, type and scheduling, with servers given by hostname resolved
+	// to an address of each family. Servers with an address of a single
+	// family only serve that family's service
+	DualStackService struct {
+		Name          string         `json:"name,omitempty"` // suffixed with -ipv4 and -ipv6 for the two services
+		Host4         string         `json:"host4"`
+		Host6         string         `json:"host6"`
+		Port          int            `json:"port"`
+		Type          string         `json:"type"`
+		Scheduler     string         `json:"scheduler"`
+		SchedulerOpts *SchedulerOpts `json:"scheduler_opts,omitempty"`
+		Persistence   int            `json:"persistence"`
+		Servers       []Server       `json:"servers"`
+	}
+
+	// DualStackStatus is what is applied of a DualStackService
+	DualStackStatus struct {
+		IPv4 *Service `json:"ipv4"` // nil when it isn't applied
+		IPv6 *Service `json:"ipv6"`
+		// InSync is set when both services are applied as described
+		InSync bool `json:"in_sync"`
+	}
+)
+
+var (
+	InvalidDualStack = errors.New("Invalid Dual Stack Service, expected an ipv4 and an ipv6 address")
+)
+
+// Services returns the ipv4 and the ipv6 service d describes, resolving
+// the servers' hostnames
+func (d DualStackService) Services() ([]Service, error) {
+	host4, host6 := net.ParseIP(d.Host4), net.ParseIP(d.Host6)
+	if host4 == nil || host4.To4() == nil || host6 == nil || host6.To4() != nil {
+		return nil, InvalidDualStack
+	}
+	services := []Service{d.service(host4.String(), "-ipv4"), d.service(host6.String(), "-ipv6")}
+	for _, server := range d.Servers {
+		host, err := NormalizeHost(server.Host)
+		if err != nil {
+			return nil, err
+		}
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			if ips, err = lookupIP(host); err != nil {
+				return nil, err
+			}
+		}
+		if len(ips) == 0 {
+			return nil, InvalidHost
+		}
+		// the first address of each family
+		for k := range services {
+			for _, ip := range ips {
+				if (ip.To4() != nil) == (k == 0) {
+					server.Host = ip.String()
+					services[k].Servers = append(services[k].Servers, server)
+					break
+				}
+			}
+		}
+	}
+	for j := range services {
+		if err := services[j].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return services, nil
+}
+
+func (d DualStackService) service(host, suffix string) Service {
+	service := Service{
+		Host:          host,
+		Port:          d.Port,
+		Type:          d.Type,
+		Scheduler:     d.Scheduler,
+		SchedulerOpts: d.SchedulerOpts,
+		Persistence:   d.Persistence,
+		Servers:       make([]Server, 0, len(d.Servers)),
+	}
+	if d.Name != "" {
+		service.Name = d.Name + suffix
+	}
+	return service
+}
+
+// ApplyDualStack adds or updates both services of d (including their
+// servers) with a single `ipvsadm -R`, so neither family is applied
+// without the other
+func (i *Ipvs) ApplyDualStack(d DualStackService) error {
+	services, err := d.Services()
+	if err != nil {
+		return err
+	}
+	changes := make([]batchChange, len(services))
+	for j := range services {
+		service, err := services[j].Normalize()
+		if err != nil {
+			return err
+		}
+		changes[j] = batchChange{service: service}
+	}
+	return i.applyBatch(changes)
+}
+
+// RemoveDualStack removes both services of d with a single `ipvsadm -R`
+func (i *Ipvs) RemoveDualStack(d DualStackService) error {
+	changes := make([]batchChange, 0, 2)
+	for _, host := range []string{d.Host4, d.Host6} {
+		changes = append(changes, batchChange{service: Service{Type: d.Type, Host: host, Port: d.Port}, remove: true})
+	}
+	return i.applyBatch(changes)
+}
+
+// DualStack reports what is applied of d
+func (i Ipvs) DualStack(d DualStackService) (DualStackStatus, error) {
+	services, err := d.Services()
+	if err != nil {
+		return DualStackStatus{}, err
+	}
+	status := DualStackStatus{InSync: true}
+	for j, current := range []**Service{&status.IPv4, &status.IPv6} {
+		found := i.FindService(services[j].Type, services[j].Host, services[j].Port)
+		if found == nil {
+			status.InSync = false
+			continue
+		}
+		service := copyServices([]Service{*found})[0]
+		*current = &service
+		status.InSync = status.InSync && service.Equal(services[j])
+	}
+	return status, nil
+}
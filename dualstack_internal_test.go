@@ -0,0 +1,57 @@
+package lvs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDualStackService(t *testing.T) {
+	defer useFakeBackend()()
+	fakeHosts = map[string][]net.IP{
+		"web1.example.com": {net.ParseIP("2001:db8::11"), net.ParseIP("10.0.1.1")},
+		"web2.example.com": {net.ParseIP("10.0.1.2")},
+	}
+
+	d := DualStackService{Name: "web", Host4: "10.0.0.1", Host6: "2001:DB8::1", Port: 80, Type: "tcp", Scheduler: "rr", Servers: []Server{
+		{Host: "web1.example.com", Port: 80, Weight: 1},
+		{Host: "web2.example.com", Port: 80, Weight: 1},
+	}}
+	ipvs := NewIpvs()
+	if err := ipvs.ApplyDualStack(d); err != nil {
+		t.Fatalf("failed to apply - %v", err)
+	}
+	if len(fakeExecuted) != 0 || len(fakeStdin) != 1 {
+		t.Errorf("both families should be applied with a single restore - %q %q", fakeExecuted, fakeStdin)
+	}
+	expected := "-A -t 10.0.0.1:80 -s rr   \n" +
+		"-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -y 0 -x 0 -w 1\n" +
+		"-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -y 0 -x 0 -w 1\n" +
+		"-A -t [2001:db8::1]:80 -s rr   \n" +
+		"-a -t [2001:db8::1]:80 -r [2001:db8::11]:80 -g -y 0 -x 0 -w 1\n"
+	if len(fakeStdin) == 1 && fakeStdin[0] != expected {
+		t.Errorf("expected restore input\n%s\ngot\n%s", expected, fakeStdin[0])
+	}
+	v4, v6 := ipvs.FindService("tcp", "10.0.0.1", 80), ipvs.FindService("tcp", "2001:db8::1", 80)
+	if v4 == nil || v6 == nil || v4.Name != "web-ipv4" || v6.Name != "web-ipv6" {
+		t.Fatalf("expected both services - %+v", ipvs.Services)
+	}
+	if len(v4.Servers) != 2 || len(v6.Servers) != 1 || v6.Servers[0].Host != "2001:db8::11" {
+		t.Errorf("servers should be resolved per family - %+v %+v", v4.Servers, v6.Servers)
+	}
+
+	status, err := ipvs.DualStack(d)
+	if err != nil || !status.InSync || status.IPv4 == nil || status.IPv6 == nil {
+		t.Errorf("expected both services in sync - %+v %v", status, err)
+	}
+	d.Scheduler = "wlc"
+	if status, _ = ipvs.DualStack(d); status.InSync {
+		t.Errorf("changed service shouldn't be in sync")
+	}
+
+	if err := ipvs.RemoveDualStack(d); err != nil || len(ipvs.Services) != 0 {
+		t.Errorf("expected both services removed - %+v %v", ipvs.Services, err)
+	}
+	if _, err := (DualStackService{Host4: "2001:db8::1", Host6: "10.0.0.1", Port: 80}).Services(); err != InvalidDualStack {
+		t.Errorf("expected InvalidDualStack, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strconv"
+)
+
+var (
+	// DuplicateService is returned for two services with the same protocol,
+	// address and port (written differently, such as "::1" and "0::1"), or
+	// the same fwmark
+	DuplicateService = errors.New("duplicate service")
+	// OverlappingService is returned for a service taking every port of an
+	// address (port 0) alongside services on single ports of that address,
+	// which shadow it for their ports
+	OverlappingService = errors.New("service overlaps another")
+)
+
+// ValidateServices validates every service and checks that none of them
+// duplicate or shadow each other, see DuplicateService and
+// OverlappingService
+func ValidateServices(services []Service) error {
+	for j := range services {
+		if err := services[j].Validate(); err != nil {
+			return err
+		}
+	}
+	return checkOverlaps(services)
+}
+
+// Validate checks the table's services, see ValidateServices
+func (i Ipvs) Validate() error {
+	return ValidateServices(i.Services)
+}
+
+// checkOverlaps fails when services duplicate or shadow each other
+func checkOverlaps(services []Service) error {
+	seen := make(map[string]bool)
+	ports := make(map[string][]int) // of each protocol and address
+	for j := range services {
+		key := services[j].canonicalKey()
+		if seen[key] {
+			return DuplicateService
+		}
+		seen[key] = true
+
+		if ServiceTypeFlag[services[j].Type] == "-f" {
+			continue
+		}
+		vip := ServiceTypeFlag[services[j].Type] + " " + canonicalHost(services[j].Host)
+		for _, port := range ports[vip] {
+			if (port == 0) != (services[j].Port == 0) {
+				return OverlappingService
+			}
+		}
+		ports[vip] = append(ports[vip], services[j].Port)
+	}
+	return nil
+}
+
+// canonicalKey is like key, but spells addresses and fwmarks the same way
+// however they were written
+func (s Service) canonicalKey() string {
+	if ServiceTypeFlag[s.Type] == "-f" {
+		if mark, err := strconv.ParseUint(s.Host, 0, 32); err == nil {
+			return "-f " + strconv.FormatUint(mark, 10)
+		}
+		return "-f " + s.Host
+	}
+	return ServiceTypeFlag[s.Type] + " " + net.JoinHostPort(canonicalHost(s.Host), strconv.Itoa(s.Port))
+}
@@ -0,0 +1,54 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestValidateServices(t *testing.T) {
+	tests := []struct {
+		services []Service
+		err      error
+	}{
+		{[]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80}, {Type: "udp", Host: "10.0.0.1", Port: 80}}, nil},
+		{[]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80}, {Type: "tcp", Host: "10.0.0.1", Port: 80}}, DuplicateService},
+		{[]Service{{Type: "tcp", Host: "2001:db8::1", Port: 80}, {Type: "tcp", Host: "[2001:db8:0::1]", Port: 80}}, DuplicateService},
+		{[]Service{{Type: "fwmark", Host: "16"}, {Type: "fwmark", Host: "0x10"}}, DuplicateService},
+		{[]Service{{Type: "tcp", Host: "10.0.0.1", Persistence: 300}, {Type: "tcp", Host: "10.0.0.1", Port: 443}}, OverlappingService},
+		{[]Service{{Type: "tcp", Host: "10.0.0.1", Persistence: 300}, {Type: "udp", Host: "10.0.0.1", Port: 53}}, nil},
+		{[]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80}, {Type: "bogus", Host: "10.0.0.2", Port: 80}}, InvalidServiceType},
+	}
+	for _, test := range tests {
+		if err := ValidateServices(test.services); err != test.err {
+			t.Errorf("expected %v for %+v, got %v", test.err, test.services, err)
+		}
+	}
+}
+
+func TestDuplicateRejectedBeforeApplying(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	if err := ipvs.AddService(Service{Type: "tcp", Host: "2001:db8::1", Port: 80}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	if err := ipvs.AddService(Service{Type: "fwmark", Host: "16"}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	fakeExecuted = nil
+	// the same mark, already added
+	if err := ipvs.AddService(Service{Type: "fwmark", Host: "0x10"}); err != nil || len(ipvs.Services) != 2 {
+		t.Errorf("expected 0x10 to be the service added as 16 - %+v, %v", ipvs.Services, err)
+	}
+	if err := ipvs.AddServices([]Service{{Type: "tcp", Host: "2001:db8::1", Port: 0, Persistence: 60}}); err != OverlappingService {
+		t.Errorf("expected OverlappingService, got %v", err)
+	}
+
+	fakeRunOutput = []byte("-A -t 10.0.0.1:80 -s wlc\n")
+	err := ipvs.Sync([]Service{{Type: "tcp", Host: "10.0.0.2", Port: 80}, {Type: "tcp", Host: "10.0.0.2", Port: 80, Scheduler: "rr"}})
+	if err != DuplicateService {
+		t.Errorf("expected DuplicateService from Sync, got %v", err)
+	}
+	if len(fakeExecuted) != 0 || len(fakeStdin) != 0 {
+		t.Errorf("nothing should be applied - %v %v", fakeExecuted, fakeStdin)
+	}
+}
@@ -0,0 +1,195 @@
+package lvs
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// EchoAgent runs on real servers, behind a service checked with
+	// CheckPath, answering each token it gets with the token and its Id
+	EchoAgent struct {
+		Network string // tcp or udp, defaults to tcp
+		Addr    string // eg. ":7", the servers' port of the service
+		Id      string // defaults to the hostname
+	}
+
+	// PathResult is what CheckPath saw of the tokens it sent through a vip
+	PathResult struct {
+		Service string `json:"service"` // type and host:port of the service
+		Samples int    `json:"samples"`
+		Echoed  int    `json:"echoed"`
+		// Failed are the tokens without an answer, or answered with
+		// another token, eg. because forwarded replies don't make it back
+		Failed    int    `json:"failed"`
+		LastError string `json:"last_error,omitempty"`
+		// Agents counts the tokens echoed by each agent Id
+		Agents map[string]int `json:"agents"`
+	}
+)
+
+var (
+	EchoUnsupported = errors.New("Echo Unsupported, only tcp and udp services can be checked")
+)
+
+// Run answers tokens until stop is closed
+func (a EchoAgent) Run(stop <-chan struct{}) error {
+	if a.Network == "udp" {
+		conn, err := net.ListenPacket("udp", a.Addr)
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-stop
+			conn.Close()
+		}()
+		return a.ServePacket(conn)
+	}
+	l, err := net.Listen("tcp", a.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-stop
+		l.Close()
+	}()
+	return a.Serve(l)
+}
+
+// Serve answers the tokens of the tcp connections accepted from l, a line
+// each, until l is closed
+func (a EchoAgent) Serve(l net.Listener) error {
+	id := a.id()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(HealthCheckTimeout))
+			token, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(conn, "%s %s\n", strings.TrimSpace(token), id)
+		}()
+	}
+}
+
+// ServePacket answers the token of every udp datagram read from conn until
+// it is closed
+func (a EchoAgent) ServePacket(conn net.PacketConn) error {
+	id := a.id()
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		conn.WriteTo([]byte(strings.TrimSpace(string(buf[:n]))+" "+id+"\n"), addr)
+	}
+}
+
+func (a EchoAgent) id() string {
+	if a.Id != "" {
+		return a.Id
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// CheckPath sends samples random tokens, each over its own connection (or
+// datagram for udp), to the applied tcp or udp service identified by
+// service (its type, host and port), from this host, expecting them back
+// from the EchoAgent of the server ipvs forwards them to. Unlike health
+// checks, which reach the servers directly, this checks the forwarding
+// itself: the vip on the servers' loopback for direct routing, tunnel
+// interfaces or the director being the servers' gateway for nat
+func (i *Ipvs) CheckPath(service Service, samples int) (PathResult, error) {
+	if service.Type != "tcp" && service.Type != "udp" {
+		return PathResult{}, EchoUnsupported
+	}
+	current, err := i.appliedService(service)
+	if err != nil {
+		return PathResult{}, err
+	}
+	return checkPath(*current, samples), nil
+}
+
+// checkPath sends the tokens of CheckPath through current
+func checkPath(current Service, samples int) PathResult {
+	result := PathResult{Service: current.Type + " " + current.getHostPort(), Samples: samples, Agents: make(map[string]int)}
+	address := net.JoinHostPort(current.Host, strconv.Itoa(current.Port))
+	for j := 0; j < samples; j++ {
+		id, err := echo(current.Type, address)
+		if err != nil {
+			result.Failed++
+			result.LastError = err.Error()
+			continue
+		}
+		result.Echoed++
+		result.Agents[id]++
+	}
+	return result
+}
+
+// echo sends a token to address, returning the Id of the agent echoing it
+func echo(network, address string) (string, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	sent := hex.EncodeToString(token)
+
+	conn, err := net.DialTimeout(network, address, HealthCheckTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(HealthCheckTimeout))
+	if _, err = conn.Write([]byte(sent + "\n")); err != nil {
+		return "", err
+	}
+	answer, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(answer)
+	if len(fields) != 2 || fields[0] != sent {
+		return "", fmt.Errorf("unexpected answer %q to token %s", strings.TrimSpace(answer), sent)
+	}
+	return fields[1], nil
+}
+
+// CheckPath checks like Ipvs.CheckPath, only holding the client's lock to
+// read the table, not while the tokens are sent
+func (l *Lvs) CheckPath(service Service, samples int) (PathResult, error) {
+	if service.Type != "tcp" && service.Type != "udp" {
+		return PathResult{}, EchoUnsupported
+	}
+	var current *Service
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		current, err = i.appliedService(service)
+		return err
+	})
+	if err != nil {
+		return PathResult{}, err
+	}
+	return checkPath(*current, samples), nil
+}
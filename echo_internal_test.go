@@ -0,0 +1,118 @@
+package lvs
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckPath(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go EchoAgent{Id: "web1"}.Serve(l)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go EchoAgent{Id: "dns1"}.ServePacket(conn)
+	// answers with the wrong token, like something other than the agent
+	wrong, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wrong.Close()
+	go func() {
+		for {
+			conn, err := wrong.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("nope web2\n"))
+			conn.Close()
+		}
+	}()
+
+	ipvs := NewIpvs(WithRunner(NewSimulator()))
+	services := []Service{
+		{Type: "tcp", Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port, Scheduler: "rr"},
+		{Type: "udp", Host: "127.0.0.1", Port: conn.LocalAddr().(*net.UDPAddr).Port, Scheduler: "rr"},
+		{Type: "tcp", Host: "127.0.0.1", Port: wrong.Addr().(*net.TCPAddr).Port, Scheduler: "rr"},
+	}
+	if err := ipvs.Sync(services); err != nil {
+		t.Fatal(err)
+	}
+
+	for j, id := range []string{"web1", "dns1"} {
+		result, err := ipvs.CheckPath(services[j], 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Echoed != 3 || result.Failed != 0 || result.Agents[id] != 3 {
+			t.Errorf("unexpected %s result - %+v", services[j].Type, result)
+		}
+	}
+	result, err := ipvs.CheckPath(services[2], 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Echoed != 0 || result.Failed != 2 || !strings.HasPrefix(result.LastError, `unexpected answer "nope web2"`) {
+		t.Errorf("expected wrong tokens to fail - %+v", result)
+	}
+
+	if _, err := ipvs.CheckPath(Service{Type: "fwmark", Host: "1"}, 1); err != EchoUnsupported {
+		t.Errorf("expected fwmark to be unsupported - %v", err)
+	}
+	if _, err := ipvs.CheckPath(Service{Type: "tcp", Host: "127.0.0.1", Port: 1}, 1); err != NotFound {
+		t.Errorf("expected an unknown service to be not found - %v", err)
+	}
+}
+
+// lockedListener records whether client is locked when accepting
+type lockedListener struct {
+	net.Listener
+	client *Lvs
+	locked *atomic.Bool
+}
+
+func (l lockedListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		if !l.client.mu.TryLock() {
+			l.locked.Store(true)
+		} else {
+			l.client.mu.Unlock()
+		}
+	}
+	return conn, err
+}
+
+func TestCheckPathUnlocked(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client := New(WithRunner(NewSimulator()))
+	locked := &atomic.Bool{}
+	go EchoAgent{Id: "web1"}.Serve(lockedListener{Listener: l, client: client, locked: locked})
+	service := Service{Type: "tcp", Host: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port, Scheduler: "rr"}
+	if err := client.Sync([]Service{service}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.CheckPath(service, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked.Load() {
+		t.Error("expected the client not to be locked while sending tokens")
+	}
+	if result.Echoed != 2 {
+		t.Errorf("unexpected result - %+v", result)
+	}
+}
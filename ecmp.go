@@ -0,0 +1,73 @@
+package lvs
+
+import (
+	"math"
+)
+
+// ecmpFactor is how much more (or less) of the ecmp traffic director gets
+// than with an even split
+func (f Fleet) ecmpFactor(director string) float64 {
+	if len(f.Shares) == 0 || len(f.Directors) == 0 {
+		return 1
+	}
+	total := 0.0
+	for name := range f.Directors {
+		total += f.share(name)
+	}
+	if total == 0 {
+		return 1
+	}
+	return f.share(director) * float64(len(f.Directors)) / total
+}
+
+func (f Fleet) share(director string) float64 {
+	share, ok := f.Shares[director]
+	if !ok {
+		return 1
+	}
+	if share < 0 {
+		return 0
+	}
+	return share
+}
+
+// EcmpServices returns a copy of services as applied to director, their
+// MaxConns and their servers' connection thresholds scaled by how much more
+// (or less) of the ecmp traffic than an even split the director gets
+// according to Shares. Thresholds are absolute per director, so each then
+// caps its share of a server's connections and a server's connections
+// summed over the fleet stay within what its thresholds allow for an even
+// split. Weights are left as they are, schedulers only weigh a director's
+// servers against each other. Scaled limits keep at least 1, even when the
+// director's share is 0, as 0 would lift them. Without Shares, or with
+// equal ones, services are returned as they are
+func (f Fleet) EcmpServices(director string, services []Service) []Service {
+	scaled := copyServices(services)
+	factor := f.ecmpFactor(director)
+	if factor == 1 {
+		return scaled
+	}
+	for i := range scaled {
+		// sync splits MaxConns into the servers' upper thresholds
+		scaled[i].MaxConns = scaleEcmp(scaled[i].MaxConns, factor)
+		for j := range scaled[i].Servers {
+			server := &scaled[i].Servers[j]
+			server.UpperThreshold = scaleEcmp(server.UpperThreshold, factor)
+			server.LowerThreshold = scaleEcmp(server.LowerThreshold, factor)
+		}
+	}
+	return scaled
+}
+
+// scaleEcmp scales a limit by factor, leaving 0 (unbounded) as it is and
+// keeping the others at least 1
+func scaleEcmp(value int, factor float64) int {
+	if value <= 0 {
+		return value
+	}
+	scaled := int(math.Round(float64(value) * factor))
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
@@ -0,0 +1,75 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestEcmpServices(t *testing.T) {
+	services := []Service{{Host: "10.0.0.1", Port: 80, WeightBounds: &WeightBounds{Min: 2, Max: 40}, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 4, UpperThreshold: 100, LowerThreshold: 80},
+		{Host: "10.0.1.2", Port: 80, Weight: 0},
+		{Host: "10.0.1.3", Port: 80, Weight: 1, UpperThreshold: 1},
+	}}}
+
+	fleet := Fleet{Directors: map[string]*Lvs{"a": nil, "b": nil, "c": nil}}
+	if scaled := fleet.EcmpServices("a", services); !scaled[0].Equal(services[0]) || scaled[0].Servers[0].UpperThreshold != 100 {
+		t.Errorf("expected services as they are without shares - %+v", scaled)
+	}
+
+	// a gets half the traffic, b and c a quarter each
+	fleet.Shares = map[string]float64{"a": 2}
+	tests := []struct {
+		director   string
+		thresholds [][2]int
+	}{
+		{"a", [][2]int{{150, 120}, {0, 0}, {2, 0}}},
+		{"b", [][2]int{{75, 60}, {0, 0}, {1, 0}}},
+	}
+	for _, test := range tests {
+		scaled := fleet.EcmpServices(test.director, services)
+		for j, thresholds := range test.thresholds {
+			server := scaled[0].Servers[j]
+			if server.UpperThreshold != thresholds[0] || server.LowerThreshold != thresholds[1] {
+				t.Errorf("%s: expected thresholds %v for %s - %+v", test.director, thresholds, server.Host, server)
+			}
+			// schedulers only weigh a director's servers against each other
+			if server.Weight != services[0].Servers[j].Weight {
+				t.Errorf("%s: expected the weight of %s left as it is - %+v", test.director, server.Host, server)
+			}
+		}
+		if *scaled[0].WeightBounds != *services[0].WeightBounds {
+			t.Errorf("%s: expected the weight bounds left as they are - %+v", test.director, scaled[0].WeightBounds)
+		}
+	}
+	if services[0].Servers[0].UpperThreshold != 100 {
+		t.Errorf("services should be left as they are - %+v", services[0])
+	}
+}
+
+func TestEcmpServicesMaxConns(t *testing.T) {
+	services := []Service{{Host: "10.0.0.1", Port: 80, Scheduler: "rr", MaxConns: 400, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 1, UpperThreshold: 3},
+	}}}
+	// a gets half the traffic, b and c a quarter each
+	fleet := Fleet{Directors: map[string]*Lvs{"a": nil, "b": nil, "c": nil}, Shares: map[string]float64{"a": 2}}
+
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+	if err := ipvs.Sync(fleet.EcmpServices("b", services)); err != nil {
+		t.Fatal(err)
+	}
+	// 400 * 3/4, split between the servers
+	for _, server := range simulator.Services()[0].Servers {
+		if server.UpperThreshold != 150 {
+			t.Errorf("expected MaxConns scaled before being split - %+v", server)
+		}
+	}
+
+	// d gets none of the traffic
+	fleet.Directors["d"], fleet.Shares["d"] = nil, 0
+	scaled := fleet.EcmpServices("d", services)
+	if scaled[0].MaxConns != 1 || scaled[0].Servers[1].UpperThreshold != 1 || scaled[0].Servers[0].UpperThreshold != 0 {
+		t.Errorf("expected limits kept at 1 with no share - %+v", scaled[0])
+	}
+}
@@ -0,0 +1,163 @@
+package lvs
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Elector elects a leader among controllers sharing a lock file, so
+	// several can run for redundancy while only the leader changes the
+	// table (see WithLeaderElection). The leader holds an advisory lock
+	// (flock, linux only) on the file until it stops or dies, followers
+	// retry taking it every Retry
+	Elector struct {
+		Path  string        // lock file, defaults to DefaultLeaderLock
+		Id    string        // identifies the leader in the lock file, defaults to hostname:pid
+		Retry time.Duration // defaults to 1s
+
+		OnElected func() // called once this controller leads
+		OnDemoted func() // called once it no longer does
+
+		mu     sync.Mutex
+		leader bool
+	}
+)
+
+var (
+	ErrNotLeader = errors.New("not the elected leader")
+
+	// DefaultLeaderLock is the lock file used by an Elector without a Path
+	DefaultLeaderLock = "/var/run/golang-lvs.leader"
+)
+
+// WithLeaderElection refuses the commands changing the table with
+// ErrNotLeader unless e elected this controller. Reading the table is
+// always allowed, so followers can keep up with it
+func WithLeaderElection(e *Elector) Option {
+	return func(i *Ipvs) {
+		i.exec.elector = e
+	}
+}
+
+// Run campaigns until stop is closed, then steps down if leading. Losing
+// the lock file (eg. it was deleted) steps down too, the election then
+// starts over
+func (e *Elector) Run(stop <-chan struct{}) error {
+	path, retry := e.path(), e.Retry
+	if retry <= 0 {
+		retry = time.Second
+	}
+	ticker := time.NewTicker(retry)
+	defer ticker.Stop()
+
+	var file *os.File
+	defer func() {
+		if file != nil {
+			e.release(file)
+		}
+	}()
+	for {
+		if file != nil && !holds(file, path) {
+			e.release(file)
+			file = nil
+		}
+		if file == nil {
+			var err error
+			file, err = e.campaign(path)
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsLeader reports whether this controller currently leads
+func (e *Elector) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Leader returns the id of the current (or last) leader from the lock file
+func (e *Elector) Leader() (string, error) {
+	bytes, err := os.ReadFile(e.path())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bytes)), nil
+}
+
+// campaign tries to take the lock, returning the held file when elected
+func (e *Elector) campaign(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := tryLock(file)
+	if err != nil || !locked {
+		file.Close()
+		return nil, err
+	}
+
+	file.Truncate(0)
+	file.WriteAt([]byte(e.id()+"\n"), 0)
+	e.setLeader(true)
+	return file, nil
+}
+
+func (e *Elector) release(file *os.File) {
+	e.setLeader(false)
+	unlock(file)
+	file.Close()
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+	if changed && leader && e.OnElected != nil {
+		e.OnElected()
+	}
+	if changed && !leader && e.OnDemoted != nil {
+		e.OnDemoted()
+	}
+}
+
+func (e *Elector) path() string {
+	if e.Path == "" {
+		return DefaultLeaderLock
+	}
+	return e.Path
+}
+
+func (e *Elector) id() string {
+	if e.Id != "" {
+		return e.Id
+	}
+	hostname, _ := os.Hostname()
+	return hostname + ":" + strconv.Itoa(os.Getpid())
+}
+
+// holds reports whether the locked file is still the one at path
+func holds(file *os.File, path string) bool {
+	held, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(held, current)
+}
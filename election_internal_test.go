@@ -0,0 +1,62 @@
+package lvs
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestElector(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("leader election is only supported on linux")
+	}
+	defer useFakeBackend()()
+
+	path := filepath.Join(t.TempDir(), "leader")
+	first := &Elector{Path: path, Id: "first", Retry: 10 * time.Millisecond}
+	second := &Elector{Path: path, Id: "second", Retry: 10 * time.Millisecond}
+	elected := make(chan string, 2)
+	second.OnElected = func() { elected <- "second" }
+
+	ipvs := NewIpvs(WithLeaderElection(second))
+	if err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80}); err != ErrNotLeader {
+		t.Errorf("expected ErrNotLeader before being elected, got %v", err)
+	}
+
+	stopFirst, stopSecond := make(chan struct{}), make(chan struct{})
+	defer close(stopSecond)
+	go first.Run(stopFirst)
+	waitFor(t, first.IsLeader)
+	go second.Run(stopSecond)
+	time.Sleep(50 * time.Millisecond)
+	if second.IsLeader() {
+		t.Fatal("only one controller should lead")
+	}
+	if leader, err := second.Leader(); err != nil || leader != "first" {
+		t.Errorf("expected first to lead, got '%s' (%v)", leader, err)
+	}
+
+	close(stopFirst)
+	select {
+	case <-elected:
+	case <-time.After(time.Second):
+		t.Fatal("second controller wasn't elected")
+	}
+	if first.IsLeader() {
+		t.Error("stopped controller still leads")
+	}
+	if err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80}); err != nil {
+		t.Errorf("leader should change the table - %v", err)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
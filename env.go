@@ -0,0 +1,60 @@
+package lvs
+
+import (
+	"context"
+	"os"
+)
+
+type (
+	// envKey carries the environment of backend commands in their context
+	envKey struct{}
+)
+
+var (
+	// RemotePath is the PATH of commands run on a remote director, where
+	// the local PATH means nothing
+	RemotePath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// WithEnv adds vars ("NAME=value") to the environment of every backend
+// command, see CommandEnv
+func WithEnv(vars ...string) Option {
+	return func(i *Ipvs) {
+		i.exec.env = append(i.exec.env, vars...)
+	}
+}
+
+// CommandEnv returns the environment a backend command run with ctx must
+// get, instead of inheriting the process'. It is minimal: LC_ALL=C and
+// LANG=C so ipvsadm's output is never localized, and the vars added with
+// WithEnv or DoEnv. Runners should pass it on along with a PATH, nil means
+// the command isn't a backend command and inherits the environment
+func CommandEnv(ctx context.Context) []string {
+	env, _ := ctx.Value(envKey{}).([]string)
+	return env
+}
+
+// withEnv has commands run with ctx get e's environment
+func (e *executor) withEnv(ctx context.Context) context.Context {
+	env := []string{"LC_ALL=C", "LANG=C"}
+	if e != nil {
+		env = append(env, e.env...)
+	}
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// inheritEnv has commands run with ctx inherit the environment, for the
+// commands reaching a remote director (such as ssh, which needs the agent)
+func inheritEnv(ctx context.Context) context.Context {
+	return context.WithValue(ctx, envKey{}, []string(nil))
+}
+
+// localEnv is the environment of a local command run with ctx, keeping the
+// PATH it is looked up in
+func localEnv(ctx context.Context) []string {
+	env := CommandEnv(ctx)
+	if env == nil {
+		return nil
+	}
+	return append([]string{"PATH=" + os.Getenv("PATH")}, env...)
+}
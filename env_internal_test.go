@@ -0,0 +1,39 @@
+package lvs
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestCommandEnv(t *testing.T) {
+	os.Setenv("LVS_TEST_LEAK", "leaked")
+	defer os.Unsetenv("LVS_TEST_LEAK")
+
+	ipvs := NewIpvs(WithEnv("LVS_TEST=1"))
+	out, err := ipvs.exec.run([]string{"sh", "-c", "echo $LC_ALL $LANG $LVS_TEST $LVS_TEST_LEAK"})
+	if err != nil {
+		t.Fatalf("failed to run - %v", err)
+	}
+	if string(out) != "C C 1\n" {
+		t.Errorf("expected a minimal environment, got '%s'", out)
+	}
+
+	client := &Lvs{ipvs: ipvs}
+	client.DoEnv([]string{"LVS_CALL=2"}, func(i *Ipvs) error {
+		out, err = i.exec.run([]string{"sh", "-c", "echo $LVS_TEST $LVS_CALL"})
+		return err
+	})
+	if string(out) != "1 2\n" {
+		t.Errorf("expected the call's vars, got '%s'", out)
+	}
+	if len(ipvs.exec.env) != 1 {
+		t.Errorf("call's vars should not stick - %v", ipvs.exec.env)
+	}
+
+	// commands outside of the backend inherit the environment
+	out, _, err = runOutput(context.Background(), "sh", "-c", "echo $LVS_TEST_LEAK")
+	if err != nil || string(out) != "leaked\n" {
+		t.Errorf("expected the inherited environment, got '%s' (%v)", out, err)
+	}
+}
@@ -0,0 +1,216 @@
+package lvs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Event describes a change in the state of a client's table or servers,
+	// carrying the table's Checksum once changed
+	Event struct {
+		// Sequence numbers the client's events from 1, see EventsSince
+		Sequence uint64    `json:"sequence"`
+		Type     string    `json:"type"`
+		Time     time.Time `json:"time"`
+		Service  *Service  `json:"service,omitempty"`
+		Server   *Server   `json:"server,omitempty"`
+		Message  string    `json:"message,omitempty"`
+		Checksum string    `json:"checksum,omitempty"`
+	}
+
+	// EventHandler is notified of the events of the clients it subscribed
+	// to. Events are delivered synchronously, so handlers must not block
+	EventHandler interface {
+		HandleEvent(Event)
+	}
+
+	// EventHandlerFunc adapts a func to an EventHandler
+	EventHandlerFunc func(Event)
+
+	// EventJournal is what a client's events since a sequence are served
+	// as, see NewEventsHandler
+	EventJournal struct {
+		Sequence uint64  `json:"sequence"` // of the last event published
+		Events   []Event `json:"events"`
+	}
+
+	// eventHandlers are the handlers subscribed to a client, and the
+	// journal of its last events
+	eventHandlers struct {
+		mu       sync.Mutex
+		handlers []EventHandler
+		sequence uint64
+		journal  []Event
+		changed  chan struct{} // closed and replaced whenever an event is published
+	}
+
+	eventsHandler struct {
+		lvs *Lvs
+	}
+)
+
+var (
+	// EventJournalSize is how many of their last events clients keep for
+	// EventsSince
+	EventJournalSize = 1024
+
+	// ErrEventsLost is returned for a sequence whose following events are
+	// no longer in the journal (or from before the client was created),
+	// the watcher must resync from the client's services
+	ErrEventsLost = errors.New("events since the sequence are no longer in the journal")
+)
+
+const (
+	EventServerDown     = "server-down"
+	EventServerUp       = "server-up"
+	EventServiceCreated = "service-created"
+	EventServiceRemoved = "service-removed"
+	EventSyncApplied    = "sync-applied"
+
+	// published by a HealthChecker entering or leaving panic mode
+	EventPanicEngaged    = "panic-engaged"
+	EventPanicDisengaged = "panic-disengaged"
+
+	// published by a Reconciler finding the table drifted, and once it
+	// synced it back
+	EventDriftDetected  = "drift-detected"
+	EventDriftCorrected = "drift-corrected"
+)
+
+func (f EventHandlerFunc) HandleEvent(e Event) {
+	f(e)
+}
+
+// Subscribe has h notified of the client's events
+func (l *Lvs) Subscribe(h EventHandler) {
+	l.events.mu.Lock()
+	defer l.events.mu.Unlock()
+	l.events.handlers = append(l.events.handlers, h)
+}
+
+// publish notifies every subscribed handler of e. It must not be called
+// while holding the client's lock, so handlers are free to use the client
+func (l *Lvs) publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Checksum == "" {
+		e.Checksum = l.Checksum()
+	}
+	l.events.mu.Lock()
+	l.events.sequence++
+	e.Sequence = l.events.sequence
+	l.events.journal = append(l.events.journal, e)
+	if trim := len(l.events.journal) - EventJournalSize; trim > 0 {
+		l.events.journal = append([]Event{}, l.events.journal[trim:]...)
+	}
+	if l.events.changed != nil {
+		close(l.events.changed)
+		l.events.changed = nil
+	}
+	handlers := append([]EventHandler{}, l.events.handlers...)
+	l.events.mu.Unlock()
+	for _, h := range handlers {
+		h.HandleEvent(e)
+	}
+}
+
+// EventsSince returns the client's events after sequence (0 for all those
+// kept), so a watcher reconnecting can catch up with what it missed. It
+// fails with ErrEventsLost when some of them are no longer kept
+func (l *Lvs) EventsSince(sequence uint64) (EventJournal, error) {
+	l.events.mu.Lock()
+	defer l.events.mu.Unlock()
+	return l.events.since(sequence)
+}
+
+func (h *eventHandlers) since(sequence uint64) (EventJournal, error) {
+	journal := EventJournal{Sequence: h.sequence, Events: make([]Event, 0, 0)}
+	oldest := h.sequence + 1 // of the events kept
+	if len(h.journal) > 0 {
+		oldest = h.journal[0].Sequence
+	}
+	if sequence > h.sequence || (sequence > 0 && sequence+1 < oldest) {
+		return journal, ErrEventsLost
+	}
+	for _, e := range h.journal {
+		if e.Sequence > sequence {
+			journal.Events = append(journal.Events, e)
+		}
+	}
+	return journal, nil
+}
+
+// waitEvents returns the events after sequence, waiting up to timeout for one
+// when there are none yet
+func (l *Lvs) waitEvents(sequence uint64, timeout time.Duration, done <-chan struct{}) (EventJournal, error) {
+	l.events.mu.Lock()
+	journal, err := l.events.since(sequence)
+	if err != nil || len(journal.Events) > 0 || timeout <= 0 {
+		l.events.mu.Unlock()
+		return journal, err
+	}
+	if l.events.changed == nil {
+		l.events.changed = make(chan struct{})
+	}
+	changed := l.events.changed
+	l.events.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-done:
+	}
+	return l.EventsSince(sequence)
+}
+
+// NewEventsHandler serves the events of l (DefaultLvs when nil) as an
+// EventJournal, those after ?since={sequence} waiting up to ?timeout=
+// seconds (30 by default) for one. A watcher passes the Sequence of the
+// last journal it got to catch up after reconnecting, and resyncs when
+// answered 410 Gone as the events it missed were dropped
+func NewEventsHandler(l *Lvs) http.Handler {
+	if l == nil {
+		l = DefaultLvs
+	}
+	return eventsHandler{lvs: l}
+}
+
+func (h eventsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	sequence := uint64(0)
+	if since := req.URL.Query().Get("since"); since != "" {
+		var err error
+		if sequence, err = strconv.ParseUint(since, 10, 64); err != nil {
+			writeError(rw, http.StatusBadRequest, err)
+			return
+		}
+	}
+	timeout := 30 * time.Second
+	if seconds, err := strconv.Atoi(req.URL.Query().Get("timeout")); err == nil && seconds >= 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	journal, err := h.lvs.waitEvents(sequence, timeout, req.Context().Done())
+	if err == ErrEventsLost {
+		writeError(rw, http.StatusGone, err)
+		return
+	}
+	writeJson(rw, http.StatusOK, journal)
+}
+
+// serviceEvent returns an event about service, without its exec so it can
+// be handed out
+func serviceEvent(eventType string, service Service, server *Server) Event {
+	service = copyServices([]Service{service})[0]
+	service.exec = nil
+	return Event{Type: eventType, Service: &service, Server: server}
+}
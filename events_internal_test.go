@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	events := make([]Event, 0, 0)
+	client.Subscribe(EventHandlerFunc(func(e Event) {
+		// handlers may use the client
+		client.Services()
+		events = append(events, e)
+	}))
+
+	service := Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}
+	client.AddService(service)
+	client.AddService(service)
+	client.Sync([]Service{service})
+
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		return errors.New("refused")
+	})}
+	checker.CheckOnce()
+	checker.CheckOnce()
+	checker.Check = checkFunc(func(service Service, server Server) error { return nil })
+	checker.CheckOnce()
+
+	client.RemoveService("tcp", "10.0.0.1", 80)
+	client.RemoveService("tcp", "10.0.0.1", 80)
+
+	expected := []string{EventServiceCreated, EventSyncApplied, EventServerDown, EventServerUp, EventServiceRemoved}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, events)
+	}
+	for i := range expected {
+		if events[i].Type != expected[i] || events[i].Time.IsZero() {
+			t.Errorf("expected %s, got %+v", expected[i], events[i])
+		}
+	}
+	if events[2].Server == nil || events[2].Server.Host != "10.0.1.1" || events[2].Message != "refused" || events[2].Service.Port != 80 {
+		t.Errorf("unexpected server-down event - %+v", events[2])
+	}
+}
+
+func TestEventJournal(t *testing.T) {
+	defer useFakeBackend()()
+	defer func(size int) { EventJournalSize = size }(EventJournalSize)
+	EventJournalSize = 3
+
+	client := New()
+	for j := 0; j < 5; j++ {
+		client.publish(Event{Type: EventSyncApplied})
+	}
+	journal, err := client.EventsSince(3)
+	if err != nil || journal.Sequence != 5 || len(journal.Events) != 2 || journal.Events[0].Sequence != 4 {
+		t.Errorf("expected events 4 and 5 - %+v %v", journal, err)
+	}
+	if _, err := client.EventsSince(1); err != ErrEventsLost {
+		t.Errorf("expected ErrEventsLost for dropped events, got %v", err)
+	}
+	if _, err := client.EventsSince(6); err != ErrEventsLost {
+		t.Errorf("expected ErrEventsLost for an unknown sequence, got %v", err)
+	}
+
+	// watchers wait for the next event
+	handler := NewEventsHandler(client)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", "/?since=5&timeout=5", nil))
+		done <- rw
+	}()
+	time.Sleep(10 * time.Millisecond)
+	client.publish(Event{Type: EventServiceCreated})
+	rw := <-done
+	journal = EventJournal{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &journal); err != nil || len(journal.Events) != 1 || journal.Events[0].Sequence != 6 {
+		t.Errorf("expected the next event - %d %s", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", "/?since=1", nil))
+	if rw.Code != http.StatusGone {
+		t.Errorf("expected 410 for dropped events, got %d", rw.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package lvs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+// printRunner prints the commands it is asked to run instead of running
+// them, answering `ipvsadm -S` with saved
+type printRunner struct {
+	saved string
+}
+
+func (p printRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	fmt.Println(exe, strings.Join(args, " "))
+	return nil
+}
+
+func (p printRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	fmt.Println(exe, strings.Join(args, " "))
+	fmt.Print(in)
+	return nil
+}
+
+func (p printRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	return []byte(p.saved), nil, nil
+}
+
+func ExampleIpvs_AddService() {
+	ipvs := lvs.NewIpvs(lvs.WithRunner(printRunner{}))
+	err := ipvs.AddService(lvs.Service{
+		Host:      "10.0.0.1",
+		Port:      80,
+		Type:      "tcp",
+		Scheduler: "wrr",
+		Servers: []lvs.Server{
+			{Host: "10.0.1.1", Port: 80, Weight: 2},
+			{Host: "10.0.1.2", Port: 80, Weight: 1},
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// ipvsadm -A -t 10.0.0.1:80 -s wrr
+	// ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -y 0 -x 0 -w 2
+	// ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -y 0 -x 0 -w 1
+}
+
+func ExampleLvs_Sync() {
+	client := lvs.New(lvs.WithRunner(printRunner{saved: `-A -t 10.0.0.1:80 -s wlc
+-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1
+-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -w 1
+`}))
+
+	// only the differences to what is applied are changed
+	err := client.Sync([]lvs.Service{{
+		Host: "10.0.0.1",
+		Port: 80,
+		Servers: []lvs.Server{
+			{Host: "10.0.1.1", Port: 80, Weight: 1},
+			{Host: "10.0.1.3", Port: 80, Weight: 1},
+		},
+	}})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.3:80 -g -y 0 -x 0 -w 1
+	// ipvsadm -d -t 10.0.0.1:80 -r 10.0.1.2:80
+}
+
+func ExampleIpvs_Drain() {
+	ipvs := lvs.NewIpvs(lvs.WithRunner(printRunner{saved: `-A -t 10.0.0.1:80 -s wlc
+-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 5
+`}))
+	if err := ipvs.Save(); err != nil {
+		fmt.Println(err)
+	}
+
+	// stop scheduling new connections, leaving existing ones alone
+	if err := ipvs.Drain(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// ipvsadm -e -t 10.0.0.1:80 -r 10.0.1.1:80 -g -y 0 -x 0 -w 0
+}
+
+type failingCheck string
+
+func (f failingCheck) Check(service lvs.Service, server lvs.Server) error {
+	if server.Host == string(f) {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func ExampleHealthChecker() {
+	client := lvs.New(lvs.WithRunner(printRunner{saved: `-A -t 10.0.0.1:80 -s wlc
+-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1
+-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -w 1
+`}))
+	if err := client.Save(); err != nil {
+		fmt.Println(err)
+	}
+
+	// usually lvs.TCPCheck{} or lvs.HTTPCheck{}, run with checker.Run(stop)
+	checker := &lvs.HealthChecker{Lvs: client, Check: failingCheck("10.0.1.2")}
+	checker.CheckOnce()
+	// Output:
+	// ipvsadm -e -t 10.0.0.1:80 -r 10.0.1.2:80 -g -y 0 -x 0 -w 0
+}
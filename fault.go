@@ -0,0 +1,135 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// FaultRunner wraps a Runner, injecting faults into the commands it
+	// runs so reconciliation logic can be tested against a flaky ipvsadm.
+	// Each command independently:
+	//   - is delayed by up to Delay, honoring its timeout
+	//   - fails with ErrInjectedFault without running, at FailureRate
+	//   - partially succeeds at PartialRate: a restore applies only some of
+	//     its lines, other changes are applied but reported as failed, and
+	//     output is cut short
+	FaultRunner struct {
+		Runner      Runner        // defaults to the local host
+		FailureRate float64       // from 0 to 1
+		PartialRate float64       // from 0 to 1
+		Delay       time.Duration // upper bound of the random delay
+
+		// Match picks the commands faults are injected into, all of them
+		// when nil
+		Match func(exe string, args []string) bool
+		// Rand makes runs reproducible when seeded, defaults to a time
+		// seeded source
+		Rand *rand.Rand
+
+		mu sync.Mutex
+	}
+
+	// fault is what happens to a single command
+	fault struct {
+		delay   time.Duration
+		fail    bool
+		partial bool
+		cut     float64 // fraction of the input or output kept when partial
+	}
+)
+
+var (
+	ErrInjectedFault = errors.New("injected fault")
+)
+
+func (r *FaultRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	f, err := r.inject(ctx, exe, args)
+	if err != nil {
+		return err
+	}
+	if err = r.runner().Execute(ctx, exe, args...); err != nil {
+		return err
+	}
+	if f.partial {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+func (r *FaultRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	f, err := r.inject(ctx, exe, args)
+	if err != nil {
+		return err
+	}
+	if !f.partial {
+		return r.runner().ExecuteStdin(ctx, in, exe, args...)
+	}
+	// always leave out at least the last line
+	lines := strings.SplitAfter(in, "\n")
+	if err = r.runner().ExecuteStdin(ctx, strings.Join(lines[:int(float64(len(lines)-1)*f.cut)], ""), exe, args...); err != nil {
+		return err
+	}
+	return ErrInjectedFault
+}
+
+func (r *FaultRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	f, err := r.inject(ctx, exe, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, stderr, err := r.runner().RunOutput(ctx, exe, args...)
+	if err == nil && f.partial {
+		stdout = stdout[:int(float64(len(stdout))*f.cut)]
+	}
+	return stdout, stderr, err
+}
+
+// inject picks the command's fault and waits out its delay, failing when
+// the command must not run
+func (r *FaultRunner) inject(ctx context.Context, exe string, args []string) (fault, error) {
+	if r.Match != nil && !r.Match(exe, args) {
+		return fault{}, nil
+	}
+	f := r.pick()
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return f, ctx.Err()
+		}
+	}
+	if f.fail {
+		return f, ErrInjectedFault
+	}
+	return f, nil
+}
+
+func (r *FaultRunner) pick() fault {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Rand == nil {
+		r.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	f := fault{}
+	if r.Delay > 0 {
+		f.delay = time.Duration(r.Rand.Int63n(int64(r.Delay)))
+	}
+	f.fail = r.Rand.Float64() < r.FailureRate
+	f.partial = !f.fail && r.Rand.Float64() < r.PartialRate
+	f.cut = r.Rand.Float64()
+	return f
+}
+
+func (r *FaultRunner) runner() Runner {
+	if r.Runner == nil {
+		return localRunner{}
+	}
+	return r.Runner
+}
@@ -0,0 +1,45 @@
+package lvs
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestFaultRunner(t *testing.T) {
+	simulator := NewSimulator()
+	faults := &FaultRunner{Runner: simulator, FailureRate: 1, Rand: rand.New(rand.NewSource(1))}
+	ipvs := NewIpvs(WithRunner(faults))
+
+	if err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80}); err != ErrInjectedFault {
+		t.Errorf("expected ErrInjectedFault, got %v", err)
+	}
+	if len(simulator.Services()) != 0 {
+		t.Errorf("failed command shouldn't run - %+v", simulator.Services())
+	}
+
+	// partial restores apply some of their lines and report failure
+	faults.FailureRate, faults.PartialRate = 0, 1
+	services := make([]Service, 0, 0)
+	for port := 1; port <= 10; port++ {
+		services = append(services, Service{Host: "10.0.0.1", Port: port})
+	}
+	if err := ipvs.Restore(services); err != ErrInjectedFault {
+		t.Errorf("expected ErrInjectedFault, got %v", err)
+	}
+	if applied := len(simulator.Services()); applied >= 10 {
+		t.Errorf("expected part of the restore to be applied, got %d services", applied)
+	}
+
+	// only matching commands are faulted
+	faults.Match = func(exe string, args []string) bool { return args[0] == "-R" }
+	if err := ipvs.AddService(Service{Host: "10.0.0.2", Port: 80}); err != nil {
+		t.Errorf("unmatched command shouldn't fail - %v", err)
+	}
+
+	// delays honor the commands' timeout
+	slow := NewIpvs(WithRunner(&FaultRunner{Runner: NewSimulator(), Delay: time.Hour}), WithTimeout(10*time.Millisecond))
+	if err := slow.AddService(Service{Host: "10.0.0.1", Port: 80}); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
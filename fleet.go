@@ -0,0 +1,96 @@
+package lvs
+
+import (
+	"sort"
+	"sync"
+)
+
+type (
+	// Fleet applies one set of services to many directors, eg. the nodes of
+	// an anycast/ecmp cluster that must stay identical
+	Fleet struct {
+		Directors map[string]*Lvs // clients by director name
+		// Shares are the relative shares of the ecmp traffic the directors
+		// get (eg. their weights on the routers), by director name, see
+		// EcmpServices. Directors not listed get 1
+		Shares map[string]float64
+	}
+
+	// FleetResult is the outcome of applying services to one director
+	FleetResult struct {
+		Director  string `json:"director"`
+		Err       error  `json:"-"`
+		Error     string `json:"error,omitempty"`
+		Converged bool   `json:"converged"` // whether the director's rules were read back matching
+	}
+)
+
+// Apply syncs services (scaled to each director's share, see EcmpServices)
+// to every director concurrently and reads each director's rules back to
+// verify it converged
+func (f Fleet) Apply(services []Service) []FleetResult {
+	results := make([]FleetResult, 0, len(f.Directors))
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for name, director := range f.Directors {
+		wg.Add(1)
+		go func(name string, director *Lvs) {
+			defer wg.Done()
+			result := FleetResult{Director: name}
+			result.Converged, result.Err = director.apply(f.EcmpServices(name, services))
+			if result.Err != nil {
+				result.Error = result.Err.Error()
+			}
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(name, director)
+	}
+	wg.Wait()
+	sort.Slice(results, func(a, b int) bool {
+		return results[a].Director < results[b].Director
+	})
+	return results
+}
+
+// Converged reports whether every result converged
+func Converged(results []FleetResult) bool {
+	for i := range results {
+		if !results[i].Converged {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Lvs) apply(services []Service) (bool, error) {
+	converged := false
+	err := l.Do(func(i *Ipvs) error {
+		err := i.Sync(services)
+		if err != nil {
+			return err
+		}
+		converged, err = i.Converged(services)
+		return err
+	})
+	return converged, err
+}
+
+// copyServices deep copies services so each director gets its own
+func copyServices(services []Service) []Service {
+	copied := make([]Service, len(services))
+	for i := range services {
+		copied[i] = services[i]
+		copied[i].Servers = append([]Server{}, services[i].Servers...)
+		if services[i].SchedulerOpts != nil {
+			opts := *services[i].SchedulerOpts
+			opts.Flags = append([]string{}, opts.Flags...)
+			copied[i].SchedulerOpts = &opts
+		}
+		if services[i].WeightBounds != nil {
+			bounds := *services[i].WeightBounds
+			copied[i].WeightBounds = &bounds
+		}
+	}
+	return copied
+}
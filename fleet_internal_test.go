@@ -0,0 +1,29 @@
+package lvs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFleetApply(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte(`-A -t 10.0.0.1:80 -s wlc
+-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1
+`)
+
+	fleet := Fleet{Directors: map[string]*Lvs{"b": New(WithNetns("b")), "a": New(WithNetns("a"))}}
+	services := []Service{{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}
+	results := fleet.Apply(services)
+	if len(results) != 2 || results[0].Director != "a" || results[1].Director != "b" {
+		t.Fatalf("expected a result per director in order - %+v", results)
+	}
+	if !Converged(results) {
+		t.Errorf("expected every director to converge - %+v", results)
+	}
+
+	fakeRunErr = errors.New("ssh: connect to host b: Connection refused")
+	results = fleet.Apply(services)
+	if Converged(results) || results[0].Err == nil || results[0].Error == "" {
+		t.Errorf("failures should be reported per director - %+v", results)
+	}
+}
@@ -0,0 +1,125 @@
+package lvs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Follower mirrors the table of an active director onto a backup by
+	// watching the active director's Api, so a takeover only requires
+	// claiming the vips. Only changes made through the active director's api
+	// are watched, anything else is picked up with the next change made
+	// through it
+	Follower struct {
+		Url          string            // base url of the active director's api, eg. https://10.0.0.10:8443
+		Lvs          *Lvs              // client for the backup's table, defaults to DefaultLvs
+		Client       *http.Client      // defaults to http.DefaultClient, set it up for tls if needed
+		Headers      map[string]string // eg. an Authorization header
+		WatchTimeout time.Duration     // how long each watch waits for a change, defaults to 30s
+		RetryDelay   time.Duration     // wait after a failure, defaults to 1s
+		OnError      func(error)
+
+		lvsOnce sync.Once
+	}
+)
+
+// Run mirrors the active director's table until stop is closed
+func (f *Follower) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	retry := f.RetryDelay
+	if retry <= 0 {
+		retry = time.Second
+	}
+	version := ""
+	for ctx.Err() == nil {
+		next, err := f.Poll(ctx, version)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if f.OnError != nil {
+				f.OnError(err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(retry):
+			}
+			continue
+		}
+		version = next
+	}
+}
+
+// Poll waits for the active director's table to differ from version (or
+// fetches it straight away when version is empty), syncs the backup's table
+// to it and returns its new version
+func (f *Follower) Poll(ctx context.Context, version string) (string, error) {
+	timeout := f.WatchTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	url := strings.TrimRight(f.Url, "/") + "/services"
+	if version != "" {
+		url += "?watch=" + version + "&timeout=" + strconv.Itoa(int(timeout.Seconds()))
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return version, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for name, value := range f.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return version, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return version, fmt.Errorf("active director answered with status %d", res.StatusCode)
+	}
+
+	next := res.Header.Get("X-Lvs-Version")
+	if next != "" && next == version {
+		// the watch timed out without a change
+		return version, nil
+	}
+	services := make([]Service, 0, 0)
+	if err := json.NewDecoder(res.Body).Decode(&services); err != nil {
+		return version, err
+	}
+
+	if err := f.lvs().Sync(services); err != nil {
+		return version, err
+	}
+	return next, nil
+}
+
+// lvs returns the client, defaulting Lvs once as Poll may be called
+// concurrently with Run
+func (f *Follower) lvs() *Lvs {
+	f.lvsOnce.Do(func() {
+		if f.Lvs == nil {
+			f.Lvs = DefaultLvs
+		}
+	})
+	return f.Lvs
+}
@@ -0,0 +1,63 @@
+package lvs
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFollower(t *testing.T) {
+	active := NewApi(NewIpvs(WithRunner(NewSimulator())))
+	server := httptest.NewServer(active)
+	defer server.Close()
+
+	backup := NewSimulator()
+	follower := &Follower{Url: server.URL, Lvs: New(WithRunner(backup)), RetryDelay: 10 * time.Millisecond}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		follower.Run(stop)
+		close(done)
+	}()
+
+	res, err := http.Post(server.URL+"/services", "application/json", bytes.NewBufferString(`{"host": "10.0.0.1", "port": 80, "servers": [{"host": "10.0.1.1", "port": 80}]}`))
+	if err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	res.Body.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(backup.Services()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	services := backup.Services()
+	if len(services) != 1 || len(services[0].Servers) != 1 {
+		t.Errorf("backup didn't follow the active director - %+v", services)
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Errorf("follower didn't stop")
+	}
+}
+
+func TestFollowerWatchTimeout(t *testing.T) {
+	active := NewApi(NewIpvs(WithRunner(NewSimulator())))
+	server := httptest.NewServer(active)
+	defer server.Close()
+
+	follower := &Follower{Url: server.URL, Lvs: New(WithRunner(NewSimulator())), WatchTimeout: time.Millisecond}
+	version, err := follower.Poll(context.Background(), "")
+	if err != nil || version == "" {
+		t.Fatalf("failed to poll - %q, %v", version, err)
+	}
+	next, err := follower.Poll(context.Background(), version)
+	if err != nil || next != version {
+		t.Errorf("expected the version to be unchanged, got %q - %v", next, err)
+	}
+}
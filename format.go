@@ -0,0 +1,113 @@
+package lvs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// FormatStyle is how Service.Format writes a service
+	FormatStyle int
+)
+
+const (
+	// FormatSave writes the service like `ipvsadm -S -n`, which
+	// `ipvsadm -R`, Restore and ParseSave read back
+	FormatSave FormatStyle = iota
+	// FormatTable writes the service as an aligned table like
+	// `ipvsadm -L -n`, which ParseList reads back
+	FormatTable
+)
+
+var (
+	// formatTableHeader heads FormatTable services in FormatServices
+	formatTableHeader = "Prot LocalAddress:Port Scheduler Flags\n" +
+		"  -> RemoteAddress:Port           Forward Weight\n"
+
+	formatTableType = map[string]string{
+		"tcp":    "TCP",
+		"udp":    "UDP",
+		"fwmark": "FWM",
+	}
+	formatTableForwarder = map[string]string{
+		"-g": "Route",
+		"-i": "Tunnel",
+		"-m": "Masq",
+	}
+)
+
+// Format writes the service and its servers in style, a line each. Unlike
+// String, which writes the arguments of the commands applying it, only what
+// differs from ipvsadm's defaults is written
+func (s Service) Format(style FormatStyle) string {
+	if style == FormatTable {
+		return s.formatTable()
+	}
+	flag, hostPort := ServiceTypeFlag[s.Type], s.getHostPort()
+	lines := []string{strings.Join(append([]string{"-A", flag, hostPort, "-s", ServiceSchedulerFlag[s.Scheduler]}, s.formatOptions()...), " ")}
+	for _, server := range s.Servers {
+		server = s.withDefaults(server)
+		args := []string{"-a", flag, hostPort, "-r", server.getHostPort(), ServerForwarderFlag[server.Forwarder], "-w", strconv.Itoa(server.Weight)}
+		if server.UpperThreshold > 0 {
+			args = append(args, "-x", strconv.Itoa(server.UpperThreshold))
+		}
+		if server.LowerThreshold > 0 {
+			args = append(args, "-y", strconv.Itoa(server.LowerThreshold))
+		}
+		lines = append(lines, strings.Join(args, " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (s Service) formatTable() string {
+	fields := []string{formatTableType[s.Type] + " ", s.getHostPort(), ServiceSchedulerFlag[s.Scheduler]}
+	if flags := s.getSchedFlags(); len(flags) > 0 {
+		fields = append(fields, "("+flags[1]+")")
+	}
+	if s.Persistence > 0 {
+		fields = append(fields, "persistent", strconv.Itoa(s.Persistence))
+		if s.hasNetmask() {
+			fields = append(fields, "mask", s.Netmask)
+		}
+		if s.PersistenceEngine != "" {
+			fields = append(fields, "pe", s.PersistenceEngine)
+		}
+	}
+	if s.OnePacket {
+		fields = append(fields, "ops")
+	}
+	lines := []string{strings.Join(fields, " ")}
+	for _, server := range s.Servers {
+		server = s.withDefaults(server)
+		lines = append(lines, fmt.Sprintf("  -> %-28s %-7s %d", server.getHostPort(), formatTableForwarder[ServerForwarderFlag[server.Forwarder]], server.Weight))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// FormatServices writes services in style, under a header for FormatTable
+func FormatServices(services []Service, style FormatStyle) string {
+	out := ""
+	if style == FormatTable {
+		out = formatTableHeader
+	}
+	for _, service := range services {
+		out += service.Format(style)
+	}
+	return out
+}
+
+// formatOptions returns the options of s ipvsadm writes, leaving out the
+// netmask of services that aren't persistent or group single addresses
+func (s Service) formatOptions() []string {
+	options := s.getPersistence()
+	if s.Persistence > 0 && s.hasNetmask() {
+		options = append(options, s.getNetmask()...)
+	}
+	options = append(options, s.getSchedFlags()...)
+	return append(options, s.getOnePacket()...)
+}
+
+func (s Service) hasNetmask() bool {
+	return s.Netmask != "" && s.Netmask != "255.255.255.255" && s.Netmask != "128"
+}
@@ -0,0 +1,54 @@
+package lvs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for _, version := range IpvsadmCompatibility {
+		save, err := os.ReadFile(filepath.Join("testdata", "ipvsadm", version.Version, "save.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		services, err := ParseSave(string(save))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if formatted := FormatServices(services, FormatSave); formatted != string(save) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", version.Version, save, formatted)
+		}
+
+		listed, err := ParseList(FormatServices(services, FormatTable))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listed) != len(services) {
+			t.Fatalf("%s: expected the table to list every service - %+v", version.Version, listed)
+		}
+		for j := range services {
+			if !listed[j].Equal(services[j]) {
+				t.Errorf("%s: expected the table to list %+v, got %+v", version.Version, services[j], listed[j])
+			}
+		}
+	}
+
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 443, Scheduler: "sh", Persistence: 600, Netmask: "255.255.255.0",
+		SchedulerOpts: &SchedulerOpts{Fallback: true},
+		Servers:       []Server{{Host: "10.0.1.1", Port: 8443, Forwarder: "m", Weight: 2, UpperThreshold: 100}}}
+	expected := "-A -t 10.0.0.1:443 -s sh -p 600 -M 255.255.255.0 -b sh-fallback\n" +
+		"-a -t 10.0.0.1:443 -r 10.0.1.1:8443 -m -w 2 -x 100\n"
+	if formatted := service.Format(FormatSave); formatted != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, formatted)
+	}
+	expected = "TCP  10.0.0.1:443 sh (sh-fallback) persistent 600 mask 255.255.255.0\n" +
+		"  -> 10.0.1.1:8443                Masq    2\n"
+	if formatted := service.Format(FormatTable); formatted != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, formatted)
+	}
+	if !strings.HasPrefix(service.String(), "-A -t 10.0.0.1:443 -s sh -p 600 -M 255.255.255.0 -b sh-fallback") {
+		t.Errorf("String changed - %s", service.String())
+	}
+}
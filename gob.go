@@ -0,0 +1,38 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+func (i *Ipvs) FromGob(data []byte) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(i)
+}
+
+func (i Ipvs) ToGob() ([]byte, error) {
+	return toGob(i)
+}
+
+func (s *Service) FromGob(data []byte) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(s)
+}
+
+func (s Service) ToGob() ([]byte, error) {
+	return toGob(s)
+}
+
+func (s *Server) FromGob(data []byte) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(s)
+}
+
+func (s Server) ToGob() ([]byte, error) {
+	return toGob(s)
+}
+
+func toGob(v interface{}) ([]byte, error) {
+	out := bytes.Buffer{}
+	if err := gob.NewEncoder(&out).Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
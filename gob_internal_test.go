@@ -0,0 +1,53 @@
+package lvs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGob(t *testing.T) {
+	ipvs := Ipvs{
+		MulticastInterface: "eth1",
+		Syncid:             3,
+		Services: []Service{{
+			Host:          "10.0.0.1",
+			Port:          443,
+			Scheduler:     "sh",
+			SchedulerOpts: &SchedulerOpts{Fallback: true},
+			Servers:       []Server{{Host: "10.0.1.1", Port: 443, Weight: 0, Zone: "east"}},
+			exec:          &executor{},
+		}},
+	}
+	data, err := ipvs.ToGob()
+	if err != nil {
+		t.Fatalf("failed to encode - %v", err)
+	}
+	decoded := Ipvs{}
+	if err := decoded.FromGob(data); err != nil {
+		t.Fatalf("failed to decode - %v", err)
+	}
+	ipvs.Services[0].exec = nil
+	if !reflect.DeepEqual(ipvs, decoded) {
+		t.Errorf("expected %+v, got %+v", ipvs, decoded)
+	}
+
+	server := Server{}
+	data, _ = Server{Host: "10.0.1.2", Port: 80, Weight: 5}.ToGob()
+	if err := server.FromGob(data); err != nil || server.Weight != 5 {
+		t.Errorf("unexpected server %+v - %v", server, err)
+	}
+}
+
+func TestGobSize(t *testing.T) {
+	// gob carries its type information once, so larger tables are smaller
+	// than their json
+	ipvs := Ipvs{}
+	for port := 1; port <= 100; port++ {
+		ipvs.Services = append(ipvs.Services, Service{Host: "10.0.0.1", Port: port, Servers: []Server{{Host: "10.0.1.1", Port: port, Weight: 1}}})
+	}
+	data, _ := ipvs.ToGob()
+	json, _ := ipvs.ToJson()
+	if len(data) >= len(json)/2 {
+		t.Errorf("gob (%d bytes) should be far smaller than json (%d bytes)", len(data), len(json))
+	}
+}
@@ -0,0 +1,471 @@
+package lvs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// HealthCheck checks whether a server of service is able to take traffic
+	HealthCheck interface {
+		Check(service Service, server Server) error
+	}
+
+	// TCPCheck passes when a tcp connection to the server can be opened
+	TCPCheck struct {
+		Timeout time.Duration // defaults to 2s
+	}
+
+	// HTTPCheck passes when a GET of Path on the server returns Status
+	HTTPCheck struct {
+		Path    string        // defaults to /
+		Status  int           // defaults to 200
+		Timeout time.Duration // defaults to 2s
+	}
+
+	// ServerHealth is the last known health of a server
+	ServerHealth struct {
+		Service   string    `json:"service"` // type and host:port of the owning service
+		Host      string    `json:"host"`
+		Port      int       `json:"port"`
+		Weight    int       `json:"weight"` // configured weight, restored when the server recovers
+		Healthy   bool      `json:"healthy"`
+		LastCheck time.Time `json:"last_check"`
+		LastError string    `json:"last_error,omitempty"`
+		// LastStateChange is when the server last went down or up, zero
+		// while it has been healthy since it was first checked
+		LastStateChange time.Time `json:"last_state_change"`
+		// Pinned is set while a failing server is kept in rotation to
+		// honor its service's MinServers
+		Pinned bool `json:"pinned,omitempty"`
+		// LastRTT is how long the last passing check took, RTT its
+		// exponentially weighted moving average
+		LastRTT time.Duration `json:"last_rtt"`
+		RTT     time.Duration `json:"rtt"`
+
+		successes int
+		failures  int
+	}
+
+	// HealthChecker periodically checks every server of the client's
+	// services, quiescing (weight 0) servers that fail and restoring their
+	// weight once they recover
+	HealthChecker struct {
+		Lvs      *Lvs              // defaults to DefaultLvs
+		Check    HealthCheck       // defaults to a TCPCheck
+		Interval time.Duration     // defaults to 5s
+		Fall     int               // consecutive failures before a server is taken out, defaults to 1
+		Rise     int               // consecutive successes before a server is put back, defaults to 1
+		Latency  *LatencyWeighting // derives weights from check RTTs when set
+		// Schedules check the servers of some services on their own
+		// schedule, by service Name or key (eg. "-t 10.0.0.1:80"), see Run
+		Schedules map[string]HealthSchedule
+
+		mu        sync.Mutex
+		health    map[string]*ServerHealth
+		panicking bool // every server is kept in rotation, see Panic
+	}
+)
+
+var (
+	HealthCheckTimeout = 2 * time.Second
+
+	// errMinServers keeps a failing server in rotation for MinServers
+	errMinServers = errors.New("service is at its minimum servers")
+)
+
+func (c TCPCheck) Check(service Service, server Server) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = HealthCheckTimeout
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(server.Host, strconv.Itoa(server.Port)), timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (c HTTPCheck) Check(service Service, server Server) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = HealthCheckTimeout
+	}
+	path, status := c.Path, c.Status
+	if path == "" {
+		path = "/"
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	client := http.Client{Timeout: timeout}
+	res, err := client.Get("http://" + net.JoinHostPort(server.Host, strconv.Itoa(server.Port)) + path)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != status {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// Run checks the servers every Interval until stop is closed. With
+// Schedules, each server is checked when due on its service's schedule
+// instead (Interval for services without one)
+func (h *HealthChecker) Run(stop <-chan struct{}) {
+	if len(h.Schedules) > 0 {
+		h.runScheduled(stop)
+		return
+	}
+	ticker := time.NewTicker(h.interval())
+	defer ticker.Stop()
+	for {
+		h.CheckOnce()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecker) interval() time.Duration {
+	if h.Interval <= 0 {
+		return 5 * time.Second
+	}
+	return h.Interval
+}
+
+// CheckOnce checks every server concurrently and updates their weights
+func (h *HealthChecker) CheckOnce() {
+	h.check(func(Service, Server) bool { return true })
+}
+
+// check checks the servers due concurrently and updates their weights
+func (h *HealthChecker) check(due func(Service, Server) bool) {
+	if h.Lvs == nil {
+		h.Lvs = DefaultLvs
+	}
+	if h.Check == nil {
+		h.Check = TCPCheck{}
+	}
+
+	services := h.Lvs.Services()
+	results := make(map[string]error)
+	rtts := make(map[string]time.Duration)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for i := range services {
+		check := h.checkFor(services[i])
+		for j := range services[i].Servers {
+			if !due(services[i], services[i].Servers[j]) {
+				continue
+			}
+			wg.Add(1)
+			go func(service Service, server Server) {
+				defer wg.Done()
+				start := time.Now()
+				err := check.Check(service, server)
+				rtt := time.Since(start)
+				mu.Lock()
+				results[healthKey(service, server)] = err
+				rtts[healthKey(service, server)] = rtt
+				mu.Unlock()
+			}(services[i], services[i].Servers[j])
+		}
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	if h.health == nil {
+		h.health = make(map[string]*ServerHealth)
+	}
+	seen := make(map[string]bool)
+	events := make([]Event, 0, 0)
+	for i := range services {
+		for j := range services[i].Servers {
+			key := healthKey(services[i], services[i].Servers[j])
+			seen[key] = true
+			if _, ok := results[key]; !ok {
+				continue
+			}
+			if eventType := h.record(services[i], services[i].Servers[j], results[key], rtts[key]); eventType != "" {
+				server := services[i].Servers[j]
+				event := serviceEvent(eventType, services[i], &server)
+				if results[key] != nil {
+					event.Message = results[key].Error()
+				}
+				events = append(events, event)
+			}
+		}
+	}
+	// forget servers that were removed
+	for key := range h.health {
+		if !seen[key] {
+			delete(h.health, key)
+		}
+	}
+	if h.Latency != nil && !h.panicking && len(results) > 0 {
+		for i := range services {
+			h.weighByLatency(services[i])
+		}
+	}
+	checked := make(map[string]ServerHealth, len(h.health))
+	for key, health := range h.health {
+		checked[key] = *health
+	}
+	h.mu.Unlock()
+
+	if len(results) > 0 {
+		h.stampChecked(checked)
+	}
+	for _, event := range events {
+		h.Lvs.publish(event)
+	}
+}
+
+// stampChecked sets LastChecked and LastStateChange on the client's servers
+// from their health, and on their services from the latest of their servers
+func (h *HealthChecker) stampChecked(checked map[string]ServerHealth) {
+	h.Lvs.Do(func(i *Ipvs) error {
+		for j := range i.Services {
+			service := &i.Services[j]
+			for k := range service.Servers {
+				health, ok := checked[healthKey(*service, service.Servers[k])]
+				if !ok {
+					continue
+				}
+				server := &service.Servers[k]
+				lastCheck := health.LastCheck
+				server.LastChecked = &lastCheck
+				service.LastChecked = latest(service.LastChecked, server.LastChecked)
+				if !health.LastStateChange.IsZero() {
+					lastStateChange := health.LastStateChange
+					server.LastStateChange = &lastStateChange
+					service.LastStateChange = latest(service.LastStateChange, server.LastStateChange)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Health returns the last known health of every server
+func (h *HealthChecker) Health() []ServerHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health := make([]ServerHealth, 0, len(h.health))
+	for _, server := range h.health {
+		health = append(health, *server)
+	}
+	return health
+}
+
+// ServerHealth returns the last known health of a server of service
+func (h *HealthChecker) ServerHealth(service Service, server Server) (ServerHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	health, ok := h.health[healthKey(service, server)]
+	if !ok {
+		return ServerHealth{}, false
+	}
+	return *health, true
+}
+
+// Panic engages (or disengages) panic mode, for when the checks themselves
+// can't be trusted, such as during a monitoring outage. While engaged, every
+// server is put back in rotation with its configured weight and check
+// results no longer change weights. Once disengaged, servers that are still
+// failing are quiesced by the next check. An EventPanicEngaged or
+// EventPanicDisengaged is published when the mode changes
+func (h *HealthChecker) Panic(on bool) error {
+	if h.Lvs == nil {
+		h.Lvs = DefaultLvs
+	}
+
+	h.mu.Lock()
+	if h.panicking == on {
+		h.mu.Unlock()
+		return nil
+	}
+	h.panicking = on
+	var err error
+	if on {
+		services := h.Lvs.Services()
+		for i := range services {
+			for j := range services[i].Servers {
+				health, ok := h.health[healthKey(services[i], services[i].Servers[j])]
+				if !ok || health.Healthy {
+					continue
+				}
+				if err2 := h.setWeight(services[i], services[i].Servers[j], health.Weight, "panic mode engaged"); err2 != nil {
+					if err == nil {
+						err = err2
+					}
+					continue
+				}
+				health.Healthy = true
+				health.LastStateChange = time.Now()
+			}
+		}
+	}
+	h.mu.Unlock()
+
+	eventType := EventPanicDisengaged
+	if on {
+		eventType = EventPanicEngaged
+	}
+	h.Lvs.publish(Event{Type: eventType})
+	return err
+}
+
+// Panicking reports whether panic mode is engaged
+func (h *HealthChecker) Panicking() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.panicking
+}
+
+// record updates the health of a server with a check result and how long
+// the check took, changing its weight when it crosses the Fall or Rise
+// threshold. The event to publish is returned when it did
+func (h *HealthChecker) record(service Service, server Server, err error, rtt time.Duration) string {
+	key := healthKey(service, server)
+	health, ok := h.health[key]
+	if !ok {
+		health = &ServerHealth{
+			Service: service.key(),
+			Host:    server.Host,
+			Port:    server.Port,
+			Weight:  server.Weight,
+			Healthy: true,
+		}
+		h.health[key] = health
+	}
+	if health.Healthy {
+		// track weight changes made while the server is in rotation
+		health.Weight = server.Weight
+	}
+
+	health.LastCheck = time.Now()
+	health.LastError = ""
+	if err != nil {
+		health.LastError = err.Error()
+		health.failures++
+		health.successes = 0
+	} else {
+		health.successes++
+		health.failures = 0
+		health.LastRTT = rtt
+		health.RTT = h.Latency.smooth(health.RTT, rtt)
+	}
+
+	if err == nil {
+		health.Pinned = false
+	}
+	if h.panicking {
+		// keep counting, servers still failing are taken out once panic
+		// mode is left
+		return ""
+	}
+	switch {
+	case health.Healthy && health.failures >= threshold(h.Fall):
+		switch h.setWeight(service, server, 0, health.LastError) {
+		case nil:
+			health.Healthy = false
+			health.Pinned = false
+			health.LastStateChange = health.LastCheck
+			return EventServerDown
+		case errMinServers:
+			health.Pinned = true
+		}
+	case !health.Healthy && health.successes >= threshold(h.Rise):
+		if h.setWeight(service, server, health.Weight, "recovered") == nil {
+			health.Healthy = true
+			health.LastStateChange = health.LastCheck
+			return EventServerUp
+		}
+	}
+	return ""
+}
+
+// setWeight changes the weight of a server, for reason
+func (h *HealthChecker) setWeight(service Service, server Server, weight int, reason string) error {
+	return h.Lvs.Do(func(i *Ipvs) error {
+		current := i.FindService(service.Type, service.Host, service.Port)
+		if current == nil {
+			return NotFound
+		}
+		server := current.FindServer(server.Host, server.Port)
+		if server == nil {
+			return NotFound
+		}
+		if weight == 0 && server.Weight > 0 {
+			// fail open rather than leave the service short of servers
+			inRotation := 0
+			for j := range current.Servers {
+				if current.Servers[j].Weight > 0 {
+					inRotation++
+				}
+			}
+			if inRotation-1 < current.MinServers {
+				return errMinServers
+			}
+		}
+		edit := *server
+		edit.Weight = weight
+		_, err := current.editServer(edit, WeightChange{Source: WeightSourceHealth, Reason: reason})
+		return err
+	})
+}
+
+// adjustWeights returns a copy of services with the weights h set in place
+// of theirs: 0 for the servers it quiesced and, with Latency, the weights
+// derived for the rest. Quiesced servers recover to the weight given by
+// services. Called with h.mu held
+func (h *HealthChecker) adjustWeights(services []Service) []Service {
+	services = copyServices(services)
+	for i := range services {
+		normalized, err := services[i].Normalize()
+		if err != nil {
+			// left for the sync to report
+			continue
+		}
+		if normalized.Type == "" {
+			normalized.Type = "tcp"
+		}
+		for j := range normalized.Servers {
+			server := normalized.Servers[j]
+			health, ok := h.health[healthKey(normalized, server)]
+			if !ok || server.Weight == 0 {
+				continue
+			}
+			switch {
+			case !health.Healthy:
+				health.Weight = server.Weight
+				services[i].Servers[j].Weight = 0
+			case h.Latency != nil && !h.panicking && !health.Pinned && health.Weight > 0:
+				services[i].Servers[j].Weight = health.Weight
+			}
+		}
+	}
+	return services
+}
+
+func healthKey(service Service, server Server) string {
+	return service.key() + " " + server.getHostPort()
+}
+
+func threshold(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
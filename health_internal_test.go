@@ -0,0 +1,166 @@
+package lvs
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type checkFunc func(Service, Server) error
+
+func (c checkFunc) Check(service Service, server Server) error {
+	return c(service, server)
+}
+
+func TestHealthChecker(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	err := client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 5},
+		{Host: "10.0.1.2", Port: 80, Weight: 5},
+	}})
+	if err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+
+	down := "10.0.1.2"
+	checker := &HealthChecker{Lvs: client, Fall: 2, Check: checkFunc(func(service Service, server Server) error {
+		if server.Host == down {
+			return errors.New("connection refused")
+		}
+		return nil
+	})}
+
+	checker.CheckOnce()
+	if weight := client.Services()[0].Servers[1].Weight; weight != 5 {
+		t.Errorf("server should stay in rotation until Fall is reached, weight %d", weight)
+	}
+	checker.CheckOnce()
+	if weight := client.Services()[0].Servers[1].Weight; weight != 0 {
+		t.Errorf("failing server should be quiesced, weight %d", weight)
+	}
+
+	rw := httptest.NewRecorder()
+	NewStatusHandler(client, checker).ServeHTTP(rw, httptest.NewRequest("GET", "/?format=json", nil))
+	if !strings.Contains(rw.Body.String(), `"health":"down","last_check"`) || !strings.Contains(rw.Body.String(), `"last_error":"connection refused"`) {
+		t.Errorf("status should report the down server - %s", rw.Body)
+	}
+
+	down = ""
+	checker.CheckOnce()
+	if weight := client.Services()[0].Servers[1].Weight; weight != 5 {
+		t.Errorf("recovered server should get its weight back, weight %d", weight)
+	}
+
+	rw = httptest.NewRecorder()
+	NewStatusHandler(client, checker).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rw.Body.String(), `<tr class="up"><td>10.0.1.2:80</td>`) {
+		t.Errorf("html status should list the server as up - %s", rw.Body)
+	}
+}
+
+func TestHealthCheckerMinServers(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, MinServers: 1, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 5},
+		{Host: "10.0.1.2", Port: 80, Weight: 5},
+	}})
+
+	failing := true
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	})}
+	checker.CheckOnce()
+
+	inRotation := 0
+	for _, server := range client.Services()[0].Servers {
+		if server.Weight > 0 {
+			inRotation++
+		}
+	}
+	if inRotation != 1 {
+		t.Errorf("expected MinServers to keep 1 server in rotation, got %d", inRotation)
+	}
+	pinned := 0
+	for _, health := range checker.Health() {
+		if health.Pinned {
+			pinned++
+			if !health.Healthy || health.LastError == "" {
+				t.Errorf("pinned server should stay in rotation with its error - %+v", health)
+			}
+		}
+	}
+	if pinned != 1 {
+		t.Errorf("expected 1 pinned server, got %d", pinned)
+	}
+
+	failing = false
+	checker.CheckOnce()
+	for _, health := range checker.Health() {
+		if health.Pinned || !health.Healthy {
+			t.Errorf("recovered server still pinned - %+v", health)
+		}
+	}
+	for _, server := range client.Services()[0].Servers {
+		if server.Weight != 5 {
+			t.Errorf("server not back in rotation - %+v", server)
+		}
+	}
+}
+
+func TestHealthCheckerPanic(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 5},
+		{Host: "10.0.1.2", Port: 80, Weight: 5},
+	}})
+	events := make([]string, 0, 0)
+	client.Subscribe(EventHandlerFunc(func(e Event) {
+		events = append(events, e.Type)
+	}))
+
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		return errors.New("monitoring is down")
+	})}
+	checker.CheckOnce()
+	for _, server := range client.Services()[0].Servers {
+		if server.Weight != 0 {
+			t.Fatalf("failing server should be quiesced - %+v", server)
+		}
+	}
+
+	if err := checker.Panic(true); err != nil {
+		t.Fatalf("failed to engage panic mode - %v", err)
+	}
+	checker.CheckOnce()
+	for _, server := range client.Services()[0].Servers {
+		if server.Weight != 5 {
+			t.Errorf("panic mode should put every server back in rotation - %+v", server)
+		}
+	}
+	if !checker.Panicking() {
+		t.Error("panic mode not reported")
+	}
+
+	checker.Panic(false)
+	checker.CheckOnce()
+	for _, server := range client.Services()[0].Servers {
+		if server.Weight != 0 {
+			t.Errorf("failing server should be quiesced once panic mode is left - %+v", server)
+		}
+	}
+
+	expected := []string{EventServerDown, EventServerDown, EventPanicEngaged, EventPanicDisengaged, EventServerDown, EventServerDown}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected events - %v", events)
+	}
+}
@@ -0,0 +1,99 @@
+package lvs
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// WeightChange records a change of a server's weight, for analysing
+	// traffic shifts after an incident
+	WeightChange struct {
+		Time    time.Time `json:"time"`
+		Service string    `json:"service"` // type and host:port of the owning service
+		Host    string    `json:"host"`
+		Port    int       `json:"port"`
+		From    int       `json:"from"`
+		To      int       `json:"to"`
+		Source  string    `json:"source,omitempty"` // what made the change, eg. WeightSourceHealth
+		Who     string    `json:"who,omitempty"`    // who asked for it, eg. the api client's address
+		Reason  string    `json:"reason,omitempty"`
+	}
+
+	// weightHistory keeps the last weight changes of every server
+	weightHistory struct {
+		mu      sync.Mutex
+		size    int
+		changes map[string][]WeightChange
+	}
+)
+
+const (
+	WeightSourceHealth = "health"
+	WeightSourceApi    = "api"
+	WeightSourceSync   = "sync"
+)
+
+var (
+	// DefaultWeightHistory is how many weight changes are kept per server
+	DefaultWeightHistory = 32
+)
+
+// WithWeightHistory keeps the last size weight changes of every server
+// rather than DefaultWeightHistory, 0 keeps none
+func WithWeightHistory(size int) Option {
+	if size <= 0 {
+		size = -1
+	}
+	return func(i *Ipvs) {
+		i.exec.history.size = size
+	}
+}
+
+// WeightHistory returns the last weight changes of a server, oldest first
+func (s Service) WeightHistory(host string, port int) []WeightChange {
+	if s.exec == nil {
+		return []WeightChange{}
+	}
+	return s.exec.history.get(s.key() + " " + Server{Host: canonicalHost(host), Port: port}.getHostPort())
+}
+
+// recordWeight records a server's weight changing from from to to, made
+// for cause (its Source, Who and Reason)
+func (e *executor) recordWeight(cause WeightChange, service Service, server Server, from, to int) {
+	if e == nil || from == to {
+		return
+	}
+	change := cause
+	change.Time = time.Now()
+	change.Service = service.key()
+	change.Host, change.Port = server.Host, server.Port
+	change.From, change.To = from, to
+	e.history.add(change.Service+" "+server.getHostPort(), change)
+}
+
+func (h *weightHistory) add(key string, change WeightChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	size := h.size
+	if size == 0 {
+		size = DefaultWeightHistory
+	}
+	if size < 0 {
+		return
+	}
+	if h.changes == nil {
+		h.changes = make(map[string][]WeightChange)
+	}
+	changes := append(h.changes[key], change)
+	if len(changes) > size {
+		changes = append([]WeightChange{}, changes[len(changes)-size:]...)
+	}
+	h.changes[key] = changes
+}
+
+func (h *weightHistory) get(key string) []WeightChange {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]WeightChange{}, h.changes[key]...)
+}
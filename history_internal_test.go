@@ -0,0 +1,76 @@
+package lvs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWeightHistory(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := &Lvs{ipvs: NewIpvs(WithWeightHistory(2))}
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 5},
+		{Host: "10.0.1.2", Port: 80, Weight: 5},
+	}})
+
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		if server.Host == "10.0.1.1" {
+			return errors.New("connection refused")
+		}
+		return nil
+	})}
+	checker.CheckOnce()
+
+	history := client.Services()[0].WeightHistory("10.0.1.1", 80)
+	if len(history) != 1 {
+		t.Fatalf("expected 1 change, got %+v", history)
+	}
+	change := history[0]
+	if change.From != 5 || change.To != 0 || change.Source != WeightSourceHealth || change.Reason != "connection refused" || change.Time.IsZero() {
+		t.Errorf("unexpected change - %+v", change)
+	}
+	if len(client.Services()[0].WeightHistory("10.0.1.2", 80)) != 0 {
+		t.Error("unchanged server should have no history")
+	}
+
+	api := NewClientApi(client)
+	req := httptest.NewRequest("PUT", "/services/tcp/10.0.0.1/80/servers/10.0.1.1/80", strings.NewReader(`{"weight":3}`))
+	req.RemoteAddr = "192.168.0.9:4000"
+	api.ServeHTTP(httptest.NewRecorder(), req)
+	fakeRunOutput = []byte("-A -t 10.0.0.1:80 -s wlc\n-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 3\n-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -w 5\n")
+	client.Sync([]Service{{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 7},
+		{Host: "10.0.1.2", Port: 80, Weight: 5},
+	}}})
+
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("GET", "/services/tcp/10.0.0.1/80/servers/10.0.1.1/80/history", nil))
+	history = nil
+	if err := json.NewDecoder(rw.Body).Decode(&history); err != nil {
+		t.Fatalf("failed to decode history - %v", err)
+	}
+	// bounded to the last 2 changes
+	if len(history) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", history)
+	}
+	if history[0].Source != WeightSourceApi || history[0].Who != "192.168.0.9:4000" || history[0].From != 0 || history[0].To != 3 {
+		t.Errorf("unexpected api change - %+v", history[0])
+	}
+	if history[1].Source != WeightSourceSync || history[1].From != 3 || history[1].To != 7 {
+		t.Errorf("unexpected sync change - %+v", history[1])
+	}
+
+	// a sync made through the api is attributed to the api
+	fakeRunOutput = []byte("-A -t 10.0.0.1:80 -s wlc\n-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 7\n-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -w 5\n")
+	req = httptest.NewRequest("PUT", "/services", strings.NewReader(`[{"host":"10.0.0.1","port":80,"servers":[{"host":"10.0.1.1","port":80,"weight":2},{"host":"10.0.1.2","port":80,"weight":5}]}]`))
+	req.RemoteAddr = "192.168.0.7:4000"
+	api.ServeHTTP(httptest.NewRecorder(), req)
+	history = client.Services()[0].WeightHistory("10.0.1.1", 80)
+	if len(history) != 2 || history[1].Source != WeightSourceApi || history[1].Who != "192.168.0.7:4000" || history[1].To != 2 {
+		t.Errorf("unexpected api sync change - %+v", history)
+	}
+}
@@ -0,0 +1,72 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	InterfaceAddressMissing = errors.New("Unable to find an address for the interface")
+)
+
+// resolveHost returns host normalized when it is already an ip (or a
+// fwmark), otherwise it is treated as an interface name or `interface:label`
+// and resolved to the primary address currently assigned to it, or failing
+// that as a hostname
+func (e *executor) resolveHost(host string) (string, error) {
+	host, err := NormalizeHost(host)
+	if err != nil {
+		return host, err
+	}
+	if host == "" || net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if _, err := strconv.Atoi(host); err == nil {
+		return host, nil
+	}
+
+	// labels only exist for ipv4 addresses, fall back to the device for ipv6
+	lookups := [][]string{{"ip", "-o", "addr", "show", "label", host}}
+	if !strings.Contains(host, ":") {
+		lookups = append(lookups, []string{"ip", "-o", "addr", "show", "dev", host})
+	}
+	for i := range lookups {
+		out, err := e.run(lookups[i])
+		if err != nil {
+			continue
+		}
+		if addr := parseInterfaceAddress(string(out)); addr != "" {
+			return addr, nil
+		}
+	}
+	if addr, err := lookupHost(host); err == nil {
+		return addr, nil
+	}
+	return "", InterfaceAddressMissing
+}
+
+// parseInterfaceAddress returns the first primary address found in
+// the output of `ip -o addr show`
+func parseInterfaceAddress(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		addr := ""
+		secondary := false
+		for i := range fields {
+			switch fields[i] {
+			case "inet", "inet6":
+				if i+1 < len(fields) {
+					addr = strings.Split(fields[i+1], "/")[0]
+				}
+			case "secondary":
+				secondary = true
+			}
+		}
+		if addr != "" && !secondary {
+			return addr
+		}
+	}
+	return ""
+}
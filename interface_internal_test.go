@@ -0,0 +1,37 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestParseInterfaceAddress(t *testing.T) {
+	out := `2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever preferred_lft forever
+2: eth0    inet 10.0.0.6/24 scope global secondary eth0\       valid_lft forever preferred_lft forever
+`
+	if addr := parseInterfaceAddress(out); addr != "10.0.0.5" {
+		t.Errorf("expected primary address 10.0.0.5, got '%s'", addr)
+	}
+	if addr := parseInterfaceAddress(""); addr != "" {
+		t.Errorf("expected no address, got '%s'", addr)
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	defer useFakeBackend()()
+
+	fakeRunOutput = []byte("3: eth1    inet 192.168.0.10/24 scope global eth1:vip\\       valid_lft forever preferred_lft forever\n")
+	host, err := DefaultIpvs.exec.resolveHost("eth1:vip")
+	if err != nil || host != "192.168.0.10" {
+		t.Errorf("failed to resolve interface label - %s, %v", host, err)
+	}
+
+	host, err = DefaultIpvs.exec.resolveHost("10.0.0.1")
+	if err != nil || host != "10.0.0.1" {
+		t.Errorf("ip should resolve to itself - %s, %v", host, err)
+	}
+
+	fakeRunOutput = []byte("")
+	if _, err = DefaultIpvs.exec.resolveHost("eth9"); err != InterfaceAddressMissing {
+		t.Errorf("expected InterfaceAddressMissing, got %v", err)
+	}
+}
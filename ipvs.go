@@ -1,28 +1,92 @@
 package lvs
 
 import (
-	"fmt"
+	"encoding/json"
+	"strconv"
 	"strings"
 )
 
 type (
 	Ipvs struct {
-		MulticastInterface string    `json:mcast_interface`
-		Syncid             int       `json:syncid`
-		Tcp                int       `json:tcp_timeout`
-		Tcpfin             int       `json:tcp_fin_timeout`
-		Udp                int       `json:udp_fin_timeout`
-		Services           []Service `json:services`
+		MulticastInterface string    `json:"mcast_interface"`
+		Syncid             int       `json:"syncid"`
+		Tcp                int       `json:"tcp_timeout"`
+		Tcpfin             int       `json:"tcp_fin_timeout"`
+		Udp                int       `json:"udp_fin_timeout"`
+		Services           []Service `json:"services"`
+		// Resources are applied by Sync before the services requiring
+		// them, see Service.Requires
+		Resources []Resource `json:"resources,omitempty"`
+		// SyncVersion is the sync protocol version the daemons speak,
+		// defaulting to 1 when the kernel has it. Set 0 for backups
+		// running kernels older than 2.6.39
+		SyncVersion *int `json:"sync_version,omitempty"`
+
+		exec      *executor
+		syncLimit SyncLimit
+		churn     *ChurnGuard
+		statePath string // side state file, see WithStateFile
+		state     *committedState
 	}
+
+	// Option configures how an Ipvs runs its backend commands
+	Option func(*Ipvs)
 )
 
 var (
-	DefaultIpvs = &Ipvs{}
+	DefaultIpvs = NewIpvs()
 )
 
+// NewIpvs returns an Ipvs configured with opts
+func NewIpvs(opts ...Option) *Ipvs {
+	i := &Ipvs{exec: &executor{}, state: &committedState{}}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.commit()
+	return i
+}
+
+func (i *Ipvs) FromJson(bytes []byte) error {
+	return unmarshal("json", bytes, i)
+}
+
+// UnmarshalJSON decodes the Ipvs, also accepting the keys configs had
+// before its json tags were fixed (MulticastInterface, Tcp, Tcpfin and
+// Udp) when the current ones aren't set
+func (i *Ipvs) UnmarshalJSON(bytes []byte) error {
+	type ipvs Ipvs
+	decoded := struct {
+		*ipvs
+		LegacyMulticastInterface *string `json:"MulticastInterface"`
+		LegacyTcp                *int    `json:"Tcp"`
+		LegacyTcpfin             *int    `json:"Tcpfin"`
+		LegacyUdp                *int    `json:"Udp"`
+	}{ipvs: (*ipvs)(i)}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		return err
+	}
+	if decoded.LegacyMulticastInterface != nil && i.MulticastInterface == "" {
+		i.MulticastInterface = *decoded.LegacyMulticastInterface
+	}
+	for _, legacy := range []struct {
+		value   *int
+		current *int
+	}{{decoded.LegacyTcp, &i.Tcp}, {decoded.LegacyTcpfin, &i.Tcpfin}, {decoded.LegacyUdp, &i.Udp}} {
+		if legacy.value != nil && *legacy.current == 0 {
+			*legacy.current = *legacy.value
+		}
+	}
+	return nil
+}
+
+func (i Ipvs) ToJson() ([]byte, error) {
+	return marshal("json", i)
+}
+
 func (i Ipvs) FindService(netType, host string, port int) *Service {
 	for j := range i.Services {
-		if i.Services[j].Host == host && i.Services[j].Port == port && i.Services[j].Type == netType {
+		if sameHost(i.Services[j].Host, host) && i.Services[j].Port == port && ServiceTypeFlag[i.Services[j].Type] == ServiceTypeFlag[netType] {
 			return &i.Services[j]
 		}
 	}
@@ -30,125 +94,327 @@ func (i Ipvs) FindService(netType, host string, port int) *Service {
 }
 
 func (i *Ipvs) AddService(service Service) error {
+	defer i.changing()()
 	err := service.Validate()
 	if err != nil {
 		return err
 	}
+	service, err = service.Normalize()
+	if err != nil {
+		return err
+	}
 	if i.FindService(service.Type, service.Host, service.Port) != nil {
 		return nil
 	}
-	err = backend("ipvsadm", append([]string{"-A", ServiceTypeFlag[service.Type], service.getHostPort(), "-s", ServiceSchedulerFlag[service.Scheduler]}, append(service.getPersistence(), service.getNetmask()...)...)...)
+	if service.MaxConns > 0 {
+		service = service.WithConnLimit(service.MaxConns)
+	}
+	if err := checkOverlaps(append(i.Services[:len(i.Services):len(i.Services)], service)); err != nil {
+		return err
+	}
+	service.exec = i.exec
+	applied, err := service.resolve()
 	if err != nil {
 		return err
 	}
-	for i := range service.Servers {
-		err := backend("ipvsadm", append([]string{"-a", ServiceTypeFlag[service.Type], service.getHostPort(), "-r"}, strings.Split(service.Servers[i].String(), " ")...)...)
+	if err = i.exec.checkReachable(applied, applied.Servers...); err != nil {
+		return err
+	}
+	err = i.exec.execute("ipvsadm", append([]string{"-A", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-s", ServiceSchedulerFlag[applied.Scheduler]}, applied.getOptions()...)...)
+	if err != nil {
+		return err
+	}
+	for j := range applied.Servers {
+		err := i.exec.execute("ipvsadm", append([]string{"-a", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-r"}, applied.Servers[j].Args()...)...)
 		if err != nil {
 			return err
 		}
 	}
-	i.Services = append(i.Services, service)
-	return nil
+	i.Services = append(i.Services, stampApplied(nil, service, stampNow()))
+	return i.writeState()
 }
 
 func (i *Ipvs) EditService(service Service) error {
-	err := backend("ipvsadm", append([]string{"-E", ServiceTypeFlag[service.Type], service.getHostPort(), ServiceSchedulerFlag[service.Scheduler]}, append(service.getPersistence(), service.getNetmask()...)...)...)
+	_, err := i.EditServiceChanged(service)
+	return err
+}
+
+// AddServiceChanged adds service like AddService, reporting whether it was
+// actually added
+func (i *Ipvs) AddServiceChanged(service Service) (bool, error) {
+	if i.FindService(service.Type, service.Host, service.Port) != nil {
+		return false, service.Validate()
+	}
+	err := i.AddService(service)
+	return err == nil, err
+}
+
+// EditServiceChanged edits service like EditService, skipping the edit and
+// reporting false if it is already applied as requested
+func (i *Ipvs) EditServiceChanged(service Service) (bool, error) {
+	defer i.changing()()
+	service, err := service.Normalize()
 	if err != nil {
-		return err
+		return false, err
+	}
+	current := i.FindService(service.Type, service.Host, service.Port)
+	if current != nil && current.sameAttributes(service) {
+		i.exec.skip()
+		if err := service.Validate(); err != nil {
+			return false, err
+		}
+		// keep what ipvs doesn't know about, such as the name
+		current.Name, current.MinServers, current.Protected = service.Name, service.MinServers, service.Protected
+		current.DefaultForwarder, current.Requires = service.DefaultForwarder, service.Requires
+		if current.MaxConns != service.MaxConns {
+			current.MaxConns = service.MaxConns
+			if err := current.applyConnLimit(); err != nil {
+				return false, err
+			}
+		}
+		return false, i.writeState()
+	}
+	if err := service.Validate(); err != nil {
+		return false, err
 	}
 
+	service.exec = i.exec
+	applied, err := service.resolve()
+	if err != nil {
+		return false, err
+	}
+	err = i.exec.execute("ipvsadm", append([]string{"-E", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-s", ServiceSchedulerFlag[applied.Scheduler]}, applied.getOptions()...)...)
+	if err != nil {
+		return false, err
+	}
+
+	if current != nil {
+		service = current.carryTimes(service)
+	}
+	service.LastApplied = stampNow()
 	for j := range i.Services {
-		if i.Services[j].Host == service.Host && i.Services[j].Port == service.Port && i.Services[j].Type == service.Type {
+		if sameHost(i.Services[j].Host, service.Host) && i.Services[j].Port == service.Port && ServiceTypeFlag[i.Services[j].Type] == ServiceTypeFlag[service.Type] {
 			i.Services = append(i.Services[:j], append([]Service{service}, i.Services[j+1:]...)...)
+			if err := i.Services[j].applyConnLimit(); err != nil {
+				return true, err
+			}
 			break
 		}
 	}
-	return nil
+	return true, i.writeState()
+}
+
+// OpCounts returns how many ipvsadm changes were run for the table, and how
+// many were skipped as the last known state showed they were already applied
+func (i *Ipvs) OpCounts() OpCounts {
+	return i.exec.opCounts()
+}
+
+// RemoveServiceChanged removes the service like RemoveService, reporting
+// false without touching ipvsadm if the service isn't known
+func (i *Ipvs) RemoveServiceChanged(netType, host string, port int) (bool, error) {
+	if i.FindService(netType, host, port) == nil {
+		return false, nil
+	}
+	err := i.RemoveService(netType, host, port)
+	return err == nil, err
 }
 
 func (i *Ipvs) RemoveService(netType, host string, port int) error {
-	err := backend("ipvsadm", "-D", ServiceTypeFlag[netType], fmt.Sprintf("%s:%d", host, port))
+	defer i.changing()()
+	addr, err := i.exec.resolveHost(host)
+	if err != nil {
+		return err
+	}
+	err = i.exec.execute("ipvsadm", "-D", ServiceTypeFlag[netType], Service{Host: addr, Port: port}.getHostPort())
 	if err != nil {
 		return err
 	}
 
+	removed := Service{}
 	for j := range i.Services {
-		if i.Services[j].Host == host && i.Services[j].Port == port && i.Services[j].Type == netType {
+		if sameHost(i.Services[j].Host, host) && i.Services[j].Port == port && ServiceTypeFlag[i.Services[j].Type] == ServiceTypeFlag[netType] {
+			removed = i.Services[j]
 			i.Services = append(i.Services[:j], i.Services[j+1:]...)
 			break
 		}
 	}
-	return nil
+	if err = i.writeState(); err != nil {
+		return err
+	}
+	removed.exec, removed.Host = i.exec, addr
+	return removed.flushConntracks()
 }
 
 func (i *Ipvs) Clear() error {
-	err := backend("ipvsadm", "-C")
+	return i.clear(false)
+}
+
+// ClearForce clears the table like Clear, protected services included
+func (i *Ipvs) ClearForce() error {
+	return i.clear(true)
+}
+
+func (i *Ipvs) clear(force bool) error {
+	defer i.changing()()
+	unlock, err := i.exec.lockTable()
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
+	if !force {
+		// what is applied, protected as the state file says, not only what
+		// this Ipvs has seen
+		if err := i.Save(); err != nil {
+			return err
+		}
+		if err := checkProtected(i.Services, nil); err != nil {
+			return err
+		}
+	}
+	err = i.exec.execute("ipvsadm", "-C")
+	if err != nil {
+		return err
+	}
+
+	cleared := i.Services
 	i.Services = make([]Service, 0, 0)
+	if err = i.writeState(); err != nil {
+		return err
+	}
+	for _, service := range cleared {
+		if !i.exec.flushesConntrack() {
+			break
+		}
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return err
+		}
+		if err = applied.flushConntracks(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (i Ipvs) SetTimeouts() error {
 	if i.Tcp > 0 || i.Tcpfin > 0 || i.Udp > 0 {
-		return backend("ipvsadm", "--set", string(i.Tcp), string(i.Tcpfin), string(i.Udp))
+		return i.exec.execute("ipvsadm", "--set", strconv.Itoa(i.Tcp), strconv.Itoa(i.Tcpfin), strconv.Itoa(i.Udp))
 	}
 	return nil
 }
 
 func (i *Ipvs) Restore(services []Service) error {
+	defer i.changing()()
+	unlock, err := i.exec.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	services, err = normalizeServices(services)
+	if err != nil {
+		return err
+	}
+	if err := checkOverlaps(services); err != nil {
+		return err
+	}
 	in := make([]string, 0, 0)
-	for i := range services {
-		in = append(in, services[i].String())
+	for _, service := range services {
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return err
+		}
+		in = append(in, applied.String())
 	}
-	err := backendStdin(strings.Join(in, ""), "ipvsadm", "-R")
+	err = i.exec.executeStdin(strings.Join(in, ""), "ipvsadm", "-R")
 	if err != nil {
 		return err
 	}
 
+	now := stampNow()
+	for j := range services {
+		services[j] = stampApplied(nil, services[j], now)
+	}
 	i.Services = services
-	return nil
+	i.adopt()
+	return i.writeState()
 }
 
 // save reads the applied ipvsadm rules from the host and saves them as i.Services
 func (i *Ipvs) Save() error {
-	out, err := backendRun([]string{"ipvsadm", "-S", "-n"})
+	defer i.changing()()
+	services, err := i.exec.readRules()
 	if err != nil {
 		return err
 	}
+	if err = i.label(services); err != nil {
+		return err
+	}
+	i.Services = services
+	i.adopt()
+	return nil
+}
 
-	i.Services = make([]Service, 0, 0)
-	serviceStrings := strings.Split(string(out), "-A")
-	for j := range serviceStrings {
-		if serviceStrings[j] == "" {
+// ParseSave parses the services and servers in the output of `ipvsadm -S -n`
+func ParseSave(out string) ([]Service, error) {
+	services := make([]Service, 0, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
 			continue
 		}
-		serverStrings := strings.Split(serviceStrings[j], "-a")
-		serviceString := serverStrings[0]
-		serverStrings = serverStrings[1:]
-		// fmt.Println("Service: ", serviceString)
-		service := parseService(serviceString)
-		for k := range serverStrings {
-			// fmt.Println("Server: ", serverStrings[j])
-			server := parseServer(serverStrings[k])
-			service.Servers = append(service.Servers, server)
+		switch fields[0] {
+		case "-A", "--add-service":
+			service, err := ParseServiceLine(line)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, service)
+		case "-a", "--add-server":
+			if len(services) == 0 {
+				return nil, UnexpecedToken
+			}
+			server, err := ParseServerLine(line)
+			if err != nil {
+				return nil, err
+			}
+			services[len(services)-1].Servers = append(services[len(services)-1].Servers, server)
 		}
-		i.Services = append(i.Services, service)
 	}
-	return nil
+	return services, nil
 }
 
+// adopt has every service run its backend commands the same way as i
+func (i *Ipvs) adopt() {
+	for j := range i.Services {
+		i.Services[j].exec = i.exec
+	}
+}
+
+// StartDaemon starts the primary and backup sync daemons on the
+// MulticastInterface (detected from the routes to the vips when it is
+// AutoInterface), after setting the sync protocol version. Without a
+// MulticastInterface it does nothing
 func (i Ipvs) StartDaemon() (error, error) {
 	if i.MulticastInterface != "" {
+		iface, err := i.syncInterface()
+		if err != nil {
+			return err, err
+		}
+		if err = i.setSyncVersion(); err != nil {
+			return err, err
+		}
 		var err1, err2 error
 		if i.Syncid > 0 {
-			err1 = backend("ipvsadm", "--start-daemon", "primary", "--mcast-interface", i.MulticastInterface, "--syncid", string(i.Syncid))
-			err2 = backend("ipvsadm", "--start-daemon", "backup", "--mcast-interface", i.MulticastInterface, "--syncid", string(i.Syncid))
+			err1 = i.exec.execute("ipvsadm", "--start-daemon", "primary", "--mcast-interface", iface, "--syncid", strconv.Itoa(i.Syncid))
+			err2 = i.exec.execute("ipvsadm", "--start-daemon", "backup", "--mcast-interface", iface, "--syncid", strconv.Itoa(i.Syncid))
 		} else {
-			err1 = backend("ipvsadm", "--start-daemon", "primary", "--mcast-interface", i.MulticastInterface)
-			err2 = backend("ipvsadm", "--start-daemon", "backup", "--mcast-interface", i.MulticastInterface)
+			err1 = i.exec.execute("ipvsadm", "--start-daemon", "primary", "--mcast-interface", iface)
+			err2 = i.exec.execute("ipvsadm", "--start-daemon", "backup", "--mcast-interface", iface)
 		}
 		return err1, err2
 	}
@@ -158,13 +424,29 @@ func (i Ipvs) StartDaemon() (error, error) {
 func (i Ipvs) StopDaemon() (error, error) {
 	if i.MulticastInterface != "" {
 		var err1, err2 error
-		err1 = backend("ipvsadm", "--stop-daemon", "primary")
-		err2 = backend("ipvsadm", "--stop-daemon", "backup")
+		err1 = i.exec.execute("ipvsadm", "--stop-daemon", "primary")
+		err2 = i.exec.execute("ipvsadm", "--stop-daemon", "backup")
 		return err1, err2
 	}
 	return nil, nil
 }
 
+// Drain sets the weight of every server to 0 so no new connections are
+// scheduled to them while existing connections are left alone
+func (i *Ipvs) Drain() error {
+	defer i.changing()()
+	for j := range i.Services {
+		for k := range i.Services[j].Servers {
+			server := i.Services[j].Servers[k]
+			server.Weight = 0
+			if err := i.Services[j].EditServer(server); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (i Ipvs) Zero() error {
-	return backend("ipvsadm", "-Z")
+	return i.exec.execute("ipvsadm", "-Z")
 }
@@ -1 +1,163 @@
 package lvs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceChanged(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := &Ipvs{}
+	service := Service{Host: "10.0.0.1", Port: 80, Type: "tcp", Scheduler: "wlc"}
+	if changed, err := ipvs.AddServiceChanged(service); !changed || err != nil {
+		t.Errorf("new service should be added - %v, %v", changed, err)
+	}
+	if changed, err := ipvs.AddServiceChanged(service); changed || err != nil {
+		t.Errorf("existing service should not be added - %v, %v", changed, err)
+	}
+	if changed, err := ipvs.EditServiceChanged(service); changed || err != nil {
+		t.Errorf("unchanged service should not be edited - %v, %v", changed, err)
+	}
+	service.Scheduler = "rr"
+	if changed, err := ipvs.EditServiceChanged(service); !changed || err != nil {
+		t.Errorf("changed service should be edited - %v, %v", changed, err)
+	}
+	if changed, err := ipvs.RemoveServiceChanged("tcp", "10.0.0.1", 80); !changed || err != nil {
+		t.Errorf("service should be removed - %v, %v", changed, err)
+	}
+	if changed, err := ipvs.RemoveServiceChanged("tcp", "10.0.0.1", 80); changed || err != nil {
+		t.Errorf("missing service should not be removed - %v, %v", changed, err)
+	}
+	if len(fakeExecuted) != 3 {
+		t.Errorf("expected 3 ipvsadm calls, got %q", fakeExecuted)
+	}
+}
+
+func TestWithNetns(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs(WithNetns("director"))
+	err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80}}})
+	if err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	service := ipvs.FindService("", "10.0.0.1", 80)
+	if err = service.RemoveServer("10.0.1.1", 80); err != nil {
+		t.Fatalf("failed to remove server - %v", err)
+	}
+
+	if len(fakeExecuted) != 3 {
+		t.Fatalf("expected 3 commands, got %q", fakeExecuted)
+	}
+	for _, cmd := range fakeExecuted {
+		if !strings.HasPrefix(cmd, "nsenter --net=/var/run/netns/director -- ipvsadm ") {
+			t.Errorf("command not run in the namespace - '%s'", cmd)
+		}
+	}
+}
+
+func TestSkipNoopEdits(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	ipvs.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}})
+	fakeExecuted = nil
+
+	ipvs.EditService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "wlc"})
+	ipvs.Services[0].EditServer(Server{Host: "10.0.1.1", Port: 80, Weight: 1, Zone: "east"})
+	if len(fakeExecuted) != 0 {
+		t.Errorf("no-op edits ran ipvsadm - %q", fakeExecuted)
+	}
+	if ipvs.Services[0].Servers[0].Zone != "east" {
+		t.Errorf("skipped edit should still keep the server's metadata")
+	}
+
+	ipvs.EditService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "rr"})
+	ipvs.Services[0].EditServer(Server{Host: "10.0.1.1", Port: 80, Weight: 2})
+	if len(fakeExecuted) != 2 {
+		t.Errorf("expected 2 edits, got %q", fakeExecuted)
+	}
+
+	expected := OpCounts{Executed: 4, Skipped: 2}
+	if counts := ipvs.OpCounts(); counts != expected {
+		t.Errorf("expected %+v, got %+v", expected, counts)
+	}
+}
+
+func TestEditServiceFlags(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	ipvs.AddService(Service{Type: "udp", Host: "10.0.0.1", Port: 5060, Scheduler: "sh"})
+	edits := []struct {
+		service  Service
+		expected string
+	}{
+		{Service{Scheduler: "sh", SchedulerOpts: &SchedulerOpts{Fallback: true, Port: true}}, "-s sh -b sh-fallback,sh-port"},
+		{Service{Scheduler: "sh", Persistence: 60}, "-s sh -p 60"},
+		{Service{Scheduler: "sh", Persistence: 60, PersistenceEngine: "sip"}, "-s sh -p 60 --pe sip"},
+		{Service{Scheduler: "sh", OnePacket: true}, "-s sh -o"},
+		{Service{Scheduler: "rr", Persistence: 60, PersistenceEngine: "sip", Netmask: "255.255.255.0", SchedulerOpts: &SchedulerOpts{Flags: []string{"flag-3"}}, OnePacket: true}, "-s rr -p 60 --pe sip -M 255.255.255.0 -b flag-3 -o"},
+		// left out flags are cleared
+		{Service{Scheduler: "rr"}, "-s rr"},
+	}
+	for _, edit := range edits {
+		edit.service.Type, edit.service.Host, edit.service.Port = "udp", "10.0.0.1", 5060
+		fakeExecuted = nil
+		if err := ipvs.EditService(edit.service); err != nil {
+			t.Fatalf("failed to edit %q - %v", edit.expected, err)
+		}
+		expected := "ipvsadm -E -u 10.0.0.1:5060 " + edit.expected
+		if len(fakeExecuted) != 1 || fakeExecuted[0] != expected {
+			t.Errorf("expected %q, got %q", expected, fakeExecuted)
+		}
+		if !ipvs.Services[0].sameAttributes(edit.service) {
+			t.Errorf("edit %q wasn't kept - %+v", edit.expected, ipvs.Services[0])
+		}
+		parsed, err := ParseServiceLine(strings.Replace(expected, "ipvsadm -E", "-A", 1))
+		if err != nil || !parsed.sameAttributes(edit.service) {
+			t.Errorf("%q doesn't parse back - %+v", expected, parsed)
+		}
+	}
+
+	invalid := []Service{
+		{Type: "udp", Host: "10.0.0.1", Port: 5060, PersistenceEngine: "sip"},
+		{Type: "udp", Host: "10.0.0.1", Port: 5060, Persistence: 60, PersistenceEngine: "h323"},
+		{Type: "tcp", Host: "10.0.0.1", Port: 5060, OnePacket: true},
+	}
+	for _, service := range invalid {
+		if err := ipvs.EditService(service); err == nil {
+			t.Errorf("expected %+v to be rejected", service)
+		}
+	}
+}
+
+func TestLegacyJsonKeys(t *testing.T) {
+	// as configs were written before the json tags were fixed
+	legacy := `{"MulticastInterface": "eth0", "Syncid": 3, "Tcp": 900, "Tcpfin": 120, "Udp": 300,
+		"Services": [{"host": "10.0.0.1", "port": 80, "servers": [{"host": "10.0.1.1", "port": 80, "weight": 1, "UpperThreshold": 10, "LowerThreshold": 5}]}]}`
+	ipvs := NewIpvs()
+	if err := ipvs.FromJson([]byte(legacy)); err != nil {
+		t.Fatalf("failed to decode legacy config - %v", err)
+	}
+	if ipvs.MulticastInterface != "eth0" || ipvs.Syncid != 3 || ipvs.Tcp != 900 || ipvs.Tcpfin != 120 || ipvs.Udp != 300 {
+		t.Errorf("legacy keys not read - %+v", ipvs)
+	}
+	if len(ipvs.Services) != 1 || ipvs.Services[0].Servers[0].UpperThreshold != 10 || ipvs.Services[0].Servers[0].LowerThreshold != 5 {
+		t.Errorf("legacy server keys not read - %+v", ipvs.Services)
+	}
+	if ipvs.exec == nil {
+		t.Errorf("decoding shouldn't reset the ipvs' executor")
+	}
+
+	// the current keys win
+	current := `{"mcast_interface": "eth1", "MulticastInterface": "eth0", "tcp_timeout": 60, "Tcp": 900}`
+	if err := ipvs.FromJson([]byte(current)); err != nil || ipvs.MulticastInterface != "eth1" || ipvs.Tcp != 60 {
+		t.Errorf("expected the current keys to win - %+v, %v", ipvs, err)
+	}
+
+	if err := ValidateSchema([]byte(`{"host": "10.0.1.1", "UpperThreshold": 10}`), SchemaServer); err != nil {
+		t.Errorf("expected the schema to accept legacy keys - %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package lvs
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ipvsadm failures, recognized by mapIpvsadmError whatever the version
+	// of ipvsadm worded them
+	ErrServiceExists = errors.New("service already exists")
+	ErrNoSuchService = errors.New("no such service")
+	ErrDestExists    = errors.New("destination already exists")
+	ErrNoSuchDest    = errors.New("no such destination")
+	ErrPermission    = errors.New("permission denied, ipvsadm must run as root or with CAP_NET_ADMIN")
+
+	// ipvsadmErrors are the (lowercased) messages of known failures.
+	// ipvsadm's exit code doesn't tell them apart (1 or 255 for any failed
+	// command, 2 when it can't initialize ipvs, eg. without permission),
+	// its message does. Add service (or destination) commands report a
+	// missing service as not defined, the others as no such service. They
+	// are worded so the runner's own failures, such as ssh's "Permission
+	// denied (publickey)", don't match
+	ipvsadmErrors = []struct {
+		message string
+		err     error
+	}{
+		{"service already exists", ErrServiceExists},
+		{"no such service", ErrNoSuchService},
+		{"service not defined", ErrNoSuchService},
+		{"destination already exists", ErrDestExists},
+		{"no such destination", ErrNoSuchDest},
+		{"you must be root", ErrPermission},
+		{"can't initialize ipvs: permission denied", ErrPermission},
+		{"can't initialize ipvs: operation not permitted", ErrPermission},
+	}
+
+	// ipvsadmExitStatus is how the backends prefix ipvsadm's output in
+	// their errors, see execute and runOutput
+	ipvsadmExitStatus = regexp.MustCompile(`^exit status \d+(: | output: )`)
+)
+
+// ipvsadmErr maps the error of exe when it is ipvsadm, see mapIpvsadmError
+func ipvsadmErr(exe string, err error) error {
+	if exe != "ipvsadm" {
+		return err
+	}
+	return mapIpvsadmError(err)
+}
+
+// mapIpvsadmError wraps the error of a failed ipvsadm command with the
+// sentinel error of the failure it recognizes in ipvsadm's output (see
+// ipvsadmErrors), keeping the output (eg. which rule of a restore failed).
+// Errors that aren't ipvsadm's output, such as failing to run it at all,
+// are returned as is
+func mapIpvsadmError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	prefix := ipvsadmExitStatus.FindString(message)
+	if prefix == "" {
+		return err
+	}
+	output := strings.ToLower(message[len(prefix):])
+	for _, known := range ipvsadmErrors {
+		if strings.Contains(output, known.message) {
+			return fmt.Errorf("%w: %v", known.err, err)
+		}
+	}
+	return err
+}
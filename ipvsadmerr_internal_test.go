@@ -0,0 +1,71 @@
+package lvs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMapIpvsadmError(t *testing.T) {
+	variants := []struct {
+		output string
+		err    error
+	}{
+		// ipvsadm 1.2x and later, as returned by execute
+		{"exit status 255: Service already exists\n", ErrServiceExists},
+		{"exit status 255: No such service\n", ErrNoSuchService},
+		{"exit status 255: Service not defined\n", ErrNoSuchService},
+		{"exit status 255: Destination already exists\n", ErrDestExists},
+		{"exit status 255: No such destination\n", ErrNoSuchDest},
+		// older builds exiting with 1, and the simulator
+		{"exit status 1: Service already exists", ErrServiceExists},
+		{"exit status 1: No such service", ErrNoSuchService},
+		{"exit status 1: Destination already exists", ErrDestExists},
+		{"exit status 1: No such destination", ErrNoSuchDest},
+		// from runOutput
+		{"exit status 255 output: No such service\n", ErrNoSuchService},
+		// without permission to use ipvs, or to run ipvsadm at all
+		{"exit status 2: Can't initialize ipvs: Permission denied (you must be root)\nAre you sure that IP Virtual Server is built in the kernel or as module?\n", ErrPermission},
+		{"exit status 255: Permission denied (you must be root)\n", ErrPermission},
+		{"exit status 2: Can't initialize ipvs: Operation not permitted\n", ErrPermission},
+	}
+	for _, variant := range variants {
+		err := mapIpvsadmError(errors.New(variant.output))
+		if !errors.Is(err, variant.err) {
+			t.Errorf("expected %q to map to %v, got %v", variant.output, variant.err, err)
+		}
+		// ipvsadm's output is kept
+		if !strings.Contains(err.Error(), variant.output) {
+			t.Errorf("expected %q to be kept - %v", variant.output, err)
+		}
+	}
+
+	for _, unknown := range []error{
+		errors.New("exit status 255: Memory allocation problem"),
+		// the runner's own failures aren't ipvsadm's
+		errors.New("exit status 255: root@director1: Permission denied (publickey).\r\n"),
+		errors.New("fork/exec /sbin/ipvsadm: permission denied"),
+	} {
+		if err := mapIpvsadmError(unknown); err != unknown {
+			t.Errorf("expected unknown errors to be kept - %v", err)
+		}
+	}
+	if err := mapIpvsadmError(nil); err != nil {
+		t.Errorf("expected no error - %v", err)
+	}
+}
+
+func TestIpvsadmErrors(t *testing.T) {
+	defer useFakeBackend()()
+	fakeExecuteErr = errors.New("exit status 255: No such destination\n")
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80, exec: DefaultIpvs.exec}
+	if err := service.RemoveServer("10.0.1.1", 80); !errors.Is(err, ErrNoSuchDest) {
+		t.Errorf("expected ipvsadm's error to be mapped - %v", err)
+	}
+
+	// other commands' errors are kept as is
+	fakeExecuteErr = errors.New("exit status 2: RTNETLINK answers: Operation not permitted")
+	if err := DefaultIpvs.exec.execute("ip", "addr", "add", "10.0.0.1/32", "dev", "lo"); err != fakeExecuteErr {
+		t.Errorf("expected other commands' errors to be kept - %v", err)
+	}
+}
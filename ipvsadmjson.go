@@ -0,0 +1,187 @@
+package lvs
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type (
+	// ipvsadmTable is the output of `ipvsadm -L -n --json`, a format defined
+	// by this package rather than upstream ipvsadm, see ParseJSON
+	ipvsadmTable struct {
+		Services []ipvsadmService `json:"services"`
+	}
+
+	ipvsadmService struct {
+		Protocol     string               `json:"protocol"` // tcp, udp or fwmark
+		Address      string               `json:"address,omitempty"`
+		Port         int                  `json:"port,omitempty"`
+		Fwmark       uint32               `json:"fwmark,omitempty"`
+		Scheduler    string               `json:"scheduler"`
+		Persistence  int                  `json:"persistence,omitempty"`
+		Netmask      string               `json:"netmask,omitempty"`
+		Pe           string               `json:"pe,omitempty"`
+		SchedFlags   []string             `json:"sched_flags,omitempty"`
+		Ops          bool                 `json:"ops,omitempty"`
+		Destinations []ipvsadmDestination `json:"destinations"`
+	}
+
+	ipvsadmDestination struct {
+		Address        string `json:"address"`
+		Port           int    `json:"port"`
+		Forward        string `json:"forward"` // route, tunnel or masq
+		Weight         int    `json:"weight"`
+		UpperThreshold int    `json:"upper_threshold"`
+		LowerThreshold int    `json:"lower_threshold"`
+	}
+)
+
+var (
+	jsonServerForwarder = map[string]string{
+		"route":  "g",
+		"local":  "g",
+		"tunnel": "i",
+		"masq":   "m",
+	}
+	jsonForward = map[string]string{
+		"g": "route",
+		"i": "tunnel",
+		"m": "masq",
+		"":  "route",
+	}
+)
+
+// ParseJSON parses the services and servers in the output of
+// `ipvsadm -L -n --json`. Upstream ipvsadm (up to 1.31 at least) has no json
+// output: the format is this package's own, FormatJSON being its reference,
+// for ipvsadm builds patched or wrapped to answer it, eg. by a script
+// rendering the netlink state with FormatJSON. Being structured it is more
+// robust than the text outputs, and preferred by Save when available. Stock
+// ipvsadm fails on --json, and Save falls back to `ipvsadm -S -n`
+func ParseJSON(out []byte) ([]Service, error) {
+	table := ipvsadmTable{}
+	if err := json.Unmarshal(out, &table); err != nil {
+		return nil, err
+	}
+	if table.Services == nil {
+		return nil, UnexpecedToken
+	}
+	services := make([]Service, 0, len(table.Services))
+	for _, s := range table.Services {
+		service := Service{
+			Type:              s.Protocol,
+			Host:              s.Address,
+			Port:              s.Port,
+			Scheduler:         s.Scheduler,
+			Persistence:       s.Persistence,
+			Netmask:           s.Netmask,
+			PersistenceEngine: s.Pe,
+			OnePacket:         s.Ops,
+			Servers:           make([]Server, 0, len(s.Destinations)),
+		}
+		if _, ok := ServiceTypeFlag[service.Type]; !ok || service.Type == "" {
+			return nil, UnexpecedToken
+		}
+		if service.Type == "fwmark" {
+			service.Host, service.Port = strconv.FormatUint(uint64(s.Fwmark), 10), 0
+		}
+		if len(s.SchedFlags) > 0 {
+			service.SchedulerOpts = parseSchedFlags(strings.Join(s.SchedFlags, ","))
+		}
+		for _, d := range s.Destinations {
+			forwarder, ok := jsonServerForwarder[d.Forward]
+			if !ok {
+				return nil, UnexpecedToken
+			}
+			service.Servers = append(service.Servers, Server{
+				Host:           d.Address,
+				Port:           d.Port,
+				Forwarder:      forwarder,
+				Weight:         d.Weight,
+				UpperThreshold: d.UpperThreshold,
+				LowerThreshold: d.LowerThreshold,
+			})
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// FormatJSON formats services like `ipvsadm -L -n --json`, see ParseJSON. It
+// defines the format: an object with a "services" array, each with the
+// protocol (tcp, udp or fwmark), address, port or fwmark, scheduler,
+// persistence, netmask, pe, sched_flags, ops and the "destinations", each
+// with the address, port, forward (route, tunnel or masq), weight,
+// upper_threshold and lower_threshold
+func FormatJSON(services []Service) ([]byte, error) {
+	table := ipvsadmTable{Services: make([]ipvsadmService, 0, len(services))}
+	for _, service := range services {
+		s := ipvsadmService{
+			Protocol:     "tcp",
+			Address:      service.Host,
+			Port:         service.Port,
+			Scheduler:    ServiceSchedulerFlag[service.Scheduler],
+			Persistence:  service.Persistence,
+			Netmask:      service.Netmask,
+			Pe:           service.PersistenceEngine,
+			Ops:          service.OnePacket,
+			Destinations: make([]ipvsadmDestination, 0, len(service.Servers)),
+		}
+		switch ServiceTypeFlag[service.Type] {
+		case "-u":
+			s.Protocol = "udp"
+		case "-f":
+			mark, err := strconv.ParseUint(service.Host, 0, 32)
+			if err != nil {
+				return nil, InvalidHost
+			}
+			s.Protocol, s.Address, s.Port, s.Fwmark = "fwmark", "", 0, uint32(mark)
+		}
+		if flags := service.getSchedFlags(); len(flags) > 0 {
+			s.SchedFlags = strings.Split(flags[1], ",")
+		}
+		for _, server := range service.Servers {
+			s.Destinations = append(s.Destinations, ipvsadmDestination{
+				Address:        server.Host,
+				Port:           server.Port,
+				Forward:        jsonForward[server.Forwarder],
+				Weight:         server.Weight,
+				UpperThreshold: server.UpperThreshold,
+				LowerThreshold: server.LowerThreshold,
+			})
+		}
+		table.Services = append(table.Services, s)
+	}
+	return json.Marshal(table)
+}
+
+// readRules reads the services applied on the host, with
+// `ipvsadm -L -n --json` when ipvsadm has it and `ipvsadm -S -n` otherwise.
+// Whether it has it is detected once, on the first read
+func (e *executor) readRules() ([]Service, error) {
+	if e != nil && e.jsonOutput.Load() >= 0 {
+		out, err := e.run([]string{"ipvsadm", "-L", "-n", "--json"})
+		if e.jsonOutput.Load() > 0 {
+			if err != nil {
+				return nil, err
+			}
+			return ParseJSON(out)
+		}
+		if err == nil {
+			if services, err := ParseJSON(out); err == nil {
+				e.jsonOutput.Store(1)
+				return services, nil
+			}
+		}
+		if err != ErrTimeout {
+			e.jsonOutput.Store(-1)
+		}
+	}
+
+	out, err := e.run([]string{"ipvsadm", "-S", "-n"})
+	if err != nil {
+		return nil, err
+	}
+	return ParseSave(string(out))
+}
@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestIpvsadmJSON(t *testing.T) {
+	services := []Service{
+		{Type: "udp", Host: "10.0.0.1", Port: 5060, Scheduler: "sh", Persistence: 60, PersistenceEngine: "sip", OnePacket: true, SchedulerOpts: &SchedulerOpts{Fallback: true}, Servers: []Server{{Host: "10.0.1.1", Port: 5060, Forwarder: "i", Weight: 2, UpperThreshold: 10}}},
+		{Type: "fwmark", Host: "0x10", Scheduler: "rr", Servers: []Server{{Host: "2001:db8::2", Forwarder: "g", Weight: 1}}},
+	}
+	out, err := FormatJSON(services)
+	if err != nil {
+		t.Fatalf("failed to format - %v", err)
+	}
+	parsed, err := ParseJSON(out)
+	if err != nil {
+		t.Fatalf("failed to parse %s - %v", out, err)
+	}
+	if len(parsed) != 2 || !parsed[0].Equal(services[0]) || parsed[1].Host != "16" || !parsed[1].Servers[0].Equal(services[1].Servers[0]) {
+		t.Errorf("unexpected services from %s - %+v", out, parsed)
+	}
+	if _, err = ParseJSON([]byte("IP Virtual Server version 1.2.1 (size=4096)")); err == nil {
+		t.Errorf("expected text output to fail")
+	}
+}
+
+func TestSavePrefersJSON(t *testing.T) {
+	for _, withJson := range []bool{true, false} {
+		simulator := NewSimulator()
+		simulator.Json = withJson
+		ipvs := NewIpvs(WithRunner(simulator))
+		services := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3, LowerThreshold: 5}}}}
+		if err := ipvs.Sync(services); err != nil {
+			t.Fatalf("failed to sync - %v", err)
+		}
+
+		expected := int32(-1)
+		if withJson {
+			expected = 1
+		}
+		if ipvs.exec.jsonOutput.Load() != expected {
+			t.Errorf("json %v: expected detection %d, got %d", withJson, expected, ipvs.exec.jsonOutput.Load())
+		}
+		if err := ipvs.Save(); err != nil || len(ipvs.Services) != 1 || !ipvs.Services[0].Equal(services[0]) {
+			t.Errorf("json %v: unexpected services %+v - %v", withJson, ipvs.Services, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package lvs
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// KubeProxyInterface is the dummy interface kube-proxy binds the
+	// ClusterIPs (and external and load balancer ips) to in ipvs mode
+	KubeProxyInterface = "kube-ipvs0"
+
+	// kinds of kube-proxy services, prefixing the names of imported services
+	KubeProxyClusterIP = "cluster-ip"
+	KubeProxyNodePort  = "node-port"
+)
+
+// KubeProxyServices reads the table kube-proxy programs in ipvs mode, see
+// ParseKubeProxy
+func (i Ipvs) KubeProxyServices(names map[string]string) ([]Service, error) {
+	save, err := i.exec.run([]string{"ipvsadm", "-S", "-n"})
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := i.exec.run([]string{"ip", "-o", "addr", "show", "dev", KubeProxyInterface})
+	if err != nil {
+		return nil, err
+	}
+	return ParseKubeProxy(string(save), string(addrs), names)
+}
+
+// ParseKubeProxy parses the services kube-proxy programs in ipvs mode from
+// the output of `ipvsadm -S -n` and `ip -o addr show dev kube-ipvs0`,
+// labeling them so they can be inspected and diffed. Services on an address
+// bound to kube-ipvs0 are ClusterIPs (or external or load balancer ips),
+// the others NodePorts on the node's addresses. ipvs doesn't know which
+// kubernetes service they belong to, names may map addresses (ip, or
+// host:port) to one, such as "kube-system/kube-dns". Otherwise services are
+// named after their kind, eg. "cluster-ip tcp 10.96.0.1:443"
+func ParseKubeProxy(save, addrs string, names map[string]string) ([]Service, error) {
+	services, err := ParseSave(save)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := make(map[string]bool)
+	for _, line := range strings.Split(addrs, "\n") {
+		fields := strings.Fields(line)
+		for j := range fields {
+			if (fields[j] == "inet" || fields[j] == "inet6") && j+1 < len(fields) {
+				bound[canonicalHost(strings.Split(fields[j+1], "/")[0])] = true
+			}
+		}
+	}
+
+	for j := range services {
+		host := canonicalHost(services[j].Host)
+		services[j].Host = host
+		hostPort := net.JoinHostPort(host, strconv.Itoa(services[j].Port))
+		if name, ok := names[hostPort]; ok {
+			services[j].Name = name
+			continue
+		}
+		if name, ok := names[host]; ok {
+			services[j].Name = name
+			continue
+		}
+		kind := KubeProxyNodePort
+		if bound[host] {
+			kind = KubeProxyClusterIP
+		}
+		services[j].Name = kind + " " + services[j].Type + " " + hostPort
+	}
+	return services, nil
+}
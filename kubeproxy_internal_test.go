@@ -0,0 +1,42 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestParseKubeProxy(t *testing.T) {
+	save := `-A -t 10.96.0.1:443 -s rr
+-a -t 10.96.0.1:443 -r 172.18.0.2:6443 -m -w 1
+-A -u 10.96.0.10:53 -s rr
+-a -u 10.96.0.10:53 -r 10.244.0.2:53 -m -w 1
+-a -u 10.96.0.10:53 -r 10.244.0.3:53 -m -w 1
+-A -t 172.18.0.2:30080 -s rr
+-a -t 172.18.0.2:30080 -r 10.244.0.5:80 -m -w 1
+-A -t [fd00:10:96::1]:443 -s rr
+`
+	addrs := `5: kube-ipvs0    inet 10.96.0.1/32 scope global kube-ipvs0\       valid_lft forever preferred_lft forever
+5: kube-ipvs0    inet 10.96.0.10/32 scope global kube-ipvs0\       valid_lft forever preferred_lft forever
+5: kube-ipvs0    inet6 fd00:10:96::1/128 scope global \       valid_lft forever preferred_lft forever
+`
+	services, err := ParseKubeProxy(save, addrs, map[string]string{"10.96.0.10": "kube-system/kube-dns"})
+	if err != nil {
+		t.Fatalf("failed to parse - %v", err)
+	}
+	expected := []string{
+		"cluster-ip tcp 10.96.0.1:443",
+		"kube-system/kube-dns",
+		"node-port tcp 172.18.0.2:30080",
+		"cluster-ip tcp [fd00:10:96::1]:443",
+	}
+	if len(services) != len(expected) {
+		t.Fatalf("expected %d services, got %+v", len(expected), services)
+	}
+	for j := range expected {
+		if services[j].Name != expected[j] {
+			t.Errorf("expected '%s', got '%s'", expected[j], services[j].Name)
+		}
+	}
+	if len(services[1].Servers) != 2 || services[1].Servers[0].Forwarder != "m" {
+		t.Errorf("servers not parsed - %+v", services[1])
+	}
+}
@@ -0,0 +1,95 @@
+package lvs
+
+import (
+	"encoding/json"
+	"os"
+)
+
+type (
+	// serviceState is what ipvs doesn't know about the services, kept in
+	// the side state file
+	serviceState struct {
+		Names     map[string]string `json:"names"`               // by canonical service key
+		Protected map[string]bool   `json:"protected,omitempty"` // by canonical service key
+	}
+)
+
+// WithStateFile keeps what ipvs doesn't know about the services, their
+// names and protection, in a json file at path. It is rewritten whenever the services
+// change, and used by Save and List to label the services read back from
+// the kernel
+func WithStateFile(path string) Option {
+	return func(i *Ipvs) {
+		i.statePath = path
+	}
+}
+
+// writeState rewrites the state file, if there is one, atomically
+func (i *Ipvs) writeState() error {
+	if i.statePath == "" {
+		return nil
+	}
+	state := serviceState{Names: make(map[string]string), Protected: make(map[string]bool)}
+	for j := range i.Services {
+		if i.Services[j].Name != "" {
+			state.Names[i.Services[j].canonicalKey()] = i.Services[j].Name
+		}
+		if i.Services[j].Protected {
+			state.Protected[i.Services[j].canonicalKey()] = true
+		}
+	}
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(i.statePath, bytes, 0644)
+}
+
+// readState reads the state file, a missing one is empty
+func (i Ipvs) readState() (serviceState, error) {
+	state := serviceState{}
+	if i.statePath == "" {
+		return state, nil
+	}
+	bytes, err := os.ReadFile(i.statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(bytes, &state)
+}
+
+// label names (and protects) services read from the kernel after the known
+// services, falling back to the state file
+func (i Ipvs) label(services []Service) error {
+	names := make(map[string]string)
+	protected := make(map[string]bool)
+	state, err := i.readState()
+	if err != nil {
+		return err
+	}
+	for key, name := range state.Names {
+		names[key] = name
+	}
+	for key := range state.Protected {
+		protected[key] = true
+	}
+	for j := range i.Services {
+		if i.Services[j].Name != "" {
+			names[i.Services[j].canonicalKey()] = i.Services[j].Name
+		}
+		if i.Services[j].Protected {
+			protected[i.Services[j].canonicalKey()] = true
+		}
+	}
+	for j := range services {
+		if services[j].Name == "" {
+			services[j].Name = names[services[j].canonicalKey()]
+		}
+		services[j].Protected = services[j].Protected || protected[services[j].canonicalKey()]
+	}
+	carryTimes(i.Services, services)
+	return nil
+}
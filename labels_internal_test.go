@@ -0,0 +1,61 @@
+package lvs
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStateFileNames(t *testing.T) {
+	defer useFakeBackend()()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	ipvs := NewIpvs(WithStateFile(path))
+	if err := ipvs.AddService(Service{Name: "web", Type: "tcp", Host: "2001:db8::1", Port: 80}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	if err := ipvs.AddService(Service{Type: "udp", Host: "10.0.0.1", Port: 53}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+
+	// a restarted director only knows what the kernel and the state file tell it
+	restarted := NewIpvs(WithStateFile(path))
+	fakeRunOutput = []byte("-A -t [2001:db8::1]:80 -s wlc\n-A -u 10.0.0.1:53 -s wlc\n")
+	if err := restarted.Save(); err != nil {
+		t.Fatalf("failed to save - %v", err)
+	}
+	if restarted.Services[0].Name != "web" || restarted.Services[1].Name != "" {
+		t.Errorf("names not restored - %+v", restarted.Services)
+	}
+
+	// renaming is skipped by ipvsadm but still kept
+	fakeExecuted = nil
+	if err := restarted.EditService(Service{Name: "www", Type: "tcp", Host: "2001:db8::1", Port: 80}); err != nil {
+		t.Fatalf("failed to rename - %v", err)
+	}
+	if len(fakeExecuted) != 0 {
+		t.Errorf("renaming shouldn't run ipvsadm - %v", fakeExecuted)
+	}
+	state, err := restarted.readState()
+	if err != nil || state.Names["-t [2001:db8::1]:80"] != "www" {
+		t.Errorf("rename not persisted - %+v %v", state, err)
+	}
+}
+
+func TestMetricsNames(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Name: "web.frontend", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}})
+	sink := &recordingSink{metrics: make(map[string]float64)}
+	exporter := MetricsExporter{Lvs: client, Sink: sink}
+
+	fakeRunOutput = []byte(statsOutput)
+	exporter.Export()
+	exporter.Export()
+	if _, ok := sink.metrics["service.web_frontend.connections"]; !ok {
+		t.Errorf("counters should be named after the service - %v", sink.metrics)
+	}
+	if sink.metrics["service.web_frontend.server.10_0_1_1_80.weight"] != 3 {
+		t.Errorf("gauges should be named after the service - %v", sink.metrics)
+	}
+}
@@ -0,0 +1,97 @@
+package lvs
+
+import (
+	"time"
+)
+
+type (
+	// LatencyWeighting has a HealthChecker derive the weights of a
+	// service's servers from how quickly they answer their checks. The
+	// fastest server in rotation gets MaxWeight and the others a share
+	// of it inversely proportional to their RTT, never less than MinWeight.
+	// Servers out of rotation (weight 0) are left alone
+	LatencyWeighting struct {
+		// Alpha smooths the RTT, between 0 and 1, higher values following
+		// the latest checks more closely. Defaults to 0.3
+		Alpha float64
+		// MinWeight and MaxWeight bound the derived weights, defaulting
+		// to 1 and 100
+		MinWeight int
+		MaxWeight int
+	}
+)
+
+var (
+	DefaultLatencyAlpha     = 0.3
+	DefaultLatencyMaxWeight = 100
+)
+
+// smooth returns the moving average of rtt with the previous average. A nil
+// LatencyWeighting still averages, with the default Alpha
+func (l *LatencyWeighting) smooth(average, rtt time.Duration) time.Duration {
+	if average == 0 {
+		return rtt
+	}
+	alpha := DefaultLatencyAlpha
+	if l != nil && l.Alpha > 0 && l.Alpha <= 1 {
+		alpha = l.Alpha
+	}
+	return time.Duration(alpha*float64(rtt) + (1-alpha)*float64(average))
+}
+
+// Weights returns the weights of servers, keyed by host:port, given their
+// average RTTs. Servers without an RTT are left out
+func (l *LatencyWeighting) Weights(rtts map[string]time.Duration) map[string]int {
+	low, high := l.MinWeight, l.MaxWeight
+	if high <= 0 {
+		high = DefaultLatencyMaxWeight
+	}
+	if low <= 0 {
+		low = 1
+	}
+	if low > high {
+		low = high
+	}
+
+	var fastest time.Duration
+	for _, rtt := range rtts {
+		if rtt > 0 && (fastest == 0 || rtt < fastest) {
+			fastest = rtt
+		}
+	}
+	weights := make(map[string]int)
+	for key, rtt := range rtts {
+		if rtt <= 0 {
+			continue
+		}
+		weight := int(float64(high)*float64(fastest)/float64(rtt) + 0.5)
+		if weight < low {
+			weight = low
+		}
+		weights[key] = weight
+	}
+	return weights
+}
+
+// weighByLatency sets the weights of service's servers in rotation from
+// their RTTs. Callers hold h.mu
+func (h *HealthChecker) weighByLatency(service Service) {
+	rtts := make(map[string]time.Duration)
+	for _, server := range service.Servers {
+		health, ok := h.health[healthKey(service, server)]
+		if !ok || !health.Healthy || health.Pinned || server.Weight == 0 {
+			continue
+		}
+		rtts[server.getHostPort()] = health.RTT
+	}
+	weights := h.Latency.Weights(rtts)
+	for _, server := range service.Servers {
+		weight, ok := weights[server.getHostPort()]
+		if !ok || weight == server.Weight {
+			continue
+		}
+		if h.setWeight(service, server, weight, "latency") == nil {
+			h.health[healthKey(service, server)].Weight = weight
+		}
+	}
+}
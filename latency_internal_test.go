@@ -0,0 +1,69 @@
+package lvs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWeights(t *testing.T) {
+	weighting := &LatencyWeighting{MinWeight: 10, MaxWeight: 100}
+	weights := weighting.Weights(map[string]time.Duration{
+		"10.0.1.1:80": 10 * time.Millisecond,
+		"10.0.1.2:80": 20 * time.Millisecond,
+		"10.0.1.3:80": time.Second,
+		"10.0.1.4:80": 0,
+	})
+	expected := map[string]int{"10.0.1.1:80": 100, "10.0.1.2:80": 50, "10.0.1.3:80": 10}
+	if len(weights) != len(expected) {
+		t.Fatalf("unexpected weights - %v", weights)
+	}
+	for key, weight := range expected {
+		if weights[key] != weight {
+			t.Errorf("expected %s to weigh %d, got %d", key, weight, weights[key])
+		}
+	}
+}
+
+func TestLatencySmooth(t *testing.T) {
+	weighting := &LatencyWeighting{Alpha: 0.5}
+	if rtt := weighting.smooth(0, 10*time.Millisecond); rtt != 10*time.Millisecond {
+		t.Errorf("first rtt should be taken as is, got %v", rtt)
+	}
+	if rtt := weighting.smooth(10*time.Millisecond, 20*time.Millisecond); rtt != 15*time.Millisecond {
+		t.Errorf("expected an average of 15ms, got %v", rtt)
+	}
+}
+
+func TestHealthCheckerLatency(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 1},
+		{Host: "10.0.1.3", Port: 80, Weight: 0},
+	}})
+	checker := &HealthChecker{Lvs: client, Latency: &LatencyWeighting{MaxWeight: 50}, Check: checkFunc(func(service Service, server Server) error {
+		if server.Host == "10.0.1.2" {
+			time.Sleep(40 * time.Millisecond)
+		}
+		return nil
+	})}
+	checker.CheckOnce()
+
+	servers := client.Services()[0].Servers
+	if servers[0].Weight != 50 {
+		t.Errorf("fastest server should get MaxWeight - %+v", servers[0])
+	}
+	if servers[1].Weight < 1 || servers[1].Weight >= 50 {
+		t.Errorf("slow server should get less traffic - %+v", servers[1])
+	}
+	if servers[2].Weight != 0 {
+		t.Errorf("drained server should stay out of rotation - %+v", servers[2])
+	}
+
+	health, _ := checker.ServerHealth(client.Services()[0], servers[1])
+	if health.RTT < 40*time.Millisecond || health.LastRTT < 40*time.Millisecond {
+		t.Errorf("rtt not recorded - %+v", health)
+	}
+}
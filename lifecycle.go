@@ -0,0 +1,145 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// Lifecycle runs the components of a program embedding the package and
+	// stops them in order, each stage bounded by its timeout: discovery
+	// first so nothing changes the table anymore, then health checks so
+	// they don't put drained servers back, then draining, then the api
+	Lifecycle struct {
+		// Discovery are what feed the table, eg. Watcher.Run,
+		// Reconciler.Run or Follower.Run
+		Discovery []func(stop <-chan struct{})
+		Health    []func(stop <-chan struct{}) // eg. HealthChecker.Run
+		Api       *http.Server                 // served with ListenAndServe when set
+
+		Lvs          *Lvs          // drained on shutdown, defaults to DefaultLvs
+		DrainTimeout time.Duration // how long servers are drained before the api stops, 0 skips draining
+
+		DiscoveryTimeout time.Duration // how long discovery may take to stop, defaults to DefaultStageTimeout
+		HealthTimeout    time.Duration // same for the health checks
+		ApiTimeout       time.Duration // same for in flight api requests
+	}
+
+	// StageTimeout is returned when a stage of a Lifecycle took longer than
+	// its timeout to stop, shutdown then moved on to the next stage
+	StageTimeout struct {
+		Stage   string
+		Timeout time.Duration
+	}
+)
+
+const (
+	StageDiscovery = "discovery"
+	StageHealth    = "health"
+	StageApi       = "api"
+)
+
+var (
+	DefaultStageTimeout = 10 * time.Second
+)
+
+func (e StageTimeout) Error() string {
+	return fmt.Sprintf("%s didn't stop within %s", e.Stage, e.Timeout)
+}
+
+// Run starts every component and blocks until stop is closed (or the api
+// fails), then shuts them down in order, returning the first error
+func (m *Lifecycle) Run(stop <-chan struct{}) error {
+	discovery, health := m.start(m.Discovery), m.start(m.Health)
+	served := make(chan error, 1)
+	if m.Api != nil {
+		go func() { served <- m.Api.ListenAndServe() }()
+	}
+
+	var err error
+	select {
+	case <-stop:
+	case err = <-served:
+	}
+
+	if stageErr := discovery(StageDiscovery, m.DiscoveryTimeout); err == nil {
+		err = stageErr
+	}
+	if stageErr := health(StageHealth, m.HealthTimeout); err == nil {
+		err = stageErr
+	}
+	if m.DrainTimeout > 0 {
+		l := m.Lvs
+		if l == nil {
+			l = DefaultLvs
+		}
+		// only waiting for connections to finish up once drained
+		if drainErr := l.Drain(); drainErr != nil {
+			if err == nil {
+				err = drainErr
+			}
+		} else {
+			time.Sleep(m.DrainTimeout)
+		}
+	}
+	if m.Api != nil {
+		if apiErr := m.stopApi(); err == nil {
+			err = apiErr
+		}
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// start runs components until the func returned is called, which stops
+// them and waits up to timeout for them to return
+func (m *Lifecycle) start(components []func(stop <-chan struct{})) func(stage string, timeout time.Duration) error {
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for _, run := range components {
+		wg.Add(1)
+		go func(run func(stop <-chan struct{})) {
+			defer wg.Done()
+			run(stop)
+		}(run)
+	}
+	return func(stage string, timeout time.Duration) error {
+		close(stop)
+		if timeout <= 0 {
+			timeout = DefaultStageTimeout
+		}
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(timeout):
+			return StageTimeout{Stage: stage, Timeout: timeout}
+		}
+	}
+}
+
+func (m *Lifecycle) stopApi() error {
+	timeout := m.ApiTimeout
+	if timeout <= 0 {
+		timeout = DefaultStageTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := m.Api.Shutdown(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return StageTimeout{Stage: StageApi, Timeout: timeout}
+		}
+		return err
+	}
+	return nil
+}
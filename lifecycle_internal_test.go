@@ -0,0 +1,103 @@
+package lvs
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	if err := client.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu := sync.Mutex{}
+	stopped := make([]string, 0, 0)
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = append(stopped, name)
+	}
+	recorded := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(stopped, ",")
+	}
+	component := func(name string) func(stop <-chan struct{}) {
+		return func(stop <-chan struct{}) {
+			<-stop
+			record(name)
+		}
+	}
+	api := &http.Server{Addr: "127.0.0.1:0"}
+	api.RegisterOnShutdown(func() {
+		if client.Services()[0].Servers[0].Weight != 0 {
+			t.Error("the api stopped before servers were drained")
+		}
+		record("api")
+	})
+	m := &Lifecycle{
+		Discovery:    []func(stop <-chan struct{}){component("watcher")},
+		Health:       []func(stop <-chan struct{}){component("health")},
+		Api:          api,
+		Lvs:          client,
+		DrainTimeout: time.Millisecond,
+	}
+	stop := make(chan struct{})
+	close(stop)
+	if err := m.Run(stop); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if recorded() != "watcher,health,api" {
+		t.Errorf("unexpected shutdown order - %v", stopped)
+	}
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	m = &Lifecycle{
+		Discovery:        []func(stop <-chan struct{}){func(<-chan struct{}) { <-stuck }},
+		Health:           []func(stop <-chan struct{}){component("health")},
+		DiscoveryTimeout: time.Millisecond,
+	}
+	mu.Lock()
+	stopped = nil
+	mu.Unlock()
+	err := m.Run(stop)
+	if timeout, ok := err.(StageTimeout); !ok || timeout.Stage != StageDiscovery {
+		t.Errorf("expected discovery to time out - %v", err)
+	}
+	if recorded() != "health" {
+		t.Errorf("expected health to stop after discovery timed out - %v", stopped)
+	}
+}
+
+func TestLifecycleDrainFailed(t *testing.T) {
+	simulator := NewSimulator()
+	client := New(WithRunner(simulator))
+	if err := client.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}}}); err != nil {
+		t.Fatal(err)
+	}
+	// removed behind the client's back, so draining fails
+	if err := NewIpvs(WithRunner(simulator)).Clear(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Lifecycle{Lvs: client, DrainTimeout: time.Hour}
+	stop := make(chan struct{})
+	close(stop)
+	done := make(chan error, 1)
+	go func() { done <- m.Run(stop) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrNoSuchService) {
+			t.Errorf("expected the drain error - %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected not to wait for connections when draining failed")
+	}
+}
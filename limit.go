@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"errors"
+)
+
+type (
+	// SyncLimit bounds how many servers a single Sync may remove, protecting
+	// against a bad discovery feed emptying the pool. Zero values are
+	// unlimited
+	SyncLimit struct {
+		MaxRemovals       int // servers
+		MaxRemovalPercent int // of the servers currently applied
+	}
+)
+
+var (
+	ErrTooManyChanges = errors.New("sync would remove more servers than allowed")
+	// ErrProtectedService is returned by Sync and Clear rather than removing
+	// a Protected service
+	ErrProtectedService = errors.New("service is protected from removal")
+)
+
+// WithSyncLimit has Sync refuse to remove more servers than limit allows,
+// failing with ErrTooManyChanges before changing anything
+func WithSyncLimit(limit SyncLimit) Option {
+	return func(i *Ipvs) {
+		i.syncLimit = limit
+	}
+}
+
+// check fails when going from current to wanted removes too many servers.
+// Servers of removed services count as removed too
+func (l SyncLimit) check(current, wanted []Service) error {
+	if l.MaxRemovals <= 0 && l.MaxRemovalPercent <= 0 {
+		return nil
+	}
+	total, removed := 0, removedServers(current, wanted)
+	for j := range current {
+		total += len(current[j].Servers)
+	}
+	if l.MaxRemovals > 0 && removed > l.MaxRemovals {
+		return ErrTooManyChanges
+	}
+	if l.MaxRemovalPercent > 0 && removed*100 > total*l.MaxRemovalPercent {
+		return ErrTooManyChanges
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestSyncLimit(t *testing.T) {
+	simulator := NewSimulator()
+	servers := []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1}, {Host: "10.0.1.3", Port: 80, Weight: 1}, {Host: "10.0.1.4", Port: 80, Weight: 1}}
+	NewIpvs(WithRunner(simulator)).Sync([]Service{
+		{Host: "10.0.0.1", Port: 80, Servers: servers},
+		{Host: "10.0.0.2", Port: 80, Servers: servers[:1]},
+	})
+
+	ipvs := NewIpvs(WithRunner(simulator), WithSyncLimit(SyncLimit{MaxRemovals: 1}))
+	err := ipvs.Sync([]Service{{Host: "10.0.0.1", Port: 80, Servers: servers[:3]}})
+	if err != ErrTooManyChanges {
+		t.Errorf("removing a service's servers should count, got %v", err)
+	}
+	if services := simulator.Services(); len(services) != 2 || len(services[0].Servers) != 4 {
+		t.Errorf("aborted sync changed the table - %+v", services)
+	}
+	if err := ipvs.Sync([]Service{{Host: "10.0.0.1", Port: 80, Servers: servers[1:]}, {Host: "10.0.0.2", Port: 80, Servers: servers[:1]}}); err != nil {
+		t.Errorf("removing one server should be allowed - %v", err)
+	}
+
+	ipvs = NewIpvs(WithRunner(simulator), WithSyncLimit(SyncLimit{MaxRemovalPercent: 50}))
+	if err := ipvs.Sync([]Service{}); err != ErrTooManyChanges {
+		t.Errorf("emptying the table should be refused, got %v", err)
+	}
+	if err := ipvs.Sync([]Service{{Host: "10.0.0.1", Port: 80, Servers: servers[1:]}}); err != nil {
+		t.Errorf("removing 1 of 4 servers should be allowed - %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package lvs
+
+import (
+	"strconv"
+	"strings"
+)
+
+type (
+	// IpvsadmVersion is a row of the compatibility matrix, recording which
+	// outputs of an ipvsadm release's format the parsers are tested against
+	IpvsadmVersion struct {
+		Version string
+		List    bool // `ipvsadm -L -n`, see ParseList
+		Save    bool // `ipvsadm -S -n`, see ParseSave
+	}
+)
+
+var (
+	// IpvsadmCompatibility is the compatibility matrix of ipvsadm releases,
+	// each one has hand-written outputs in its format in
+	// testdata/ipvsadm/<version>
+	IpvsadmCompatibility = []IpvsadmVersion{
+		{Version: "1.27", List: true, Save: true},
+		{Version: "1.28", List: true, Save: true},
+		{Version: "1.29", List: true, Save: true},
+		{Version: "1.30", List: true, Save: true},
+		{Version: "1.31", List: true, Save: true},
+	}
+
+	listServerForwarder = map[string]string{
+		"Route":  "g",
+		"Local":  "g",
+		"Tunnel": "i",
+		"Masq":   "m",
+	}
+)
+
+// List reads the services and servers applied on the host with
+// `ipvsadm -L -n`, without changing i
+func (i Ipvs) List() ([]Service, error) {
+	out, err := i.exec.run([]string{"ipvsadm", "-L", "-n"})
+	if err != nil {
+		return nil, err
+	}
+	services, err := ParseList(string(out))
+	if err != nil {
+		return nil, err
+	}
+	return services, i.label(services)
+}
+
+// ParseList parses the services and servers in the output of
+// `ipvsadm -L -n`. Flags it doesn't know (such as scheduler flags) are
+// ignored
+func ParseList(out string) ([]Service, error) {
+	services := make([]Service, 0, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "->" {
+			if len(fields) < 4 || fields[1] == "RemoteAddress:Port" {
+				continue
+			}
+			if len(services) == 0 {
+				return nil, UnexpecedToken
+			}
+			forwarder, ok := listServerForwarder[fields[2]]
+			if !ok {
+				return nil, UnexpecedToken
+			}
+			weight, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, UnexpecedToken
+			}
+			server := Server{Forwarder: forwarder, Weight: weight}
+			server.Host, server.Port = parseHostPort(fields[1])
+			services[len(services)-1].Servers = append(services[len(services)-1].Servers, server)
+			continue
+		}
+
+		netType, ok := statsServiceType[fields[0]]
+		if !ok || len(fields) < 3 {
+			continue
+		}
+		service := Service{Type: netType}
+		var err error
+		service.Host, service.Port, err = parseServiceAddress(netType, fields[1])
+		if err != nil {
+			return nil, err
+		}
+		j := 2
+		// fwmark services note the address family after the mark
+		if fields[j] == "IPv6" || fields[j] == "IPv4" {
+			j++
+		}
+		if j >= len(fields) {
+			return nil, EOFError
+		}
+		service.Scheduler = fields[j]
+		for j++; j < len(fields); j++ {
+			var err error
+			switch fields[j] {
+			case "persistent":
+				service.Persistence, err = nextInt(fields, j)
+			case "mask":
+				service.Netmask, err = nextToken(fields, j)
+			case "pe":
+				service.PersistenceEngine, err = nextToken(fields, j)
+			case "ops":
+				service.OnePacket = true
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
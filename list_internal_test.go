@@ -0,0 +1,83 @@
+package lvs
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestIpvsadmCompatibility parses the hand-written fixtures modeled on each
+// release's output, see testdata/ipvsadm/README.md
+func TestIpvsadmCompatibility(t *testing.T) {
+	for _, version := range IpvsadmCompatibility {
+		dir := filepath.Join("testdata", "ipvsadm", version.Version)
+		golden := filepath.Join(dir, "expected.json")
+
+		// save first, it's the output the golden file is updated from
+		files, parsers := []string{}, []func(string) ([]Service, error){}
+		if version.Save {
+			files, parsers = append(files, "save.txt"), append(parsers, ParseSave)
+		}
+		if version.List {
+			files, parsers = append(files, "list.txt"), append(parsers, ParseList)
+		}
+		for j, file := range files {
+			parse := parsers[j]
+			out, err := os.ReadFile(filepath.Join(dir, file))
+			if err != nil {
+				t.Fatalf("%s: missing fixture - %v", version.Version, err)
+			}
+			services, err := parse(string(out))
+			if err != nil {
+				t.Fatalf("%s: failed to parse %s - %v", version.Version, file, err)
+			}
+			actual, _ := json.MarshalIndent(services, "", "  ")
+			if *update && file == "save.txt" {
+				if err := os.WriteFile(golden, append(actual, '\n'), 0644); err != nil {
+					t.Fatalf("failed to update %s - %v", golden, err)
+				}
+			}
+
+			expected := make([]Service, 0, 0)
+			bytes, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s: missing golden file - %v", version.Version, err)
+			}
+			if err := json.Unmarshal(bytes, &expected); err != nil {
+				t.Fatalf("%s: bad golden file - %v", version.Version, err)
+			}
+			actualServices := make([]Service, 0, 0)
+			json.Unmarshal(actual, &actualServices)
+			if !reflect.DeepEqual(expected, actualServices) {
+				t.Errorf("%s: %s parsed as\n%s", version.Version, file, actual)
+			}
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	services, err := ParseList(`FWM  7 IPv6 rr
+  -> [2001:db8::2]:0              Local   1      0          0
+`)
+	if err != nil {
+		t.Fatalf("failed to parse - %v", err)
+	}
+	if len(services) != 1 || services[0].Type != "fwmark" || services[0].Host != "7" || services[0].Scheduler != "rr" {
+		t.Errorf("unexpected services - %+v", services)
+	}
+	if services[0].Servers[0].Host != "2001:db8::2" || services[0].Servers[0].Forwarder != "g" {
+		t.Errorf("unexpected server - %+v", services[0].Servers[0])
+	}
+
+	if _, err := ParseList("  -> 10.0.1.1:80 Route 1 0 0\n"); err != UnexpecedToken {
+		t.Errorf("expected UnexpecedToken for a server without a service, got %v", err)
+	}
+	if _, err := ParseList("TCP  10.0.0.1:80 wlc persistent\n"); err != EOFError {
+		t.Errorf("expected EOFError, got %v", err)
+	}
+}
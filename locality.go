@@ -0,0 +1,92 @@
+package lvs
+
+import (
+	"errors"
+)
+
+type (
+	// LocalityPolicy works out server weights from their Zone, so stretched
+	// deployments keep traffic from crossing datacenters where possible
+	LocalityPolicy struct {
+		// Mode is LocalityPreferLocal or LocalityWeightedByZone
+		Mode string `json:"mode"`
+		// LocalZone is the zone of the director, for LocalityPreferLocal
+		LocalZone string `json:"local_zone"`
+		// ZoneWeights scale the weights of each zone's servers by a
+		// percentage, for LocalityWeightedByZone. Zones not listed are left
+		// as they are
+		ZoneWeights map[string]int `json:"zone_weights"`
+	}
+)
+
+const (
+	// LocalityPreferLocal sends everything to servers in the local zone,
+	// falling back to the other zones only when no local server has weight
+	LocalityPreferLocal = "prefer-local"
+	// LocalityWeightedByZone scales weights by the percentage of their zone
+	LocalityWeightedByZone = "weighted-by-zone"
+)
+
+var (
+	InvalidLocalityPolicy = errors.New("Invalid Locality Policy")
+)
+
+func (p LocalityPolicy) Validate() error {
+	switch p.Mode {
+	case LocalityPreferLocal:
+		if p.LocalZone == "" {
+			return InvalidLocalityPolicy
+		}
+	case LocalityWeightedByZone:
+		for _, percent := range p.ZoneWeights {
+			if percent < 0 {
+				return InvalidLocalityPolicy
+			}
+		}
+	default:
+		return InvalidLocalityPolicy
+	}
+	return nil
+}
+
+// Apply returns a copy of service with the weights of its servers worked
+// out from their configured weight and zone
+func (p LocalityPolicy) Apply(service Service) (Service, error) {
+	if err := p.Validate(); err != nil {
+		return service, err
+	}
+	servers := make([]Server, len(service.Servers))
+	copy(servers, service.Servers)
+
+	switch p.Mode {
+	case LocalityPreferLocal:
+		local := false
+		for _, server := range servers {
+			if server.Zone == p.LocalZone && server.Weight > 0 {
+				local = true
+			}
+		}
+		if local {
+			for j := range servers {
+				if servers[j].Zone != p.LocalZone {
+					servers[j].Weight = 0
+				}
+			}
+		}
+	case LocalityWeightedByZone:
+		for j := range servers {
+			percent, ok := p.ZoneWeights[servers[j].Zone]
+			if !ok || servers[j].Weight == 0 {
+				continue
+			}
+			weight := servers[j].Weight * percent / 100
+			// only an explicit 0% takes a server out
+			if weight == 0 && percent > 0 {
+				weight = 1
+			}
+			servers[j].Weight = weight
+		}
+	}
+	service.Servers = servers
+	return service, nil
+}
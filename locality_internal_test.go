@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestLocalityPolicy(t *testing.T) {
+	service := Service{Host: "10.0.0.1", Port: 80, Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 10, Zone: "east"},
+		{Host: "10.0.1.2", Port: 80, Weight: 10, Zone: "west"},
+		{Host: "10.0.1.3", Port: 80, Weight: 3, Zone: "central"},
+	}}
+
+	local, err := LocalityPolicy{Mode: LocalityPreferLocal, LocalZone: "east"}.Apply(service)
+	if err != nil {
+		t.Fatalf("failed to apply - %v", err)
+	}
+	if weights(local) != [3]int{10, 0, 0} {
+		t.Errorf("expected only the local server, got %v", weights(local))
+	}
+	if service.Servers[1].Weight != 10 {
+		t.Errorf("policy changed the original servers")
+	}
+
+	service.Servers[0].Weight = 0
+	failover, _ := LocalityPolicy{Mode: LocalityPreferLocal, LocalZone: "east"}.Apply(service)
+	if weights(failover) != [3]int{0, 10, 3} {
+		t.Errorf("expected remote servers without a local one, got %v", weights(failover))
+	}
+	service.Servers[0].Weight = 10
+
+	zoned, err := LocalityPolicy{Mode: LocalityWeightedByZone, ZoneWeights: map[string]int{"east": 100, "west": 0, "central": 10}}.Apply(service)
+	if err != nil {
+		t.Fatalf("failed to apply - %v", err)
+	}
+	if weights(zoned) != [3]int{10, 0, 1} {
+		t.Errorf("unexpected zone weights %v", weights(zoned))
+	}
+
+	for _, invalid := range []LocalityPolicy{{}, {Mode: LocalityPreferLocal}, {Mode: LocalityWeightedByZone, ZoneWeights: map[string]int{"east": -1}}} {
+		if _, err := invalid.Apply(service); err != InvalidLocalityPolicy {
+			t.Errorf("expected InvalidLocalityPolicy for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+func weights(service Service) [3]int {
+	return [3]int{service.Servers[0].Weight, service.Servers[1].Weight, service.Servers[2].Weight}
+}
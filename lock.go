@@ -0,0 +1,65 @@
+package lvs
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrTableLocked = errors.New("ipvs table is locked by another process")
+
+	// DefaultTableLock is the lock file used by WithTableLock when given no path
+	DefaultTableLock = "/var/run/golang-lvs.lock"
+
+	// tableLockRetry is how often a held table lock is retried while waiting
+	tableLockRetry = 50 * time.Millisecond
+)
+
+// WithTableLock takes an advisory lock (flock) on the file at path around
+// Restore, AddServices, Sync and Clear, so processes sharing the file don't interleave
+// their changes to the table. A held lock is waited on for up to wait
+// before failing with ErrTableLocked, a negative wait waits forever. The
+// lock is taken on the local host, even when commands run elsewhere, and
+// is only supported on linux
+func WithTableLock(path string, wait time.Duration) Option {
+	if path == "" {
+		path = DefaultTableLock
+	}
+	return func(i *Ipvs) {
+		i.exec.lockPath = path
+		i.exec.lockWait = wait
+	}
+}
+
+// lockTable takes the table lock if one is configured, returning the func
+// releasing it
+func (e *executor) lockTable() (func(), error) {
+	if e == nil || e.lockPath == "" {
+		return func() {}, nil
+	}
+	file, err := os.OpenFile(e.lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(e.lockWait)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlock(file)
+				file.Close()
+			}, nil
+		}
+		if e.lockWait >= 0 && !time.Now().Before(deadline) {
+			file.Close()
+			return nil, ErrTableLocked
+		}
+		time.Sleep(tableLockRetry)
+	}
+}
@@ -0,0 +1,42 @@
+package lvs
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTableLock(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("table locks are only supported on linux")
+	}
+	defer useFakeBackend()()
+
+	path := filepath.Join(t.TempDir(), "lvs.lock")
+	holder := NewIpvs(WithTableLock(path, 0))
+	unlock, err := holder.exec.lockTable()
+	if err != nil {
+		t.Fatalf("failed to lock - %v", err)
+	}
+
+	ipvs := NewIpvs(WithTableLock(path, 0))
+	if err := ipvs.Restore([]Service{{Host: "10.0.0.1", Port: 80}}); err != ErrTableLocked {
+		t.Errorf("expected ErrTableLocked, got %v", err)
+	}
+	if len(fakeStdin) != 0 {
+		t.Errorf("restore ran without the lock - %q", fakeStdin)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		unlock()
+	}()
+	ipvs = NewIpvs(WithTableLock(path, 5*time.Second))
+	if err := ipvs.Sync([]Service{{Host: "10.0.0.1", Port: 80}}); err != nil {
+		t.Errorf("failed to sync once unlocked - %v", err)
+	}
+	if err := ipvs.Clear(); err != nil {
+		t.Errorf("failed to clear - %v", err)
+	}
+}
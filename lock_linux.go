@@ -0,0 +1,20 @@
+package lvs
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock takes an exclusive flock on file without blocking, reporting
+// whether it was taken
+func tryLock(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
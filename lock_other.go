@@ -0,0 +1,14 @@
+//go:build !linux
+
+package lvs
+
+import (
+	"os"
+)
+
+// tryLock is only supported on linux, other platforms always get the lock
+func tryLock(file *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(file *os.File) {}
@@ -1,9 +1,61 @@
 package lvs
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os/exec"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Runner runs the commands backing an Ipvs, swap it with WithRunner to
+	// manage a table somewhere other than the local host. Commands must be
+	// killed once ctx is done
+	Runner interface {
+		Execute(ctx context.Context, exe string, args ...string) error
+		ExecuteStdin(ctx context.Context, in, exe string, args ...string) error
+		// RunOutput runs a command whose output is needed, keeping stdout
+		// apart from stderr so warnings don't end up being parsed
+		RunOutput(ctx context.Context, exe string, args ...string) (stdout, stderr []byte, err error)
+	}
+
+	// localRunner runs commands on the local host
+	localRunner struct{}
+
+	// executor runs the backend commands for an Ipvs and its Services
+	executor struct {
+		runner Runner   // defaults to the local host
+		wrap   []string // command (and args) every backend command is run through
+
+		// how long a command may run before it is killed, 0 uses
+		// ExecTimeout and a negative timeout disables it
+		timeout time.Duration
+
+		flushConntrack bool               // see WithConntrackFlush
+		env            []string           // added to the environment of commands, see WithEnv
+		reachability   *ReachabilityCheck // see WithReachabilityCheck
+
+		history weightHistory // see WithWeightHistory
+
+		lockPath string        // see WithTableLock
+		elector  *Elector      // see WithLeaderElection
+		lockWait time.Duration // how long to wait for the table lock
+
+		executed atomic.Uint64 // ipvsadm changes run
+		skipped  atomic.Uint64 // ipvsadm changes skipped as they were already applied
+
+		jsonOutput atomic.Int32 // whether ipvsadm has --json: 1 yes, -1 no, 0 not known yet
+	}
+
+	// OpCounts counts the ipvsadm changes run for a table, and those skipped
+	// because the last known state showed they were already applied
+	OpCounts struct {
+		Executed uint64 `json:"executed"`
+		Skipped  uint64 `json:"skipped"`
+	}
 )
 
 var (
@@ -11,73 +63,195 @@ var (
 	NotFound       = errors.New("object was not found")
 	DeleteFailed   = errors.New("object was not deleted")
 	IpvsadmMissing = errors.New("unable to find the ipvsadm command on the system")
+	ErrTimeout     = errors.New("backend command timed out and was killed")
+
+	// ExecTimeout is how long backend commands may run for by default, a
+	// wedged netlink socket can otherwise hang ipvsadm forever
+	ExecTimeout = 5 * time.Second
 
 	// these are to allow a pluggable backend for testing, ipvsadm is
 	// not needed to run the tests
 	backend      = execute
-	backendRun   = run
+	backendRun   = runOutput
 	backendStdin = executeStdin
 )
 
 // Load verifies that lvs can be used, and populates it with values
 // from the backup file
 func Load() error {
-	if err := check(); err != nil {
-		return err
-	}
-
-	// NYI
-	// populate the ipvsadm command with what was stored in the backup
-	return nil
-}
-
-func check() error {
-	if err := backend("which", "ipvsadm"); err != nil {
-		return IpvsadmMissing
-	}
-	return nil
+	return DefaultLvs.Load()
 }
 
 func SetTimeouts() error {
-	return DefaultIpvs.SetTimeouts()
+	return DefaultLvs.SetTimeouts()
 }
 
 func StartDaemon() (error, error) {
-	return DefaultIpvs.StartDaemon()
+	return DefaultLvs.StartDaemon()
 }
 
 func StopDaemon() (error, error) {
-	return DefaultIpvs.StopDaemon()
+	return DefaultLvs.StopDaemon()
 }
 
 func Clear() error {
-	return DefaultIpvs.Clear()
+	return DefaultLvs.Clear()
 }
 
 func Restore(services []Service) error {
-	return DefaultIpvs.Restore(services)
+	return DefaultLvs.Restore(services)
 }
 
 func Save() error {
-	return DefaultIpvs.Save()
+	return DefaultLvs.Save()
+}
+
+func Drain() error {
+	return DefaultLvs.Drain()
 }
 
 func Zero() error {
-	return DefaultIpvs.Zero()
+	return DefaultLvs.Zero()
 }
 
-func run(args []string) ([]byte, error) {
-	cmd := exec.Command(args[0], args[1:]...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, errors.New(err.Error() + " output: " + string(output))
+// execute runs a backend command, wrapped by e when it is configured (eg. to
+// enter a network namespace). A nil executor runs commands as is
+func (e *executor) execute(exe string, args ...string) error {
+	if !e.leads() {
+		return ErrNotLeader
+	}
+	e.count(exe)
+	ctx, cancel := e.context()
+	defer cancel()
+	command, args := e.wrapCommand(exe, args)
+	return timedOut(ctx, ipvsadmErr(exe, e.backend().Execute(ctx, command, args...)))
+}
+
+func (e *executor) run(args []string) ([]byte, error) {
+	ctx, cancel := e.context()
+	defer cancel()
+	exe, rest := e.wrapCommand(args[0], args[1:])
+	stdout, _, err := e.backend().RunOutput(ctx, exe, rest...)
+	return stdout, timedOut(ctx, ipvsadmErr(args[0], err))
+}
+
+func (e *executor) executeStdin(in, exe string, args ...string) error {
+	if !e.leads() {
+		return ErrNotLeader
+	}
+	e.count(exe)
+	ctx, cancel := e.context()
+	defer cancel()
+	command, args := e.wrapCommand(exe, args)
+	return timedOut(ctx, ipvsadmErr(exe, e.backend().ExecuteStdin(ctx, in, command, args...)))
+}
+
+// leads reports whether the commands changing the table may run, see
+// WithLeaderElection
+func (e *executor) leads() bool {
+	return e == nil || e.elector.IsLeader()
+}
+
+// count counts the ipvsadm changes run
+func (e *executor) count(exe string) {
+	if e != nil && exe == "ipvsadm" {
+		e.executed.Add(1)
+	}
+}
+
+// skip counts a change that wasn't run as it was already applied
+func (e *executor) skip() {
+	if e != nil {
+		e.skipped.Add(1)
+	}
+}
+
+func (e *executor) opCounts() OpCounts {
+	if e == nil {
+		return OpCounts{}
+	}
+	return OpCounts{Executed: e.executed.Load(), Skipped: e.skipped.Load()}
+}
+
+// context bounds a single backend command by the configured timeout
+func (e *executor) context() (context.Context, context.CancelFunc) {
+	timeout := ExecTimeout
+	if e != nil && e.timeout != 0 {
+		timeout = e.timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if timeout < 0 {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	return e.withEnv(ctx), cancel
+}
+
+// timedOut replaces the error of a command killed by its deadline with
+// ErrTimeout
+func timedOut(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return err
+}
+
+func (e *executor) backend() Runner {
+	if e == nil || e.runner == nil {
+		return localRunner{}
+	}
+	return e.runner
+}
+
+func (e *executor) wrapCommand(exe string, args []string) (string, []string) {
+	if e == nil || len(e.wrap) == 0 {
+		return exe, args
+	}
+	wrapped := append(append(append([]string{}, e.wrap[1:]...), exe), args...)
+	return e.wrap[0], wrapped
+}
+
+// WithTimeout sets how long each backend command may run before it is
+// killed, overriding ExecTimeout. A negative timeout disables it
+func WithTimeout(timeout time.Duration) Option {
+	return func(i *Ipvs) {
+		i.exec.timeout = timeout
+	}
+}
+
+// WithRunner runs every backend command with r
+func WithRunner(r Runner) Option {
+	return func(i *Ipvs) {
+		i.exec.runner = r
+	}
+}
+
+func (l localRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	return backend(ctx, exe, args...)
+}
+
+func (l localRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	return backendStdin(ctx, in, exe, args...)
+}
+
+func (l localRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	return backendRun(ctx, exe, args...)
+}
+
+func runOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Env = localEnv(ctx)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), stderr.Bytes(), errors.New(err.Error() + " output: " + stderr.String())
 	}
-	return output, err
+	return stdout.Bytes(), stderr.Bytes(), nil
 }
 
-func execute(exe string, args ...string) error {
+func execute(ctx context.Context, exe string, args ...string) error {
 	// fmt.Printf("%s\n", strings.Join(append([]string{exe}, args...), " "))
-	cmd := exec.Command(exe, args...)
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Env = localEnv(ctx)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return errors.New(err.Error() + ": " + string(output))
@@ -85,13 +259,14 @@ func execute(exe string, args ...string) error {
 	return nil
 }
 
-func executeStdin(in, exe string, args ...string) error {
+func executeStdin(ctx context.Context, in, exe string, args ...string) error {
 	// fmt.Printf("%s\n%s\n", strings.Join(append([]string{exe}, args...), " "), in)
 	var err error
 	var total, part, segment int
 	var stdin io.WriteCloser
 
-	cmd := exec.Command(exe, args...)
+	cmd := exec.CommandContext(ctx, exe, args...)
+	cmd.Env = localEnv(ctx)
 	stdin, err = cmd.StdinPipe()
 	defer stdin.Close()
 	if err = cmd.Start(); err != nil {
@@ -1,28 +1,64 @@
 package lvs
 
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
 var (
 	fakeRunOutput       []byte
 	fakeRunErr          error
 	fakeExecuteErr      error
 	fakeExecuteStdinErr error
+	fakeExecuted        []string
+	fakeExecutedArgs    [][]string
+	fakeStdin           []string
+	fakeHosts           map[string][]net.IP
+	fakeMu              sync.Mutex
 )
 
-func fakeRun(args []string) ([]byte, error) {
+// useFakeBackend swaps in the fake backend, recording executed commands,
+// and returns a func restoring the real one
+func useFakeBackend() func() {
+	fakeRunOutput, fakeRunErr, fakeExecuteErr, fakeExecuteStdinErr = nil, nil, nil, nil
+	fakeExecuted, fakeExecutedArgs, fakeStdin, fakeHosts = nil, nil, nil, nil
+	backend, backendRun, backendStdin, lookupIP = fakeExecute, fakeRun, fakeExecuteStdin, fakeLookupIP
+	return func() {
+		backend, backendRun, backendStdin, lookupIP = execute, runOutput, executeStdin, net.LookupIP
+	}
+}
+
+// fakeLookupIP resolves the hostnames in fakeHosts rather than using dns
+func fakeLookupIP(host string) ([]net.IP, error) {
+	if ips, ok := fakeHosts[host]; ok {
+		return ips, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func fakeRun(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
 	// cmd := exec.Command(args[0], args[1:]...)
 	// output, err := cmd.CombinedOutput()
 	// if err != nil {
 	// 	return nil, errors.New(err.Error() + " output: " + string(output))
 	// }
-	return fakeRunOutput, fakeRunErr
+	return fakeRunOutput, nil, fakeRunErr
 }
 
-func fakeExecute(exe string, args ...string) error {
+func fakeExecute(ctx context.Context, exe string, args ...string) error {
 	// // fmt.Printf("%s\n", strings.Join(append([]string{exe}, args...), " "))
 	// cmd := exec.Command(exe, args...)
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeExecuted = append(fakeExecuted, strings.Join(append([]string{exe}, args...), " "))
+	fakeExecutedArgs = append(fakeExecutedArgs, append([]string{exe}, args...))
 	return fakeExecuteErr
 }
 
-func fakeExecuteStdin(in, exe string, args ...string) error {
+func fakeExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
 	// var err error
 	// var total, part, segment int
 	// var stdin io.WriteCloser
@@ -41,5 +77,23 @@ func fakeExecuteStdin(in, exe string, args ...string) error {
 	// 		return err
 	// 	}
 	// }
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeStdin = append(fakeStdin, in)
 	return fakeExecuteStdinErr
 }
+
+func TestRunOutput(t *testing.T) {
+	stdout, stderr, err := runOutput(context.Background(), "sh", "-c", "echo out; echo warning >&2")
+	if err != nil {
+		t.Fatalf("failed to run - %v", err)
+	}
+	if string(stdout) != "out\n" || string(stderr) != "warning\n" {
+		t.Errorf("stdout and stderr should be kept apart - '%s', '%s'", stdout, stderr)
+	}
+
+	_, _, err = runOutput(context.Background(), "sh", "-c", "echo failed >&2; exit 2")
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("error should include stderr - %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Package lvstest provides throwaway network namespaces with real ipvs
+// tables, so code using lvs can be tested end to end without touching the
+// host's table. It needs root, the ip and ipvsadm commands and the ip_vs
+// kernel module; New skips the test when any of them is missing.
+package lvstest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+type (
+	// Namespace is a network namespace created for a test
+	Namespace struct {
+		Name string
+	}
+)
+
+var (
+	NotRoot = errors.New("lvstest needs to run as root")
+
+	count uint32
+)
+
+// Available reports why namespaces with ipvs tables can't be created on this
+// host, or nil if they can
+func Available() error {
+	if os.Geteuid() != 0 {
+		return NotRoot
+	}
+	for _, exe := range []string{"ip", "ipvsadm", "nsenter"} {
+		if _, err := exec.LookPath(exe); err != nil {
+			return err
+		}
+	}
+	// loads the ip_vs module if it isn't already
+	return run("ipvsadm", "-L", "-n")
+}
+
+// New creates a namespace with its loopback up, removing it when the test
+// finishes. The test is skipped if namespaces aren't available
+func New(t testing.TB) *Namespace {
+	t.Helper()
+	if err := Available(); err != nil {
+		t.Skipf("network namespaces unavailable - %v", err)
+	}
+	n, err := NewNamespace(fmt.Sprintf("lvstest-%d-%d", os.Getpid(), atomic.AddUint32(&count, 1)))
+	if err != nil {
+		t.Fatalf("failed to create namespace - %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+// NewNamespace creates a namespace named name with its loopback up
+func NewNamespace(name string) (*Namespace, error) {
+	if err := run("ip", "netns", "add", name); err != nil {
+		return nil, err
+	}
+	n := &Namespace{Name: name}
+	if err := n.Exec("ip", "link", "set", "lo", "up"); err != nil {
+		n.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+// Close removes the namespace along with its ipvs table and interfaces
+func (n *Namespace) Close() error {
+	return run("ip", "netns", "del", n.Name)
+}
+
+// Lvs returns a client managing the namespace's ipvs table
+func (n *Namespace) Lvs() *lvs.Lvs {
+	return lvs.New(lvs.WithNetns(n.Name))
+}
+
+// Exec runs a command inside the namespace
+func (n *Namespace) Exec(exe string, args ...string) error {
+	return run("ip", append([]string{"netns", "exec", n.Name, exe}, args...)...)
+}
+
+// AddAddress assigns addr (cidr notation) to the namespace's loopback, eg.
+// to hold a vip
+func (n *Namespace) AddAddress(addr string) error {
+	return n.Exec("ip", "addr", "add", addr, "dev", "lo")
+}
+
+// Connect links n to peer with a veth pair, assigning addr (cidr notation)
+// to n's end and peerAddr to peer's end
+func (n *Namespace) Connect(peer *Namespace, addr, peerAddr string) error {
+	id := atomic.AddUint32(&count, 1)
+	local, remote := fmt.Sprintf("lvsa%d", id), fmt.Sprintf("lvsb%d", id)
+	steps := [][]string{
+		{"ip", "link", "add", local, "netns", n.Name, "type", "veth", "peer", "name", remote, "netns", peer.Name},
+		{"ip", "-n", n.Name, "addr", "add", addr, "dev", local},
+		{"ip", "-n", peer.Name, "addr", "add", peerAddr, "dev", remote},
+		{"ip", "-n", n.Name, "link", "set", local, "up"},
+		{"ip", "-n", peer.Name, "link", "set", remote, "up"},
+	}
+	for i := range steps {
+		if err := run(steps[i][0], steps[i][1:]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func run(exe string, args ...string) error {
+	output, err := exec.Command(exe, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", exe, strings.Join(args, " "), err, output)
+	}
+	return nil
+}
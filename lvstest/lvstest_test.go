@@ -0,0 +1,38 @@
+package lvstest
+
+import (
+	"testing"
+
+	lvs "github.com/mu-box/golang-lvs"
+)
+
+func TestSyncInNamespace(t *testing.T) {
+	director := New(t)
+	backend := New(t)
+	if err := director.Connect(backend, "10.99.0.1/24", "10.99.0.2/24"); err != nil {
+		t.Fatalf("failed to connect namespaces - %v", err)
+	}
+	if err := director.AddAddress("10.98.0.1/32"); err != nil {
+		t.Fatalf("failed to add vip - %v", err)
+	}
+
+	client := director.Lvs()
+	services := []lvs.Service{{
+		Host: "10.98.0.1", Port: 80, Type: "tcp", Scheduler: "rr",
+		Servers: []lvs.Server{{Host: "10.99.0.2", Port: 80, Forwarder: "m", Weight: 1}},
+	}}
+	if err := client.Sync(services); err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+
+	err := client.Do(func(i *lvs.Ipvs) error {
+		converged, err := i.Converged(services)
+		if err == nil && !converged {
+			t.Errorf("namespace table doesn't match the synced services")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read the namespace table - %v", err)
+	}
+}
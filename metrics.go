@@ -0,0 +1,303 @@
+package lvs
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// MetricsSink receives the metrics exported by a MetricsExporter
+	MetricsSink interface {
+		Gauge(name string, value float64) error
+		Count(name string, delta float64) error
+		Flush() error
+	}
+
+	// StatsdSink sends metrics to a statsd (or graphite via statsd) server
+	// over udp, batching them into packets until flushed
+	StatsdSink struct {
+		Addr   string // host:port of the statsd server
+		Prefix string // prepended to every metric, eg. "lvs."
+
+		mu   sync.Mutex
+		conn net.Conn
+		buf  bytes.Buffer
+	}
+
+	// MetricsExporter periodically reads the client's stats and sends them
+	// to Sink. Connections, packets and bytes are sent as counters of what
+	// changed since the last flush, weights, server counts and the
+	// services' SLIs as gauges
+	MetricsExporter struct {
+		Lvs      *Lvs // defaults to DefaultLvs
+		Sink     MetricsSink
+		Interval time.Duration // defaults to 10s
+		OnError  func(error)
+		// CountersPath is where the last exported counters are saved, so
+		// the first export after a restart sends what changed since the
+		// last one before it rather than only recording a baseline
+		CountersPath string
+		// Templates also sends the number of persistence templates of each
+		// persistent service, per server and per client network, to find
+		// what skews the load of persistent services. It reads the
+		// connection table, which is slow when it is large
+		Templates bool
+		// TemplatePrefix and TemplatePrefix6 are the prefix lengths client
+		// networks are counted by, 24 and 64 by default
+		TemplatePrefix, TemplatePrefix6 int
+		// TemplateClients is how many of a service's client networks (those
+		// with the most templates) are sent, 10 by default
+		TemplateClients int
+
+		last   map[string]Stats
+		loaded bool
+		sli    SLITracker
+		// clientGauges are the client network gauges sent by the last
+		// export, by service
+		clientGauges map[string]map[string]bool
+	}
+)
+
+var (
+	// statsdPacketSize keeps packets under the usual ethernet mtu
+	statsdPacketSize = 1432
+)
+
+func (s *StatsdSink) Gauge(name string, value float64) error {
+	return s.write(name, value, "g")
+}
+
+func (s *StatsdSink) Count(name string, delta float64) error {
+	return s.write(name, delta, "c")
+}
+
+func (s *StatsdSink) write(name string, value float64, kind string) error {
+	line := s.Prefix + name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() > 0 && s.buf.Len()+1+len(line) > statsdPacketSize {
+		if err := s.send(); err != nil {
+			return err
+		}
+	}
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	s.buf.WriteString(line)
+	return nil
+}
+
+// Flush sends any buffered metrics
+func (s *StatsdSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	return s.send()
+}
+
+func (s *StatsdSink) send() error {
+	defer s.buf.Reset()
+	if s.conn == nil {
+		conn, err := net.Dial("udp", s.Addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	_, err := s.conn.Write(s.buf.Bytes())
+	return err
+}
+
+// Close closes the connection to the statsd server
+func (s *StatsdSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Run exports metrics every Interval until stop is closed
+func (e *MetricsExporter) Run(stop <-chan struct{}) {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Export(); err != nil && e.OnError != nil {
+			e.OnError(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Export sends the current metrics to the sink and flushes it
+func (e *MetricsExporter) Export() error {
+	if e.Lvs == nil {
+		e.Lvs = DefaultLvs
+	}
+	var list []byte
+	var stats []ServiceStats
+	var templates []Connection
+	err := e.Lvs.Do(func(i *Ipvs) error {
+		var err error
+		if list, err = i.exec.run([]string{"ipvsadm", "-L", "-n"}); err != nil {
+			return err
+		}
+		if e.Templates {
+			if templates, err = i.PersistenceTemplates(); err != nil {
+				return err
+			}
+		}
+		stats, err = i.Stats()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	slis := e.sli.update(time.Now(), string(list), stats)
+	if e.CountersPath != "" && !e.loaded {
+		if e.last, _, err = loadCounters(e.CountersPath); err != nil {
+			return err
+		}
+		e.loaded = true
+	}
+
+	services := e.Lvs.Services()
+	names := make(map[string]string)
+	for _, service := range services {
+		names[service.canonicalKey()] = service.Name
+	}
+
+	last := e.last
+	e.last = make(map[string]Stats)
+	for j, service := range stats {
+		name := "service." + metricName(service.Type, service.hostPort())
+		if label := names[Service{Type: service.Type, Host: service.Host, Port: service.Port}.canonicalKey()]; label != "" {
+			name = "service." + metricName(label)
+		}
+		if err := e.count(name, service.Stats, last); err != nil {
+			return err
+		}
+		if err := e.gaugeSLI(name, slis[j]); err != nil {
+			return err
+		}
+		for _, server := range service.Servers {
+			if err := e.count(name+".server."+metricName(server.Host, strconv.Itoa(server.Port)), server.Stats, last); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, service := range services {
+		name := "service." + metricName(service.Type, service.getHostPort())
+		if service.Name != "" {
+			name = "service." + metricName(service.Name)
+		}
+		if err := e.Sink.Gauge(name+".servers", float64(len(service.Servers))); err != nil {
+			return err
+		}
+		for _, server := range service.Servers {
+			if err := e.Sink.Gauge(name+".server."+metricName(server.Host, strconv.Itoa(server.Port))+".weight", float64(server.Weight)); err != nil {
+				return err
+			}
+		}
+		if e.Templates && service.Persistence > 0 {
+			if err := e.gaugeTemplates(name, service, templates); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.Sink.Flush(); err != nil {
+		return err
+	}
+	if e.CountersPath != "" {
+		return saveCounters(e.CountersPath, e.last, time.Now())
+	}
+	return nil
+}
+
+// gaugeSLI sends the SLIs of a service
+func (e *MetricsExporter) gaugeSLI(name string, sli ServiceSLI) error {
+	spike := 0.0
+	if sli.InactiveSpike {
+		spike = 1
+	}
+	gauges := []struct {
+		name  string
+		value float64
+	}{
+		{"availability", sli.Availability},
+		{"connections_per_second", sli.ConnectionsPerSecond},
+		{"active_conns", float64(sli.ActiveConns)},
+		{"inactive_conns", float64(sli.InactiveConns)},
+		{"inactive_spike", spike},
+	}
+	for _, gauge := range gauges {
+		if err := e.Sink.Gauge(name+"."+gauge.name, gauge.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count sends the counters that changed since the last export. The first
+// export only records a baseline, and counters that went backwards (zeroed)
+// are sent in full
+func (e *MetricsExporter) count(name string, stats Stats, last map[string]Stats) error {
+	e.last[name] = stats
+	previous, ok := last[name]
+	if !ok {
+		return nil
+	}
+	counters := []struct {
+		name      string
+		now, then uint64
+	}{
+		{"connections", stats.Connections, previous.Connections},
+		{"packets_in", stats.PacketsIn, previous.PacketsIn},
+		{"packets_out", stats.PacketsOut, previous.PacketsOut},
+		{"bytes_in", stats.BytesIn, previous.BytesIn},
+		{"bytes_out", stats.BytesOut, previous.BytesOut},
+	}
+	for _, counter := range counters {
+		delta := counter.now
+		if counter.now >= counter.then {
+			delta = counter.now - counter.then
+		}
+		if err := e.Sink.Count(name+"."+counter.name, float64(delta)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricName joins parts into a single metric path segment, anything but
+// letters, digits, _ and - becoming _ so names can't break statsd lines
+func metricName(parts ...string) string {
+	if parts[0] == "" {
+		parts[0] = "tcp"
+	}
+	name := strings.NewReplacer("[", "", "]", "").Replace(strings.Join(parts, "_"))
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,147 @@
+package lvs
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordingSink struct {
+	metrics map[string]float64
+	flushes int
+}
+
+func (s *recordingSink) Gauge(name string, value float64) error {
+	s.metrics[name] = value
+	return nil
+}
+
+func (s *recordingSink) Count(name string, delta float64) error {
+	s.metrics[name] = delta
+	return nil
+}
+
+func (s *recordingSink) Flush() error {
+	s.flushes++
+	return nil
+}
+
+func TestMetricsExporter(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}})
+	sink := &recordingSink{metrics: make(map[string]float64)}
+	exporter := MetricsExporter{Lvs: client, Sink: sink}
+
+	fakeRunOutput = []byte(statsOutput)
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if _, ok := sink.metrics["service.tcp_10_0_0_1_80.connections"]; ok {
+		t.Errorf("first export should only record a baseline")
+	}
+	if sink.metrics["service.tcp_10_0_0_1_80.server.10_0_1_1_80.weight"] != 3 {
+		t.Errorf("missing weight gauge - %v", sink.metrics)
+	}
+	if _, ok := sink.metrics["service.tcp_10_0_0_1_80.availability"]; !ok {
+		t.Errorf("missing sli gauges - %v", sink.metrics)
+	}
+
+	fakeRunOutput = []byte(strings.Replace(statsOutput, "10.0.0.1:80                        30", "10.0.0.1:80                        42", 1))
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if sink.metrics["service.tcp_10_0_0_1_80.connections"] != 12 {
+		t.Errorf("expected 12 new connections, got %v", sink.metrics)
+	}
+	if sink.metrics["service.udp_10_0_0_1_53.server.10_0_1_3_53.bytes_out"] != 0 {
+		t.Errorf("expected no new bytes, got %v", sink.metrics)
+	}
+	if sink.flushes != 2 {
+		t.Errorf("expected 2 flushes, got %d", sink.flushes)
+	}
+}
+
+func TestMetricsExporterCountersPath(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}})
+	path := filepath.Join(t.TempDir(), "counters.json")
+	fakeRunOutput = []byte(statsOutput)
+	if err := (&MetricsExporter{Lvs: client, Sink: &recordingSink{metrics: make(map[string]float64)}, CountersPath: path}).Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+
+	// a restarted exporter carries on from the saved counters
+	sink := &recordingSink{metrics: make(map[string]float64)}
+	fakeRunOutput = []byte(strings.Replace(statsOutput, "10.0.0.1:80                        30", "10.0.0.1:80                        42", 1))
+	if err := (&MetricsExporter{Lvs: client, Sink: sink, CountersPath: path}).Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if sink.metrics["service.tcp_10_0_0_1_80.connections"] != 12 {
+		t.Errorf("expected 12 new connections since the restart, got %v", sink.metrics)
+	}
+
+	// counters zeroed by a reboot are sent in full
+	sink = &recordingSink{metrics: make(map[string]float64)}
+	fakeRunOutput = []byte(strings.Replace(statsOutput, "10.0.0.1:80                        30", "10.0.0.1:80                         5", 1))
+	if err := (&MetricsExporter{Lvs: client, Sink: sink, CountersPath: path}).Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if sink.metrics["service.tcp_10_0_0_1_80.connections"] != 5 {
+		t.Errorf("expected the zeroed counter in full, got %v", sink.metrics)
+	}
+}
+
+func TestStatsdSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen - %v", err)
+	}
+	defer conn.Close()
+
+	sink := &StatsdSink{Addr: conn.LocalAddr().String(), Prefix: "lvs."}
+	defer sink.Close()
+	sink.Gauge("servers", 2)
+	sink.Count("connections", 1.5)
+	if err := sink.Flush(); err != nil {
+		t.Fatalf("failed to flush - %v", err)
+	}
+
+	buf := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("failed to read - %v", err)
+	}
+	if string(buf[:n]) != "lvs.servers:2|g\nlvs.connections:1.5|c" {
+		t.Errorf("unexpected packet %q", buf[:n])
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	tests := map[string][]string{
+		"web_front_v2":       {"web front|v2"},
+		"api_1_2-blue_":      {"api@1#2-blue\n"},
+		"tcp_2001_db8__1_80": {"", "[2001:db8::1]", "80"},
+	}
+	for expected, parts := range tests {
+		if name := metricName(parts...); name != expected {
+			t.Errorf("expected %q for %q, got %q", expected, parts, name)
+		}
+	}
+
+	defer useFakeBackend()()
+	client := New()
+	client.AddService(Service{Name: "web front|v2", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 3}}})
+	sink := &recordingSink{metrics: make(map[string]float64)}
+	fakeRunOutput = []byte(statsOutput)
+	if err := (&MetricsExporter{Lvs: client, Sink: sink}).Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if sink.metrics["service.web_front_v2.server.10_0_1_1_80.weight"] != 3 {
+		t.Errorf("expected the name sanitized - %v", sink.metrics)
+	}
+}
@@ -0,0 +1,114 @@
+package lvs
+
+import (
+	"errors"
+	"strconv"
+)
+
+type (
+	// Mirror copies a fraction of a service's traffic (with iptables' TEE
+	// target) to Gateway, a director balancing the copies over staging
+	// servers with a fwmark service, for shadow testing new backends. The
+	// staging servers' replies must not reach the clients, eg. masquerade
+	// them through a staging director that drops the replies
+	Mirror struct {
+		Service  Service  `json:"service"`  // production service whose traffic is copied
+		Fraction float64  `json:"fraction"` // of packets copied, from 0 to 1
+		Gateway  string   `json:"gateway"`  // address of the staging director
+		Fwmark   int      `json:"fwmark"`   // marks the copies on the staging director
+		Servers  []Server `json:"servers"`  // staging servers
+	}
+)
+
+var (
+	InvalidMirror = errors.New("Invalid Mirror")
+)
+
+func (m Mirror) Validate() error {
+	if m.Fraction <= 0 || m.Fraction > 1 || m.Gateway == "" || m.Fwmark <= 0 || m.Service.Port == 0 {
+		return InvalidMirror
+	}
+	if ServiceTypeFlag[m.Service.Type] == "-f" {
+		return InvalidMirror
+	}
+	return m.StagingService().Validate()
+}
+
+// StagingService is the fwmark service balancing the copies over the
+// staging servers
+func (m Mirror) StagingService() Service {
+	return Service{
+		Host:      strconv.Itoa(m.Fwmark),
+		Type:      "fwmark",
+		Scheduler: m.Service.Scheduler,
+		Servers:   m.Servers,
+	}
+}
+
+// TeeArgs returns the iptables arguments copying the service's packets to
+// the gateway, action being -A to add the rule or -D to delete it
+func (m Mirror) TeeArgs(action string) []string {
+	args := append([]string{"-t", "mangle", action, "PREROUTING"}, m.match()...)
+	if m.Fraction < 1 {
+		args = append(args, "-m", "statistic", "--mode", "random", "--probability", strconv.FormatFloat(m.Fraction, 'f', -1, 64))
+	}
+	return append(args, "-j", "TEE", "--gateway", m.Gateway)
+}
+
+// MarkArgs returns the iptables arguments marking the copies on the
+// staging director for the staging service
+func (m Mirror) MarkArgs(action string) []string {
+	args := append([]string{"-t", "mangle", action, "PREROUTING"}, m.match()...)
+	return append(args, "-j", "MARK", "--set-mark", strconv.Itoa(m.Fwmark))
+}
+
+func (m Mirror) match() []string {
+	protocol := "tcp"
+	if ServiceTypeFlag[m.Service.Type] == "-u" {
+		protocol = "udp"
+	}
+	return []string{"-d", m.Service.Host, "-p", protocol, "--dport", strconv.Itoa(m.Service.Port)}
+}
+
+// AddMirror sets up m, adding the staging service and its mark rule to
+// staging (the client itself when nil) before copying traffic to it
+func (l *Lvs) AddMirror(m Mirror, staging *Lvs) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if staging == nil {
+		staging = l
+	}
+	err := staging.Do(func(i *Ipvs) error {
+		if err := i.exec.execute("iptables", m.MarkArgs("-A")...); err != nil {
+			return err
+		}
+		return i.AddService(m.StagingService())
+	})
+	if err != nil {
+		return err
+	}
+	return l.Do(func(i *Ipvs) error {
+		return i.exec.execute("iptables", m.TeeArgs("-A")...)
+	})
+}
+
+// RemoveMirror stops copying traffic for m, then removes its staging
+// service and mark rule from staging (the client itself when nil)
+func (l *Lvs) RemoveMirror(m Mirror, staging *Lvs) error {
+	if staging == nil {
+		staging = l
+	}
+	err := l.Do(func(i *Ipvs) error {
+		return i.exec.execute("iptables", m.TeeArgs("-D")...)
+	})
+	if err != nil {
+		return err
+	}
+	return staging.Do(func(i *Ipvs) error {
+		if err := i.exec.execute("iptables", m.MarkArgs("-D")...); err != nil {
+			return err
+		}
+		return i.RemoveService("fwmark", strconv.Itoa(m.Fwmark), 0)
+	})
+}
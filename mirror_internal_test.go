@@ -0,0 +1,60 @@
+package lvs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	defer useFakeBackend()()
+
+	mirror := Mirror{
+		Service:  Service{Host: "10.0.0.1", Port: 80},
+		Fraction: 0.1,
+		Gateway:  "10.0.2.1",
+		Fwmark:   9,
+		Servers:  []Server{{Host: "10.0.3.1", Forwarder: "m", Weight: 1}},
+	}
+	production, staging := New(), New()
+	if err := production.AddMirror(mirror, staging); err != nil {
+		t.Fatalf("failed to add mirror - %v", err)
+	}
+	expected := []string{
+		"iptables -t mangle -A PREROUTING -d 10.0.0.1 -p tcp --dport 80 -j MARK --set-mark 9",
+		"ipvsadm -A -f 9 -s wlc",
+		"ipvsadm -a -f 9 -r 10.0.3.1:0 -m -y 0 -x 0 -w 1",
+		"iptables -t mangle -A PREROUTING -d 10.0.0.1 -p tcp --dport 80 -m statistic --mode random --probability 0.1 -j TEE --gateway 10.0.2.1",
+	}
+	if strings.Join(fakeExecuted, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(fakeExecuted, "\n"))
+	}
+	if len(staging.Services()) != 1 || len(production.Services()) != 0 {
+		t.Errorf("staging service added to the wrong client")
+	}
+
+	fakeExecuted = nil
+	if err := production.RemoveMirror(mirror, staging); err != nil {
+		t.Fatalf("failed to remove mirror - %v", err)
+	}
+	if len(fakeExecuted) != 3 || !strings.Contains(fakeExecuted[0], "-D PREROUTING") || fakeExecuted[2] != "ipvsadm -D -f 9" {
+		t.Errorf("unexpected removal %q", fakeExecuted)
+	}
+	if len(staging.Services()) != 0 {
+		t.Errorf("staging service not removed")
+	}
+
+	for _, invalid := range []Mirror{
+		{Service: mirror.Service, Fraction: 0, Gateway: "10.0.2.1", Fwmark: 9},
+		{Service: mirror.Service, Fraction: 1.5, Gateway: "10.0.2.1", Fwmark: 9},
+		{Service: mirror.Service, Fraction: 1, Fwmark: 9},
+		{Service: mirror.Service, Fraction: 1, Gateway: "10.0.2.1"},
+	} {
+		if err := invalid.Validate(); err != InvalidMirror {
+			t.Errorf("expected InvalidMirror for %+v, got %v", invalid, err)
+		}
+	}
+	mirror.Fraction = 1
+	if args := strings.Join(mirror.TeeArgs("-A"), " "); strings.Contains(args, "statistic") {
+		t.Errorf("mirroring everything shouldn't sample - %s", args)
+	}
+}
@@ -14,4 +14,14 @@ type (
 	FromJson interface {
 		FromJson([]byte) error
 	}
+
+	// ToGob and FromGob are compact binary encodings, for persisting state or
+	// replicating it between directors
+	ToGob interface {
+		ToGob() ([]byte, error)
+	}
+
+	FromGob interface {
+		FromGob([]byte) error
+	}
 )
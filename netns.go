@@ -0,0 +1,23 @@
+package lvs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// WithNetns runs every backend command inside the network namespace at path,
+// so a director running in a container can be managed from the host. A bare
+// name refers to a namespace created with `ip netns add`
+func WithNetns(path string) Option {
+	return func(i *Ipvs) {
+		i.exec.wrap = append(netnsCommand(path), i.exec.wrap...)
+	}
+}
+
+// netnsCommand returns the command entering the network namespace at path
+func netnsCommand(path string) []string {
+	if !strings.Contains(path, "/") {
+		path = filepath.Join("/var/run/netns", path)
+	}
+	return []string{"nsenter", "--net=" + path, "--"}
+}
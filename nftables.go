@@ -0,0 +1,194 @@
+package lvs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// UnsupportedNftables is returned for services nftables can't express,
+	// such as servers of another address family than the service
+	UnsupportedNftables = errors.New("service can't be expressed as nftables rules")
+
+	// NftablesMaxSlots caps the entries of a rule's map, weights needing
+	// more are scaled down to fit, see Service.NftablesRule
+	NftablesMaxSlots = 256
+)
+
+// NftablesRule returns an nftables rule DNATing the service's traffic to
+// its servers, for evaluating a move away from ipvs while keeping services
+// as the source of truth. The schedulers are approximated:
+//   - rr and wrr round robin with numgen inc
+//   - sh, mh and persistent services hash the source address with jhash,
+//     ignoring the netmask
+//   - dh hashes the destination address with jhash
+//   - the others (lc, wlc, sed, nq, lblc, lblcr) pick at random with numgen
+//     random, nftables doesn't count connections
+//
+// Weights are kept by listing servers as many times as their weight
+// (reduced by their greatest common divisor), servers with weight 0 are left
+// out. Weights adding up to more than NftablesMaxSlots are scaled down
+// proportionally to fit, rounded down but keeping every server at least
+// once. Every forwarder becomes DNAT, so replies must be routed back through
+// the host as with masquerading. A service without servers in rotation has
+// no rule, and an empty string is returned
+func (s Service) NftablesRule() (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	servers := make([]Server, 0, len(s.Servers))
+	for _, server := range s.Servers {
+		if server.Weight > 0 {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		return "", nil
+	}
+
+	family := ""
+	remaps := false
+	for _, server := range servers {
+		ip := net.ParseIP(server.Host)
+		if ip == nil {
+			return "", UnsupportedNftables
+		}
+		serverFamily := "ip6"
+		if ip.To4() != nil {
+			serverFamily = "ip"
+		}
+		if family != "" && family != serverFamily {
+			return "", UnsupportedNftables
+		}
+		family = serverFamily
+		remaps = remaps || server.Port != s.Port
+	}
+
+	match := make([]string, 0, 0)
+	if ServiceTypeFlag[s.Type] == "-f" {
+		mark, err := strconv.ParseUint(s.Host, 0, 32)
+		if err != nil {
+			return "", UnsupportedNftables
+		}
+		match = append(match, fmt.Sprintf("meta mark 0x%x", mark))
+	} else {
+		ip := net.ParseIP(s.Host)
+		if ip == nil {
+			return "", UnsupportedNftables
+		}
+		if (ip.To4() != nil) != (family == "ip") {
+			return "", UnsupportedNftables
+		}
+		protocol := "tcp"
+		if ServiceTypeFlag[s.Type] == "-u" {
+			protocol = "udp"
+		}
+		match = append(match, family+" daddr "+ip.String())
+		if s.Port == 0 {
+			match = append(match, "meta l4proto "+protocol)
+		} else {
+			match = append(match, protocol+" dport "+strconv.Itoa(s.Port))
+		}
+	}
+
+	slots := nftablesSlots(servers)
+	entries := make([]string, len(slots))
+	for j, server := range slots {
+		target := server.Host
+		if remaps {
+			target += " . " + strconv.Itoa(server.Port)
+		}
+		entries[j] = fmt.Sprintf("%d : %s", j, target)
+	}
+
+	var pick string
+	switch {
+	case s.Persistence > 0 || s.Scheduler == "sh" || s.Scheduler == "mh":
+		pick = fmt.Sprintf("jhash %s saddr mod %d", family, len(slots))
+	case s.Scheduler == "dh":
+		pick = fmt.Sprintf("jhash %s daddr mod %d", family, len(slots))
+	case s.Scheduler == "rr" || s.Scheduler == "wrr":
+		pick = fmt.Sprintf("numgen inc mod %d", len(slots))
+	default:
+		pick = fmt.Sprintf("numgen random mod %d", len(slots))
+	}
+
+	dnat := "dnat " + family + " to "
+	if remaps {
+		dnat = "dnat " + family + " addr . port to "
+	}
+	return strings.Join(match, " ") + " " + dnat + pick + " map { " + strings.Join(entries, ", ") + " }", nil
+}
+
+// NftablesRuleset returns an nftables ruleset, to load with `nft -f`,
+// replacing the inet table named table (lvs when empty) with the rules of
+// every service, see Service.NftablesRule
+func NftablesRuleset(table string, services []Service) (string, error) {
+	if table == "" {
+		table = "lvs"
+	}
+	lines := []string{
+		"table inet " + table,
+		"flush table inet " + table,
+		"table inet " + table + " {",
+		"\tchain prerouting {",
+		"\t\ttype nat hook prerouting priority -100; policy accept;",
+	}
+	for _, service := range services {
+		rule, err := service.NftablesRule()
+		if err != nil {
+			return "", err
+		}
+		if rule == "" {
+			continue
+		}
+		comment := service.Type + " " + service.getHostPort()
+		if service.Name != "" {
+			comment = service.Name + " (" + comment + ")"
+		}
+		lines = append(lines, "\t\t# "+comment, "\t\t"+rule)
+	}
+	lines = append(lines, "\t}", "}")
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// nftablesSlots lists each server as many times as its weight, reduced by
+// the weights' greatest common divisor and scaled down to NftablesMaxSlots
+func nftablesSlots(servers []Server) []Server {
+	weights := make([]int, len(servers))
+	total := 0
+	for j, server := range servers {
+		weights[j] = server.Weight
+		total += server.Weight
+	}
+	if total > NftablesMaxSlots {
+		for j := range weights {
+			weights[j] = weights[j] * NftablesMaxSlots / total
+			if weights[j] < 1 {
+				weights[j] = 1
+			}
+		}
+	}
+	divisor := 0
+	for _, weight := range weights {
+		divisor = gcd(divisor, weight)
+	}
+	slots := make([]Server, 0, 0)
+	for j, server := range servers {
+		for n := 0; n < weights[j]/divisor; n++ {
+			slots = append(slots, server)
+		}
+	}
+	return slots
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,76 @@
+package lvs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNftablesRule(t *testing.T) {
+	tests := []struct {
+		service Service
+		rule    string
+		err     error
+	}{
+		{
+			Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "wrr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 4}, {Host: "10.0.1.2", Port: 80, Weight: 2}, {Host: "10.0.1.3", Port: 80, Weight: 0}}},
+			"ip daddr 10.0.0.1 tcp dport 80 dnat ip to numgen inc mod 3 map { 0 : 10.0.1.1, 1 : 10.0.1.1, 2 : 10.0.1.2 }", nil,
+		},
+		{
+			Service{Type: "udp", Host: "2001:db8::1", Port: 53, Persistence: 300, Servers: []Server{{Host: "2001:db8::2", Port: 5353, Forwarder: "m", Weight: 1}}},
+			"ip6 daddr 2001:db8::1 udp dport 53 dnat ip6 addr . port to jhash ip6 saddr mod 1 map { 0 : 2001:db8::2 . 5353 }", nil,
+		},
+		{
+			Service{Type: "fwmark", Host: "16", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1}}},
+			"meta mark 0x10 dnat ip addr . port to numgen random mod 2 map { 0 : 10.0.1.1 . 80, 1 : 10.0.1.2 . 80 }", nil,
+		},
+		{
+			Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 0}}},
+			"", nil,
+		},
+		{
+			Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "2001:db8::2", Port: 80, Weight: 1}}},
+			"", UnsupportedNftables,
+		},
+	}
+	for _, test := range tests {
+		rule, err := test.service.NftablesRule()
+		if rule != test.rule || err != test.err {
+			t.Errorf("expected '%s' (%v), got '%s' (%v)", test.rule, test.err, rule, err)
+		}
+	}
+}
+
+func TestNftablesRuleset(t *testing.T) {
+	ruleset, err := NftablesRuleset("", []Service{
+		{Name: "web", Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "sh", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	expected := strings.Join([]string{
+		"table inet lvs",
+		"flush table inet lvs",
+		"table inet lvs {",
+		"\tchain prerouting {",
+		"\t\ttype nat hook prerouting priority -100; policy accept;",
+		"\t\t# web (tcp 10.0.0.1:80)",
+		"\t\tip daddr 10.0.0.1 tcp dport 80 dnat ip to jhash ip saddr mod 1 map { 0 : 10.0.1.1 }",
+		"\t}",
+		"}",
+	}, "\n") + "\n"
+	if ruleset != expected {
+		t.Errorf("unexpected ruleset:\n%s", ruleset)
+	}
+}
+
+func TestNftablesSlots(t *testing.T) {
+	servers := []Server{{Host: "10.0.1.1", Weight: 65535}, {Host: "10.0.1.2", Weight: 32768}, {Host: "10.0.1.3", Weight: 1}}
+	slots := nftablesSlots(servers)
+	counts := make(map[string]int)
+	for _, server := range slots {
+		counts[server.Host]++
+	}
+	if len(slots) > NftablesMaxSlots || counts["10.0.1.1"] != 170 || counts["10.0.1.2"] != 85 || counts["10.0.1.3"] != 1 {
+		t.Errorf("unexpected slots - %v", counts)
+	}
+}
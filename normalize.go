@@ -0,0 +1,143 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"unicode"
+)
+
+var (
+	InvalidHost = errors.New("Invalid Host, expected a single address")
+	// ErrInvalidCharacters is returned for fields passed to the backend
+	// with whitespace or shell metacharacters. Commands aren't run through
+	// a shell locally, but they are over ssh and by CommandRunner scripts
+	ErrInvalidCharacters = errors.New("Invalid Characters, whitespace and shell metacharacters aren't allowed")
+
+	// lookupIP resolves hostnames, swapped out by the tests
+	lookupIP = net.LookupIP
+)
+
+// NormalizeHost returns host with ip addresses in their canonical form
+// (ipv6 lowercased and compressed, without brackets), so the same address
+// always compares equal to itself and to what the kernel reports. CIDR
+// notation and hosts with spaces fail with InvalidHost. Anything else, such
+// as an interface name, a hostname or a fwmark, is returned as is
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if strings.ContainsAny(host, "/ \t") {
+		return host, InvalidHost
+	}
+	if ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")); ip != nil {
+		return ip.String(), nil
+	}
+	return host, nil
+}
+
+// checkCharacters fails with ErrInvalidCharacters when a field contains
+// whitespace, a control character or a shell metacharacter. Brackets and
+// colons are allowed for ipv6 addresses and interface labels
+func checkCharacters(fields ...string) error {
+	for _, field := range fields {
+		for _, r := range field {
+			if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(";&|$`'\"\\<>(){}*?!#~^", r) {
+				return ErrInvalidCharacters
+			}
+		}
+	}
+	return nil
+}
+
+// Normalize returns s with its host and its servers' in canonical form, see
+// NormalizeHost, fwmarks in decimal without a port. Servers given by
+// hostname are resolved to their address, the service's own host is only
+// resolved when applied as it may name an interface. Servers get the
+// service's defaults for their forwarder and port, and weights within its
+// WeightBounds
+func (s Service) Normalize() (Service, error) {
+	host, err := NormalizeHost(s.Host)
+	if err != nil {
+		return s, err
+	}
+	s.Host = host
+	if ServiceTypeFlag[s.Type] == "-f" {
+		// as the kernel reports it, eg. 16 for 0x10
+		if s.Host, err = parseFwmark(s.Host); err != nil {
+			return s, err
+		}
+		s.Port = 0
+	}
+	if s.Servers != nil {
+		servers := make([]Server, len(s.Servers))
+		for j := range s.Servers {
+			servers[j], err = s.withDefaults(s.Servers[j]).Normalize()
+			if err != nil {
+				return s, err
+			}
+			if servers[j], err = s.boundWeight(servers[j]); err != nil {
+				return s, err
+			}
+		}
+		s.Servers = servers
+	}
+	return s, nil
+}
+
+// Normalize returns s with its host in canonical form, resolving hostnames
+// to their (first ipv4, or first) address
+func (s Server) Normalize() (Server, error) {
+	host, err := NormalizeHost(s.Host)
+	if err != nil {
+		return s, err
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		host, err = lookupHost(host)
+		if err != nil {
+			return s, err
+		}
+	}
+	s.Host = host
+	return s, nil
+}
+
+func lookupHost(host string) (string, error) {
+	ips, err := lookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", InvalidHost
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip.String(), nil
+		}
+	}
+	return ips[0].String(), nil
+}
+
+// normalizeServices normalizes every service, see Service.Normalize
+func normalizeServices(services []Service) ([]Service, error) {
+	normalized := make([]Service, len(services))
+	for j := range services {
+		service, err := services[j].Normalize()
+		if err != nil {
+			return nil, err
+		}
+		normalized[j] = service
+	}
+	return normalized, nil
+}
+
+// canonicalHost is host normalized, or host itself when it can't be
+func canonicalHost(host string) string {
+	normalized, err := NormalizeHost(host)
+	if err != nil {
+		return host
+	}
+	return normalized
+}
+
+func sameHost(a, b string) bool {
+	return a == b || canonicalHost(a) == canonicalHost(b)
+}
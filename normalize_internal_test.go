@@ -0,0 +1,104 @@
+package lvs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host, normalized string
+		err              error
+	}{
+		{"10.0.0.1", "10.0.0.1", nil},
+		{" 10.0.0.1 ", "10.0.0.1", nil},
+		{"2001:DB8:0:0::1", "2001:db8::1", nil},
+		{"[2001:db8::1]", "2001:db8::1", nil},
+		{"::ffff:10.0.0.1", "10.0.0.1", nil},
+		{"eth0:web", "eth0:web", nil},
+		{"16", "16", nil},
+		{"10.0.0.0/24", "10.0.0.0/24", InvalidHost},
+		{"2001:db8::/64", "2001:db8::/64", InvalidHost},
+	}
+	for _, test := range tests {
+		normalized, err := NormalizeHost(test.host)
+		if normalized != test.normalized || err != test.err {
+			t.Errorf("expected '%s' (%v) for '%s', got '%s' (%v)", test.normalized, test.err, test.host, normalized, err)
+		}
+	}
+}
+
+func TestNormalizeService(t *testing.T) {
+	defer useFakeBackend()()
+	fakeHosts = map[string][]net.IP{"web1.example.com": {net.ParseIP("2001:db8::10"), net.ParseIP("10.0.1.1")}}
+
+	ipvs := NewIpvs()
+	err := ipvs.AddService(Service{Type: "tcp", Host: "2001:DB8::1", Port: 80, Servers: []Server{
+		{Host: "web1.example.com", Port: 80, Weight: 1},
+		{Host: "2001:DB8:0::2", Port: 80, Weight: 1},
+	}})
+	if err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	service := ipvs.Services[0]
+	if service.Host != "2001:db8::1" || service.Servers[0].Host != "10.0.1.1" || service.Servers[1].Host != "2001:db8::2" {
+		t.Errorf("hosts not normalized - %+v", service)
+	}
+	if ipvs.FindService("tcp", "2001:db8:0::1", 80) == nil || service.FindServer("[2001:db8::2]", 80) == nil {
+		t.Error("differently written hosts should be found")
+	}
+
+	// the kernel's state compares equal however the host was written
+	fakeExecuted = nil
+	fakeRunOutput = []byte("-A -t [2001:db8::1]:80 -s wlc\n-a -t [2001:db8::1]:80 -r 10.0.1.1:80 -g -w 1\n-a -t [2001:db8::1]:80 -r [2001:db8::2]:80 -g -w 1\n")
+	if err := ipvs.Sync([]Service{{Type: "tcp", Host: "2001:0db8::1", Port: 80, Servers: []Server{
+		{Host: "web1.example.com", Port: 80, Weight: 1},
+		{Host: "2001:db8::2", Port: 80, Weight: 1},
+	}}}); err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+	if len(fakeExecuted) != 0 {
+		t.Errorf("nothing should change - %v", fakeExecuted)
+	}
+
+	if err := ipvs.AddService(Service{Type: "tcp", Host: "10.0.0.0/24", Port: 80}); err != InvalidHost {
+		t.Errorf("expected InvalidHost for a cidr, got %v", err)
+	}
+	if err := ipvs.AddService(Service{Type: "tcp", Host: "10.0.0.2", Port: 80, Servers: []Server{{Host: "missing.example.com", Port: 80}}}); err == nil {
+		t.Error("unresolvable servers should fail")
+	}
+}
+
+func TestInvalidCharacters(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	tests := []Service{
+		{Type: "tcp", Host: "10.0.0.1;reboot", Port: 80},
+		{Type: "tcp", Host: "$(id)", Port: 80},
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Netmask: "255.255.255.0 -x"},
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr|sh"},
+		{Type: "tcp", Host: "10.0.0.1 10.0.0.2", Port: 80},
+		{Type: "tcp", Host: "10.0.0.1\t-x", Port: 80},
+	}
+	for _, service := range tests {
+		if err := ipvs.AddService(service); err != ErrInvalidCharacters {
+			t.Errorf("expected ErrInvalidCharacters for %+v, got %v", service, err)
+		}
+	}
+	if len(fakeExecuted) != 0 {
+		t.Errorf("nothing should have been run - %q", fakeExecuted)
+	}
+
+	if err := ipvs.AddService(Service{Type: "tcp", Host: "[2001:db8::1]", Port: 80, Netmask: "255.255.255.0"}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	for _, host := range []string{"10.0.1.1`id`", "10.0.1.1 10.0.1.2", "10.0.1.1\t-x"} {
+		if err := (Server{Host: host, Port: 80}).Validate(); err != ErrInvalidCharacters {
+			t.Errorf("expected ErrInvalidCharacters for server %q, got %v", host, err)
+		}
+	}
+	if err := ipvs.Services[0].AddServer(Server{Host: "10.0.1.1`id`", Port: 80}); err != ErrInvalidCharacters {
+		t.Errorf("expected ErrInvalidCharacters for the server, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/smtp"
+	"strings"
+	"text/template"
+)
+
+type (
+	// SlackNotifier posts a message to a Slack incoming webhook when servers
+	// go down or come back up
+	SlackNotifier struct {
+		WebhookURL string
+		Channel    string   // overrides the webhook's channel when set
+		Username   string   // overrides the webhook's username when set
+		Template   string   // text/template rendered with the Event, defaults to DefaultNotifyTemplate
+		Events     []string // defaults to EventServerDown and EventServerUp
+		OnError    func(Event, error)
+	}
+
+	// SMTPNotifier emails To when servers go down or come back up, like
+	// ldirectord's email alerts
+	SMTPNotifier struct {
+		Addr     string    // host:port of the mail server
+		Auth     smtp.Auth // optional
+		From     string
+		To       []string
+		Subject  string   // text/template rendered with the Event, defaults to DefaultNotifySubject
+		Template string   // text/template rendered with the Event, defaults to DefaultNotifyTemplate
+		Events   []string // defaults to EventServerDown and EventServerUp
+		OnError  func(Event, error)
+	}
+)
+
+var (
+	DefaultNotifyTemplate = `{{.Type}}{{with .Server}} real server {{.Host}}:{{.Port}}{{end}}{{with .Service}} of {{.Host}}{{if .Port}}:{{.Port}}{{end}}{{end}}{{with .Message}}: {{.}}{{end}}`
+	DefaultNotifySubject  = `[lvs] {{.Type}}{{with .Server}} {{.Host}}:{{.Port}}{{end}}`
+
+	notifyEvents = []string{EventServerDown, EventServerUp}
+
+	// to allow sending mail to be faked in tests
+	smtpSendMail = smtp.SendMail
+)
+
+func (n *SlackNotifier) HandleEvent(e Event) {
+	if !notifyWants(n.Events, e.Type) {
+		return
+	}
+	go func() {
+		if err := n.Notify(e); err != nil && n.OnError != nil {
+			n.OnError(e, err)
+		}
+	}()
+}
+
+// Notify posts the message for e
+func (n *SlackNotifier) Notify(e Event) error {
+	text, err := renderNotification(n.Template, DefaultNotifyTemplate, e)
+	if err != nil {
+		return err
+	}
+	message := map[string]string{"text": text}
+	if n.Channel != "" {
+		message["channel"] = n.Channel
+	}
+	if n.Username != "" {
+		message["username"] = n.Username
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return (&Webhook{URL: n.WebhookURL}).deliver(body)
+}
+
+func (n *SMTPNotifier) HandleEvent(e Event) {
+	if !notifyWants(n.Events, e.Type) {
+		return
+	}
+	go func() {
+		if err := n.Notify(e); err != nil && n.OnError != nil {
+			n.OnError(e, err)
+		}
+	}()
+}
+
+// Notify emails the message for e
+func (n *SMTPNotifier) Notify(e Event) error {
+	subject, err := renderNotification(n.Subject, DefaultNotifySubject, e)
+	if err != nil {
+		return err
+	}
+	text, err := renderNotification(n.Template, DefaultNotifyTemplate, e)
+	if err != nil {
+		return err
+	}
+	message := "From: " + n.From + "\r\n" +
+		"To: " + strings.Join(n.To, ", ") + "\r\n" +
+		"Subject: " + strings.NewReplacer("\r", " ", "\n", " ").Replace(subject) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + text + "\r\n"
+	return smtpSendMail(n.Addr, n.Auth, n.From, n.To, []byte(message))
+}
+
+func notifyWants(events []string, eventType string) bool {
+	if len(events) == 0 {
+		events = notifyEvents
+	}
+	return wantsEvent(events, eventType)
+}
+
+// renderNotification renders text (or fallback when empty) with e
+func renderNotification(text, fallback string, e Event) (string, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	out := bytes.Buffer{}
+	if err := tmpl.Execute(&out, e); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
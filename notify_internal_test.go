@@ -0,0 +1,73 @@
+package lvs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+var notifyEvent = Event{
+	Type:    EventServerDown,
+	Service: &Service{Host: "10.0.0.1", Port: 80},
+	Server:  &Server{Host: "10.0.1.1", Port: 80},
+	Message: "connection refused",
+}
+
+func TestSlackNotifier(t *testing.T) {
+	message := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&message)
+	}))
+	defer server.Close()
+
+	notifier := &SlackNotifier{WebhookURL: server.URL, Channel: "#ops"}
+	if err := notifier.Notify(notifyEvent); err != nil {
+		t.Fatalf("failed to notify - %v", err)
+	}
+	if message["text"] != "server-down real server 10.0.1.1:80 of 10.0.0.1:80: connection refused" || message["channel"] != "#ops" {
+		t.Errorf("unexpected message - %v", message)
+	}
+	if _, ok := message["username"]; ok {
+		t.Errorf("unset username was sent - %v", message)
+	}
+
+	notifier.Template = "{{.Server.Host}} is {{if eq .Type \"server-up\"}}up{{else}}down{{end}}"
+	notifier.Notify(notifyEvent)
+	if message["text"] != "10.0.1.1 is down" {
+		t.Errorf("template not used - %v", message)
+	}
+
+	if !notifyWants(nil, EventServerUp) || notifyWants(nil, EventSyncApplied) {
+		t.Errorf("notifiers should default to server up and down events")
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	var sentTo []string
+	var sent string
+	smtpSendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		sentTo, sent = to, string(msg)
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+
+	notifier := &SMTPNotifier{Addr: "localhost:25", From: "lvs@example.com", To: []string{"ops@example.com", "oncall@example.com"}}
+	if err := notifier.Notify(notifyEvent); err != nil {
+		t.Fatalf("failed to notify - %v", err)
+	}
+	if len(sentTo) != 2 {
+		t.Errorf("unexpected recipients - %v", sentTo)
+	}
+	for _, part := range []string{
+		"To: ops@example.com, oncall@example.com\r\n",
+		"Subject: [lvs] server-down 10.0.1.1:80\r\n",
+		"\r\n\r\nserver-down real server 10.0.1.1:80 of 10.0.0.1:80: connection refused\r\n",
+	} {
+		if !strings.Contains(sent, part) {
+			t.Errorf("missing %q from %q", part, sent)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package lvs
+
+import (
+	"fmt"
+)
+
+type (
+	// OrphanPolicy is what happens to the orphans found on start, servers
+	// left in the table (eg. by a controller that crashed between applying
+	// them and persisting its desired services) that the desired services
+	// don't have
+	OrphanPolicy int
+
+	// Orphan is a server in the table absent from the desired services
+	Orphan struct {
+		Service Service `json:"service"` // the applied service, without its servers
+		Server  Server  `json:"server"`
+	}
+)
+
+const (
+	// OrphanReport reports the orphans and leaves them in the table until
+	// the next sync, for an operator to adopt or remove them
+	OrphanReport OrphanPolicy = iota
+	// OrphanAdopt adds the orphans to the desired services
+	OrphanAdopt
+	// OrphanRemove removes the orphans from the table
+	OrphanRemove
+)
+
+func (o Orphan) String() string {
+	return fmt.Sprintf("server %s of %s %s", o.Server.getHostPort(), o.Service.Type, o.Service.getHostPort())
+}
+
+// Orphans reads the rules applied on the host and returns the servers
+// absent from services, without changing i
+func (i *Ipvs) Orphans(services []Service) ([]Orphan, error) {
+	applied := Ipvs{exec: i.exec}
+	if err := applied.Save(); err != nil {
+		return nil, err
+	}
+	desired := make(map[string]Service)
+	for _, wanted := range services {
+		// a copy, the caller's services are left alone
+		wanted.exec = i.exec
+		service, err := wanted.resolve()
+		if err != nil {
+			return nil, err
+		}
+		service, err = service.Normalize()
+		if err != nil {
+			return nil, err
+		}
+		desired[service.canonicalKey()] = service
+	}
+	orphans := make([]Orphan, 0, 0)
+	for _, current := range applied.Services {
+		service := desired[current.canonicalKey()]
+		for _, server := range current.Servers {
+			if service.FindServer(server.Host, server.Port) != nil {
+				continue
+			}
+			orphan := Orphan{Service: current, Server: server}
+			orphan.Service.Servers = nil
+			orphan.Service.exec = nil
+			orphans = append(orphans, orphan)
+		}
+	}
+	return orphans, nil
+}
+
+// CollectOrphans finds the orphans of services (see Orphans) and handles
+// them per policy, returning them along with the services to sync: services
+// plus the orphans under OrphanReport and OrphanAdopt (which callers then
+// persist), services as given under OrphanRemove, once the orphans are
+// removed from the table
+func (i *Ipvs) CollectOrphans(services []Service, policy OrphanPolicy) ([]Service, []Orphan, error) {
+	orphans, err := i.Orphans(services)
+	if err != nil || len(orphans) == 0 {
+		return services, orphans, err
+	}
+	if policy != OrphanRemove {
+		return withOrphans(services, orphans), orphans, nil
+	}
+
+	defer i.changing()()
+	if err := i.Save(); err != nil {
+		return nil, nil, err
+	}
+	for _, orphan := range orphans {
+		service := i.FindService(orphan.Service.Type, orphan.Service.Host, orphan.Service.Port)
+		if service == nil {
+			continue
+		}
+		if _, err := service.RemoveServerChanged(orphan.Server.Host, orphan.Server.Port); err != nil {
+			return nil, nil, err
+		}
+	}
+	return services, orphans, i.writeState()
+}
+
+// withOrphans returns a copy of services with the orphans added to their
+// service, which is added too when services don't have it
+func withOrphans(services []Service, orphans []Orphan) []Service {
+	services = copyServices(services)
+	for _, orphan := range orphans {
+		found := -1
+		for j := range services {
+			resolved, err := services[j].resolve()
+			if err != nil {
+				continue
+			}
+			if resolved.canonicalKey() == orphan.Service.canonicalKey() {
+				found = j
+				break
+			}
+		}
+		if found < 0 {
+			service := orphan.Service
+			service.Servers = make([]Server, 0, 1)
+			services = append(services, service)
+			found = len(services) - 1
+		}
+		services[found].Servers = append(services[found].Servers, orphan.Server)
+	}
+	return services
+}
@@ -0,0 +1,84 @@
+package lvs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOrphans(t *testing.T) {
+	simulator := NewSimulator()
+	ctx := context.Background()
+	simulator.Execute(ctx, "ipvsadm", "-A", "-t", "10.0.0.1:80", "-s", "rr")
+	simulator.Execute(ctx, "ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.1:80", "-m", "-w", "1")
+	simulator.Execute(ctx, "ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.2:80", "-m", "-w", "1")
+	simulator.Execute(ctx, "ipvsadm", "-A", "-u", "10.0.0.2:53", "-s", "rr")
+	simulator.Execute(ctx, "ipvsadm", "-a", "-u", "10.0.0.2:53", "-r", "10.0.1.3:53", "-m", "-w", "1")
+	desired := func() []Service {
+		return []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Forwarder: "m", Weight: 1}}}}
+	}
+
+	ipvs := NewIpvs(WithRunner(simulator))
+	orphans, err := ipvs.Orphans(desired())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 2 || orphans[0].String() != "server 10.0.1.2:80 of tcp 10.0.0.1:80" || orphans[1].String() != "server 10.0.1.3:53 of udp 10.0.0.2:53" {
+		t.Fatalf("unexpected orphans - %v", orphans)
+	}
+
+	services, _, err := ipvs.CollectOrphans(desired(), OrphanAdopt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || len(services[0].Servers) != 2 || services[1].Type != "udp" || len(services[1].Servers) != 1 {
+		t.Errorf("orphans weren't adopted - %+v", services)
+	}
+	if orphans, _ := ipvs.Orphans(services); len(orphans) != 0 {
+		t.Errorf("adopted servers are still orphans - %v", orphans)
+	}
+
+	services, orphans, err = ipvs.CollectOrphans(desired(), OrphanRemove)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || len(services[0].Servers) != 1 || len(orphans) != 2 {
+		t.Errorf("unexpected services - %+v", services)
+	}
+	applied := simulator.Services()
+	if len(applied) != 2 || len(applied[0].Servers) != 1 || len(applied[1].Servers) != 0 {
+		t.Errorf("orphans weren't removed - %+v", applied)
+	}
+}
+
+func TestDaemonOrphans(t *testing.T) {
+	simulator := NewSimulator()
+	ctx := context.Background()
+	simulator.Execute(ctx, "ipvsadm", "-A", "-t", "10.0.0.1:80", "-s", "rr")
+	simulator.Execute(ctx, "ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.1:80", "-m", "-w", "1")
+	simulator.Execute(ctx, "ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.2:80", "-m", "-w", "1")
+	store := &MemoryStore{}
+	store.Save([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Forwarder: "m", Weight: 1}}}})
+
+	reported := []Orphan{}
+	d := &Daemon{Store: store, Ipvs: NewIpvs(WithRunner(simulator)), OnOrphans: func(orphans []Orphan) { reported = orphans }}
+	if err := d.reload(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || reported[0].Server.Host != "10.0.1.2" {
+		t.Errorf("unexpected orphans - %v", reported)
+	}
+	if applied := simulator.Services(); len(applied) != 1 || len(applied[0].Servers) != 2 {
+		t.Errorf("reported orphans weren't kept - %+v", applied)
+	}
+	if stored, _ := store.Load(); len(stored[0].Servers) != 1 {
+		t.Errorf("reported orphans were stored - %+v", stored)
+	}
+
+	d.Orphans = OrphanAdopt
+	if err := d.reload(true); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.Load(); len(stored[0].Servers) != 2 {
+		t.Errorf("adopted orphans weren't stored - %+v", stored)
+	}
+}
@@ -11,6 +11,48 @@ var (
 	UnexpecedToken = errors.New("Unexpected Token")
 )
 
+// nextToken returns the value following the flag at tokens[i]
+func nextToken(tokens []string, i int) (string, error) {
+	if i+1 >= len(tokens) {
+		return "", EOFError
+	}
+	return tokens[i+1], nil
+}
+
+// nextInt returns the numeric value following the flag at tokens[i]
+func nextInt(tokens []string, i int) (int, error) {
+	value, err := nextToken(tokens, i)
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, UnexpecedToken
+	}
+	return number, nil
+}
+
+// parseFwmark returns the firewall mark value (decimal, or hex as in
+// iptables' 0x10) in decimal, as ipvsadm writes it
+func parseFwmark(value string) (string, error) {
+	mark, err := strconv.ParseUint(value, 0, 32)
+	if err != nil || mark == 0 {
+		return value, InvalidFwmark
+	}
+	return strconv.FormatUint(mark, 10), nil
+}
+
+// parseServiceAddress parses the address of a service of netType, the mark
+// of fwmark services (which have no port) or its host:port
+func parseServiceAddress(netType, value string) (string, int, error) {
+	if ServiceTypeFlag[netType] == "-f" {
+		mark, err := parseFwmark(value)
+		return mark, 0, err
+	}
+	host, port := parseHostPort(value)
+	return host, port, nil
+}
+
 func parseHostPort(hostPort string) (string, int) {
 	host, port, err := net.SplitHostPort(hostPort)
 	if err != nil {
@@ -0,0 +1,109 @@
+package lvs
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// TemplateCounts are the persistence templates of a service, counted
+	// per server (host:port) and per client network
+	TemplateCounts struct {
+		Total   int            `json:"total"`
+		Servers map[string]int `json:"servers"`
+		Clients map[string]int `json:"clients"` // by network, eg. "10.0.0.0/24"
+	}
+)
+
+// CountTemplates counts the persistence templates of service in templates,
+// grouping clients by their first prefix bits (prefix6 for ipv6 clients)
+func CountTemplates(service Service, templates []Connection, prefix, prefix6 int) TemplateCounts {
+	counts := TemplateCounts{Servers: make(map[string]int), Clients: make(map[string]int)}
+	key := service.canonicalKey()
+	for _, template := range templates {
+		if !template.IsTemplate() || (Service{Type: template.Type, Host: template.VirtualHost, Port: template.VirtualPort}).canonicalKey() != key {
+			continue
+		}
+		counts.Total++
+		counts.Servers[net.JoinHostPort(canonicalHost(template.DestinationHost), strconv.Itoa(template.DestinationPort))]++
+		counts.Clients[clientNetwork(template.SourceHost, prefix, prefix6)]++
+	}
+	return counts
+}
+
+// clientNetwork returns the network of the client host, the host as is
+// when it isn't an address
+func clientNetwork(host string, prefix, prefix6 int) string {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(prefix, 32)), Mask: net.CIDRMask(prefix, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(prefix6, 128)), Mask: net.CIDRMask(prefix6, 128)}).String()
+}
+
+// gaugeTemplates sends the template counts of a persistent service, those
+// of the TemplateClients client networks with the most templates. Networks
+// sent by the last export that no longer are get a 0, rather than keeping
+// their last value in the sink
+func (e *MetricsExporter) gaugeTemplates(name string, service Service, templates []Connection) error {
+	prefix, prefix6, clients := e.TemplatePrefix, e.TemplatePrefix6, e.TemplateClients
+	if prefix <= 0 || prefix > 32 {
+		prefix = 24
+	}
+	if prefix6 <= 0 || prefix6 > 128 {
+		prefix6 = 64
+	}
+	if clients <= 0 {
+		clients = 10
+	}
+	counts := CountTemplates(service, templates, prefix, prefix6)
+	if err := e.Sink.Gauge(name+".templates", float64(counts.Total)); err != nil {
+		return err
+	}
+	for _, server := range service.Servers {
+		hostPort := net.JoinHostPort(canonicalHost(server.Host), strconv.Itoa(server.Port))
+		if err := e.Sink.Gauge(name+".server."+metricName(server.Host, strconv.Itoa(server.Port))+".templates", float64(counts.Servers[hostPort])); err != nil {
+			return err
+		}
+	}
+
+	networks := make([]string, 0, len(counts.Clients))
+	for network := range counts.Clients {
+		networks = append(networks, network)
+	}
+	sort.Slice(networks, func(a, b int) bool {
+		if counts.Clients[networks[a]] != counts.Clients[networks[b]] {
+			return counts.Clients[networks[a]] > counts.Clients[networks[b]]
+		}
+		return networks[a] < networks[b]
+	})
+	if len(networks) > clients {
+		networks = networks[:clients]
+	}
+	sent := make(map[string]bool)
+	for _, network := range networks {
+		gauge := name + ".templates.client." + metricName(strings.Replace(network, "/", "_", 1))
+		if err := e.Sink.Gauge(gauge, float64(counts.Clients[network])); err != nil {
+			return err
+		}
+		sent[gauge] = true
+	}
+	for gauge := range e.clientGauges[name] {
+		if sent[gauge] {
+			continue
+		}
+		if err := e.Sink.Gauge(gauge, 0); err != nil {
+			return err
+		}
+	}
+	if e.clientGauges == nil {
+		e.clientGauges = make(map[string]map[string]bool)
+	}
+	e.clientGauges[name] = sent
+	return nil
+}
@@ -0,0 +1,66 @@
+package lvs
+
+import (
+	"testing"
+)
+
+const templatesOutput = `IPVS connection entries
+pro expire state       source             virtual            destination
+TCP 14:56  NONE        10.0.2.5:0         10.0.0.1:80        10.0.1.1:80
+TCP 14:50  NONE        10.0.2.9:0         10.0.0.1:80        10.0.1.1:80
+TCP 12:01  NONE        10.0.3.7:0         10.0.0.1:80        10.0.1.2:80
+TCP 01:56  ESTABLISHED 10.0.2.5:51234     10.0.0.1:80        10.0.1.1:80
+UDP 04:10  NONE        10.0.2.5:0         10.0.0.1:53        10.0.1.3:53
+TCP 14:56  NONE        [2001:db8:1::5]:0  10.0.0.1:80        10.0.1.2:80
+`
+
+func TestCountTemplates(t *testing.T) {
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80}
+	counts := CountTemplates(service, parseConnections(templatesOutput), 24, 48)
+	if counts.Total != 4 || counts.Servers["10.0.1.1:80"] != 2 || counts.Servers["10.0.1.2:80"] != 2 {
+		t.Errorf("templates counted wrong - %+v", counts)
+	}
+	if counts.Clients["10.0.2.0/24"] != 2 || counts.Clients["10.0.3.0/24"] != 1 || counts.Clients["2001:db8:1::/48"] != 1 {
+		t.Errorf("clients counted wrong - %+v", counts.Clients)
+	}
+}
+
+func TestMetricsExporterTemplates(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Persistence: 300, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1}}})
+	sink := &recordingSink{metrics: make(map[string]float64)}
+	exporter := MetricsExporter{Lvs: client, Sink: sink, Templates: true, TemplateClients: 1}
+
+	fakeRunOutput = []byte(templatesOutput)
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	expected := map[string]float64{
+		"service.tcp_10_0_0_1_80.templates":                    4,
+		"service.tcp_10_0_0_1_80.server.10_0_1_1_80.templates": 2,
+		"service.tcp_10_0_0_1_80.server.10_0_1_2_80.templates": 2,
+		"service.tcp_10_0_0_1_80.templates.client.10_0_2_0_24": 2,
+	}
+	for name, value := range expected {
+		if sink.metrics[name] != value {
+			t.Errorf("expected %s to be %v - %v", name, value, sink.metrics)
+		}
+	}
+	if _, ok := sink.metrics["service.tcp_10_0_0_1_80.templates.client.10_0_3_0_24"]; ok {
+		t.Errorf("only the busiest client network should be sent - %v", sink.metrics)
+	}
+
+	// 10.0.3.0/24 becomes the busiest, 10.0.2.0/24 drops out
+	fakeRunOutput = []byte(`IPVS connection entries
+pro expire state       source             virtual            destination
+TCP 14:56  NONE        10.0.3.5:0         10.0.0.1:80        10.0.1.1:80
+`)
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	if sink.metrics["service.tcp_10_0_0_1_80.templates.client.10_0_3_0_24"] != 1 || sink.metrics["service.tcp_10_0_0_1_80.templates.client.10_0_2_0_24"] != 0 {
+		t.Errorf("expected the network that dropped out to be zeroed - %v", sink.metrics)
+	}
+}
@@ -0,0 +1,185 @@
+package lvs
+
+import (
+	"sync"
+)
+
+type (
+	// Pool is a set of real servers shared by several services (eg. the port
+	// 80 and 443 services of a vip), changes to the pool are applied to every
+	// attached service
+	Pool struct {
+		Lvs *Lvs // defaults to DefaultLvs
+
+		mu       sync.Mutex
+		servers  []Server
+		services []poolService
+	}
+
+	poolService struct {
+		netType string
+		host    string
+		port    int
+	}
+)
+
+// NewPool returns a pool of servers managed through l. Servers with no port
+// take the port of each service they are attached to
+func NewPool(l *Lvs, servers ...Server) *Pool {
+	if l == nil {
+		l = DefaultLvs
+	}
+	return &Pool{Lvs: l, servers: servers}
+}
+
+// Servers returns the servers in the pool
+func (p *Pool) Servers() []Server {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Server{}, p.servers...)
+}
+
+// Attach adds the pool's servers to an existing service and keeps them in
+// sync from then on
+func (p *Pool) Attach(netType, host string, port int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	attached := poolService{netType: netType, host: host, port: port}
+	for i := range p.services {
+		if p.services[i] == attached {
+			return nil
+		}
+	}
+	err := p.each([]poolService{attached}, func(service *Service) error {
+		for _, server := range p.servers {
+			if _, err := service.AddServerChanged(service.poolServer(server)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.services = append(p.services, attached)
+	return nil
+}
+
+// Detach removes the pool's servers from a service
+func (p *Pool) Detach(netType, host string, port int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.services {
+		if p.services[i] != (poolService{netType: netType, host: host, port: port}) {
+			continue
+		}
+		err := p.each(p.services[i:i+1], func(service *Service) error {
+			for _, server := range p.servers {
+				server = service.poolServer(server)
+				if _, err := service.RemoveServerChanged(server.Host, server.Port); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		p.services = append(p.services[:i], p.services[i+1:]...)
+		return nil
+	}
+	return NotFound
+}
+
+// AddServer adds server to the pool and every attached service
+func (p *Pool) AddServer(server Server) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.find(server.Host, server.Port) >= 0 {
+		return nil
+	}
+	err := p.each(p.services, func(service *Service) error {
+		_, err := service.AddServerChanged(service.poolServer(server))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.servers = append(p.servers, server)
+	return nil
+}
+
+// EditServer edits server in the pool and every attached service
+func (p *Pool) EditServer(server Server) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.find(server.Host, server.Port)
+	if i < 0 {
+		return NotFound
+	}
+	err := p.each(p.services, func(service *Service) error {
+		_, err := service.EditServerChanged(service.poolServer(server))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.servers[i] = server
+	return nil
+}
+
+// RemoveServer removes a server from the pool and every attached service
+func (p *Pool) RemoveServer(host string, port int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.find(host, port)
+	if i < 0 {
+		return NotFound
+	}
+	err := p.each(p.services, func(service *Service) error {
+		server := service.poolServer(p.servers[i])
+		_, err := service.RemoveServerChanged(server.Host, server.Port)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	p.servers = append(p.servers[:i], p.servers[i+1:]...)
+	return nil
+}
+
+func (p *Pool) find(host string, port int) int {
+	for i := range p.servers {
+		if p.servers[i].Host == host && p.servers[i].Port == port {
+			return i
+		}
+	}
+	return -1
+}
+
+// each runs fn against every service in services
+func (p *Pool) each(services []poolService, fn func(*Service) error) error {
+	if p.Lvs == nil {
+		p.Lvs = DefaultLvs
+	}
+	return p.Lvs.Do(func(i *Ipvs) error {
+		for _, attached := range services {
+			service := i.FindService(attached.netType, attached.host, attached.port)
+			if service == nil {
+				return NotFound
+			}
+			if err := fn(service); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// poolServer gives pool servers without a port the port of s
+func (s Service) poolServer(server Server) Server {
+	if server.Port == 0 {
+		server.Port = s.Port
+	}
+	return server
+}
@@ -0,0 +1,47 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestPool(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80})
+	client.AddService(Service{Host: "10.0.0.1", Port: 443})
+
+	pool := NewPool(client, Server{Host: "10.0.1.1", Weight: 1})
+	if err := pool.Attach("tcp", "10.0.0.1", 80); err != nil {
+		t.Fatalf("failed to attach pool - %v", err)
+	}
+	if err := pool.Attach("tcp", "10.0.0.1", 443); err != nil {
+		t.Fatalf("failed to attach pool - %v", err)
+	}
+	if err := pool.AddServer(Server{Host: "10.0.1.2", Weight: 1}); err != nil {
+		t.Fatalf("failed to add server - %v", err)
+	}
+	if err := pool.EditServer(Server{Host: "10.0.1.1", Weight: 0}); err != nil {
+		t.Fatalf("failed to edit server - %v", err)
+	}
+
+	for _, service := range client.Services() {
+		if len(service.Servers) != 2 {
+			t.Fatalf("pool servers missing from %d - %+v", service.Port, service.Servers)
+		}
+		if service.Servers[0].Port != service.Port || service.Servers[0].Weight != 0 {
+			t.Errorf("pool server not applied to %d - %+v", service.Port, service.Servers[0])
+		}
+	}
+
+	if err := pool.RemoveServer("10.0.1.1", 0); err != nil {
+		t.Fatalf("failed to remove server - %v", err)
+	}
+	if err := pool.Detach("tcp", "10.0.0.1", 443); err != nil {
+		t.Fatalf("failed to detach pool - %v", err)
+	}
+	services := client.Services()
+	if len(services[0].Servers) != 1 || len(services[1].Servers) != 0 {
+		t.Errorf("pool changes not propagated - %+v", services)
+	}
+}
@@ -0,0 +1,178 @@
+package lvs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// PortRange describes a service listening across a range of ports (eg. a
+	// NodePort style "30000-32767"). It expands into one service per port, or
+	// into a single fwmark service when Fwmark is set
+	PortRange struct {
+		Host        string   `json:"host"`
+		Ports       string   `json:"ports"` // eg. "30000-32767" or "80,443,8000-8010"
+		Type        string   `json:"type"`  // tcp or udp
+		Scheduler   string   `json:"scheduler"`
+		Persistence int      `json:"persistence"`
+		Netmask     string   `json:"netmask"`
+		Fwmark      int      `json:"fwmark"`
+		Servers     []Server `json:"servers"` // servers with no port use the service's port
+	}
+)
+
+// Services returns the services r expands into
+func (r PortRange) Services() ([]Service, error) {
+	if ServiceTypeFlag[r.Type] == "-f" {
+		return nil, InvalidServiceType
+	}
+	ports, err := parsePorts(r.Ports)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Fwmark > 0 {
+		service := r.service(strconv.Itoa(r.Fwmark), 0)
+		service.Type = "fwmark"
+		return []Service{service}, service.Validate()
+	}
+
+	services := make([]Service, 0, len(ports))
+	for _, port := range ports {
+		service := r.service(r.Host, port)
+		if err := service.Validate(); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+func (r PortRange) service(host string, port int) Service {
+	service := Service{
+		Host:        host,
+		Port:        port,
+		Type:        r.Type,
+		Scheduler:   r.Scheduler,
+		Persistence: r.Persistence,
+		Netmask:     r.Netmask,
+		Servers:     make([]Server, 0, len(r.Servers)),
+	}
+	for _, server := range r.Servers {
+		if server.Port == 0 {
+			server.Port = port
+		}
+		service.Servers = append(service.Servers, server)
+	}
+	return service
+}
+
+// MarkArgs returns the iptables arguments marking the range's packets for
+// its fwmark service, or nil when r doesn't use a fwmark
+func (r PortRange) MarkArgs() []string {
+	return r.markArgs("-A", r.Host)
+}
+
+// markArgs returns the MarkArgs matching packets to host, the range's host
+// once resolved, action being -A to add the rule, -C to check for it or -D
+// to delete it
+func (r PortRange) markArgs(action, host string) []string {
+	if r.Fwmark <= 0 {
+		return nil
+	}
+	protocol := "tcp"
+	if ServiceTypeFlag[r.Type] == "-u" {
+		protocol = "udp"
+	}
+	return []string{
+		"-t", "mangle", action, "PREROUTING", "-d", host, "-p", protocol,
+		"-m", "multiport", "--dports", strings.Replace(strings.Replace(r.Ports, "-", ":", -1), " ", "", -1),
+		"-j", "MARK", "--set-mark", strconv.Itoa(r.Fwmark),
+	}
+}
+
+// AddPortRange adds the services r expands into in a single batch, along
+// with the iptables rule marking its packets when a fwmark is used. The rule
+// is only added when missing, and deleted again if the services can't be
+// added
+func (i *Ipvs) AddPortRange(r PortRange) error {
+	services, err := r.Services()
+	if err != nil {
+		return err
+	}
+	if r.Fwmark <= 0 {
+		return i.AddServices(services)
+	}
+
+	host, err := i.exec.resolveHost(r.Host)
+	if err != nil {
+		return err
+	}
+	added := false
+	// -C fails when the rule is missing
+	if err := i.exec.execute("iptables", r.markArgs("-C", host)...); err != nil {
+		if err := i.exec.execute("iptables", r.markArgs("-A", host)...); err != nil {
+			return err
+		}
+		added = true
+	}
+	if err := i.AddServices(services); err != nil {
+		if added {
+			if deleteErr := i.exec.execute("iptables", r.markArgs("-D", host)...); deleteErr != nil {
+				return fmt.Errorf("%w (and failed to delete the mark rule - %v)", err, deleteErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// AddServices adds every service not already known with one `ipvsadm -R`,
+// which is far quicker than adding thousands of services one at a time
+func (i *Ipvs) AddServices(services []Service) error {
+	defer i.changing()()
+	unlock, err := i.exec.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	added := make([]Service, 0, len(services))
+	in := make([]string, 0, len(services))
+	for _, service := range services {
+		if err := service.Validate(); err != nil {
+			return err
+		}
+		service, err = service.Normalize()
+		if err != nil {
+			return err
+		}
+		if i.FindService(service.Type, service.Host, service.Port) != nil {
+			continue
+		}
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return err
+		}
+		added = append(added, service)
+		in = append(in, applied.String())
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err := checkOverlaps(append(i.Services[:len(i.Services):len(i.Services)], added...)); err != nil {
+		return err
+	}
+	err = i.exec.executeStdin(strings.Join(in, ""), "ipvsadm", "-R")
+	if err != nil {
+		return err
+	}
+	now := stampNow()
+	for j := range added {
+		added[j] = stampApplied(nil, added[j], now)
+	}
+	i.Services = append(i.Services, added...)
+	return i.writeState()
+}
@@ -0,0 +1,111 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPortRangeServices(t *testing.T) {
+	r := PortRange{Host: "10.0.0.1", Ports: "30000-30002", Servers: []Server{{Host: "10.0.1.1", Weight: 1}}}
+	services, err := r.Services()
+	if err != nil {
+		t.Fatalf("failed to expand range - %v", err)
+	}
+	if len(services) != 3 || services[2].Port != 30002 || services[2].Servers[0].Port != 30002 {
+		t.Errorf("unexpected services - %+v", services)
+	}
+
+	r.Fwmark = 7
+	services, err = r.Services()
+	if err != nil {
+		t.Fatalf("failed to expand range - %v", err)
+	}
+	if len(services) != 1 || services[0].Type != "fwmark" || services[0].Host != "7" || services[0].Servers[0].Port != 0 {
+		t.Errorf("unexpected fwmark service - %+v", services)
+	}
+	if args := strings.Join(r.MarkArgs(), " "); !strings.Contains(args, "--dports 30000:30002") || !strings.HasSuffix(args, "--set-mark 7") {
+		t.Errorf("unexpected mark rule - %s", args)
+	}
+}
+
+func TestAddPortRange(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	ipvs.AddService(Service{Host: "10.0.0.1", Port: 30000})
+	err := ipvs.AddPortRange(PortRange{Host: "10.0.0.1", Ports: "30000-30002", Servers: []Server{{Host: "10.0.1.1", Weight: 1}}})
+	if err != nil {
+		t.Fatalf("failed to add range - %v", err)
+	}
+	if len(ipvs.Services) != 3 {
+		t.Errorf("expected 3 services, got %+v", ipvs.Services)
+	}
+	if len(fakeStdin) != 1 || strings.Contains(fakeStdin[0], ":30000 ") || strings.Count(fakeStdin[0], "-A ") != 2 {
+		t.Errorf("expected one batch of the missing services, got %q", fakeStdin)
+	}
+}
+
+// iptablesRunner keeps the iptables rules of a Simulator, -C failing for
+// missing ones like iptables does
+type iptablesRunner struct {
+	*Simulator
+	rules     map[string]int
+	failStdin error
+}
+
+func (r iptablesRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	if exe != "iptables" {
+		return r.Simulator.Execute(ctx, exe, args...)
+	}
+	rule := strings.Join(append(append([]string{}, args[:2]...), args[3:]...), " ")
+	switch args[2] {
+	case "-C":
+		if r.rules[rule] == 0 {
+			return errors.New("exit status 1: Bad rule (does a matching rule exist in that chain?)")
+		}
+	case "-A":
+		r.rules[rule]++
+	case "-D":
+		r.rules[rule]--
+	}
+	return nil
+}
+
+func (r iptablesRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	if r.failStdin != nil {
+		return r.failStdin
+	}
+	return r.Simulator.ExecuteStdin(ctx, in, exe, args...)
+}
+
+func TestAddPortRangeFwmark(t *testing.T) {
+	runner := iptablesRunner{Simulator: NewSimulator(), rules: make(map[string]int)}
+	ipvs := NewIpvs(WithRunner(runner))
+	r := PortRange{Host: "10.0.0.1", Ports: "30000-32767", Fwmark: 3, Servers: []Server{{Host: "10.0.1.1", Weight: 1}}}
+	rule := "-t mangle PREROUTING -d 10.0.0.1 -p tcp -m multiport --dports 30000:32767 -j MARK --set-mark 3"
+
+	// adding it again doesn't duplicate the mark rule
+	for j := 0; j < 2; j++ {
+		if err := ipvs.AddPortRange(r); err != nil {
+			t.Fatalf("failed to add range - %v", err)
+		}
+	}
+	if len(runner.rules) != 1 || runner.rules[rule] != 1 {
+		t.Errorf("expected the mark rule once, got %v", runner.rules)
+	}
+	if services := runner.Services(); len(services) != 1 || services[0].Host != "3" || services[0].Servers[0].Host != "10.0.1.1" {
+		t.Errorf("unexpected fwmark services %+v", services)
+	}
+
+	// the rule added is deleted again when the services can't be
+	runner = iptablesRunner{Simulator: NewSimulator(), rules: make(map[string]int), failStdin: errors.New("exit status 1")}
+	ipvs = NewIpvs(WithRunner(runner))
+	if err := ipvs.AddPortRange(r); err == nil {
+		t.Fatal("expected adding the services to fail")
+	}
+	if runner.rules[rule] != 0 {
+		t.Errorf("expected the mark rule deleted, got %v", runner.rules)
+	}
+}
@@ -0,0 +1,149 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// UnsupportedProxyConfig is returned for services a proxy can't be
+	// configured with, such as fwmark services or servers without a port
+	UnsupportedProxyConfig = errors.New("service can't be expressed as a proxy config")
+
+	// HAProxyMaxWeight is the highest weight haproxy accepts, higher
+	// weights are capped to it
+	HAProxyMaxWeight = 256
+
+	haproxyBalance = map[string]string{
+		"rr":    "roundrobin",
+		"wrr":   "roundrobin",
+		"lc":    "leastconn",
+		"wlc":   "leastconn",
+		"sed":   "leastconn",
+		"nq":    "leastconn",
+		"lblc":  "leastconn",
+		"lblcr": "leastconn",
+		"dh":    "roundrobin",
+		"sh":    "source",
+		"mh":    "source",
+	}
+	nginxBalance = map[string]string{
+		"lc":    "least_conn",
+		"wlc":   "least_conn",
+		"sed":   "least_conn",
+		"nq":    "least_conn",
+		"lblc":  "least_conn",
+		"lblcr": "least_conn",
+		"sh":    "hash $remote_addr",
+		"mh":    "hash $remote_addr consistent",
+	}
+)
+
+// HAProxyBackend returns an haproxy backend block (in tcp mode) balancing
+// over the service's servers, for hybrid deployments driving an L7 tier
+// from the same services as ipvs. name defaults to the service's Name, or
+// its type and address. Schedulers are approximated: rr, wrr and dh
+// round robin, the connection counting ones use leastconn, and sh, mh and
+// persistent services balance by source (mh consistently). Weights above
+// HAProxyMaxWeight are capped and UpperThreshold becomes maxconn. haproxy
+// doesn't balance udp, so udp services are unsupported
+func (s Service) HAProxyBackend(name string) (string, error) {
+	if err := s.checkProxyConfig(); err != nil {
+		return "", err
+	}
+	if ServiceTypeFlag[s.Type] == "-u" {
+		return "", UnsupportedProxyConfig
+	}
+
+	scheduler := ServiceSchedulerFlag[s.Scheduler]
+	balance := haproxyBalance[scheduler]
+	if s.Persistence > 0 {
+		balance = "source"
+	}
+	lines := []string{
+		"backend " + s.proxyName(name),
+		"    mode tcp",
+		"    balance " + balance,
+	}
+	if scheduler == "mh" {
+		lines = append(lines, "    hash-type consistent")
+	}
+	for _, server := range s.Servers {
+		weight := server.Weight
+		if weight > HAProxyMaxWeight {
+			weight = HAProxyMaxWeight
+		}
+		line := "    server " + metricName(server.Host, strconv.Itoa(server.Port)) + " " + net.JoinHostPort(server.Host, strconv.Itoa(server.Port)) + " weight " + strconv.Itoa(weight)
+		if server.UpperThreshold > 0 {
+			line += " maxconn " + strconv.Itoa(server.UpperThreshold)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// NginxUpstream returns an nginx upstream block balancing over the
+// service's servers, for the http or (for udp) stream context. name
+// defaults like HAProxyBackend's. Schedulers are approximated: rr, wrr and
+// dh round robin, the connection counting ones use least_conn, and sh, mh
+// and persistent services hash the client address (mh consistently).
+// Servers with weight 0 are marked down and UpperThreshold becomes
+// max_conns
+func (s Service) NginxUpstream(name string) (string, error) {
+	if err := s.checkProxyConfig(); err != nil {
+		return "", err
+	}
+
+	scheduler := ServiceSchedulerFlag[s.Scheduler]
+	balance := nginxBalance[scheduler]
+	if s.Persistence > 0 && !strings.HasPrefix(balance, "hash") {
+		balance = "hash $remote_addr"
+	}
+	lines := []string{"upstream " + s.proxyName(name) + " {"}
+	if balance != "" {
+		lines = append(lines, "    "+balance+";")
+	}
+	for _, server := range s.Servers {
+		line := "    server " + net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+		if server.Weight == 0 {
+			line += " down"
+		} else if server.Weight != 1 {
+			line += " weight=" + strconv.Itoa(server.Weight)
+		}
+		if server.UpperThreshold > 0 {
+			line += " max_conns=" + strconv.Itoa(server.UpperThreshold)
+		}
+		lines = append(lines, line+";")
+	}
+	return strings.Join(append(lines, "}"), "\n") + "\n", nil
+}
+
+// checkProxyConfig fails for services a proxy can't balance: invalid ones,
+// fwmark services and servers without a port
+func (s Service) checkProxyConfig() error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if ServiceTypeFlag[s.Type] == "-f" {
+		return UnsupportedProxyConfig
+	}
+	for _, server := range s.Servers {
+		if server.Port == 0 {
+			return UnsupportedProxyConfig
+		}
+	}
+	return nil
+}
+
+// proxyName is name, or a name derived from the service
+func (s Service) proxyName(name string) string {
+	if name != "" {
+		return name
+	}
+	if s.Name != "" {
+		return metricName(s.Name)
+	}
+	return metricName(s.Type, s.getHostPort())
+}
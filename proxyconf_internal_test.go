@@ -0,0 +1,58 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestProxyConfig(t *testing.T) {
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "wlc", Name: "web", Servers: []Server{
+		{Host: "10.0.1.1", Port: 8080, Forwarder: "m", Weight: 1},
+		{Host: "10.0.1.2", Port: 8080, Forwarder: "m", Weight: 1000, UpperThreshold: 50},
+		{Host: "2001:db8::3", Port: 8080, Forwarder: "m", Weight: 0},
+	}}
+
+	backend, err := service.HAProxyBackend("")
+	if err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	expected := `backend web
+    mode tcp
+    balance leastconn
+    server 10_0_1_1_8080 10.0.1.1:8080 weight 1
+    server 10_0_1_2_8080 10.0.1.2:8080 weight 256 maxconn 50
+    server 2001_db8__3_8080 [2001:db8::3]:8080 weight 0
+`
+	if backend != expected {
+		t.Errorf("unexpected backend:\n%s", backend)
+	}
+
+	upstream, err := service.NginxUpstream("web_tier")
+	if err != nil {
+		t.Fatalf("failed to export - %v", err)
+	}
+	expected = `upstream web_tier {
+    least_conn;
+    server 10.0.1.1:8080;
+    server 10.0.1.2:8080 weight=1000 max_conns=50;
+    server [2001:db8::3]:8080 down;
+}
+`
+	if upstream != expected {
+		t.Errorf("unexpected upstream:\n%s", upstream)
+	}
+
+	service.Persistence, service.Scheduler = 300, "rr"
+	if backend, _ = service.HAProxyBackend("web"); backend[:len("backend web\n    mode tcp\n    balance source")] != "backend web\n    mode tcp\n    balance source" {
+		t.Errorf("persistent service should balance by source:\n%s", backend)
+	}
+	if upstream, _ = service.NginxUpstream("web"); upstream[:len("upstream web {\n    hash $remote_addr;")] != "upstream web {\n    hash $remote_addr;" {
+		t.Errorf("persistent service should hash the client address:\n%s", upstream)
+	}
+
+	if _, err = (Service{Type: "udp", Host: "10.0.0.1", Port: 53}).HAProxyBackend(""); err != UnsupportedProxyConfig {
+		t.Errorf("expected udp to be unsupported by haproxy, got %v", err)
+	}
+	if _, err = (Service{Type: "fwmark", Host: "5"}).NginxUpstream(""); err != UnsupportedProxyConfig {
+		t.Errorf("expected fwmark to be unsupported, got %v", err)
+	}
+}
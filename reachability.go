@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type (
+	// ReachabilityCheck checks that servers answer before they are added,
+	// catching typos in their addresses before they go into rotation, see
+	// WithReachabilityCheck
+	ReachabilityCheck struct {
+		// Ping sends an ICMP echo (with the ping command, run wherever
+		// ipvsadm runs, eg. in the netns or on the remote director) rather
+		// than opening a tcp connection (from this host) to the server.
+		// Servers of udp and fwmark services, or without a port, are
+		// always pinged
+		Ping    bool
+		Timeout time.Duration // defaults to HealthCheckTimeout
+		Policy  ReachabilityPolicy
+		// OnUnreachable is called for the servers added despite failing
+		// the check under ReachabilityWarn
+		OnUnreachable func(service Service, server Server, err error)
+	}
+
+	// ReachabilityPolicy is what happens to servers failing a
+	// ReachabilityCheck
+	ReachabilityPolicy int
+
+	// UnreachableServer is returned rather than adding a server failing
+	// its ReachabilityCheck under ReachabilityRefuse
+	UnreachableServer struct {
+		Service string // type and host:port of the service
+		Server  Server
+		Err     error
+	}
+)
+
+const (
+	// ReachabilityWarn adds the server anyway, calling OnUnreachable
+	ReachabilityWarn ReachabilityPolicy = iota
+	// ReachabilityRefuse fails with an UnreachableServer
+	ReachabilityRefuse
+)
+
+// WithReachabilityCheck has AddServer, AddService and Sync check that servers
+// are reachable before adding them, see ReachabilityCheck. Servers already
+// there aren't checked, nor are the ones added by Restore or AddServices
+func WithReachabilityCheck(check ReachabilityCheck) Option {
+	return func(i *Ipvs) {
+		i.exec.reachability = &check
+	}
+}
+
+func (e UnreachableServer) Error() string {
+	return fmt.Sprintf("server %s of %s is unreachable: %v", e.Server.getHostPort(), e.Service, e.Err)
+}
+
+// checkReachable checks that the (resolved) servers of s are reachable, per
+// the ReachabilityCheck e was configured with
+func (e *executor) checkReachable(s Service, servers ...Server) error {
+	if e == nil || e.reachability == nil {
+		return nil
+	}
+	check := e.reachability
+	for _, server := range servers {
+		err := check.reach(e, s, server)
+		if err == nil {
+			continue
+		}
+		if check.Policy == ReachabilityRefuse {
+			return UnreachableServer{Service: s.Type + " " + s.getHostPort(), Server: server, Err: err}
+		}
+		if check.OnUnreachable != nil {
+			check.OnUnreachable(s, server, err)
+		}
+	}
+	return nil
+}
+
+func (c ReachabilityCheck) reach(e *executor, s Service, server Server) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = HealthCheckTimeout
+	}
+	if !c.Ping && ServiceTypeFlag[s.Type] == "-t" && server.Port != 0 {
+		return TCPCheck{Timeout: timeout}.Check(s, server)
+	}
+
+	seconds := int((timeout + time.Second - 1) / time.Second)
+	_, err := e.run([]string{"ping", "-c", "1", "-W", strconv.Itoa(seconds), server.Host})
+	return err
+}
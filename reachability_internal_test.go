@@ -0,0 +1,54 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReachabilityCheck(t *testing.T) {
+	defer useFakeBackend()()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close() // nothing listens on port anymore
+
+	ipvs := NewIpvs(WithReachabilityCheck(ReachabilityCheck{Policy: ReachabilityRefuse}))
+	service := Service{Type: "tcp", Host: "127.0.0.1", Port: port, Servers: []Server{{Host: "127.0.0.1", Port: port}}}
+	err = ipvs.AddService(service)
+	if unreachable, ok := err.(UnreachableServer); !ok || unreachable.Server.Host != "127.0.0.1" {
+		t.Fatalf("expected an UnreachableServer, got %v", err)
+	}
+	if len(fakeExecuted) != 0 {
+		t.Errorf("unreachable server was added - %v", fakeExecuted)
+	}
+
+	// udp servers are pinged
+	warned := []string{}
+	ipvs = NewIpvs(WithReachabilityCheck(ReachabilityCheck{OnUnreachable: func(service Service, server Server, err error) {
+		warned = append(warned, server.Host+" "+err.Error())
+	}}))
+	if err := ipvs.AddService(Service{Type: "udp", Host: "10.0.0.1", Port: 53}); err != nil {
+		t.Fatal(err)
+	}
+	fakeRunErr = errors.New("100% packet loss")
+	if err := ipvs.Services[0].AddServer(Server{Host: "10.0.1.1", Port: 53, Weight: 1}); err != nil {
+		t.Fatalf("warn policy shouldn't fail - %v", err)
+	}
+	if len(warned) != 1 || warned[0] != "10.0.1.1 100% packet loss" || len(ipvs.Services[0].Servers) != 1 {
+		t.Errorf("expected a warning and the server added - %v %+v", warned, ipvs.Services[0].Servers)
+	}
+
+	fakeRunErr = nil
+	ipvs = NewIpvs(WithReachabilityCheck(ReachabilityCheck{Ping: true, Policy: ReachabilityRefuse}))
+	if err := ipvs.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80}}}); err != nil {
+		t.Errorf("reachable server should be added - %v", err)
+	}
+	if !strings.Contains(fakeExecuted[len(fakeExecuted)-1], "-r 10.0.1.1:80") {
+		t.Errorf("server wasn't added - %v", fakeExecuted)
+	}
+}
@@ -0,0 +1,80 @@
+package lvs
+
+import (
+	"errors"
+)
+
+type (
+	// WorkloadProfile describes the traffic of a service, see
+	// RecommendScheduler
+	WorkloadProfile string
+
+	// SchedulerRecommendation is how a service with a given workload is
+	// best scheduled
+	SchedulerRecommendation struct {
+		Scheduler     string         `json:"scheduler"`
+		SchedulerOpts *SchedulerOpts `json:"scheduler_opts,omitempty"`
+		Persistence   int            `json:"persistence"`
+		Reason        string         `json:"reason"`
+	}
+)
+
+const (
+	// ProfileLongLivedTCP is for connections lasting minutes or more, such
+	// as databases, websockets or message queues
+	ProfileLongLivedTCP WorkloadProfile = "long-lived-tcp"
+	// ProfileShortHTTP is for many short requests, such as an http api
+	ProfileShortHTTP WorkloadProfile = "short-http"
+	// ProfileUDPDNS is for single datagram exchanges, such as dns
+	ProfileUDPDNS WorkloadProfile = "udp-dns"
+	// ProfileSticky is for clients that must keep reaching the same
+	// server, such as applications keeping sessions in memory
+	ProfileSticky WorkloadProfile = "sticky"
+)
+
+var (
+	UnknownWorkloadProfile = errors.New("Unknown Workload Profile")
+
+	recommendations = map[WorkloadProfile]SchedulerRecommendation{
+		ProfileLongLivedTCP: {
+			Scheduler: "wlc",
+			Reason:    "long connections pile up unevenly, weighted least connections balances what servers actually hold",
+		},
+		ProfileShortHTTP: {
+			Scheduler: "wrr",
+			Reason:    "connection counts churn too fast to mean much, weighted round robin spreads requests cheaply and predictably",
+		},
+		ProfileUDPDNS: {
+			Scheduler:     "mh",
+			SchedulerOpts: &SchedulerOpts{Fallback: true, Port: true},
+			Reason:        "udp has no connections to count, maglev hashing of the source address and port spreads queries statelessly and consistently, falling back when a server is out of rotation",
+		},
+		ProfileSticky: {
+			Scheduler:   "wlc",
+			Persistence: 300,
+			Reason:      "persistence keeps a client on the same server for 5 minutes after its last connection, weighted least connections balances new clients",
+		},
+	}
+)
+
+// RecommendScheduler returns the scheduler, its options and the
+// persistence recommended for a workload
+func RecommendScheduler(profile WorkloadProfile) (SchedulerRecommendation, error) {
+	recommendation, ok := recommendations[profile]
+	if !ok {
+		return SchedulerRecommendation{}, UnknownWorkloadProfile
+	}
+	if recommendation.SchedulerOpts != nil {
+		opts := *recommendation.SchedulerOpts
+		recommendation.SchedulerOpts = &opts
+	}
+	return recommendation, nil
+}
+
+// Apply returns service scheduled as recommended
+func (r SchedulerRecommendation) Apply(service Service) Service {
+	service.Scheduler = r.Scheduler
+	service.SchedulerOpts = r.SchedulerOpts
+	service.Persistence = r.Persistence
+	return service
+}
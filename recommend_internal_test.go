@@ -0,0 +1,35 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestRecommendScheduler(t *testing.T) {
+	for _, profile := range []WorkloadProfile{ProfileLongLivedTCP, ProfileShortHTTP, ProfileUDPDNS, ProfileSticky} {
+		recommendation, err := RecommendScheduler(profile)
+		if err != nil {
+			t.Fatalf("no recommendation for %s - %v", profile, err)
+		}
+		service := recommendation.Apply(Service{Type: "udp", Host: "10.0.0.1", Port: 53})
+		if err := service.Validate(); err != nil {
+			t.Errorf("recommendation for %s isn't valid - %v", profile, err)
+		}
+		if recommendation.Reason == "" {
+			t.Errorf("recommendation for %s should explain itself", profile)
+		}
+	}
+
+	recommendation, _ := RecommendScheduler(ProfileUDPDNS)
+	service := recommendation.Apply(Service{Type: "udp", Host: "10.0.0.1", Port: 53})
+	if service.Scheduler != "mh" || len(service.getSchedFlags()) != 2 || service.Persistence != 0 {
+		t.Errorf("unexpected dns scheduling - %+v", service)
+	}
+	recommendation.SchedulerOpts.Port = false
+	if again, _ := RecommendScheduler(ProfileUDPDNS); !again.SchedulerOpts.Port {
+		t.Error("recommendations should not be shared")
+	}
+
+	if _, err := RecommendScheduler("batch"); err != UnknownWorkloadProfile {
+		t.Errorf("expected UnknownWorkloadProfile, got %v", err)
+	}
+}
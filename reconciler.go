@@ -0,0 +1,143 @@
+package lvs
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Reconciler holds the desired services of a client and periodically
+	// syncs the table back to them, correcting drift such as changes made
+	// by hand with ipvsadm. Drift is published as an EventDriftDetected,
+	// followed by an EventDriftCorrected once synced. Nothing is
+	// reconciled until SetDesired is called, or Store has services
+	Reconciler struct {
+		Lvs      *Lvs          // defaults to DefaultLvs
+		Interval time.Duration // defaults to 30s
+		// Jitter adds up to this much to every interval, so directors
+		// started together don't reconcile in lockstep
+		Jitter  time.Duration
+		OnError func(error)
+		// Store persists the desired services when set and is read on
+		// every reconcile, so they survive restarts and (with an
+		// EtcdStore) are shared by the reconcilers of every director
+		Store Store
+		// Health, when set, is the HealthChecker of the same client. The
+		// servers it quiesced are then reconciled to 0 and, with its
+		// Latency, the rest to the weights derived for them, rather than
+		// put back to the desired weights on every interval
+		Health *HealthChecker
+
+		mu      sync.Mutex
+		desired []Service
+		set     bool
+	}
+)
+
+// SetDesired replaces the services the table is reconciled to, saving them
+// to Store first when set
+func (r *Reconciler) SetDesired(services []Service) error {
+	if r.Store != nil {
+		if err := r.Store.Save(services); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.desired = copyServices(services)
+	r.set = true
+	return nil
+}
+
+// Desired returns a copy of the services the table is reconciled to
+func (r *Reconciler) Desired() []Service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return copyServices(r.desired)
+}
+
+// Run reconciles every Interval, plus jitter, until stop is closed
+func (r *Reconciler) Run(stop <-chan struct{}) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	for {
+		if _, err := r.ReconcileOnce(); err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		wait := interval
+		if r.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(r.Jitter)))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// ReconcileOnce syncs the table to the desired services if it drifted from
+// them, reporting whether it did
+func (r *Reconciler) ReconcileOnce() (bool, error) {
+	if r.Store != nil {
+		stored, err := r.Store.Load()
+		if err != nil {
+			return false, err
+		}
+		if stored != nil {
+			r.mu.Lock()
+			r.desired, r.set = stored, true
+			r.mu.Unlock()
+		}
+	}
+	r.mu.Lock()
+	desired, set := copyServices(r.desired), r.set
+	r.mu.Unlock()
+	if !set {
+		return false, nil
+	}
+	l := r.Lvs
+	if l == nil {
+		l = DefaultLvs
+	}
+
+	if r.Health != nil {
+		// hold the checker while syncing, so a server it quiesces in
+		// between isn't put back
+		r.Health.mu.Lock()
+		desired = r.Health.adjustWeights(desired)
+	}
+	var drift []string
+	var events []Event
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		if drift, err = i.Drift(desired); err != nil || len(drift) == 0 {
+			return err
+		}
+		defer func() { events = i.churn.takeEvents() }()
+		return i.Sync(desired)
+	})
+	if r.Health != nil {
+		r.Health.mu.Unlock()
+	}
+	if len(drift) == 0 {
+		return false, err
+	}
+	message := strings.Join(drift, ", ")
+	l.publish(Event{Type: EventDriftDetected, Message: message})
+	for _, event := range events {
+		l.publish(event)
+	}
+	if err != nil {
+		return true, err
+	}
+	l.publish(Event{Type: EventSyncApplied})
+	l.publish(Event{Type: EventDriftCorrected, Message: message})
+	return true, nil
+}
@@ -0,0 +1,117 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReconciler(t *testing.T) {
+	simulator := NewSimulator()
+	client := New(WithRunner(simulator))
+	events := []Event{}
+	client.Subscribe(EventHandlerFunc(func(e Event) {
+		if e.Type == EventDriftDetected || e.Type == EventDriftCorrected {
+			events = append(events, e)
+		}
+	}))
+
+	r := &Reconciler{Lvs: client}
+	if drifted, err := r.ReconcileOnce(); drifted || err != nil {
+		t.Fatalf("reconciled without desired state - %v %v", drifted, err)
+	}
+	r.SetDesired([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}})
+	if drifted, err := r.ReconcileOnce(); !drifted || err != nil {
+		t.Fatalf("expected the empty table to drift - %v %v", drifted, err)
+	}
+	if drifted, err := r.ReconcileOnce(); drifted || err != nil {
+		t.Fatalf("expected the table to have converged - %v %v", drifted, err)
+	}
+
+	// manual changes
+	ctx := context.Background()
+	simulator.Execute(ctx, "ipvsadm", "-e", "-t", "10.0.0.1:80", "-r", "10.0.1.1:80", "-g", "-w", "5")
+	simulator.Execute(ctx, "ipvsadm", "-A", "-u", "10.0.0.2:53", "-s", "rr")
+	events = nil
+	if drifted, err := r.ReconcileOnce(); !drifted || err != nil {
+		t.Fatalf("expected manual changes to drift - %v %v", drifted, err)
+	}
+	if len(events) != 2 || events[0].Type != EventDriftDetected || events[0].Message != "changed tcp 10.0.0.1:80, unexpected udp 10.0.0.2:53" || events[1].Type != EventDriftCorrected {
+		t.Errorf("unexpected events - %+v", events)
+	}
+	services := simulator.Services()
+	if len(services) != 1 || services[0].Servers[0].Weight != 1 {
+		t.Errorf("drift wasn't corrected - %+v", services)
+	}
+}
+
+func TestReconcilerHealth(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	failing := true
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		if failing && server.Host == "10.0.1.2" {
+			return errors.New("connection refused")
+		}
+		return nil
+	})}
+	r := &Reconciler{Lvs: client, Health: checker}
+	desired := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "wrr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 1},
+	}}}
+	r.SetDesired(desired)
+	if drifted, err := r.ReconcileOnce(); !drifted || err != nil {
+		t.Fatalf("expected the empty table to drift - %v %v", drifted, err)
+	}
+
+	checker.CheckOnce()
+	if drifted, err := r.ReconcileOnce(); drifted || err != nil {
+		t.Fatalf("quiesced server shouldn't drift - %v %v", drifted, err)
+	}
+	if servers := client.Services()[0].Servers; servers[0].Weight != 1 || servers[1].Weight != 0 {
+		t.Errorf("quiesced server was put back - %+v", servers)
+	}
+
+	desired[0].Servers[0].Weight, desired[0].Servers[1].Weight = 3, 3
+	r.SetDesired(desired)
+	if drifted, err := r.ReconcileOnce(); !drifted || err != nil {
+		t.Fatalf("expected the new weights to drift - %v %v", drifted, err)
+	}
+	if servers := client.Services()[0].Servers; servers[0].Weight != 3 || servers[1].Weight != 0 {
+		t.Errorf("unexpected weights - %+v", servers)
+	}
+	failing = false
+	checker.CheckOnce()
+	if servers := client.Services()[0].Servers; servers[1].Weight != 3 {
+		t.Errorf("recovered server should get the desired weight - %+v", servers[1])
+	}
+}
+
+func TestReconcilerLatency(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	checker := &HealthChecker{Lvs: client, Latency: &LatencyWeighting{MaxWeight: 50}, Check: checkFunc(func(service Service, server Server) error {
+		if server.Host == "10.0.1.2" {
+			time.Sleep(40 * time.Millisecond)
+		}
+		return nil
+	})}
+	r := &Reconciler{Lvs: client, Health: checker}
+	r.SetDesired([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "wrr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 1},
+	}}})
+	r.ReconcileOnce()
+
+	checker.CheckOnce()
+	weighted := client.Services()[0].Servers
+	if weighted[0].Weight != 50 {
+		t.Fatalf("expected latency weights - %+v", weighted)
+	}
+	if drifted, err := r.ReconcileOnce(); drifted || err != nil {
+		t.Fatalf("latency weights shouldn't drift - %v %v", drifted, err)
+	}
+	if servers := client.Services()[0].Servers; servers[0].Weight != weighted[0].Weight || servers[1].Weight != weighted[1].Weight {
+		t.Errorf("latency weights were undone - %+v", servers)
+	}
+}
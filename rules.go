@@ -0,0 +1,111 @@
+package lvs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// RulesFile is the file a distribution's ipvsadm service restores the
+	// table from at boot
+	RulesFile struct {
+		Path    string      // defaults to DetectRulesPath()
+		Backups int         // previous versions kept as Path.1 (newest) to Path.{Backups}
+		Mode    os.FileMode // defaults to 0600
+	}
+)
+
+var (
+	// DefaultRulesPath is where Debian's ipvsadm service (with AUTO="true"
+	// in /etc/default/ipvsadm) restores the table from
+	DefaultRulesPath = "/etc/ipvsadm.rules"
+	// RHELRulesPath is where RHEL's ipvsadm service restores it from
+	RHELRulesPath = "/etc/sysconfig/ipvsadm"
+
+	// rhelMarker tells RHEL like distributions apart
+	rhelMarker = "/etc/redhat-release"
+)
+
+// DetectRulesPath returns the rules file of the host's distribution,
+// RHELRulesPath on RHEL like distributions and DefaultRulesPath otherwise
+func DetectRulesPath() string {
+	if _, err := os.Stat(rhelMarker); err == nil {
+		return RHELRulesPath
+	}
+	return DefaultRulesPath
+}
+
+// WriteRules writes the table's services to the rules file restored at
+// boot, like iptables-persistent does for iptables. The file is replaced
+// atomically, rotating the previous version into the backups, and left
+// alone when it already holds the same rules
+func (i Ipvs) WriteRules(f RulesFile) error {
+	if f.Path == "" {
+		f.Path = DetectRulesPath()
+	}
+	if f.Mode == 0 {
+		f.Mode = 0600
+	}
+	rules, err := i.rules()
+	if err != nil {
+		return err
+	}
+
+	current, err := os.ReadFile(f.Path)
+	if err == nil && bytes.Equal(current, []byte(rules)) {
+		return nil
+	}
+	if err == nil && f.Backups > 0 {
+		for n := f.Backups - 1; n > 0; n-- {
+			os.Rename(f.Path+"."+strconv.Itoa(n), f.Path+"."+strconv.Itoa(n+1))
+		}
+		if err := writeAtomic(f.Path+".1", current, f.Mode); err != nil {
+			return err
+		}
+	}
+	return writeAtomic(f.Path, []byte(rules), f.Mode)
+}
+
+// rules returns the table's services in the format of `ipvsadm -S`, with
+// interface names resolved to their address
+func (i Ipvs) rules() (string, error) {
+	rules := make([]string, 0, len(i.Services))
+	for j := range i.Services {
+		service := i.Services[j]
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return "", err
+		}
+		rules = append(rules, applied.String())
+	}
+	return strings.Join(rules, ""), nil
+}
+
+// writeAtomic replaces the file at path with data, so readers never see it
+// partially written
+func writeAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
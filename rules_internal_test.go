@@ -0,0 +1,42 @@
+package lvs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteRules(t *testing.T) {
+	defer useFakeBackend()()
+
+	path := filepath.Join(t.TempDir(), "ipvsadm.rules")
+	ipvs := Ipvs{Services: []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}}
+	for weight := 2; weight <= 4; weight++ {
+		if err := ipvs.WriteRules(RulesFile{Path: path, Backups: 2}); err != nil {
+			t.Fatalf("failed to write - %v", err)
+		}
+		ipvs.Services[0].Servers[0].Weight = weight
+	}
+	// unchanged rules don't rotate the backups
+	ipvs.Services[0].Servers[0].Weight = 3
+	if err := ipvs.WriteRules(RulesFile{Path: path, Backups: 2}); err != nil {
+		t.Fatalf("failed to write - %v", err)
+	}
+
+	for file, weight := range map[string]int{path: 3, path + ".1": 2, path + ".2": 1} {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("failed to read %s - %v", file, err)
+		}
+		services, err := ParseSave(string(bytes))
+		if err != nil || len(services) != 1 || services[0].Servers[0].Weight != weight {
+			t.Errorf("unexpected rules in %s - %s", file, bytes)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept too many backups - %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected mode - %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package lvs
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+type (
+	// HealthSchedule is when the servers of a service are checked, every
+	// Interval or on a Cron schedule, spreading the checks of its servers
+	// over Splay so thousands of them don't all run at once
+	HealthSchedule struct {
+		Interval time.Duration // defaults to the checker's Interval
+		// Cron is a cron expression such as "*/15 * * * *" or "@hourly"
+		// (see ParseCron), used rather than Interval when set
+		Cron  string
+		Splay time.Duration
+		// Check is run rather than the checker's Check, eg. a heavier
+		// HTTPCheck on a slower schedule
+		Check HealthCheck
+	}
+)
+
+// Validate checks the Cron expressions of the checker's Schedules
+func (h *HealthChecker) Validate() error {
+	for _, schedule := range h.Schedules {
+		if schedule.Cron == "" {
+			continue
+		}
+		if _, err := ParseCron(schedule.Cron); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scheduleFor returns the schedule of service, by name or key
+func (h *HealthChecker) scheduleFor(service Service) (HealthSchedule, bool) {
+	if service.Name != "" {
+		if schedule, ok := h.Schedules[service.Name]; ok {
+			return schedule, true
+		}
+	}
+	schedule, ok := h.Schedules[service.key()]
+	return schedule, ok
+}
+
+// checkFor returns the check to run against the servers of service
+func (h *HealthChecker) checkFor(service Service) HealthCheck {
+	if schedule, ok := h.scheduleFor(service); ok && schedule.Check != nil {
+		return schedule.Check
+	}
+	return h.Check
+}
+
+// splay returns the delay of the server with key within the Splay, from a
+// hash of the key so a service's servers are spread evenly over it
+func (s HealthSchedule) splay(key string) time.Duration {
+	if s.Splay <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return time.Duration(hash.Sum64() % uint64(s.Splay))
+}
+
+// next returns when the server with key is next due after being checked at
+// t. Services with an invalid Cron are checked every interval
+func (s HealthSchedule) next(key string, t time.Time, interval time.Duration) time.Time {
+	if s.Cron != "" {
+		if cron, err := ParseCron(s.Cron); err == nil {
+			splay := s.splay(key)
+			return cron.Next(t.Add(-splay)).Add(splay)
+		}
+	}
+	if s.Interval > 0 {
+		interval = s.Interval
+	}
+	return t.Add(interval)
+}
+
+// first returns when the server with key is first due from t, at once but
+// for its splay or on the Cron schedule
+func (s HealthSchedule) first(key string, t time.Time, interval time.Duration) time.Time {
+	if s.Cron != "" {
+		return s.next(key, t, interval)
+	}
+	return t.Add(s.splay(key))
+}
+
+// runScheduled checks each server when due on its service's schedule until
+// stop is closed
+func (h *HealthChecker) runScheduled(stop <-chan struct{}) {
+	interval := h.interval()
+	next := make(map[string]time.Time) // by health key
+	for {
+		now := time.Now()
+		due := make(map[string]time.Time, len(next))
+		h.check(func(service Service, server Server) bool {
+			schedule, _ := h.scheduleFor(service)
+			key := healthKey(service, server)
+			at, ok := next[key]
+			if !ok {
+				at = schedule.first(key, now, interval)
+			}
+			if at.After(now) {
+				due[key] = at
+				return false
+			}
+			due[key] = schedule.next(key, now, interval)
+			return true
+		})
+		next = due
+
+		// wake for the next check due, or to pick up new servers
+		wait := time.Second
+		for _, at := range next {
+			if until := at.Sub(time.Now()); until < wait {
+				wait = until
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package lvs
+
+import (
+	"errors"
+	"strings"
+)
+
+type (
+	// SchedulerOpts are the scheduler specific parameters of a service,
+	// applied with ipvsadm's --sched-flags
+	SchedulerOpts struct {
+		// Fallback has the sh and mh schedulers pick another server when
+		// the hashed one is unavailable (overloaded or weight 0)
+		Fallback bool `json:"fallback,omitempty"`
+		// Port has the sh and mh schedulers hash the source port along
+		// with the source address
+		Port bool `json:"port,omitempty"`
+		// Flags are passed as is, eg. "flag-3"
+		Flags []string `json:"flags,omitempty"`
+	}
+)
+
+var (
+	InvalidSchedulerOpts = errors.New("Invalid Scheduler Options")
+)
+
+// validate checks the options apply to scheduler
+func (o *SchedulerOpts) validate(scheduler string) error {
+	if o == nil {
+		return nil
+	}
+	scheduler = ServiceSchedulerFlag[scheduler]
+	if (o.Fallback || o.Port) && scheduler != "sh" && scheduler != "mh" {
+		return InvalidSchedulerOpts
+	}
+	for _, flag := range o.Flags {
+		if flag == "" || strings.ContainsAny(flag, ", ") {
+			return InvalidSchedulerOpts
+		}
+	}
+	return nil
+}
+
+// getSchedFlags returns the --sched-flags arguments for s, if any
+func (s Service) getSchedFlags() []string {
+	if s.SchedulerOpts == nil {
+		return []string{}
+	}
+	scheduler := ServiceSchedulerFlag[s.Scheduler]
+	flags := make([]string, 0, 0)
+	if s.SchedulerOpts.Fallback {
+		flags = append(flags, scheduler+"-fallback")
+	}
+	if s.SchedulerOpts.Port {
+		flags = append(flags, scheduler+"-port")
+	}
+	flags = append(flags, s.SchedulerOpts.Flags...)
+	if len(flags) == 0 {
+		return []string{}
+	}
+	return []string{"-b", strings.Join(flags, ",")}
+}
+
+// parseSchedFlags parses the value of --sched-flags
+func parseSchedFlags(value string) *SchedulerOpts {
+	opts := &SchedulerOpts{}
+	for _, flag := range strings.Split(value, ",") {
+		switch {
+		case flag == "":
+		case strings.HasSuffix(flag, "-fallback"):
+			opts.Fallback = true
+		case strings.HasSuffix(flag, "-port"):
+			opts.Port = true
+		default:
+			opts.Flags = append(opts.Flags, flag)
+		}
+	}
+	return opts
+}
@@ -0,0 +1,52 @@
+package lvs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchedulerOpts(t *testing.T) {
+	defer useFakeBackend()()
+
+	service := Service{}
+	if err := service.FromJson([]byte(`{"host": "10.0.0.1", "port": 80, "scheduler": "mh", "scheduler_opts": {"fallback": true, "port": true}}`)); err != nil {
+		t.Fatalf("failed to parse - %v", err)
+	}
+	if err := service.Validate(); err != nil {
+		t.Fatalf("failed to validate - %v", err)
+	}
+	ipvs := NewIpvs()
+	if err := ipvs.AddService(service); err != nil {
+		t.Fatalf("failed to add - %v", err)
+	}
+	if fakeExecuted[0] != "ipvsadm -A -t 10.0.0.1:80 -s mh -b mh-fallback,mh-port" {
+		t.Errorf("unexpected command %q", fakeExecuted[0])
+	}
+
+	parsed, err := ParseServiceLine("-A -t 10.0.0.1:80 -s mh -b mh-fallback,mh-port")
+	if err != nil {
+		t.Fatalf("failed to parse line - %v", err)
+	}
+	if !parsed.sameAttributes(service) {
+		t.Errorf("expected %+v, got %+v", service.SchedulerOpts, parsed.SchedulerOpts)
+	}
+	bytes, _ := parsed.ToJson()
+	if !strings.Contains(string(bytes), `"scheduler_opts":{"fallback":true,"port":true}`) {
+		t.Errorf("scheduler_opts not serialized - %s", bytes)
+	}
+
+	service.Scheduler = "rr"
+	if err := service.Validate(); err != InvalidSchedulerOpts {
+		t.Errorf("expected InvalidSchedulerOpts, got %v", err)
+	}
+	service.SchedulerOpts = &SchedulerOpts{Flags: []string{"flag-3"}}
+	if err := service.Validate(); err != nil {
+		t.Errorf("raw flags should be allowed - %v", err)
+	}
+	if flags := strings.Join(service.getSchedFlags(), " "); flags != "-b flag-3" {
+		t.Errorf("unexpected flags %q", flags)
+	}
+	if !(Service{}).sameAttributes(Service{SchedulerOpts: &SchedulerOpts{}}) {
+		t.Errorf("empty scheduler options should match none")
+	}
+}
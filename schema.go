@@ -0,0 +1,333 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// SchemaError is a document failing the config's JSON Schema, at Path
+	// (a JSON Pointer such as "/services/0/servers/1/weight")
+	SchemaError struct {
+		Path    string `json:"path"`
+		Message string `json:"message"`
+	}
+
+	// SchemaErrors are every way a document fails the schema
+	SchemaErrors []SchemaError
+)
+
+const (
+	// references to the parts of ConfigSchema documents are validated
+	// against, see ValidateSchema
+	SchemaIpvs     = "#"
+	SchemaServices = "#/definitions/services"
+	SchemaService  = "#/definitions/service"
+	SchemaServer   = "#/definitions/server"
+)
+
+// ConfigSchema returns the JSON Schema (draft-07) of Ipvs configs, with the
+// services, service and server documents the api accepts as definitions
+func ConfigSchema() map[string]interface{} {
+	port := map[string]interface{}{"type": "integer", "minimum": 0, "maximum": 65535}
+	count := map[string]interface{}{"type": "integer", "minimum": 0}
+	str := map[string]interface{}{"type": "string"}
+	strs := map[string]interface{}{"type": []interface{}{"array", "null"}, "items": str}
+	// timestamps are kept by the Ipvs, accepted so documents read back
+	// from the api validate
+	timestamp := map[string]interface{}{"type": []interface{}{"string", "null"}, "format": "date-time"}
+	legacy := func(schema map[string]interface{}) map[string]interface{} {
+		legacy := map[string]interface{}{"description": "deprecated, the key before the json tags were fixed"}
+		for key, value := range schema {
+			legacy[key] = value
+		}
+		return legacy
+	}
+
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "golang-lvs Ipvs config",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"mcast_interface": str,
+			"syncid":          count,
+			"sync_version":    map[string]interface{}{"type": []interface{}{"integer", "null"}, "minimum": 0, "maximum": 1},
+			"tcp_timeout":     count,
+			"tcp_fin_timeout": count,
+			"udp_fin_timeout": count,
+			"services":        map[string]interface{}{"$ref": SchemaServices},
+			// keys before the json tags were fixed, still read
+			"MulticastInterface": legacy(str),
+			"Tcp":                legacy(count),
+			"Tcpfin":             legacy(count),
+			"Udp":                legacy(count),
+			"resources": map[string]interface{}{
+				"type": []interface{}{"array", "null"},
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"properties": map[string]interface{}{
+						"name":     str,
+						"apply":    strs,
+						"check":    strs,
+						"remove":   strs,
+						"requires": strs,
+					},
+				},
+			},
+		},
+		"definitions": map[string]interface{}{
+			"services": map[string]interface{}{
+				"type":  []interface{}{"array", "null"},
+				"items": map[string]interface{}{"$ref": SchemaService},
+			},
+			"service": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"host":        str,
+					"port":        port,
+					"type":        map[string]interface{}{"type": "string", "enum": flagNames(ServiceTypeFlag)},
+					"scheduler":   map[string]interface{}{"type": "string", "enum": flagNames(ServiceSchedulerFlag)},
+					"persistence": count,
+					"netmask":     str,
+					"servers": map[string]interface{}{
+						"type":  []interface{}{"array", "null"},
+						"items": map[string]interface{}{"$ref": SchemaServer},
+					},
+					"scheduler_opts": map[string]interface{}{
+						"type":                 []interface{}{"object", "null"},
+						"additionalProperties": false,
+						"properties": map[string]interface{}{
+							"fallback": map[string]interface{}{"type": "boolean"},
+							"port":     map[string]interface{}{"type": "boolean"},
+							"flags":    map[string]interface{}{"type": []interface{}{"array", "null"}, "items": str},
+						},
+					},
+					"persistence_engine": map[string]interface{}{"type": "string", "enum": flagNames(ServicePersistenceEngine)},
+					"default_forwarder":  map[string]interface{}{"type": "string", "enum": flagNames(ServerForwarderFlag)},
+					"requires":           strs,
+					"one_packet":         map[string]interface{}{"type": "boolean"},
+					"min_servers":        count,
+					"max_conns":          count,
+					"weight_bounds": map[string]interface{}{
+						"type":                 []interface{}{"object", "null"},
+						"additionalProperties": false,
+						"properties": map[string]interface{}{
+							"min":   count,
+							"max":   count,
+							"clamp": map[string]interface{}{"type": "boolean"},
+						},
+					},
+					"name":              str,
+					"protected":         map[string]interface{}{"type": "boolean"},
+					"last_applied":      timestamp,
+					"last_checked":      timestamp,
+					"last_state_change": timestamp,
+				},
+			},
+			"server": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"host":              str,
+					"port":              port,
+					"forwarder":         map[string]interface{}{"type": "string", "enum": flagNames(ServerForwarderFlag)},
+					"weight":            count,
+					"upper_threshold":   count,
+					"lower_threshold":   count,
+					"UpperThreshold":    legacy(count),
+					"LowerThreshold":    legacy(count),
+					"zone":              str,
+					"last_applied":      timestamp,
+					"last_checked":      timestamp,
+					"last_state_change": timestamp,
+				},
+			},
+		},
+	}
+}
+
+// ValidateSchema validates the json document data against the part of
+// ConfigSchema at ref, such as SchemaService, returning SchemaErrors when
+// it doesn't conform
+func ValidateSchema(data []byte, ref string) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+	root := ConfigSchema()
+	errs := SchemaErrors{}
+	validateNode(root, map[string]interface{}{"$ref": ref}, doc, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for j := range e {
+		msgs[j] = e[j].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.Message
+}
+
+// validateNode validates the subset of JSON Schema ConfigSchema uses
+func validateNode(root, schema map[string]interface{}, doc interface{}, path string, errs *SchemaErrors) {
+	if ref, ok := schema["$ref"].(string); ok {
+		schema = resolveRef(root, ref)
+		if schema == nil {
+			*errs = append(*errs, SchemaError{Path: path, Message: "unknown schema " + ref})
+			return
+		}
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(doc)
+		matched := false
+		for _, t := range types {
+			matched = matched || t == actual || (t == "number" && actual == "integer")
+		}
+		if !matched {
+			*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), actual)})
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]string); ok {
+		value, _ := doc.(string)
+		found := false
+		for _, allowed := range enum {
+			found = found || allowed == value
+		}
+		if !found {
+			*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf("%q is not one of %s", value, strings.Join(quoted(enum), ", "))})
+		}
+	}
+
+	switch value := doc.(type) {
+	case json.Number:
+		number, _ := value.Float64()
+		if minimum, ok := schema["minimum"].(int); ok && number < float64(minimum) {
+			*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf("%s is less than %d", value, minimum)})
+		}
+		if maximum, ok := schema["maximum"].(int); ok && number > float64(maximum) {
+			*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf("%s is greater than %d", value, maximum)})
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for j := range value {
+				validateNode(root, items, value[j], path+"/"+strconv.Itoa(j), errs)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+					*errs = append(*errs, SchemaError{Path: path + "/" + escapePointer(key), Message: "unknown property"})
+				}
+				continue
+			}
+			validateNode(root, property, value[key], path+"/"+escapePointer(key), errs)
+		}
+	}
+}
+
+// resolveRef returns the schema at a local reference, such as
+// "#/definitions/service"
+func resolveRef(root map[string]interface{}, ref string) map[string]interface{} {
+	node := root
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		next, ok := node[part].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, each := range t {
+			if s, ok := each.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonType(doc interface{}) string {
+	switch value := doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if number, err := value.Float64(); err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// flagNames returns the names a flag map accepts, in order
+func flagNames(flags map[string]string) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func quoted(values []string) []string {
+	quoted := make([]string, len(values))
+	for j := range values {
+		quoted[j] = strconv.Quote(values[j])
+	}
+	return quoted
+}
+
+// escapePointer escapes a property name for a JSON Pointer
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
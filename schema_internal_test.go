@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	if err := ValidateSchema([]byte(`{"tcp_timeout":900,"services":[{"host":"10.0.0.1","port":80,"type":"tcp","servers":[{"host":"10.0.1.1","port":80,"weight":1}]}]}`), SchemaIpvs); err != nil {
+		t.Errorf("valid config failed - %v", err)
+	}
+
+	err := ValidateSchema([]byte(`{"port":"80","type":"sctp","servers":[{"host":"10.0.1.1","weight":-1,"bogus":1}]}`), SchemaService)
+	expected := SchemaErrors{
+		{Path: "/port", Message: "expected integer, got string"},
+		{Path: "/servers/0/bogus", Message: "unknown property"},
+		{Path: "/servers/0/weight", Message: "-1 is less than 0"},
+		{Path: "/type", Message: `"sctp" is not one of "", "fwmark", "tcp", "udp"`},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("unexpected errors - %v", err)
+	}
+}
+
+func TestApiSchema(t *testing.T) {
+	defer useFakeBackend()()
+
+	api := NewApi(&Ipvs{})
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("GET", "/schema", nil))
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `"$schema"`) {
+		t.Errorf("failed to get schema - %d %s", rw.Code, rw.Body)
+	}
+
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("POST", "/services", strings.NewReader(`{"host":"10.0.0.1","port":80,"scheduler":"fastest"}`)))
+	response := apiError{}
+	json.NewDecoder(rw.Body).Decode(&response)
+	if rw.Code != http.StatusBadRequest || len(response.Errors) != 1 || response.Errors[0].Path != "/scheduler" {
+		t.Errorf("expected the scheduler to be rejected - %d %+v", rw.Code, response)
+	}
+	if len(api.Ipvs.Services) != 0 {
+		t.Errorf("invalid service was added")
+	}
+}
@@ -4,18 +4,47 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type (
 	Server struct {
-		Host           string `json:"host"`
-		Port           int    `json:"port"`
-		Forwarder      string `json:"forwarder"`
-		Weight         int    `json:"weight"`
-		UpperThreshold int    `json:upper_threshold`
-		LowerThreshold int    `json:lower_threshold`
+		Host      string `json:"host"`
+		Port      int    `json:"port"`
+		Forwarder string `json:"forwarder"`
+		Weight    int    `json:"weight"`
+		// WeightSet is whether Weight was given, decoding json sets it when
+		// the weight is there. Payloads apply WithDefaultWeight so an
+		// omitted weight gets DefaultWeight while an explicit 0 drains
+		WeightSet      bool `json:"-"`
+		UpperThreshold int  `json:"upper_threshold"`
+		LowerThreshold int  `json:"lower_threshold"`
+
+		// Zone is the datacenter or zone the server is in, see
+		// LocalityPolicy. It isn't known to ipvs
+		Zone string `json:"zone,omitempty"`
+
+		// LastApplied is when ipvsadm last added or edited the server,
+		// LastChecked when a HealthChecker last checked it and
+		// LastStateChange when it last went down or up. They are kept by
+		// the Ipvs, set values are ignored
+		LastApplied     *time.Time `json:"last_applied,omitempty"`
+		LastChecked     *time.Time `json:"last_checked,omitempty"`
+		LastStateChange *time.Time `json:"last_state_change,omitempty"`
+	}
+
+	// ServerConflict is returned for two servers of a service on the same
+	// address and port. ipvs identifies servers by their address and port
+	// alone, the forwarder is one of their attributes (changed by
+	// EditServer), so a service can't have both. Adding a server that is
+	// already there with the same forwarder isn't a conflict and does nothing
+	ServerConflict struct {
+		Service  string // type and host:port of the service
+		Existing Server
+		Added    Server
 	}
 )
 
@@ -27,6 +56,10 @@ var (
 		"":  "-g", // default
 	}
 
+	// DefaultWeight is the weight WithDefaultWeight gives servers without
+	// one, an explicit weight of 0 drains the server instead
+	DefaultWeight = 1
+
 	InvalidServerForwarder = errors.New("Invalid Server Forwarder")
 	InvalidServerPort      = errors.New("Invalid Server Port for Forwarder")
 )
@@ -36,38 +69,121 @@ func (s Server) Validate() error {
 	if !ok {
 		return InvalidServerForwarder
 	}
-	return nil
+	// checked first, whitespace would otherwise make it an InvalidHost
+	if err := checkCharacters(strings.TrimSpace(s.Host)); err != nil {
+		return err
+	}
+	_, err := NormalizeHost(s.Host)
+	return err
+}
+
+func (e ServerConflict) Error() string {
+	existing, added := ServerForwarderFlag[e.Existing.Forwarder], ServerForwarderFlag[e.Added.Forwarder]
+	if existing == added {
+		return fmt.Sprintf("server %s is in %s twice", e.Existing.getHostPort(), e.Service)
+	}
+	return fmt.Sprintf("server %s is already in %s with forwarder %s, not %s", e.Existing.getHostPort(), e.Service, existing, added)
+}
+
+// is reports whether s is the server at host and port, which identify
+// servers within a service
+func (s Server) is(host string, port int) bool {
+	return sameHost(s.Host, host) && s.Port == port
+}
+
+func (s Server) sameAttributes(o Server) bool {
+	return sameHost(s.Host, o.Host) && s.Port == o.Port &&
+		ServerForwarderFlag[s.Forwarder] == ServerForwarderFlag[o.Forwarder] &&
+		s.Weight == o.Weight &&
+		s.UpperThreshold == o.UpperThreshold &&
+		s.LowerThreshold == o.LowerThreshold
+}
+
+// Equal reports whether o is applied the same as s
+func (s Server) Equal(o Server) bool {
+	return s.sameAttributes(o)
 }
 
 func (s *Server) FromJson(bytes []byte) error {
-	return json.Unmarshal(bytes, s)
+	return unmarshal("json", bytes, s)
+}
+
+// UnmarshalJSON decodes the server, setting WeightSet when the weight is
+// there (the weight itself is left as given), and accepting the threshold
+// keys servers had before their json tags were fixed
+// (UpperThreshold and LowerThreshold)
+func (s *Server) UnmarshalJSON(bytes []byte) error {
+	type server Server
+	decoded := struct {
+		server
+		Weight *int `json:"weight"`
+		// the keys before the json tags were fixed
+		LegacyUpperThreshold *int `json:"UpperThreshold"`
+		LegacyLowerThreshold *int `json:"LowerThreshold"`
+	}{}
+	if err := json.Unmarshal(bytes, &decoded); err != nil {
+		return err
+	}
+	*s = Server(decoded.server)
+	if decoded.Weight != nil {
+		s.Weight, s.WeightSet = *decoded.Weight, true
+	}
+	if decoded.LegacyUpperThreshold != nil && s.UpperThreshold == 0 {
+		s.UpperThreshold = *decoded.LegacyUpperThreshold
+	}
+	if decoded.LegacyLowerThreshold != nil && s.LowerThreshold == 0 {
+		s.LowerThreshold = *decoded.LegacyLowerThreshold
+	}
+	return nil
+}
+
+// WithDefaultWeight returns s with DefaultWeight when it has no weight, as
+// when decoded from json without one. Servers with WeightSet, or a weight
+// other than 0, are returned as they are
+func (s Server) WithDefaultWeight() Server {
+	if !s.WeightSet && s.Weight == 0 {
+		s.Weight = DefaultWeight
+	}
+	return s
 }
 
 func (s Server) ToJson() ([]byte, error) {
-	return json.Marshal(s)
+	return marshal("json", s)
 }
 
 func (s Server) getHostPort() string {
-	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
 }
 
-func (s Server) String() string {
-	return fmt.Sprintf("%s %s -y %d -x %d -w %d",
+// Args returns the ipvsadm arguments describing the server, following -r
+func (s Server) Args() []string {
+	return []string{
 		s.getHostPort(), ServerForwarderFlag[s.Forwarder],
-		s.LowerThreshold, s.UpperThreshold, s.Weight)
+		"-y", strconv.Itoa(s.LowerThreshold),
+		"-x", strconv.Itoa(s.UpperThreshold),
+		"-w", strconv.Itoa(s.Weight),
+	}
 }
 
-func parseServer(serverString string) Server {
+func (s Server) String() string {
+	return strings.Join(s.Args(), " ")
+}
+
+// ParseServerLine parses a real server from a line of `ipvsadm -S` output,
+// such as "-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1"
+func ParseServerLine(line string) (Server, error) {
 	server := Server{
 		Forwarder: "g",
 		Weight:    1,
 	}
-	var err error
-	exploded := strings.Split(serverString, " ")
-	for i := range exploded {
-		switch exploded[i] {
+	tokens := strings.Fields(line)
+	for i := range tokens {
+		var value string
+		var err error
+		switch tokens[i] {
 		case "-r", "--real-server":
-			server.Host, server.Port = parseHostPort(exploded[i+1])
+			value, err = nextToken(tokens, i)
+			server.Host, server.Port = parseHostPort(value)
 		case "-g", "--gatewaying":
 			server.Forwarder = "g"
 		case "-i", "--ipip":
@@ -75,21 +191,15 @@ func parseServer(serverString string) Server {
 		case "-m", "--masquerading":
 			server.Forwarder = "m"
 		case "-w", "--weight":
-			server.Weight, err = strconv.Atoi(exploded[i+1])
-			if err != nil {
-				server.Weight = 1
-			}
+			server.Weight, err = nextInt(tokens, i)
 		case "-x", "--u-threshold":
-			server.UpperThreshold, err = strconv.Atoi(exploded[i+1])
-			if err != nil {
-				server.UpperThreshold = 0
-			}
+			server.UpperThreshold, err = nextInt(tokens, i)
 		case "-y", "--l-threshold":
-			server.LowerThreshold, err = strconv.Atoi(exploded[i+1])
-			if err != nil {
-				server.LowerThreshold = 0
-			}
+			server.LowerThreshold, err = nextInt(tokens, i)
+		}
+		if err != nil {
+			return server, err
 		}
 	}
-	return server
+	return server, nil
 }
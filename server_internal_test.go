@@ -1 +1,88 @@
 package lvs
+
+import (
+	"testing"
+)
+
+func TestParseServerLine(t *testing.T) {
+	server, err := ParseServerLine("-a -t 10.0.0.1:80 -r 10.0.1.1:8080 -m -w 5 -x 100 -y 50")
+	if err != nil {
+		t.Fatalf("failed to parse server - %v", err)
+	}
+	expected := Server{Host: "10.0.1.1", Port: 8080, Forwarder: "m", Weight: 5, UpperThreshold: 100, LowerThreshold: 50}
+	if server != expected {
+		t.Errorf("expected %+v, got %+v", expected, server)
+	}
+
+	if _, err = ParseServerLine("-a -t 10.0.0.1:80 -r 10.0.1.1:80 -w"); err != EOFError {
+		t.Errorf("expected EOFError for a truncated line, got %v", err)
+	}
+	if _, err = ParseServerLine("-a -t 10.0.0.1:80 -r 10.0.1.1:80 -w heavy"); err != UnexpecedToken {
+		t.Errorf("expected UnexpecedToken for a bad weight, got %v", err)
+	}
+}
+
+func TestServerWeightJson(t *testing.T) {
+	server := Server{}
+	if err := server.FromJson([]byte(`{"host":"10.0.1.1","port":80}`)); err != nil {
+		t.Fatalf("failed to decode server - %v", err)
+	}
+	if server.WeightSet || server.Weight != 0 {
+		t.Errorf("omitted weight should be left unset, got %+v", server)
+	}
+	if weight := server.WithDefaultWeight().Weight; weight != DefaultWeight {
+		t.Errorf("omitted weight should default to %d, got %d", DefaultWeight, weight)
+	}
+
+	server = Server{}
+	if err := server.FromJson([]byte(`{"host":"10.0.1.1","port":80,"weight":0}`)); err != nil {
+		t.Fatalf("failed to decode server - %v", err)
+	}
+	if !server.WeightSet || server.WithDefaultWeight().Weight != 0 {
+		t.Errorf("explicit weight of 0 should drain, got %+v", server.WithDefaultWeight())
+	}
+	if weight := (Server{Weight: 5}).WithDefaultWeight().Weight; weight != 5 {
+		t.Errorf("a weight should be kept, got %d", weight)
+	}
+
+	service := Service{}
+	if err := service.FromJson([]byte(`{"host":"10.0.0.1","port":80,"servers":[{"host":"10.0.1.1","port":80},{"host":"10.0.1.2","port":80,"weight":0}]}`)); err != nil {
+		t.Fatalf("failed to decode service - %v", err)
+	}
+	if service = service.WithDefaultWeights(); service.Servers[0].Weight != DefaultWeight || service.Servers[1].Weight != 0 {
+		t.Errorf("servers of a service should get the default weight unless set, got %+v", service.Servers)
+	}
+}
+
+func TestServerArgs(t *testing.T) {
+	defer useFakeBackend()()
+
+	// a stray space must not make it to ipvsadm, nor should the default
+	// forwarder produce empty arguments
+	service := Service{Host: "10.0.0.1", Port: 80}
+	if err := service.AddServer(Server{Host: "eth0 10.0.1.1", Port: 80, Weight: 2}); err != ErrInvalidCharacters {
+		t.Fatalf("expected ErrInvalidCharacters, got %v", err)
+	}
+	if err := service.AddServer(Server{Host: " 10.0.1.1", Port: 80, Weight: 2}); err != nil {
+		t.Fatalf("failed to add server - %v", err)
+	}
+	expected := []string{"ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.1:80", "-g", "-y", "0", "-x", "0", "-w", "2"}
+	args := fakeExecutedArgs[0]
+	if len(args) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("argument %d - expected '%s', got '%s'", i, expected[i], args[i])
+		}
+	}
+}
+
+func FuzzParseServerLine(f *testing.F) {
+	f.Add("-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1")
+	f.Add("-a -u [2001:db8::1]:53 -r [2001:db8::2]:53 -i -w 0 -x 10 -y 5")
+	f.Add("-r")
+	f.Fuzz(func(t *testing.T, line string) {
+		ParseServerLine(line)
+	})
+}
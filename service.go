@@ -1,11 +1,12 @@
 package lvs
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type (
@@ -17,6 +18,60 @@ type (
 		Persistence int      `json:"persistence"`
 		Netmask     string   `json:"netmask"`
 		Servers     []Server `json:"servers"`
+
+		SchedulerOpts *SchedulerOpts `json:"scheduler_opts,omitempty"`
+
+		// DefaultForwarder is the forwarder of the servers added without
+		// one, eg. "g" for a service of direct routing servers. It isn't
+		// known to ipvs, servers are applied with their forwarder
+		DefaultForwarder string `json:"default_forwarder,omitempty"`
+
+		// Requires are the Resources (by name) and the other services (by
+		// Name or key, eg. "-f 1") Sync applies before the service
+		Requires []string `json:"requires,omitempty"`
+
+		// PersistenceEngine extends persistence beyond the client address
+		// (--pe), eg. "sip" to keep calls on the same server. It requires
+		// Persistence
+		PersistenceEngine string `json:"persistence_engine,omitempty"`
+		// OnePacket schedules every udp datagram independently (--ops),
+		// rather than keeping a connection entry for its flow
+		OnePacket bool `json:"one_packet,omitempty"`
+
+		// MinServers is how many servers health checks always leave in
+		// rotation, even when they fail. It isn't known to ipvs
+		MinServers int `json:"min_servers,omitempty"`
+
+		// MaxConns caps the service's connections, shared between its
+		// servers in rotation as their UpperThreshold in proportion to
+		// their weights and redistributed whenever servers are added,
+		// removed or reweighted (see WithConnLimit). It isn't known to ipvs
+		MaxConns int `json:"max_conns,omitempty"`
+
+		// WeightBounds bound the weights of the servers on every write. It
+		// isn't known to ipvs
+		WeightBounds *WeightBounds `json:"weight_bounds,omitempty"`
+
+		// Name is a human readable label for the service, used in metrics,
+		// status pages and api responses. It isn't known to ipvs, see
+		// WithStateFile to keep it across restarts
+		Name string `json:"name,omitempty"`
+
+		// Protected services aren't removed by Sync nor Clear, which fail
+		// with ErrProtectedService unless forced (SyncForce, ClearForce),
+		// guarding critical vips against config mistakes. It isn't known
+		// to ipvs, see WithStateFile to keep it across restarts
+		Protected bool `json:"protected,omitempty"`
+
+		// LastApplied is when ipvsadm last added or edited the service,
+		// LastChecked when a HealthChecker last checked any of its servers
+		// and LastStateChange when one of them last went down or up. They
+		// are kept by the Ipvs, set values are ignored
+		LastApplied     *time.Time `json:"last_applied,omitempty"`
+		LastChecked     *time.Time `json:"last_checked,omitempty"`
+		LastStateChange *time.Time `json:"last_state_change,omitempty"`
+
+		exec *executor
 	}
 )
 
@@ -39,11 +94,20 @@ var (
 		"sh":    "sh",
 		"sed":   "sed",
 		"nq":    "nq",
+		"mh":    "mh",
 		"":      "wlc", // default
 	}
 
-	InvalidServiceType      = errors.New("Invalid Service Type")
-	InvalidServiceScheduler = errors.New("Invalid Service Scheduler")
+	ServicePersistenceEngine = map[string]string{
+		"sip": "sip",
+		"":    "", // none
+	}
+
+	InvalidServiceType        = errors.New("Invalid Service Type")
+	InvalidServiceScheduler   = errors.New("Invalid Service Scheduler")
+	InvalidPersistenceEngine  = errors.New("Invalid Persistence Engine, expected a known engine and persistence")
+	InvalidOnePacketScheduler = errors.New("Invalid One Packet Scheduling, only udp and fwmark services have it")
+	InvalidFwmark             = errors.New("Invalid Fwmark, expected a mark from 1 to 4294967295")
 )
 
 func (s Service) Validate() error {
@@ -51,96 +115,296 @@ func (s Service) Validate() error {
 	if !ok {
 		return InvalidServiceType
 	}
+	// checked first, whitespace would otherwise make it an InvalidHost
+	err := checkCharacters(strings.TrimSpace(s.Host), s.Netmask, s.Scheduler)
+	if err != nil {
+		return err
+	}
+	if _, err = NormalizeHost(s.Host); err != nil {
+		return err
+	}
+	if ServiceTypeFlag[s.Type] == "-f" {
+		if _, err = parseFwmark(s.Host); err != nil {
+			return err
+		}
+	}
 	_, ok = ServiceSchedulerFlag[s.Scheduler]
 	if !ok {
 		return InvalidServiceScheduler
 	}
-	for _, server := range s.Servers {
-		err := server.Validate()
+	err = s.SchedulerOpts.validate(s.Scheduler)
+	if err != nil {
+		return err
+	}
+	if _, ok = ServicePersistenceEngine[s.PersistenceEngine]; !ok || (s.PersistenceEngine != "" && s.Persistence == 0) {
+		return InvalidPersistenceEngine
+	}
+	if s.OnePacket && ServiceTypeFlag[s.Type] == "-t" {
+		return InvalidOnePacketScheduler
+	}
+	if _, ok = ServerForwarderFlag[s.DefaultForwarder]; !ok {
+		return InvalidServerForwarder
+	}
+	seen := make(map[string]int) // index of each server by identity
+	for j, server := range s.Servers {
+		server = s.withDefaults(server)
+		err = s.validateServer(server)
 		if err != nil {
 			return err
 		}
-		// follow ipvsadm rules
-		if server.Forwarder != "m" && (s.Port != server.Port) {
-			return InvalidServerPort
+		key := net.JoinHostPort(canonicalHost(server.Host), strconv.Itoa(server.Port))
+		if k, ok := seen[key]; ok {
+			return s.conflict(s.withDefaults(s.Servers[k]), server)
 		}
+		seen[key] = j
+	}
+	return nil
+}
+
+func (s Service) conflict(existing, added Server) ServerConflict {
+	return ServerConflict{Service: s.Type + " " + s.getHostPort(), Existing: existing, Added: added}
+}
+
+// checkConflict fails with a ServerConflict when s already has a server at
+// server's address and port with another forwarder
+func (s Service) checkConflict(server Server) error {
+	current := s.FindServer(server.Host, server.Port)
+	if current != nil && ServerForwarderFlag[current.Forwarder] != ServerForwarderFlag[server.Forwarder] {
+		return s.conflict(*current, server)
+	}
+	return nil
+}
+
+// validateServer checks server can be added to s. Only masquerading can
+// remap ports, so gatewaying and tunneled servers must listen on the
+// service's port. Fwmark services have no port of their own, so any is fine
+func (s Service) validateServer(server Server) error {
+	err := server.Validate()
+	if err != nil {
+		return err
+	}
+	if ServiceTypeFlag[s.Type] == "-f" || ServerForwarderFlag[server.Forwarder] == "-m" {
+		return nil
+	}
+	if s.Port != server.Port {
+		return InvalidServerPort
 	}
 	return nil
 }
 
 func (s Service) FindServer(host string, port int) *Server {
 	for i := range s.Servers {
-		if s.Servers[i].Host == host && s.Servers[i].Port == port {
+		if s.Servers[i].is(host, port) {
 			return &s.Servers[i]
 		}
 	}
 	return nil
 }
 
+// withDefaults returns server with the service's DefaultForwarder when it
+// has no forwarder of its own, and the service's port when it has no port
+// (as usual with gatewaying and tunneling). Servers of fwmark services keep
+// port 0, ipvs then forwards to the port each packet was sent to
+func (s Service) withDefaults(server Server) Server {
+	if server.Forwarder == "" {
+		server.Forwarder = s.DefaultForwarder
+	}
+	if server.Port == 0 && ServiceTypeFlag[s.Type] != "-f" {
+		server.Port = s.Port
+	}
+	return server
+}
+
+// WithDefaultWeights returns s with its servers' WithDefaultWeight, for
+// services decoded from payloads
+func (s Service) WithDefaultWeights() Service {
+	if s.Servers != nil {
+		servers := make([]Server, len(s.Servers))
+		for j := range s.Servers {
+			servers[j] = s.Servers[j].WithDefaultWeight()
+		}
+		s.Servers = servers
+	}
+	return s
+}
+
 func (s *Service) AddServer(server Server) error {
-	err := server.Validate()
+	server = s.withDefaults(server)
+	err := s.validateServer(server)
 	if err != nil {
 		return err
 	}
-	if server.Forwarder != "m" && (s.Port != server.Port) {
-		return InvalidServerPort
+	server, err = server.Normalize()
+	if err != nil {
+		return err
 	}
 	if s.FindServer(server.Host, server.Port) != nil {
-		return nil
+		return s.checkConflict(server)
+	}
+	server, err = s.boundWeight(server)
+	if err != nil {
+		return err
+	}
+	server = s.limitConns(server)
+	applied, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	if err = s.exec.checkReachable(applied, server); err != nil {
+		return err
 	}
-	err = backend("ipvsadm", append([]string{"-a", ServiceTypeFlag[s.Type], s.getHostPort(), "-r"}, strings.Split(server.String(), " ")...)...)
+	err = s.exec.execute("ipvsadm", append([]string{"-a", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-r"}, server.Args()...)...)
 	if err != nil {
 		return err
 	}
 
+	server.LastApplied, server.LastChecked, server.LastStateChange = stampNow(), nil, nil
 	s.Servers = append(s.Servers, server)
-	return nil
+	return s.applyConnLimit()
 }
 
 func (s *Service) EditServer(server Server) error {
-	err := server.Validate()
+	_, err := s.EditServerChanged(server)
+	return err
+}
+
+// AddServerChanged adds server like AddServer, reporting whether it was
+// actually added
+func (s *Service) AddServerChanged(server Server) (bool, error) {
+	server = s.withDefaults(server)
+	if s.FindServer(server.Host, server.Port) != nil {
+		if err := server.Validate(); err != nil {
+			return false, err
+		}
+		return false, s.checkConflict(server)
+	}
+	err := s.AddServer(server)
+	return err == nil, err
+}
+
+// EditServerChanged edits server like EditServer, skipping the edit and
+// reporting false if it is already applied as requested
+func (s *Service) EditServerChanged(server Server) (bool, error) {
+	return s.editServer(server, WeightChange{})
+}
+
+// editServer edits server like EditServerChanged, attributing a change of
+// its weight to cause
+func (s *Service) editServer(server Server, cause WeightChange) (bool, error) {
+	server = s.withDefaults(server)
+	err := s.validateServer(server)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if server.Forwarder != "m" && (s.Port != server.Port) {
-		return InvalidServerPort
+	server, err = server.Normalize()
+	if err != nil {
+		return false, err
+	}
+	server, err = s.boundWeight(server)
+	if err != nil {
+		return false, err
+	}
+	server = s.limitConns(server)
+	current := s.FindServer(server.Host, server.Port)
+	if current != nil && current.Equal(server) {
+		// keep what ipvs doesn't know about, such as the zone
+		*current = current.carryTimes(server)
+		s.exec.skip()
+		return false, nil
 	}
 
-	err = backend("ipvsadm", append([]string{"-e", ServiceTypeFlag[s.Type], s.getHostPort(), "-r"}, strings.Split(server.String(), " ")...)...)
+	applied, err := s.resolve()
 	if err != nil {
-		return err
+		return false, err
 	}
+	err = s.exec.execute("ipvsadm", append([]string{"-e", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-r"}, server.Args()...)...)
+	if err != nil {
+		return false, err
+	}
+	if current != nil {
+		s.exec.recordWeight(cause, *s, server, current.Weight, server.Weight)
+		server = current.carryTimes(server)
+	}
+	server.LastApplied = stampNow()
 
 	for i := range s.Servers {
-		if s.Servers[i].Host == server.Host && s.Servers[i].Port == server.Port {
+		if s.Servers[i].is(server.Host, server.Port) {
 			s.Servers = append(s.Servers[:i], append([]Server{server}, s.Servers[i+1:]...)...)
 			break
 		}
 	}
-	return nil
+	return true, s.applyConnLimit()
+}
+
+// RemoveServerChanged removes the server like RemoveServer, reporting false
+// without touching ipvsadm if the server isn't known
+func (s *Service) RemoveServerChanged(host string, port int) (bool, error) {
+	if s.FindServer(host, port) == nil {
+		return false, nil
+	}
+	err := s.RemoveServer(host, port)
+	return err == nil, err
 }
 
 func (s *Service) RemoveServer(host string, port int) error {
-	err := backend("ipvsadm", "-d", ServiceTypeFlag[s.Type], s.getHostPort(), "-r", fmt.Sprintf("%s:%d", host, port))
+	applied, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	err = s.exec.execute("ipvsadm", "-d", ServiceTypeFlag[applied.Type], applied.getHostPort(), "-r", Server{Host: canonicalHost(host), Port: port}.getHostPort())
 	if err != nil {
 		return err
 	}
 
+	if server := s.FindServer(host, port); server != nil && server.Forwarder == "m" && s.exec.flushesConntrack() {
+		if err = applied.flushConntrack(*server); err != nil {
+			return err
+		}
+	}
+
 	for i := range s.Servers {
-		if s.Servers[i].Host == host && s.Servers[i].Port == port {
+		if s.Servers[i].is(host, port) {
 			s.Servers = append(s.Servers[:i], s.Servers[i+1:]...)
 			break
 		}
 	}
-	return nil
+	return s.applyConnLimit()
+}
+
+// sameAttributes compares everything but the servers, treating unset values
+// the same as the defaults ipvsadm would apply
+func (s Service) sameAttributes(o Service) bool {
+	return ServiceTypeFlag[s.Type] == ServiceTypeFlag[o.Type] &&
+		sameHost(s.Host, o.Host) && s.Port == o.Port &&
+		ServiceSchedulerFlag[s.Scheduler] == ServiceSchedulerFlag[o.Scheduler] &&
+		s.Persistence == o.Persistence &&
+		s.PersistenceEngine == o.PersistenceEngine &&
+		s.Netmask == o.Netmask &&
+		s.OnePacket == o.OnePacket &&
+		strings.Join(s.getSchedFlags(), " ") == strings.Join(o.getSchedFlags(), " ")
+}
+
+// Equal reports whether o describes the same service as s, including its
+// servers in any order
+func (s Service) Equal(o Service) bool {
+	if !s.sameAttributes(o) || len(s.Servers) != len(o.Servers) {
+		return false
+	}
+	for i := range s.Servers {
+		server := o.FindServer(s.Servers[i].Host, s.Servers[i].Port)
+		if server == nil || !server.Equal(s.Servers[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *Service) FromJson(bytes []byte) error {
-	return json.Unmarshal(bytes, s)
+	return unmarshal("json", bytes, s)
 }
 
 func (s Service) ToJson() ([]byte, error) {
-	return json.Marshal(s)
+	return marshal("json", s)
 }
 
 func (s Service) getNetmask() []string {
@@ -152,74 +416,136 @@ func (s Service) getNetmask() []string {
 }
 
 func (s Service) getPersistence() []string {
-	if s.Persistence != 0 {
-		return []string{"-p", fmt.Sprintf("%d", s.Persistence)}
-	} else {
+	if s.Persistence == 0 {
 		return []string{}
 	}
+	if s.PersistenceEngine != "" {
+		return []string{"-p", fmt.Sprintf("%d", s.Persistence), "--pe", s.PersistenceEngine}
+	}
+	return []string{"-p", fmt.Sprintf("%d", s.Persistence)}
+}
+
+func (s Service) getOnePacket() []string {
+	if s.OnePacket {
+		return []string{"-o"}
+	}
+	return []string{}
+}
+
+// getOptions returns the arguments describing s following its scheduler,
+// the same for -A and -E as ipvsadm resets whatever an edit leaves out
+func (s Service) getOptions() []string {
+	options := append(s.getPersistence(), s.getNetmask()...)
+	options = append(options, s.getSchedFlags()...)
+	return append(options, s.getOnePacket()...)
+}
+
+// resolve returns a copy of the service with Host resolved to the address
+// ipvsadm expects, allowing interface names to be used as the Host
+func (s Service) resolve() (Service, error) {
+	host, err := s.exec.resolveHost(s.Host)
+	if err != nil {
+		return s, err
+	}
+	s.Host = host
+	return s, nil
 }
 
 func (s Service) getHostPort() string {
-	if s.Port == 0 {
+	// fwmark services have no port, whatever Port says
+	if s.Port == 0 || ServiceTypeFlag[s.Type] == "-f" {
 		return s.Host
 	}
-	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
 }
 
+// String writes the service and its servers as the `ipvsadm -R` commands
+// applying them, see Format for ipvsadm's own formats
 func (s Service) String() string {
 	a := make([]string, 0, 0)
-	a = append(a, fmt.Sprintf("-A %s %s -s %s %s %s\n",
+	a = append(a, fmt.Sprintf("-A %s %s -s %s %s %s %s\n",
 		ServiceTypeFlag[s.Type], s.getHostPort(),
-		ServiceSchedulerFlag[s.Scheduler], strings.Join(s.getPersistence(), " "), strings.Join(s.getNetmask(), " ")))
+		ServiceSchedulerFlag[s.Scheduler], strings.Join(s.getPersistence(), " "), strings.Join(s.getNetmask(), " "), strings.Join(append(s.getSchedFlags(), s.getOnePacket()...), " ")))
 	for i := range s.Servers {
-		a = append(a, fmt.Sprintf("-a %s %s:%d -r %s\n",
-			ServiceTypeFlag[s.Type], s.Host, s.Port,
-			s.Servers[i].String()))
+		a = append(a, fmt.Sprintf("-a %s %s -r %s\n",
+			ServiceTypeFlag[s.Type], s.getHostPort(),
+			s.withDefaults(s.Servers[i]).String()))
 	}
 	return strings.Join(a, "")
 }
 
 func (s Service) Add() error {
-	return backend("ipvsadm", append([]string{"-A", ServiceTypeFlag[s.Type], s.getHostPort(), "-s", ServiceSchedulerFlag[s.Scheduler]}, append(s.getPersistence(), s.getNetmask()...)...)...)
+	s, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	return s.exec.execute("ipvsadm", append([]string{"-A", ServiceTypeFlag[s.Type], s.getHostPort(), "-s", ServiceSchedulerFlag[s.Scheduler]}, s.getOptions()...)...)
 }
 
 func (s Service) Remove() error {
-	return backend("ipvsadm", "-D", ServiceTypeFlag[s.Type], s.getHostPort())
+	s, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	return s.exec.execute("ipvsadm", "-D", ServiceTypeFlag[s.Type], s.getHostPort())
 }
 
 func (s Service) Zero() error {
-	return backend("ipvsadm", "-Z", ServiceTypeFlag[s.Type], s.getHostPort())
+	s, err := s.resolve()
+	if err != nil {
+		return err
+	}
+	return s.exec.execute("ipvsadm", "-Z", ServiceTypeFlag[s.Type], s.getHostPort())
 }
 
-func parseService(serviceString string) Service {
+// ParseServiceLine parses a virtual service from a line of `ipvsadm -S`
+// output, such as "-A -t 10.0.0.1:80 -s wlc -p 300"
+func ParseServiceLine(line string) (Service, error) {
 	service := Service{
-		Scheduler:   "wlc",
-		Type:        "tcp",
-		Persistence: 300,
-	}
-	var err error
-	exploded := strings.Split(serviceString, " ")
-	for i := range exploded {
-		switch exploded[i] {
+		Scheduler: "wlc",
+		Type:      "tcp",
+	}
+	tokens := strings.Fields(line)
+	for i := range tokens {
+		var value string
+		var err error
+		switch tokens[i] {
 		case "-t", "--tcp-service":
 			service.Type = "tcp"
-			service.Host, service.Port = parseHostPort(exploded[i+1])
+			if value, err = nextToken(tokens, i); err == nil {
+				service.Host, service.Port, err = parseServiceAddress(service.Type, value)
+			}
 		case "-u", "--udp-service":
 			service.Type = "udp"
-			service.Host, service.Port = parseHostPort(exploded[i+1])
+			if value, err = nextToken(tokens, i); err == nil {
+				service.Host, service.Port, err = parseServiceAddress(service.Type, value)
+			}
 		case "-f", "--fwmark-service":
 			service.Type = "fwmark"
-			service.Host, service.Port = parseHostPort(exploded[i+1])
+			if value, err = nextToken(tokens, i); err == nil {
+				service.Host, service.Port, err = parseServiceAddress(service.Type, value)
+			}
 		case "-s", "--scheduler":
-			service.Scheduler = exploded[i+1]
+			service.Scheduler, err = nextToken(tokens, i)
 		case "-p", "--persistent":
-			service.Persistence, err = strconv.Atoi(exploded[i+1])
-			if err != nil {
-				service.Persistence = 300
+			// the timeout is optional, defaulting to 300
+			service.Persistence = 300
+			if persistence, err := nextInt(tokens, i); err == nil {
+				service.Persistence = persistence
 			}
 		case "-M", "--netmask":
-			service.Netmask = exploded[i+1]
+			service.Netmask, err = nextToken(tokens, i)
+		case "-b", "--sched-flags":
+			value, err = nextToken(tokens, i)
+			service.SchedulerOpts = parseSchedFlags(value)
+		case "--pe":
+			service.PersistenceEngine, err = nextToken(tokens, i)
+		case "-o", "--ops":
+			service.OnePacket = true
+		}
+		if err != nil {
+			return service, err
 		}
 	}
-	return service
+	return service, nil
 }
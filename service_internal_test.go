@@ -1 +1,279 @@
 package lvs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerChanged(t *testing.T) {
+	defer useFakeBackend()()
+
+	service := Service{Host: "10.0.0.1", Port: 80}
+	server := Server{Host: "10.0.1.1", Port: 80, Weight: 1}
+	if changed, err := service.AddServerChanged(server); !changed || err != nil {
+		t.Errorf("new server should be added - %v, %v", changed, err)
+	}
+	if changed, err := service.AddServerChanged(server); changed || err != nil {
+		t.Errorf("existing server should not be added - %v, %v", changed, err)
+	}
+	if changed, err := service.EditServerChanged(server); changed || err != nil {
+		t.Errorf("unchanged server should not be edited - %v, %v", changed, err)
+	}
+	server.Weight = 0
+	if changed, err := service.EditServerChanged(server); !changed || err != nil {
+		t.Errorf("changed server should be edited - %v, %v", changed, err)
+	}
+	if changed, err := service.RemoveServerChanged("10.0.1.1", 80); !changed || err != nil {
+		t.Errorf("server should be removed - %v, %v", changed, err)
+	}
+	if changed, err := service.RemoveServerChanged("10.0.1.1", 80); changed || err != nil {
+		t.Errorf("missing server should not be removed - %v, %v", changed, err)
+	}
+}
+
+func TestServiceEqual(t *testing.T) {
+	a := Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80}, {Host: "10.0.1.2", Port: 80}}}
+	b := Service{Host: "10.0.0.1", Port: 80, Type: "tcp", Scheduler: "wlc", Servers: []Server{{Host: "10.0.1.2", Port: 80, Forwarder: "g"}, {Host: "10.0.1.1", Port: 80}}}
+	if !a.Equal(b) {
+		t.Errorf("services differing only by defaults and server order should be equal")
+	}
+	b.Servers[0].Weight = 5
+	if a.Equal(b) {
+		t.Errorf("services with different server weights should not be equal")
+	}
+}
+
+func TestParseServiceLine(t *testing.T) {
+	service, err := ParseServiceLine("-A -u 10.0.0.1:53 -s rr -p 60 -M 255.255.255.0")
+	if err != nil {
+		t.Fatalf("failed to parse service - %v", err)
+	}
+	if service.Type != "udp" || service.Host != "10.0.0.1" || service.Port != 53 || service.Scheduler != "rr" || service.Persistence != 60 || service.Netmask != "255.255.255.0" {
+		t.Errorf("service parsed wrong - %+v", service)
+	}
+
+	service, err = ParseServiceLine("-A -t 10.0.0.1:80 -s wlc -p")
+	if err != nil || service.Persistence != 300 {
+		t.Errorf("persistence without a timeout should default to 300 - %+v, %v", service, err)
+	}
+	if _, err = ParseServiceLine("-A -t 10.0.0.1:80 -s"); err != EOFError {
+		t.Errorf("expected EOFError for a truncated line, got %v", err)
+	}
+}
+
+func TestParseFwmarkService(t *testing.T) {
+	service, err := ParseServiceLine("-A -f 0x10 -s rr")
+	if err != nil || service.Type != "fwmark" || service.Host != "16" || service.Port != 0 {
+		t.Fatalf("fwmark parsed wrong - %+v, %v", service, err)
+	}
+	for _, line := range []string{"-A -f 16:80 -s rr", "-A -f 0 -s rr", "-A -f 10.0.0.1 -s rr"} {
+		if _, err = ParseServiceLine(line); err != InvalidFwmark {
+			t.Errorf("expected InvalidFwmark for %q, got %v", line, err)
+		}
+	}
+	if err = (Service{Type: "fwmark", Host: "16:80", Scheduler: "rr"}).Validate(); err != InvalidFwmark {
+		t.Errorf("expected InvalidFwmark, got %v", err)
+	}
+
+	// a stray port doesn't make it to ipvsadm, so the service round-trips
+	service = Service{Type: "fwmark", Host: "16", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Forwarder: "g", Weight: 1}}}
+	restored, err := ParseSave(service.String())
+	if err != nil || len(restored) != 1 || restored[0].Host != "16" || restored[0].Port != 0 || len(restored[0].Servers) != 1 {
+		t.Errorf("fwmark service didn't round trip - %+v, %v", restored, err)
+	}
+}
+
+func TestSyncHexFwmark(t *testing.T) {
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+	services := []Service{{Type: "fwmark", Host: "0x10", Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Forwarder: "g", Weight: 1}}}}
+	if err := ipvs.Sync(services); err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+	applied := simulator.Services()
+	if len(applied) != 1 || applied[0].Host != "16" || len(ipvs.Services) != 1 || ipvs.Services[0].Host != "16" {
+		t.Fatalf("expected the mark applied as 16 - %+v, %+v", applied, ipvs.Services)
+	}
+
+	// and found as such again
+	if err := ipvs.Sync(services); err != nil || len(simulator.Services()) != 1 {
+		t.Errorf("expected the second sync to find the service - %+v, %v", simulator.Services(), err)
+	}
+	if converged, err := ipvs.Converged(services); err != nil || !converged {
+		t.Errorf("expected 0x10 to have converged - %v", err)
+	}
+}
+
+func TestRemoveServerIPv6(t *testing.T) {
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+	err := ipvs.AddService(Service{Type: "tcp", Host: "2001:db8::1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "2001:db8::2", Port: 80, Forwarder: "g", Weight: 1},
+		{Host: "2001:db8::3", Port: 80, Forwarder: "g", Weight: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ipvs.Services[0].RemoveServer("2001:DB8::2", 80); err != nil {
+		t.Fatalf("failed to remove the ipv6 server - %v", err)
+	}
+	if servers := simulator.Services()[0].Servers; len(servers) != 1 || servers[0].Host != "2001:db8::3" {
+		t.Errorf("unexpected servers - %+v", servers)
+	}
+}
+
+func FuzzParseServiceLine(f *testing.F) {
+	f.Add("-A -t 10.0.0.1:80 -s wlc")
+	f.Add("-A -f 1 -s rr -p 300 -M 255.255.255.0")
+	f.Add("-A -t")
+	f.Fuzz(func(t *testing.T, line string) {
+		ParseServiceLine(line)
+	})
+}
+
+func TestServerPortRemapping(t *testing.T) {
+	tests := []struct {
+		service Service
+		server  Server
+		err     error
+	}{
+		{Service{Port: 80}, Server{Port: 80}, nil},
+		{Service{Port: 80}, Server{Port: 8080}, InvalidServerPort},
+		{Service{Port: 80}, Server{Port: 8080, Forwarder: "g"}, InvalidServerPort},
+		{Service{Port: 80}, Server{Port: 8080, Forwarder: "i"}, InvalidServerPort},
+		{Service{Port: 80}, Server{Port: 8080, Forwarder: "m"}, nil},
+		{Service{Type: "fwmark", Host: "1"}, Server{Port: 443, Forwarder: "g"}, nil},
+	}
+	for _, test := range tests {
+		test.service.Servers = []Server{test.server}
+		if err := test.service.Validate(); err != test.err {
+			t.Errorf("%+v - expected %v, got %v", test.service, test.err, err)
+		}
+	}
+
+	// remapped ports survive a round trip through ipvsadm's format
+	server := Server{Host: "10.0.1.1", Port: 8080, Forwarder: "m", Weight: 1}
+	parsed, err := ParseServerLine("-a -t 10.0.0.1:80 -r " + server.String())
+	if err != nil || parsed != server {
+		t.Errorf("expected %+v, got %+v, %v", server, parsed, err)
+	}
+}
+
+func TestServerPortAutoFill(t *testing.T) {
+	defer useFakeBackend()()
+
+	if err := (Service{Port: 80, Servers: []Server{{Host: "10.0.1.1", Forwarder: "i"}}}).Validate(); err != nil {
+		t.Errorf("servers without a port should get the service's - %v", err)
+	}
+
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80}
+	if err := service.AddServer(Server{Host: "10.0.1.1", Weight: 1}); err != nil {
+		t.Fatalf("failed to add server - %v", err)
+	}
+	if service.Servers[0].Port != 80 || fakeExecuted[0] != "ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -y 0 -x 0 -w 1" {
+		t.Errorf("expected the service's port - %+v %q", service.Servers, fakeExecuted)
+	}
+	if changed, err := service.AddServerChanged(Server{Host: "10.0.1.1", Weight: 1}); changed || err != nil {
+		t.Errorf("server should already be there - %v %v", changed, err)
+	}
+	if !strings.Contains((Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.2", Weight: 1}}}).String(), "-r 10.0.1.2:80 ") {
+		t.Errorf("restored servers should get the service's port")
+	}
+
+	// fwmark services have no port to fill in
+	fwmark := Service{Type: "fwmark", Host: "1"}
+	if err := fwmark.AddServer(Server{Host: "10.0.1.1", Weight: 1}); err != nil || fwmark.Servers[0].Port != 0 {
+		t.Errorf("fwmark server should keep port 0 - %+v %v", fwmark.Servers, err)
+	}
+}
+
+func TestServerConflict(t *testing.T) {
+	defer useFakeBackend()()
+
+	service := Service{Type: "tcp", Host: "10.0.0.1", Port: 80}
+	if err := service.AddServer(Server{Host: "10.0.1.1", Port: 80, Forwarder: "g"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.AddServer(Server{Host: "10.0.1.1", Port: 80}); err != nil {
+		t.Errorf("adding the same server again should do nothing - %v", err)
+	}
+	fakeExecuted = nil
+	err := service.AddServer(Server{Host: "10.0.1.1", Port: 80, Forwarder: "i"})
+	conflict, ok := err.(ServerConflict)
+	if !ok || conflict.Existing.Forwarder != "g" || conflict.Added.Forwarder != "i" || conflict.Service != "tcp 10.0.0.1:80" {
+		t.Fatalf("expected a ServerConflict, got %v", err)
+	}
+	if err.Error() != "server 10.0.1.1:80 is already in tcp 10.0.0.1:80 with forwarder -g, not -i" {
+		t.Errorf("unexpected message - %v", err)
+	}
+	if _, err := service.AddServerChanged(Server{Host: "10.0.1.1", Port: 80, Forwarder: "i"}); err != conflict {
+		t.Errorf("expected the conflict, got %v", err)
+	}
+	if len(fakeExecuted) != 0 || len(service.Servers) != 1 {
+		t.Errorf("conflicting server was added - %v %+v", fakeExecuted, service.Servers)
+	}
+
+	// servers are identified the same however their address is written
+	service = Service{Type: "tcp", Host: "2001:db8::1", Port: 80, Servers: []Server{{Host: "2001:db8::10", Port: 80}, {Host: "2001:DB8:0::10", Port: 80}}}
+	if _, ok := service.Validate().(ServerConflict); !ok {
+		t.Errorf("expected duplicate servers to conflict, got %v", service.Validate())
+	}
+}
+
+func TestDefaultForwarder(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs()
+	err := ipvs.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, DefaultForwarder: "m", Servers: []Server{
+		{Host: "10.0.1.1", Port: 8080, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Forwarder: "g", Weight: 1},
+	}})
+	if err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	if err := ipvs.Services[0].AddServer(Server{Host: "10.0.1.3", Port: 8080, Weight: 1}); err != nil {
+		t.Fatalf("failed to add server - %v", err)
+	}
+	expected := []string{
+		"ipvsadm -A -t 10.0.0.1:80 -s wlc",
+		"ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.1:8080 -m -y 0 -x 0 -w 1",
+		"ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -y 0 -x 0 -w 1",
+		"ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.3:8080 -m -y 0 -x 0 -w 1",
+	}
+	if strings.Join(fakeExecuted, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected servers with the default forwarder -\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(fakeExecuted, "\n"))
+	}
+
+	if err := (Service{Type: "tcp", Host: "10.0.0.1", Port: 80, DefaultForwarder: "x"}).Validate(); err != InvalidServerForwarder {
+		t.Errorf("expected InvalidServerForwarder, got %v", err)
+	}
+}
+
+func TestServiceClone(t *testing.T) {
+	applied := time.Now()
+	service := Service{
+		Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "mh", Name: "web", Protected: true,
+		SchedulerOpts: &SchedulerOpts{Flags: []string{"flag-3"}},
+		Requires:      []string{"vip"},
+		Servers:       []Server{{Host: "10.0.1.1", Port: 8080, Weight: 1, LastApplied: &applied}},
+		LastApplied:   &applied,
+	}
+	clone := service.Clone(CloneHost("10.0.0.2"), ClonePort(8080), CloneName("web-staging"), CloneServerPort(9090))
+	if clone.Host != "10.0.0.2" || clone.Port != 8080 || clone.Name != "web-staging" || clone.Servers[0].Port != 9090 || clone.Protected {
+		t.Errorf("overrides weren't applied - %+v", clone)
+	}
+	if clone.LastApplied != nil || clone.Servers[0].LastApplied != nil {
+		t.Errorf("expected the clone not to be applied - %+v", clone)
+	}
+
+	clone.Servers[0].Weight = 5
+	clone.SchedulerOpts.Flags[0] = "flag-1"
+	clone.Requires[0] = "other"
+	if service.Servers[0].Weight != 1 || service.Servers[0].Port != 8080 || service.SchedulerOpts.Flags[0] != "flag-3" || service.Requires[0] != "vip" {
+		t.Errorf("the clone shares state with the service - %+v", service)
+	}
+	if clone := service.Clone(); clone.Name != "" || clone.Host != service.Host || len(clone.Servers) != 1 {
+		t.Errorf("unexpected clone - %+v", clone)
+	}
+}
@@ -0,0 +1,289 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type (
+	// Simulator is a Runner keeping an ipvs table in memory, emulating the
+	// ipvsadm commands (and their errors) the package relies on. It lets
+	// applications be developed and tested on hosts without ipvs, such as
+	// macOS or Windows:
+	//
+	//	l := lvs.New(lvs.WithRunner(lvs.NewSimulator()))
+	//
+	// Commands other than ipvsadm succeed without doing anything
+	Simulator struct {
+		// Json simulates an ipvsadm patched to answer --json (see
+		// ParseJSON), without it --json is an invalid option
+		Json bool
+
+		mu       sync.Mutex
+		services []Service
+	}
+)
+
+var (
+	// the messages ipvsadm reports for table errors
+	simulatorServiceExists     = errors.New("exit status 1: Service already exists")
+	simulatorNoService         = errors.New("exit status 1: No such service")
+	simulatorDestinationExists = errors.New("exit status 1: Destination already exists")
+	simulatorNoDestination     = errors.New("exit status 1: No such destination")
+	simulatorInvalidCommand    = errors.New("exit status 2: invalid command")
+)
+
+// NewSimulator returns a Simulator with an empty table
+func NewSimulator() *Simulator {
+	return &Simulator{services: make([]Service, 0, 0)}
+}
+
+// Services returns a copy of the simulated table
+func (s *Simulator) Services() []Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyServices(s.services)
+}
+
+func (s *Simulator) Execute(ctx context.Context, exe string, args ...string) error {
+	if exe != "ipvsadm" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.apply(args)
+}
+
+// ExecuteStdin applies the rules of `ipvsadm -R`, stopping at the first
+// failing rule like ipvsadm does
+func (s *Simulator) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	if exe != "ipvsadm" {
+		return nil
+	}
+	if len(args) == 0 || args[0] != "-R" {
+		return simulatorInvalidCommand
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range strings.Split(in, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := s.apply(fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	if exe != "ipvsadm" {
+		return []byte{}, []byte{}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	command := strings.Join(args, " ")
+	switch {
+	case strings.Contains(command, "--json"):
+		if !s.Json {
+			return []byte{}, []byte{}, simulatorInvalidCommand
+		}
+		out, err := FormatJSON(s.services)
+		return out, []byte{}, err
+	case strings.HasPrefix(command, "-S"):
+		return []byte(s.save()), []byte{}, nil
+	case strings.Contains(command, "--stats"):
+		return []byte(s.stats()), []byte{}, nil
+	case strings.Contains(command, "-c"):
+		return []byte("IPVS connection entries\npro expire state       source             virtual            destination\n"), []byte{}, nil
+	case strings.HasPrefix(command, "-L") || strings.HasPrefix(command, "-l"):
+		return []byte(s.list()), []byte{}, nil
+	}
+	return []byte{}, []byte{}, s.apply(args)
+}
+
+// apply runs a single ipvsadm command against the table
+func (s *Simulator) apply(args []string) error {
+	if len(args) == 0 {
+		return simulatorInvalidCommand
+	}
+	line := strings.Join(args, " ")
+	switch args[0] {
+	case "-A", "--add-service", "-E", "--edit-service", "-D", "--delete-service":
+		service, err := ParseServiceLine(line)
+		if err != nil {
+			return simulatorInvalidCommand
+		}
+		i := s.find(service)
+		switch args[0] {
+		case "-A", "--add-service":
+			if i >= 0 {
+				return simulatorServiceExists
+			}
+			service.Servers = make([]Server, 0, 0)
+			s.services = append(s.services, service)
+		case "-E", "--edit-service":
+			if i < 0 {
+				return simulatorNoService
+			}
+			service.Servers = s.services[i].Servers
+			s.services[i] = service
+		default:
+			if i < 0 {
+				return simulatorNoService
+			}
+			s.services = append(s.services[:i], s.services[i+1:]...)
+		}
+	case "-a", "--add-server", "-e", "--edit-server", "-d", "--delete-server":
+		service, err := ParseServiceLine(line)
+		if err != nil {
+			return simulatorInvalidCommand
+		}
+		server, err := ParseServerLine(line)
+		if err != nil {
+			return simulatorInvalidCommand
+		}
+		i := s.find(service)
+		if i < 0 {
+			return simulatorNoService
+		}
+		j := -1
+		for k := range s.services[i].Servers {
+			if s.services[i].Servers[k].Host == server.Host && s.services[i].Servers[k].Port == server.Port {
+				j = k
+			}
+		}
+		switch args[0] {
+		case "-a", "--add-server":
+			if j >= 0 {
+				return simulatorDestinationExists
+			}
+			s.services[i].Servers = append(s.services[i].Servers, server)
+		case "-e", "--edit-server":
+			if j < 0 {
+				return simulatorNoDestination
+			}
+			s.services[i].Servers[j] = server
+		default:
+			if j < 0 {
+				return simulatorNoDestination
+			}
+			s.services[i].Servers = append(s.services[i].Servers[:j], s.services[i].Servers[j+1:]...)
+		}
+	case "-C", "--clear":
+		s.services = make([]Service, 0, 0)
+	case "-Z", "--zero":
+		if len(args) > 1 {
+			service, err := ParseServiceLine(line)
+			if err != nil {
+				return simulatorInvalidCommand
+			}
+			if s.find(service) < 0 {
+				return simulatorNoService
+			}
+		}
+	case "--set", "--start-daemon", "--stop-daemon":
+	default:
+		return simulatorInvalidCommand
+	}
+	return nil
+}
+
+func (s *Simulator) find(service Service) int {
+	for i := range s.services {
+		if s.services[i].key() == service.key() {
+			return i
+		}
+	}
+	return -1
+}
+
+// save formats the table like `ipvsadm -S -n`
+func (s *Simulator) save() string {
+	lines := make([]string, 0, 0)
+	for _, service := range s.services {
+		line := fmt.Sprintf("-A %s %s -s %s", ServiceTypeFlag[service.Type], service.getHostPort(), ServiceSchedulerFlag[service.Scheduler])
+		if service.Persistence != 0 {
+			line += fmt.Sprintf(" -p %d", service.Persistence)
+		}
+		if service.Netmask != "" {
+			line += " -M " + service.Netmask
+		}
+		if flags := service.getSchedFlags(); len(flags) > 0 {
+			line += " " + strings.Join(flags, " ")
+		}
+		if service.PersistenceEngine != "" {
+			line += " --pe " + service.PersistenceEngine
+		}
+		if service.OnePacket {
+			line += " -o"
+		}
+		lines = append(lines, line)
+		for _, server := range service.Servers {
+			line := fmt.Sprintf("-a %s %s -r %s %s -w %d", ServiceTypeFlag[service.Type], service.getHostPort(), server.getHostPort(), ServerForwarderFlag[server.Forwarder], server.Weight)
+			if server.UpperThreshold != 0 {
+				line += fmt.Sprintf(" -x %d", server.UpperThreshold)
+			}
+			if server.LowerThreshold != 0 {
+				line += fmt.Sprintf(" -y %d", server.LowerThreshold)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(append(lines, ""), "\n")
+}
+
+// list formats the table like `ipvsadm -L -n`
+func (s *Simulator) list() string {
+	lines := []string{
+		"IP Virtual Server version 1.2.1 (size=4096)",
+		"Prot LocalAddress:Port Scheduler Flags",
+		"  -> RemoteAddress:Port           Forward Weight ActiveConn InActConn",
+	}
+	forwarders := map[string]string{"g": "Route", "i": "Tunnel", "m": "Masq", "": "Route"}
+	for _, service := range s.services {
+		line := fmt.Sprintf("%s  %s %s", simulatorProtocol(service), service.getHostPort(), ServiceSchedulerFlag[service.Scheduler])
+		if service.Persistence != 0 {
+			line += fmt.Sprintf(" persistent %d", service.Persistence)
+		}
+		if service.Netmask != "" {
+			line += " mask " + service.Netmask
+		}
+		lines = append(lines, line)
+		for _, server := range service.Servers {
+			lines = append(lines, fmt.Sprintf("  -> %-28s %-7s %-6d 0          0", server.getHostPort(), forwarders[server.Forwarder], server.Weight))
+		}
+	}
+	return strings.Join(append(lines, ""), "\n")
+}
+
+func simulatorProtocol(service Service) string {
+	switch ServiceTypeFlag[service.Type] {
+	case "-u":
+		return "UDP"
+	case "-f":
+		return "FWM"
+	}
+	return "TCP"
+}
+
+// stats formats the table like `ipvsadm -L -n --stats --exact`, every
+// counter being 0 as no traffic is simulated
+func (s *Simulator) stats() string {
+	lines := []string{
+		"IP Virtual Server version 1.2.1 (size=4096)",
+		"Prot LocalAddress:Port               Conns   InPkts  OutPkts  InBytes OutBytes",
+		"  -> RemoteAddress:Port",
+	}
+	for _, service := range s.services {
+		lines = append(lines, fmt.Sprintf("%s  %-34s 0 0 0 0 0", simulatorProtocol(service), service.getHostPort()))
+		for _, server := range service.Servers {
+			lines = append(lines, fmt.Sprintf("  -> %-32s 0 0 0 0 0", server.getHostPort()))
+		}
+	}
+	return strings.Join(append(lines, ""), "\n")
+}
@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSimulator(t *testing.T) {
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+
+	services := []Service{
+		{Host: "10.0.0.1", Port: 80, Scheduler: "rr", Persistence: 60, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 2, UpperThreshold: 100}}},
+		{Host: "5", Type: "fwmark", Servers: []Server{{Host: "10.0.1.2", Forwarder: "m", Weight: 1}}},
+	}
+	if err := ipvs.Sync(services); err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+	if err := ipvs.EditService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "wlc"}); err != nil {
+		t.Fatalf("failed to edit service - %v", err)
+	}
+	if err := ipvs.Services[1].EditServer(Server{Host: "10.0.1.2", Forwarder: "m", Weight: 0}); err != nil {
+		t.Fatalf("failed to edit server - %v", err)
+	}
+
+	saved := NewIpvs(WithRunner(simulator))
+	if err := saved.Save(); err != nil {
+		t.Fatalf("failed to save - %v", err)
+	}
+	if len(saved.Services) != 2 {
+		t.Fatalf("expected 2 services, got %+v", saved.Services)
+	}
+	if saved.Services[0].Scheduler != "wlc" || len(saved.Services[0].Servers) != 1 || saved.Services[0].Servers[0].UpperThreshold != 100 {
+		t.Errorf("unexpected service - %+v", saved.Services[0])
+	}
+	if saved.Services[1].Servers[0].Weight != 0 || saved.Services[1].Servers[0].Forwarder != "m" {
+		t.Errorf("unexpected fwmark service - %+v", saved.Services[1])
+	}
+
+	listed, err := ipvs.List()
+	// thresholds aren't listed
+	if err != nil || len(listed) != 2 || !listed[0].sameAttributes(saved.Services[0]) || listed[0].Servers[0].Weight != 2 || !listed[1].Equal(saved.Services[1]) {
+		t.Errorf("listed %+v, saved %+v - %v", listed, saved.Services, err)
+	}
+
+	stats, err := ipvs.Stats()
+	if err != nil || len(stats) != 2 || stats[1].Type != "fwmark" || len(stats[0].Servers) != 1 {
+		t.Errorf("unexpected stats %+v - %v", stats, err)
+	}
+
+	ctx := context.Background()
+	if err := simulator.Execute(ctx, "ipvsadm", "-A", "-t", "10.0.0.1:80", "-s", "rr"); err != simulatorServiceExists {
+		t.Errorf("expected duplicate service error, got %v", err)
+	}
+	if err := simulator.Execute(ctx, "ipvsadm", "-a", "-t", "10.0.0.1:80", "-r", "10.0.1.1:80", "-g", "-w", "1"); err != simulatorDestinationExists {
+		t.Errorf("expected duplicate destination error, got %v", err)
+	}
+	if err := simulator.Execute(ctx, "ipvsadm", "-d", "-t", "10.0.0.1:80", "-r", "10.0.1.9:80"); err != simulatorNoDestination {
+		t.Errorf("expected missing destination error, got %v", err)
+	}
+	if err := ipvs.RemoveService("udp", "10.0.0.1", 53); !errors.Is(err, ErrNoSuchService) {
+		t.Errorf("expected missing service error, got %v", err)
+	}
+
+	if err := ipvs.Clear(); err != nil {
+		t.Fatalf("failed to clear - %v", err)
+	}
+	if len(simulator.Services()) != 0 {
+		t.Errorf("expected an empty table, got %+v", simulator.Services())
+	}
+}
+
+func TestSimulatorIPv6(t *testing.T) {
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(simulator))
+	service := Service{Host: "2001:db8::1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "2001:db8::11", Port: 8080, Forwarder: "m", Weight: 1}}}
+	if err := ipvs.AddService(service); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+
+	out, _, err := simulator.RunOutput(context.Background(), "ipvsadm", "-S", "-n")
+	expected := "-A -t [2001:db8::1]:80 -s rr\n-a -t [2001:db8::1]:80 -r [2001:db8::11]:8080 -m -w 1\n"
+	if err != nil || string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s - %v", expected, out, err)
+	}
+	saved := NewIpvs(WithRunner(simulator))
+	if err := saved.Save(); err != nil {
+		t.Fatalf("failed to save - %v", err)
+	}
+	if len(saved.Services) != 1 || !saved.Services[0].Equal(service) || saved.Services[0].Servers[0].Port != 8080 {
+		t.Errorf("ipv6 service didn't round trip - %+v", saved.Services)
+	}
+}
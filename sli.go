@@ -0,0 +1,182 @@
+package lvs
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// ServiceSLI are service level indicators derived from a service's
+	// counters and connection table
+	ServiceSLI struct {
+		Service string `json:"service"` // type and host:port of the service
+		// Availability is the percentage of servers in rotation (weight
+		// above 0), 0 without servers
+		Availability float64 `json:"availability"`
+		// ConnectionsPerSecond is the rate of new connections since the
+		// previous computation
+		ConnectionsPerSecond float64 `json:"connections_per_second"`
+		ActiveConns          uint64  `json:"active_conns"`
+		InactiveConns        uint64  `json:"inactive_conns"`
+		// InactiveSpike is a proxy for connection errors: inactive
+		// connections (mostly handshakes that never completed, or were
+		// reset) spiking above their moving average InactiveBaseline
+		InactiveBaseline float64 `json:"inactive_baseline"`
+		InactiveSpike    bool    `json:"inactive_spike"`
+
+		key string // canonical key of the service
+	}
+
+	// SLITracker computes ServiceSLIs, keeping what rates and spikes are
+	// measured against between computations. The zero value is ready to use
+	SLITracker struct {
+		// SpikeFactor is how many times its baseline the inactive
+		// connections must reach to spike, defaults to 3
+		SpikeFactor float64
+		// Alpha smooths the baseline, between 0 and 1, higher values
+		// following the latest counts more closely. Defaults to 0.1
+		Alpha float64
+
+		mu          sync.Mutex
+		connections map[string]uint64
+		baseline    map[string]float64
+		last        time.Time
+	}
+
+	// connCounts are the counts of a server in `ipvsadm -L -n`
+	connCounts struct {
+		weight           int
+		active, inactive uint64
+	}
+)
+
+var (
+	DefaultSLISpikeFactor = 3.0
+	DefaultSLIAlpha       = 0.1
+)
+
+// Compute reads the counters and connection table of l (DefaultLvs when nil)
+// and returns the SLIs of its services
+func (t *SLITracker) Compute(l *Lvs) ([]ServiceSLI, error) {
+	if l == nil {
+		l = DefaultLvs
+	}
+	var list []byte
+	var stats []ServiceStats
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		if list, err = i.exec.run([]string{"ipvsadm", "-L", "-n"}); err != nil {
+			return err
+		}
+		stats, err = i.Stats()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.update(time.Now(), string(list), stats), nil
+}
+
+// update computes the SLIs of the services in stats, given the output of
+// `ipvsadm -L -n` read along with them
+func (t *SLITracker) update(now time.Time, list string, stats []ServiceStats) []ServiceSLI {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	factor, alpha := t.SpikeFactor, t.Alpha
+	if factor <= 0 {
+		factor = DefaultSLISpikeFactor
+	}
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultSLIAlpha
+	}
+	elapsed := now.Sub(t.last).Seconds()
+	first := t.connections == nil
+	if first {
+		t.connections, t.baseline = make(map[string]uint64), make(map[string]float64)
+	}
+	t.last = now
+
+	counts := parseConnCounts(list)
+	slis := make([]ServiceSLI, 0, len(stats))
+	for _, service := range stats {
+		key := Service{Type: service.Type, Host: service.Host, Port: service.Port}.canonicalKey()
+		sli := ServiceSLI{Service: service.Type + " " + service.hostPort(), key: key}
+
+		inRotation := 0
+		for _, server := range counts[key] {
+			if server.weight > 0 {
+				inRotation++
+			}
+			sli.ActiveConns += server.active
+			sli.InactiveConns += server.inactive
+		}
+		if len(counts[key]) > 0 {
+			sli.Availability = 100 * float64(inRotation) / float64(len(counts[key]))
+		}
+
+		previous, seen := t.connections[key]
+		if seen && elapsed > 0 && service.Connections >= previous {
+			sli.ConnectionsPerSecond = float64(service.Connections-previous) / elapsed
+		}
+		t.connections[key] = service.Connections
+
+		baseline, seen := t.baseline[key]
+		if seen {
+			limit := baseline
+			if limit < 1 {
+				limit = 1
+			}
+			sli.InactiveSpike = float64(sli.InactiveConns) > factor*limit
+			baseline = alpha*float64(sli.InactiveConns) + (1-alpha)*baseline
+		} else {
+			baseline = float64(sli.InactiveConns)
+		}
+		t.baseline[key] = baseline
+		sli.InactiveBaseline = baseline
+
+		slis = append(slis, sli)
+	}
+	return slis
+}
+
+// parseConnCounts reads the weights and connection counts of the servers
+// in `ipvsadm -L -n`, by canonical service key. Lines it can't read are
+// skipped
+func parseConnCounts(out string) map[string][]connCounts {
+	counts := make(map[string][]connCounts)
+	key := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if netType, ok := statsServiceType[fields[0]]; ok {
+			service := Service{Type: netType}
+			var err error
+			key = ""
+			if service.Host, service.Port, err = parseServiceAddress(netType, fields[1]); err == nil {
+				key = service.canonicalKey()
+			}
+			continue
+		}
+		if fields[0] != "->" || len(fields) < 6 || key == "" {
+			continue
+		}
+		weight, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+		active, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		inactive, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[key] = append(counts[key], connCounts{weight: weight, active: active, inactive: inactive})
+	}
+	return counts
+}
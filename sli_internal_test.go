@@ -0,0 +1,38 @@
+package lvs
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLITracker(t *testing.T) {
+	list, err := os.ReadFile("testdata/ipvsadm/1.31/list.txt")
+	if err != nil {
+		t.Fatalf("missing fixture - %v", err)
+	}
+	stats := parseStats(statsOutput)
+	tracker := &SLITracker{}
+	now := time.Now()
+
+	slis := tracker.update(now, string(list), stats)
+	if len(slis) != 2 || slis[0].Service != "tcp 10.0.0.1:80" {
+		t.Fatalf("unexpected slis - %+v", slis)
+	}
+	web := slis[0]
+	if web.Availability != 50 || web.ActiveConns != 15 || web.InactiveConns != 57 || web.InactiveSpike || web.ConnectionsPerSecond != 0 {
+		t.Errorf("unexpected web slis - %+v", web)
+	}
+	if slis[1].Availability != 100 || slis[1].InactiveConns != 5 {
+		t.Errorf("unexpected dns slis - %+v", slis[1])
+	}
+
+	// 20 more connections over 10s, and the inactive connections spiking
+	stats[0].Connections += 20
+	spiking := strings.Replace(string(list), "12         40", "12         400", 1)
+	slis = tracker.update(now.Add(10*time.Second), spiking, stats)
+	if slis[0].ConnectionsPerSecond != 2 || !slis[0].InactiveSpike || slis[1].InactiveSpike {
+		t.Errorf("unexpected slis - %+v", slis)
+	}
+}
@@ -0,0 +1,196 @@
+package lvs
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Snapshot is a point in time view of the services, their health, stats
+	// and rates. Its schema is kept stable (see SnapshotVersion) so it can be
+	// queried by Grafana's json datasources
+	Snapshot struct {
+		Version  int               `json:"version"`
+		Time     time.Time         `json:"time"`
+		Interval float64           `json:"interval"` // seconds the rates were measured over, 0 for the first snapshot
+		Services []ServiceSnapshot `json:"services"`
+	}
+
+	ServiceSnapshot struct {
+		Name      string           `json:"name,omitempty"`
+		Type      string           `json:"type"`
+		Host      string           `json:"host"`
+		Port      int              `json:"port"`
+		Scheduler string           `json:"scheduler"`
+		Stats     Stats            `json:"stats"`
+		Rates     Rates            `json:"rates"`
+		Servers   []ServerSnapshot `json:"servers"`
+	}
+
+	ServerSnapshot struct {
+		Host      string     `json:"host"`
+		Port      int        `json:"port"`
+		Forwarder string     `json:"forwarder"`
+		Weight    int        `json:"weight"`
+		Health    string     `json:"health"` // up, down or unknown
+		LastCheck *time.Time `json:"last_check,omitempty"`
+		Stats     Stats      `json:"stats"`
+		Rates     Rates      `json:"rates"`
+	}
+
+	// Rates are the per second rates of Stats since the previous snapshot
+	Rates struct {
+		Connections float64 `json:"connections"`
+		PacketsIn   float64 `json:"packets_in"`
+		PacketsOut  float64 `json:"packets_out"`
+		BytesIn     float64 `json:"bytes_in"`
+		BytesOut    float64 `json:"bytes_out"`
+	}
+
+	// Snapshotter takes snapshots of a client, remembering the previous one
+	// to work out rates. It serves the latest snapshot as json over http
+	Snapshotter struct {
+		Lvs    *Lvs           // defaults to DefaultLvs
+		Health *HealthChecker // optional
+		// CountersPath is where the counters of the last snapshot are
+		// saved, so the first snapshot after a restart has rates since
+		// the last one before it
+		CountersPath string
+
+		mu       sync.Mutex
+		lvsOnce  sync.Once
+		last     map[string]Stats
+		lastTime time.Time
+		loaded   bool
+	}
+)
+
+const (
+	// SnapshotVersion is bumped whenever the Snapshot schema changes in an
+	// incompatible way
+	SnapshotVersion = 1
+)
+
+// lvs returns the client, defaulting Lvs once as snapshots may be taken
+// concurrently
+func (s *Snapshotter) lvs() *Lvs {
+	s.lvsOnce.Do(func() {
+		if s.Lvs == nil {
+			s.Lvs = DefaultLvs
+		}
+	})
+	return s.Lvs
+}
+
+// Snapshot reads the current stats and returns a snapshot of the client
+func (s *Snapshotter) Snapshot() (Snapshot, error) {
+	l := s.lvs()
+	var stats []ServiceStats
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		stats, err = i.Stats()
+		return err
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.CountersPath != "" && !s.loaded {
+		if s.last, s.lastTime, err = loadCounters(s.CountersPath); err != nil {
+			return Snapshot{}, err
+		}
+		s.loaded = true
+	}
+	now := time.Now()
+	snapshot := Snapshot{Version: SnapshotVersion, Time: now, Services: make([]ServiceSnapshot, 0, 0)}
+	if !s.lastTime.IsZero() {
+		snapshot.Interval = now.Sub(s.lastTime).Seconds()
+	}
+
+	current := make(map[string]Stats)
+	for _, service := range stats {
+		key := Service{Type: service.Type, Host: service.Host, Port: service.Port}.key()
+		current[key] = service.Stats
+		for _, server := range service.Servers {
+			current[key+" "+net.JoinHostPort(server.Host, strconv.Itoa(server.Port))] = server.Stats
+		}
+	}
+
+	for _, service := range l.Services() {
+		key := service.key()
+		serviceSnapshot := ServiceSnapshot{
+			Name:      service.Name,
+			Type:      service.Type,
+			Host:      service.Host,
+			Port:      service.Port,
+			Scheduler: ServiceSchedulerFlag[service.Scheduler],
+			Stats:     current[key],
+			Rates:     s.rates(key, current, snapshot.Interval),
+			Servers:   make([]ServerSnapshot, 0, len(service.Servers)),
+		}
+		for _, server := range service.Servers {
+			serverKey := key + " " + net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+			serverSnapshot := ServerSnapshot{
+				Host:      server.Host,
+				Port:      server.Port,
+				Forwarder: server.Forwarder,
+				Weight:    server.Weight,
+				Stats:     current[serverKey],
+				Rates:     s.rates(serverKey, current, snapshot.Interval),
+			}
+			serverSnapshot.Health, serverSnapshot.LastCheck, _ = healthStatus(s.Health, service, server)
+			serviceSnapshot.Servers = append(serviceSnapshot.Servers, serverSnapshot)
+		}
+		snapshot.Services = append(snapshot.Services, serviceSnapshot)
+	}
+
+	s.last, s.lastTime = current, now
+	if s.CountersPath != "" {
+		if err := saveCounters(s.CountersPath, current, now); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return snapshot, nil
+}
+
+// rates works out the per second rates of key since the last snapshot,
+// leaving them at 0 when there's nothing to compare against or the counters
+// went backwards (zeroed)
+func (s *Snapshotter) rates(key string, current map[string]Stats, interval float64) Rates {
+	previous, ok := s.last[key]
+	now := current[key]
+	if !ok || interval <= 0 {
+		return Rates{}
+	}
+	rate := func(now, then uint64) float64 {
+		if now < then {
+			return 0
+		}
+		return float64(now-then) / interval
+	}
+	return Rates{
+		Connections: rate(now.Connections, previous.Connections),
+		PacketsIn:   rate(now.PacketsIn, previous.PacketsIn),
+		PacketsOut:  rate(now.PacketsOut, previous.PacketsOut),
+		BytesIn:     rate(now.BytesIn, previous.BytesIn),
+		BytesOut:    rate(now.BytesOut, previous.BytesOut),
+	}
+}
+
+func (s *Snapshotter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+		return
+	}
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(rw, http.StatusOK, snapshot)
+}
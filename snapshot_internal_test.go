@@ -0,0 +1,95 @@
+package lvs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}})
+	snapshotter := &Snapshotter{Lvs: client}
+
+	fakeRunOutput = []byte(statsOutput)
+	snapshot, err := snapshotter.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot - %v", err)
+	}
+	if snapshot.Version != SnapshotVersion || snapshot.Interval != 0 || len(snapshot.Services) != 1 {
+		t.Fatalf("unexpected snapshot - %+v", snapshot)
+	}
+	service := snapshot.Services[0]
+	if service.Stats.Connections != 30 || service.Servers[0].Stats.Connections != 10 || service.Servers[0].Health != "unknown" {
+		t.Errorf("unexpected service snapshot - %+v", service)
+	}
+
+	snapshotter.lastTime = snapshotter.lastTime.Add(-10 * time.Second)
+	fakeRunOutput = []byte(strings.Replace(statsOutput, "10.0.0.1:80                        30", "10.0.0.1:80                        50", 1))
+	snapshot, err = snapshotter.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot - %v", err)
+	}
+	if rate := snapshot.Services[0].Rates.Connections; rate < 1.9 || rate > 2 {
+		t.Errorf("expected about 2 connections per second, got %v", rate)
+	}
+
+	rw := httptest.NewRecorder()
+	snapshotter.ServeHTTP(rw, httptest.NewRequest("GET", "/snapshot", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rw.Code)
+	}
+	decoded := Snapshot{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &decoded); err != nil || len(decoded.Services) != 1 {
+		t.Errorf("unexpected body %s - %v", rw.Body.String(), err)
+	}
+}
+
+func TestSnapshotCountersPath(t *testing.T) {
+	defer useFakeBackend()()
+
+	client := New()
+	client.AddService(Service{Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}})
+	path := filepath.Join(t.TempDir(), "counters.json")
+	fakeRunOutput = []byte(statsOutput)
+	if _, err := (&Snapshotter{Lvs: client, CountersPath: path}).Snapshot(); err != nil {
+		t.Fatalf("failed to snapshot - %v", err)
+	}
+
+	fakeRunOutput = []byte(strings.Replace(statsOutput, "10.0.0.1:80                        30", "10.0.0.1:80                        50", 1))
+	snapshot, err := (&Snapshotter{Lvs: client, CountersPath: path}).Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot - %v", err)
+	}
+	if snapshot.Interval <= 0 || snapshot.Services[0].Rates.Connections <= 0 {
+		t.Errorf("expected rates since the saved snapshot - %+v", snapshot)
+	}
+}
+
+func TestSnapshotDefaultLvs(t *testing.T) {
+	defer useFakeBackend()()
+
+	fakeRunOutput = []byte(statsOutput)
+	snapshotter := &Snapshotter{}
+	done := make(chan error)
+	for j := 0; j < 2; j++ {
+		go func() {
+			_, err := snapshotter.Snapshot()
+			done <- err
+		}()
+	}
+	for j := 0; j < 2; j++ {
+		if err := <-done; err != nil {
+			t.Errorf("failed to snapshot - %v", err)
+		}
+	}
+	if snapshotter.Lvs != DefaultLvs {
+		t.Error("expected the snapshotter to default to DefaultLvs")
+	}
+}
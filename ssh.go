@@ -0,0 +1,180 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// SSHRunner runs commands on a remote director through the ssh client,
+	// so a central controller can manage a fleet of directors. Key based
+	// authentication is required as ssh is run in batch mode
+	SSHRunner struct {
+		Host    string
+		Port    int      // defaults to the ssh client's configuration
+		User    string   // defaults to the ssh client's configuration
+		KeyFile string   // identity file, defaults to the ssh client's configuration
+		Options []string // extra -o options, eg. StrictHostKeyChecking=yes
+		// Jump is the bastion (or comma separated bastions) directors in
+		// private networks are reached through, as `ssh -J`:
+		// [user@]host[:port]. Bastions are authenticated as the ssh client's
+		// configuration says, KeyFile is only used for the director
+		Jump string
+		// Persist keeps a master connection (ControlMaster) open for this
+		// long after the last command, which the following commands reuse
+		// rather than dialing the director (and bastions) again. 0 dials
+		// for every command
+		Persist time.Duration
+		// ControlDir is where the master connections' sockets are, lvs-ssh
+		// in the user's cache directory by default. It must only be
+		// writable by the user running the commands
+		ControlDir string
+		// KeepAlive has ssh check the connection with a message every
+		// KeepAlive (ServerAliveInterval), failing commands over (and
+		// closing masters of) connections dead after 3 missed ones
+		KeepAlive time.Duration
+	}
+)
+
+var (
+	ErrControlDir = errors.New("the ssh control directory is accessible to other users")
+)
+
+// WithSSH runs every backend command on a remote director
+func WithSSH(r SSHRunner) Option {
+	return WithRunner(r)
+}
+
+func (r SSHRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	ssh, sshArgs, err := r.command(CommandEnv(ctx), exe, args)
+	if err != nil {
+		return err
+	}
+	return backend(inheritEnv(ctx), ssh, sshArgs...)
+}
+
+func (r SSHRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	ssh, sshArgs, err := r.command(CommandEnv(ctx), exe, args)
+	if err != nil {
+		return err
+	}
+	return backendStdin(inheritEnv(ctx), in, ssh, sshArgs...)
+}
+
+func (r SSHRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	ssh, sshArgs, err := r.command(CommandEnv(ctx), exe, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	return backendRun(inheritEnv(ctx), ssh, sshArgs...)
+}
+
+// command builds the ssh invocation running exe remotely with env (see
+// CommandEnv), the remote command is quoted as ssh hands it to the remote
+// user's shell
+func (r SSHRunner) command(env []string, exe string, args []string) (string, []string, error) {
+	sshArgs, err := r.sshArgs()
+	if err != nil {
+		return "", nil, err
+	}
+	command := append([]string{exe}, args...)
+	if env != nil {
+		command = append(append(append([]string{"env", "-i", "PATH=" + RemotePath}, env...), exe), args...)
+	}
+	remote := make([]string, 0, len(command))
+	for _, arg := range command {
+		remote = append(remote, shellQuote(arg))
+	}
+	return "ssh", append(sshArgs, r.target(), "--", strings.Join(remote, " ")), nil
+}
+
+// sshArgs are the options of every ssh invocation reaching the director:
+// through Jump, sharing a master connection and keeping connections alive
+func (r SSHRunner) sshArgs() ([]string, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	for i := range r.Options {
+		args = append(args, "-o", r.Options[i])
+	}
+	if r.Persist > 0 {
+		path, err := r.controlPath()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPath="+path,
+			"-o", "ControlPersist="+strconv.Itoa(sshSeconds(r.Persist)))
+	}
+	if r.KeepAlive > 0 {
+		args = append(args, "-o", "ServerAliveInterval="+strconv.Itoa(sshSeconds(r.KeepAlive)), "-o", "ServerAliveCountMax=3")
+	}
+	if r.Jump != "" {
+		args = append(args, "-J", r.Jump)
+	}
+	if r.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(r.Port))
+	}
+	if r.KeyFile != "" {
+		args = append(args, "-i", r.KeyFile)
+	}
+	return args, nil
+}
+
+// controlPath is the master connection's socket, named by ssh after a hash
+// of the director, port and user (%C) so each gets its own. Without a
+// ControlDir the sockets are kept in lvs-ssh in the user's cache directory,
+// created only accessible to the user: whoever can create the socket first
+// gets the commands, and forges their output
+func (r SSHRunner) controlPath() (string, error) {
+	dir := r.ControlDir
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cache, "lvs-ssh")
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+		info, err := os.Lstat(dir)
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() || info.Mode().Perm()&0077 != 0 {
+			return "", ErrControlDir
+		}
+	}
+	return filepath.Join(dir, "lvs-ssh-%C"), nil
+}
+
+func (r SSHRunner) target() string {
+	if r.User != "" {
+		return r.User + "@" + r.Host
+	}
+	return r.Host
+}
+
+// Close closes the master connection kept open with Persist, if any, rather
+// than waiting for it to expire
+func (r SSHRunner) Close() error {
+	if r.Persist <= 0 {
+		return nil
+	}
+	args, err := r.sshArgs()
+	if err != nil {
+		return err
+	}
+	return backend(context.Background(), "ssh", append(args, "-O", "exit", r.target())...)
+}
+
+// sshSeconds rounds d up to whole seconds, as ssh takes them
+func sshSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,66 @@
+package lvs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSSHRunner(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := NewIpvs(WithSSH(SSHRunner{Host: "director1", User: "root", Port: 2222, KeyFile: "/etc/lvs/id_ed25519"}), WithNetns("lb"))
+	if err := ipvs.AddService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "rr"}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+
+	expected := "ssh -o BatchMode=yes -p 2222 -i /etc/lvs/id_ed25519 root@director1 -- 'env' '-i' 'PATH=" + RemotePath + "' 'LC_ALL=C' 'LANG=C' 'nsenter' '--net=/var/run/netns/lb' '--' 'ipvsadm' '-A' '-t' '10.0.0.1:80' '-s' 'rr'"
+	if len(fakeExecuted) != 1 || fakeExecuted[0] != expected {
+		t.Errorf("expected '%s', got %q", expected, fakeExecuted)
+	}
+
+	fakeExecuted = nil
+	runner := SSHRunner{Host: "director2", Jump: "admin@bastion:2200", Persist: 90 * time.Second, ControlDir: "/run/lvs", KeepAlive: 15 * time.Second}
+	if err := NewIpvs(WithSSH(runner)).AddService(Service{Host: "10.0.0.1", Port: 80, Scheduler: "rr"}); err != nil {
+		t.Fatalf("failed to add service - %v", err)
+	}
+	expected = "ssh -o BatchMode=yes -o ControlMaster=auto -o ControlPath=/run/lvs/lvs-ssh-%C -o ControlPersist=90 -o ServerAliveInterval=15 -o ServerAliveCountMax=3 -J admin@bastion:2200 director2 -- 'env' '-i' 'PATH=" + RemotePath + "' 'LC_ALL=C' 'LANG=C' 'ipvsadm' '-A' '-t' '10.0.0.1:80' '-s' 'rr'"
+	if len(fakeExecuted) != 1 || fakeExecuted[0] != expected {
+		t.Errorf("expected '%s', got %q", expected, fakeExecuted)
+	}
+
+	fakeExecuted = nil
+	if err := runner.Close(); err != nil || len(fakeExecuted) != 1 || fakeExecuted[0] != "ssh -o BatchMode=yes -o ControlMaster=auto -o ControlPath=/run/lvs/lvs-ssh-%C -o ControlPersist=90 -o ServerAliveInterval=15 -o ServerAliveCountMax=3 -J admin@bastion:2200 -O exit director2" {
+		t.Errorf("expected the master connection to be closed - %q, %v", fakeExecuted, err)
+	}
+
+	// by default the sockets are kept where only the user can reach them
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+	fakeExecuted = nil
+	runner = SSHRunner{Host: "director2", User: "root", Port: 2222, Options: []string{"StrictHostKeyChecking=yes"}, Persist: time.Minute}
+	if err := runner.Close(); err != nil {
+		t.Fatalf("failed to close - %v", err)
+	}
+	dir := filepath.Join(cache, "lvs-ssh")
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("expected a private control directory - %v", err)
+	}
+	expected = "ssh -o BatchMode=yes -o StrictHostKeyChecking=yes -o ControlMaster=auto -o ControlPath=" + dir + "/lvs-ssh-%C -o ControlPersist=60 -p 2222 -O exit root@director2"
+	if len(fakeExecuted) != 1 || fakeExecuted[0] != expected {
+		t.Errorf("expected '%s', got %q", expected, fakeExecuted)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Execute(context.Background(), "ipvsadm", "-C"); err != ErrControlDir {
+		t.Errorf("expected ErrControlDir, got %v", err)
+	}
+
+	if quoted := shellQuote("it's"); quoted != `'it'\''s'` {
+		t.Errorf("single quotes not escaped - %s", quoted)
+	}
+}
@@ -0,0 +1,122 @@
+package lvs
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Staging is a staging table, the ipvs table of a separate network
+	// namespace that services are applied to and probed in before they are
+	// applied to production: a blue/green for the rule set itself,
+	// catching what the kernel refuses or probes find wrong before it
+	// reaches live traffic. See ApplyStaged
+	Staging struct {
+		Netns string // name or path of the namespace, as for WithNetns
+		// Probes check the staging table once applied, the first error
+		// stops the apply. The table is always checked to match services
+		Probes []func(staging *Ipvs) error
+		// Keep leaves the staging table applied, for inspection, rather
+		// than clearing it afterwards
+		Keep bool
+	}
+
+	// StagingFailed is returned when applying to, or probing, the staging
+	// table failed. Production is then left untouched
+	StagingFailed struct {
+		Err error
+	}
+)
+
+func (e StagingFailed) Error() string {
+	return "staging table failed: " + e.Err.Error()
+}
+
+func (e StagingFailed) Unwrap() error {
+	return e.Err
+}
+
+// ApplyStaged syncs services (and the Resources) to the staging table,
+// probes it, and only then syncs them to i
+func (i *Ipvs) ApplyStaged(services []Service, staging Staging) error {
+	if err := staging.stage(i, i.Resources, services); err != nil {
+		return err
+	}
+	return i.Sync(services)
+}
+
+// ApplyConfigStaged loads the config at path and applies it to the staging
+// table like ApplyStaged, then to i like ApplyConfig
+func (i *Ipvs) ApplyConfigStaged(path string, staging Staging) error {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if err = staging.stage(i, config.Resources, config.Services); err != nil {
+		return err
+	}
+	return i.applyConfig(config)
+}
+
+// stage applies resources and services to the staging table of production
+// and probes it, wrapping failures in StagingFailed
+func (s Staging) stage(production *Ipvs, resources []Resource, services []Service) error {
+	table := s.table(production)
+	table.Resources = resources
+	err := s.probe(table, copyServices(services))
+	if !s.Keep {
+		if clearErr := table.ClearForce(); err == nil && clearErr != nil {
+			err = clearErr
+		}
+	}
+	if err != nil {
+		return StagingFailed{Err: err}
+	}
+	return nil
+}
+
+func (s Staging) probe(table *Ipvs, services []Service) error {
+	if err := table.Sync(services); err != nil {
+		return err
+	}
+	drift, err := table.Drift(services)
+	if err != nil {
+		return err
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("applied rules don't match: %s", strings.Join(drift, ", "))
+	}
+	for _, probe := range s.Probes {
+		if err := probe(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// table returns the staging table, running commands like production does
+// but inside the staging namespace
+func (s Staging) table(production *Ipvs) *Ipvs {
+	exec := production.exec
+	return &Ipvs{
+		exec: &executor{
+			runner:  exec.runner,
+			wrap:    append(netnsCommand(s.Netns), exec.wrap...),
+			timeout: exec.timeout,
+			env:     exec.env,
+		},
+		state: &committedState{},
+	}
+}
+
+func (l *Lvs) ApplyStaged(services []Service, staging Staging) error {
+	err := l.Do(func(i *Ipvs) error { return i.ApplyStaged(services, staging) })
+	if err == nil {
+		l.publish(Event{Type: EventSyncApplied})
+	}
+	return err
+}
+
+func (l *Lvs) ApplyConfigStaged(path string, staging Staging) error {
+	return l.Do(func(i *Ipvs) error { return i.ApplyConfigStaged(path, staging) })
+}
@@ -0,0 +1,71 @@
+package lvs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// netnsRunner runs the commands entering a namespace against its own
+// Simulator, the others against the host's
+type netnsRunner struct {
+	host, staging *Simulator
+}
+
+func (r netnsRunner) route(exe string, args []string) (*Simulator, string, []string) {
+	if exe != "nsenter" || len(args) < 3 {
+		return r.host, exe, args
+	}
+	return r.staging, args[2], args[3:]
+}
+
+func (r netnsRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	simulator, exe, args := r.route(exe, args)
+	return simulator.Execute(ctx, exe, args...)
+}
+
+func (r netnsRunner) ExecuteStdin(ctx context.Context, in, exe string, args ...string) error {
+	simulator, exe, args := r.route(exe, args)
+	return simulator.ExecuteStdin(ctx, in, exe, args...)
+}
+
+func (r netnsRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	simulator, exe, args := r.route(exe, args)
+	return simulator.RunOutput(ctx, exe, args...)
+}
+
+func TestApplyStaged(t *testing.T) {
+	runner := netnsRunner{host: NewSimulator(), staging: NewSimulator()}
+	ipvs := NewIpvs(WithRunner(runner))
+	services := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}
+
+	probed := 0
+	staging := Staging{Netns: "staging", Probes: []func(*Ipvs) error{func(table *Ipvs) error {
+		probed++
+		if len(table.Services) != 1 || len(runner.host.Services()) != 0 {
+			t.Error("expected the staging table to be applied before production")
+		}
+		return nil
+	}}}
+	if err := ipvs.ApplyStaged(services, staging); err != nil {
+		t.Fatal(err)
+	}
+	if probed != 1 || len(runner.host.Services()) != 1 || len(runner.staging.Services()) != 0 {
+		t.Errorf("expected production to be applied and staging cleared - %d %+v %+v", probed, runner.host.Services(), runner.staging.Services())
+	}
+
+	failed := errors.New("probe failed")
+	staging.Probes = []func(*Ipvs) error{func(*Ipvs) error { return failed }}
+	staging.Keep = true
+	services[0].Servers[0].Weight = 5
+	err := ipvs.ApplyStaged(services, staging)
+	if stagingErr, ok := err.(StagingFailed); !ok || !errors.Is(err, failed) || stagingErr.Error() != "staging table failed: probe failed" {
+		t.Fatalf("expected the probe to fail staging - %v", err)
+	}
+	if runner.host.Services()[0].Servers[0].Weight != 1 {
+		t.Error("production was changed despite the failed probe")
+	}
+	if staged := runner.staging.Services(); len(staged) != 1 || staged[0].Servers[0].Weight != 5 {
+		t.Errorf("expected the staging table to be kept - %+v", staged)
+	}
+}
@@ -0,0 +1,64 @@
+package lvs
+
+import (
+	"sync/atomic"
+)
+
+type (
+	// committedState holds an immutable copy of an Ipvs's services, swapped
+	// atomically once a change is done, so readers never wait for a change
+	// nor see it half-applied
+	committedState struct {
+		services atomic.Pointer[[]Service]
+		depth    atomic.Int32 // changes in progress, see changing
+	}
+)
+
+// Committed returns the services as of the last completed change, without
+// waiting for one in progress. Changes are completed when the Ipvs method
+// making them (such as Sync) returns, when Lvs.Do's fn does or the Api
+// answers, so servers changed on a Service found by FindService are only
+// committed by the Ipvs's next change unless done within Lvs.Do. The slice is
+// shared by every reader and must not be modified, copy it first. An Ipvs
+// not created by NewIpvs returns a copy of its Services
+func (i *Ipvs) Committed() []Service {
+	if i.state == nil {
+		return copyServices(i.Services)
+	}
+	if services := i.state.services.Load(); services != nil {
+		return *services
+	}
+	return make([]Service, 0, 0)
+}
+
+// commit publishes a copy of the services for readers, see Committed.
+// Callers have exclusive access to i
+func (i *Ipvs) commit() {
+	if i.state == nil {
+		return
+	}
+	services := copyServices(i.Services)
+	i.state.services.Store(&services)
+}
+
+// changing marks a change in progress until the returned func is called,
+// committing the services once the outermost change is done:
+//
+//	defer i.changing()()
+func (i *Ipvs) changing() func() {
+	if i.state == nil {
+		return func() {}
+	}
+	i.state.depth.Add(1)
+	return func() {
+		if i.state.depth.Add(-1) == 0 {
+			i.commit()
+		}
+	}
+}
+
+// view returns an Ipvs reading the committed services, for serving reads
+// while changes are made. It must not be changed
+func (i *Ipvs) view() *Ipvs {
+	return &Ipvs{Services: i.Committed(), exec: i.exec, statePath: i.statePath}
+}
@@ -0,0 +1,82 @@
+package lvs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingRunner blocks the commands adding servers until released
+type blockingRunner struct {
+	*Simulator
+	adding  chan struct{}
+	release chan struct{}
+}
+
+func (r blockingRunner) Execute(ctx context.Context, exe string, args ...string) error {
+	if len(args) > 0 && args[0] == "-a" {
+		r.adding <- struct{}{}
+		<-r.release
+	}
+	return r.Simulator.Execute(ctx, exe, args...)
+}
+
+func TestCommittedServices(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	err := client.Do(func(i *Ipvs) error {
+		if err := i.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr"}); err != nil {
+			return err
+		}
+		if err := i.Services[0].AddServer(Server{Host: "10.0.1.1", Port: 80, Weight: 1}); err != nil {
+			return err
+		}
+		if services := client.Services(); len(services) != 1 || len(services[0].Servers) != 0 {
+			t.Errorf("saw a change in progress - %+v", services)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if services := client.Services(); len(services) != 1 || len(services[0].Servers) != 1 {
+		t.Errorf("change wasn't committed - %+v", services)
+	}
+}
+
+func TestApiReadsDuringSync(t *testing.T) {
+	runner := blockingRunner{Simulator: NewSimulator(), adding: make(chan struct{}), release: make(chan struct{})}
+	api := NewApi(NewIpvs(WithRunner(runner)))
+
+	done := make(chan int)
+	go func() {
+		rw := httptest.NewRecorder()
+		api.ServeHTTP(rw, httptest.NewRequest("PUT", "/services", strings.NewReader(`[{"host":"10.0.0.1","port":80,"type":"tcp","servers":[{"host":"10.0.1.1","port":80},{"host":"10.0.1.2","port":80}]}]`)))
+		done <- rw.Code
+	}()
+
+	// the service is added, its servers aren't yet
+	select {
+	case <-runner.adding:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sync didn't start")
+	}
+	rw := httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("GET", "/services", nil))
+	if rw.Code != http.StatusOK || rw.Body.String() != "[]\n" {
+		t.Errorf("expected the table before the sync - %d %s", rw.Code, rw.Body)
+	}
+
+	close(runner.release)
+	<-runner.adding
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("failed to sync - %d", code)
+	}
+	rw = httptest.NewRecorder()
+	api.ServeHTTP(rw, httptest.NewRequest("GET", "/services/tcp/10.0.0.1/80", nil))
+	if rw.Code != http.StatusOK || strings.Count(rw.Body.String(), `"host":"10.0.1.`) != 2 {
+		t.Errorf("expected the synced service - %d %s", rw.Code, rw.Body)
+	}
+}
@@ -0,0 +1,159 @@
+package lvs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Stats are the counters ipvs keeps for services and servers
+	Stats struct {
+		Connections uint64 `json:"connections"`
+		PacketsIn   uint64 `json:"packets_in"`
+		PacketsOut  uint64 `json:"packets_out"`
+		BytesIn     uint64 `json:"bytes_in"`
+		BytesOut    uint64 `json:"bytes_out"`
+	}
+
+	ServiceStats struct {
+		Type    string        `json:"type"`
+		Host    string        `json:"host"`
+		Port    int           `json:"port"`
+		Stats                 // counters for the service as a whole
+		Servers []ServerStats `json:"servers"`
+	}
+
+	ServerStats struct {
+		Service string `json:"service"` // type and host:port of the owning service
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Stats
+	}
+)
+
+var (
+	statsServiceType = map[string]string{
+		"TCP": "tcp",
+		"UDP": "udp",
+		"FWM": "fwmark",
+	}
+)
+
+// Stats reads the counters of every service and server applied on the host
+func (i Ipvs) Stats() ([]ServiceStats, error) {
+	out, err := i.exec.run([]string{"ipvsadm", "-L", "-n", "--stats", "--exact"})
+	if err != nil {
+		return nil, err
+	}
+	return parseStats(string(out)), nil
+}
+
+// TotalStats sums the counters of every service
+func (i Ipvs) TotalStats() (Stats, error) {
+	total := Stats{}
+	services, err := i.Stats()
+	if err != nil {
+		return total, err
+	}
+	for j := range services {
+		total.add(services[j].Stats)
+	}
+	return total, nil
+}
+
+// TopServices returns the n services with the most connections, or all of
+// them when n isn't positive
+func (i Ipvs) TopServices(n int) ([]ServiceStats, error) {
+	services, err := i.Stats()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(services, func(a, b int) bool {
+		return services[a].Connections > services[b].Connections
+	})
+	if n > 0 && n < len(services) {
+		services = services[:n]
+	}
+	return services, nil
+}
+
+// TopServers returns the n servers with the most connections, across all
+// services, or all of them when n isn't positive
+func (i Ipvs) TopServers(n int) ([]ServerStats, error) {
+	services, err := i.Stats()
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]ServerStats, 0, 0)
+	for j := range services {
+		servers = append(servers, services[j].Servers...)
+	}
+	sort.SliceStable(servers, func(a, b int) bool {
+		return servers[a].Connections > servers[b].Connections
+	})
+	if n > 0 && n < len(servers) {
+		servers = servers[:n]
+	}
+	return servers, nil
+}
+
+func (s *Stats) add(o Stats) {
+	s.Connections += o.Connections
+	s.PacketsIn += o.PacketsIn
+	s.PacketsOut += o.PacketsOut
+	s.BytesIn += o.BytesIn
+	s.BytesOut += o.BytesOut
+}
+
+// parseStats parses the output of `ipvsadm -L -n --stats --exact`
+func parseStats(out string) []ServiceStats {
+	services := make([]ServiceStats, 0, 0)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		if fields[0] == "->" {
+			if len(services) == 0 {
+				continue
+			}
+			service := &services[len(services)-1]
+			server := ServerStats{Service: service.Type + " " + service.hostPort()}
+			server.Host, server.Port = parseHostPort(fields[1])
+			server.Stats = parseStatsFields(fields[2:7])
+			service.Servers = append(service.Servers, server)
+			continue
+		}
+		netType, ok := statsServiceType[fields[0]]
+		if !ok {
+			continue
+		}
+		service := ServiceStats{Type: netType, Servers: make([]ServerStats, 0, 0)}
+		var err error
+		if service.Host, service.Port, err = parseServiceAddress(netType, fields[1]); err != nil {
+			continue
+		}
+		service.Stats = parseStatsFields(fields[2:7])
+		services = append(services, service)
+	}
+	return services
+}
+
+func parseStatsFields(fields []string) Stats {
+	values := make([]uint64, len(fields))
+	for i := range fields {
+		values[i], _ = strconv.ParseUint(fields[i], 10, 64)
+	}
+	return Stats{
+		Connections: values[0],
+		PacketsIn:   values[1],
+		PacketsOut:  values[2],
+		BytesIn:     values[3],
+		BytesOut:    values[4],
+	}
+}
+
+func (s ServiceStats) hostPort() string {
+	return Service{Host: s.Host, Port: s.Port}.getHostPort()
+}
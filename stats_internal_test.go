@@ -0,0 +1,55 @@
+package lvs
+
+import (
+	"testing"
+)
+
+const statsOutput = `IP Virtual Server version 1.2.1 (size=4096)
+Prot LocalAddress:Port               Conns   InPkts  OutPkts  InBytes OutBytes
+  -> RemoteAddress:Port
+TCP  10.0.0.1:80                        30      180        0    12000        0
+  -> 10.0.1.1:80                        10       60        0     4000        0
+  -> 10.0.1.2:80                        20      120        0     8000        0
+UDP  10.0.0.1:53                         5        5        5      300      600
+  -> 10.0.1.3:53                         5        5        5      300      600
+`
+
+func TestTotalStats(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte(statsOutput)
+
+	total, err := DefaultIpvs.TotalStats()
+	if err != nil {
+		t.Fatalf("failed to get stats - %v", err)
+	}
+	expected := Stats{Connections: 35, PacketsIn: 185, PacketsOut: 5, BytesIn: 12300, BytesOut: 600}
+	if total != expected {
+		t.Errorf("expected %+v, got %+v", expected, total)
+	}
+
+	servers, err := DefaultIpvs.TopServers(2)
+	if err != nil {
+		t.Fatalf("failed to get top servers - %v", err)
+	}
+	if len(servers) != 2 || servers[0].Host != "10.0.1.2" || servers[1].Host != "10.0.1.1" {
+		t.Errorf("wrong top servers - %+v", servers)
+	}
+	if servers[0].Service != "tcp 10.0.0.1:80" {
+		t.Errorf("wrong owning service - '%s'", servers[0].Service)
+	}
+
+	services, err := DefaultIpvs.TopServices(5)
+	if err != nil || len(services) != 2 || services[0].Port != 80 {
+		t.Errorf("wrong top services - %+v, %v", services, err)
+	}
+
+	// n that isn't positive means all of them
+	for _, n := range []int{0, -1} {
+		if servers, err = DefaultIpvs.TopServers(n); err != nil || len(servers) != 3 || servers[0].Host != "10.0.1.2" {
+			t.Errorf("expected all servers for %d - %+v, %v", n, servers, err)
+		}
+		if services, err = DefaultIpvs.TopServices(n); err != nil || len(services) != 2 || services[0].Port != 80 {
+			t.Errorf("expected all services for %d - %+v, %v", n, services, err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package lvs
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type (
+	// Status is the state rendered by the status page
+	Status struct {
+		Services []ServiceStatus `json:"services"`
+	}
+
+	ServiceStatus struct {
+		Name      string         `json:"name,omitempty"`
+		Type      string         `json:"type"`
+		Host      string         `json:"host"`
+		Port      int            `json:"port"`
+		Scheduler string         `json:"scheduler"`
+		Servers   []ServerStatus `json:"servers"`
+		SLI       *ServiceSLI    `json:"sli,omitempty"`
+	}
+
+	ServerStatus struct {
+		Host      string     `json:"host"`
+		Port      int        `json:"port"`
+		Forwarder string     `json:"forwarder"`
+		Weight    int        `json:"weight"`
+		Health    string     `json:"health"` // up, down or unknown
+		LastCheck *time.Time `json:"last_check,omitempty"`
+		LastError string     `json:"last_error,omitempty"`
+	}
+
+	statusHandler struct {
+		lvs    *Lvs
+		health *HealthChecker
+		sli    *SLITracker
+	}
+)
+
+var (
+	statusPage = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html><head><title>LVS Status</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+.up { background: #cfc; } .down { background: #fcc; }
+</style></head><body>
+{{range .Services}}<table>
+<tr><th colspan="6">{{if .Name}}{{.Name}} - {{end}}{{.Type}} {{.Host}}:{{.Port}} ({{.Scheduler}}){{with .SLI}} - {{printf "%.0f" .Availability}}% available, {{printf "%.1f" .ConnectionsPerSecond}} conn/s{{if .InactiveSpike}}, inactive connections spiking{{end}}{{end}}</th></tr>
+<tr><th>Server</th><th>Forwarder</th><th>Weight</th><th>Health</th><th>Last Check</th><th>Last Error</th></tr>
+{{range .Servers}}<tr class="{{.Health}}"><td>{{.Host}}:{{.Port}}</td><td>{{.Forwarder}}</td><td>{{.Weight}}</td><td>{{.Health}}</td><td>{{if .LastCheck}}{{.LastCheck.Format "2006-01-02 15:04:05"}}{{end}}</td><td>{{.LastError}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>
+`))
+)
+
+// NewStatusHandler serves the services of l (DefaultLvs when nil), their
+// SLIs and the health of their servers, as json when requested with ?format=json or an
+// Accept header asking for json, and as an html table otherwise. health is
+// optional
+func NewStatusHandler(l *Lvs, health *HealthChecker) http.Handler {
+	if l == nil {
+		l = DefaultLvs
+	}
+	return statusHandler{lvs: l, health: health, sli: &SLITracker{}}
+}
+
+// Status returns the current services, their SLIs and the health of their
+// servers. SLIs are left out when the counters can't be read
+func (s statusHandler) Status() Status {
+	status := Status{Services: make([]ServiceStatus, 0, 0)}
+	slis := make(map[string]*ServiceSLI)
+	if computed, err := s.sli.Compute(s.lvs); err == nil {
+		for j := range computed {
+			slis[computed[j].key] = &computed[j]
+		}
+	}
+	services := s.lvs.Services()
+	for i := range services {
+		service := ServiceStatus{
+			Name:      services[i].Name,
+			Type:      services[i].Type,
+			Host:      services[i].Host,
+			Port:      services[i].Port,
+			Scheduler: ServiceSchedulerFlag[services[i].Scheduler],
+			Servers:   make([]ServerStatus, 0, len(services[i].Servers)),
+			SLI:       slis[services[i].canonicalKey()],
+		}
+		for _, server := range services[i].Servers {
+			serverStatus := ServerStatus{
+				Host:      server.Host,
+				Port:      server.Port,
+				Forwarder: server.Forwarder,
+				Weight:    server.Weight,
+			}
+			serverStatus.Health, serverStatus.LastCheck, serverStatus.LastError = healthStatus(s.health, services[i], server)
+			service.Servers = append(service.Servers, serverStatus)
+		}
+		status.Services = append(status.Services, service)
+	}
+	return status
+}
+
+// healthStatus describes the last known health of server as up, down or
+// unknown, along with when it was last checked and why it failed
+func healthStatus(h *HealthChecker, service Service, server Server) (string, *time.Time, string) {
+	if h == nil {
+		return "unknown", nil, ""
+	}
+	health, ok := h.ServerHealth(service, server)
+	if !ok {
+		return "unknown", nil, ""
+	}
+	if health.Healthy {
+		return "up", &health.LastCheck, health.LastError
+	}
+	return "down", &health.LastCheck, health.LastError
+}
+
+func (s statusHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		writeError(rw, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	status := s.Status()
+	if req.URL.Query().Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+		writeJson(rw, http.StatusOK, status)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	statusPage.Execute(rw, status)
+}
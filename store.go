@@ -0,0 +1,175 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+type (
+	// Store persists the desired services, letting daemons pick how
+	// durable and shared they are: MemoryStore (neither), FileStore (local
+	// file) or EtcdStore (shared by every director)
+	Store interface {
+		// Load returns the stored services, nil when none were ever
+		// stored (as opposed to an empty table)
+		Load() ([]Service, error)
+		Save(services []Service) error
+	}
+
+	// MemoryStore keeps the services in memory, for tests and controllers
+	// getting them from elsewhere on every start
+	MemoryStore struct {
+		mu       sync.Mutex
+		services []Service
+	}
+
+	// FileStore keeps the services in a local file, encoded by the codec
+	// registered for its extension (see RegisterCodec) or as json, and
+	// replaced atomically
+	FileStore struct {
+		Path string
+	}
+
+	// EtcdStore keeps the services under a key of an etcd cluster, through
+	// its v3 json gateway, so directors share them
+	EtcdStore struct {
+		Endpoints []string     // eg. http://10.0.0.20:2379, tried in order
+		Key       string       // defaults to DefaultEtcdKey
+		Client    *http.Client // defaults to http.DefaultClient, set it up for tls if needed
+		Headers   map[string]string
+	}
+)
+
+var (
+	// DefaultEtcdKey is where an EtcdStore without a Key keeps the services
+	DefaultEtcdKey = "/golang-lvs/services"
+)
+
+func (s *MemoryStore) Load() ([]Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.services == nil {
+		return nil, nil
+	}
+	return copyServices(s.services), nil
+}
+
+func (s *MemoryStore) Save(services []Service) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = copyServices(services)
+	if s.services == nil {
+		s.services = make([]Service, 0, 0)
+	}
+	return nil
+}
+
+func (s FileStore) Load() ([]Service, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	services := make([]Service, 0, 0)
+	return services, codecFor(s.Path).Unmarshal(data, &services)
+}
+
+func (s FileStore) Save(services []Service) error {
+	if services == nil {
+		services = make([]Service, 0, 0)
+	}
+	data, err := codecFor(s.Path).Marshal(services)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(s.Path, data, 0644)
+}
+
+func (s EtcdStore) Load() ([]Service, error) {
+	res := struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}{}
+	if err := s.call("/v3/kv/range", map[string]string{"key": s.key()}, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Kvs) == 0 {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(res.Kvs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]Service, 0, 0)
+	return services, json.Unmarshal(data, &services)
+}
+
+func (s EtcdStore) Save(services []Service) error {
+	if services == nil {
+		services = make([]Service, 0, 0)
+	}
+	data, err := json.Marshal(services)
+	if err != nil {
+		return err
+	}
+	return s.call("/v3/kv/put", map[string]string{"key": s.key(), "value": base64.StdEncoding.EncodeToString(data)}, nil)
+}
+
+// key returns the base64 encoded key, as the gateway expects
+func (s EtcdStore) key() string {
+	key := s.Key
+	if key == "" {
+		key = DefaultEtcdKey
+	}
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+// call posts req to the gateway at path, trying each endpoint until one
+// answers, and decodes its answer into res
+func (s EtcdStore) call(path string, req interface{}, res interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	err = fmt.Errorf("no etcd endpoints")
+	for _, endpoint := range s.Endpoints {
+		var r *http.Request
+		r, err = http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		r.Header.Set("Content-Type", "application/json")
+		for name, value := range s.Headers {
+			r.Header.Set(name, value)
+		}
+		var answer *http.Response
+		answer, err = client.Do(r)
+		if err != nil {
+			continue
+		}
+		if answer.StatusCode != http.StatusOK {
+			answer.Body.Close()
+			err = fmt.Errorf("etcd answered %d", answer.StatusCode)
+			continue
+		}
+		if res != nil {
+			err = json.NewDecoder(answer.Body).Decode(res)
+		}
+		answer.Body.Close()
+		return err
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package lvs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeEtcd emulates the kv endpoints of etcd's v3 json gateway
+func fakeEtcd() *httptest.Server {
+	mu := sync.Mutex{}
+	kv := map[string]string{}
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := map[string]string{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/v3/kv/put":
+			kv[body["key"]] = body["value"]
+			rw.Write([]byte(`{"header":{}}`))
+		case "/v3/kv/range":
+			value, ok := kv[body["key"]]
+			if !ok {
+				rw.Write([]byte(`{"header":{}}`))
+				return
+			}
+			json.NewEncoder(rw).Encode(map[string]interface{}{"kvs": []map[string]string{{"key": body["key"], "value": value}}, "count": "1"})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestStores(t *testing.T) {
+	etcd := fakeEtcd()
+	defer etcd.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// loaded servers have WeightSet, their weight was stored
+	services := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Forwarder: "g", Weight: 1, WeightSet: true}}}}
+	stores := map[string]Store{
+		"memory": &MemoryStore{},
+		"file":   FileStore{Path: filepath.Join(t.TempDir(), "services.json")},
+		"etcd":   EtcdStore{Endpoints: []string{down.URL, etcd.URL}},
+	}
+	for name, store := range stores {
+		if stored, err := store.Load(); stored != nil || err != nil {
+			t.Errorf("%s: expected nothing stored - %v %v", name, stored, err)
+		}
+		if err := store.Save(services); err != nil {
+			t.Fatalf("%s: failed to save - %v", name, err)
+		}
+		if stored, err := store.Load(); err != nil || !reflect.DeepEqual(stored, services) {
+			t.Errorf("%s: loaded %+v, %v", name, stored, err)
+		}
+		if err := store.Save(nil); err != nil {
+			t.Fatalf("%s: failed to save - %v", name, err)
+		}
+		if stored, err := store.Load(); err != nil || stored == nil || len(stored) != 0 {
+			t.Errorf("%s: expected an empty table - %v %v", name, stored, err)
+		}
+	}
+
+	if _, err := (EtcdStore{Endpoints: []string{down.URL}}).Load(); err == nil {
+		t.Error("expected an error without an etcd answering")
+	}
+}
+
+func TestReconcilerStore(t *testing.T) {
+	simulator := NewSimulator()
+	store := &MemoryStore{}
+	r := &Reconciler{Lvs: New(WithRunner(simulator)), Store: store}
+	services := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}
+	if err := r.SetDesired(services); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.Load(); len(stored) != 1 {
+		t.Fatalf("desired services weren't stored - %+v", stored)
+	}
+
+	// another director changing the desired services
+	store.Save(append(services, Service{Type: "udp", Host: "10.0.0.2", Port: 53, Scheduler: "rr"}))
+	if drifted, err := r.ReconcileOnce(); !drifted || err != nil {
+		t.Fatalf("expected to reconcile - %v %v", drifted, err)
+	}
+	if len(simulator.Services()) != 2 || len(r.Desired()) != 2 {
+		t.Errorf("stored services weren't reconciled to - %+v", simulator.Services())
+	}
+}
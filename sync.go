@@ -0,0 +1,238 @@
+package lvs
+
+// Sync makes the applied ipvsadm rules match services, adding, editing and
+// removing services and servers as needed rather than clearing the table.
+// It fails with ErrProtectedService, before changing anything, if a
+// Protected service would be removed. The Resources are applied along the
+// way, every resource and service after the ones it Requires
+func (i *Ipvs) Sync(services []Service) error {
+	return i.sync(services, false, WeightChange{Source: WeightSourceSync})
+}
+
+// SyncForce syncs like Sync, removing protected services too
+func (i *Ipvs) SyncForce(services []Service) error {
+	return i.sync(services, true, WeightChange{Source: WeightSourceSync})
+}
+
+// sync syncs services, attributing the weights it changes to cause
+func (i *Ipvs) sync(services []Service, force bool, cause WeightChange) error {
+	defer i.changing()()
+	unlock, err := i.exec.lockTable()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for j := range services {
+		if err := services[j].Validate(); err != nil {
+			return err
+		}
+	}
+	services, err = normalizeServices(services)
+	if err != nil {
+		return err
+	}
+	for j := range services {
+		if services[j].MaxConns > 0 {
+			services[j] = services[j].WithConnLimit(services[j].MaxConns)
+		}
+	}
+	order, err := applyOrder(i.Resources, services)
+	if err != nil {
+		return err
+	}
+
+	// start from what is actually applied on the host
+	if err := i.Save(); err != nil {
+		return err
+	}
+
+	resolved := make([]Service, len(services))
+	for j := range services {
+		// a copy, services may be shared with its callers
+		service := services[j]
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return err
+		}
+		resolved[j] = applied
+	}
+	if err := checkOverlaps(resolved); err != nil {
+		return err
+	}
+	if err := i.syncLimit.check(i.Services, resolved); err != nil {
+		return err
+	}
+	recordChurn, err := i.churn.check(i.Services, resolved)
+	if err != nil {
+		return err
+	}
+	if !force {
+		if err := checkProtected(i.Services, resolved); err != nil {
+			return err
+		}
+	}
+
+	wanted := make(map[string]bool)
+	for _, step := range order {
+		if step.resource != nil {
+			if err := i.applyResource(*step.resource); err != nil {
+				return err
+			}
+			continue
+		}
+		applied := resolved[step.service]
+		wanted[applied.key()] = true
+
+		current := i.FindService(applied.Type, applied.Host, applied.Port)
+		if current == nil {
+			if err := i.AddService(applied); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !current.sameAttributes(applied) {
+			edit := applied
+			edit.Servers = current.Servers
+			if err := i.EditService(edit); err != nil {
+				return err
+			}
+			current = i.FindService(applied.Type, applied.Host, applied.Port)
+		}
+
+		if err := current.syncServers(applied.Servers, cause); err != nil {
+			return err
+		}
+	}
+
+	stale := make([]Service, 0, 0)
+	for j := range i.Services {
+		if !wanted[i.Services[j].key()] {
+			stale = append(stale, i.Services[j])
+		}
+	}
+	for j := range stale {
+		if err := i.RemoveService(stale[j].Type, stale[j].Host, stale[j].Port); err != nil {
+			return err
+		}
+	}
+
+	recordChurn()
+	carryTimes(i.Services, services)
+	i.Services = services
+	i.adopt()
+	return i.writeState()
+}
+
+// checkProtected fails with ErrProtectedService when going from current to
+// wanted removes a protected service
+func checkProtected(current, wanted []Service) error {
+	kept := make(map[string]bool)
+	for j := range wanted {
+		kept[wanted[j].key()] = true
+	}
+	for j := range current {
+		if current[j].Protected && !kept[current[j].key()] {
+			return ErrProtectedService
+		}
+	}
+	return nil
+}
+
+// syncServers makes the servers applied to s match servers, attributing the
+// weights it changes to cause
+func (s *Service) syncServers(servers []Server, cause WeightChange) error {
+	for j := range servers {
+		current := s.FindServer(servers[j].Host, servers[j].Port)
+		if current == nil {
+			if err := s.AddServer(servers[j]); err != nil {
+				return err
+			}
+			continue
+		}
+		if !current.sameAttributes(servers[j]) {
+			if _, err := s.editServer(servers[j], cause); err != nil {
+				return err
+			}
+		}
+	}
+
+	stale := make([]Server, 0, 0)
+	for j := range s.Servers {
+		found := false
+		for k := range servers {
+			if s.Servers[j].is(servers[k].Host, servers[k].Port) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			stale = append(stale, s.Servers[j])
+		}
+	}
+	for j := range stale {
+		if err := s.RemoveServer(stale[j].Host, stale[j].Port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// key uniquely identifies the service in the ipvs table
+func (s Service) key() string {
+	return ServiceTypeFlag[s.Type] + " " + s.getHostPort()
+}
+
+// Converged reads the rules applied on the host and reports whether they
+// match services, without changing i
+func (i *Ipvs) Converged(services []Service) (bool, error) {
+	drift, err := i.Drift(services)
+	return len(drift) == 0, err
+}
+
+// Drift reads the rules applied on the host and describes how they differ
+// from services, such as "missing tcp 10.0.0.1:80", "changed udp
+// 10.0.0.1:53" or "unexpected tcp 10.0.0.2:80", without changing i
+func (i *Ipvs) Drift(services []Service) ([]string, error) {
+	applied := Ipvs{exec: i.exec}
+	if err := applied.Save(); err != nil {
+		return nil, err
+	}
+	drift := make([]string, 0, 0)
+	wanted := make(map[string]bool)
+	for j := range services {
+		// normalized first, a hex fwmark isn't a host to resolve, into a
+		// copy rather than the caller's services
+		service, err := services[j].Normalize()
+		if err != nil {
+			return nil, err
+		}
+		if service.MaxConns > 0 {
+			// as sync applies it
+			service = service.WithConnLimit(service.MaxConns)
+		}
+		if service.Type == "" {
+			service.Type = "tcp"
+		}
+		service.exec = i.exec
+		service, err = service.resolve()
+		if err != nil {
+			return nil, err
+		}
+		wanted[service.canonicalKey()] = true
+		current := applied.FindService(service.Type, service.Host, service.Port)
+		if current == nil {
+			drift = append(drift, "missing "+service.Type+" "+service.getHostPort())
+		} else if !current.Equal(service) {
+			drift = append(drift, "changed "+service.Type+" "+service.getHostPort())
+		}
+	}
+	for _, current := range applied.Services {
+		if !wanted[current.canonicalKey()] {
+			drift = append(drift, "unexpected "+current.Type+" "+current.getHostPort())
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,151 @@
+package lvs
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSync(t *testing.T) {
+	defer useFakeBackend()()
+
+	fakeRunOutput = []byte(`-A -t 10.0.0.1:80 -s wlc
+-a -t 10.0.0.1:80 -r 10.0.1.1:80 -g -w 1
+-a -t 10.0.0.1:80 -r 10.0.1.2:80 -g -w 1
+-A -t 10.0.0.1:443 -s rr
+`)
+	ipvs := &Ipvs{}
+	err := ipvs.Sync([]Service{{
+		Host: "10.0.0.1", Port: 80, Type: "tcp", Scheduler: "wlc",
+		Servers: []Server{
+			{Host: "10.0.1.1", Port: 80, Forwarder: "g", Weight: 1},
+			{Host: "10.0.1.3", Port: 80, Forwarder: "g", Weight: 1},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("failed to sync - %v", err)
+	}
+
+	expected := []string{
+		"ipvsadm -a -t 10.0.0.1:80 -r 10.0.1.3:80 -g -y 0 -x 0 -w 1",
+		"ipvsadm -d -t 10.0.0.1:80 -r 10.0.1.2:80",
+		"ipvsadm -D -t 10.0.0.1:443",
+	}
+	if len(fakeExecuted) != len(expected) {
+		t.Fatalf("expected %d commands, got %q", len(expected), fakeExecuted)
+	}
+	for j := range expected {
+		if fakeExecuted[j] != expected[j] {
+			t.Errorf("expected '%s', got '%s'", expected[j], fakeExecuted[j])
+		}
+	}
+	if len(ipvs.Services) != 1 || len(ipvs.Services[0].Servers) != 2 {
+		t.Errorf("services not updated to the synced state - %+v", ipvs.Services)
+	}
+}
+
+func TestSyncInvalid(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := &Ipvs{}
+	err := ipvs.Sync([]Service{{Host: "10.0.0.1", Port: 80, Scheduler: "bogus"}})
+	if err != InvalidServiceScheduler {
+		t.Errorf("expected InvalidServiceScheduler, got %v", err)
+	}
+	if len(fakeExecuted) != 0 {
+		t.Errorf("invalid services should not be applied - %q", fakeExecuted)
+	}
+}
+
+func TestSyncProtected(t *testing.T) {
+	simulator := NewSimulator()
+	path := filepath.Join(t.TempDir(), "state.json")
+	ipvs := NewIpvs(WithRunner(simulator), WithStateFile(path))
+	services := []Service{
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Protected: true},
+		{Type: "tcp", Host: "10.0.0.2", Port: 80, Scheduler: "rr"},
+	}
+	if err := ipvs.Sync(services); err != nil {
+		t.Fatal(err)
+	}
+
+	// a restarted director still knows it is protected
+	restarted := NewIpvs(WithRunner(simulator), WithStateFile(path))
+	if err := restarted.Sync(services[1:]); err != ErrProtectedService {
+		t.Fatalf("expected ErrProtectedService, got %v", err)
+	}
+	if err := restarted.Clear(); err != ErrProtectedService {
+		t.Fatalf("expected ErrProtectedService, got %v", err)
+	}
+	if len(simulator.Services()) != 2 {
+		t.Fatalf("protected service was removed - %+v", simulator.Services())
+	}
+
+	if err := restarted.SyncForce(services[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if len(simulator.Services()) != 1 {
+		t.Errorf("forced sync didn't remove the protected service - %+v", simulator.Services())
+	}
+	if err := restarted.Clear(); err != nil {
+		t.Errorf("failed to clear unprotected services - %v", err)
+	}
+}
+
+func TestClearProtected(t *testing.T) {
+	simulator := NewSimulator()
+	path := filepath.Join(t.TempDir(), "state.json")
+	services := []Service{
+		{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Protected: true},
+		{Type: "tcp", Host: "10.0.0.2", Port: 80, Scheduler: "rr"},
+	}
+	if err := NewIpvs(WithRunner(simulator), WithStateFile(path)).Sync(services); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh Ipvs, eg. from the cli, hasn't seen the services
+	if err := NewIpvs(WithRunner(simulator), WithStateFile(path)).Clear(); err != ErrProtectedService {
+		t.Fatalf("expected ErrProtectedService, got %v", err)
+	}
+	if len(simulator.Services()) != 2 {
+		t.Fatalf("protected service was removed - %+v", simulator.Services())
+	}
+
+	if err := NewIpvs(WithRunner(simulator), WithStateFile(path)).ClearForce(); err != nil || len(simulator.Services()) != 0 {
+		t.Fatalf("forced clear didn't clear the table - %+v, %v", simulator.Services(), err)
+	}
+	// the protection went with the cleared service
+	if state, err := NewIpvs(WithStateFile(path)).readState(); err != nil || len(state.Protected) != 0 {
+		t.Errorf("expected the cleared state to be written back - %+v, %v", state, err)
+	}
+}
+
+func TestSyncLeavesCallersServices(t *testing.T) {
+	services := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}
+
+	// directors syncing the same services at once
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ipvs := NewIpvs(WithRunner(NewSimulator()))
+			if err := ipvs.Restore(services); err != nil {
+				t.Errorf("failed to restore - %v", err)
+			}
+			if err := ipvs.Sync(services); err != nil {
+				t.Errorf("failed to sync - %v", err)
+			}
+			if _, err := ipvs.Drift(services); err != nil {
+				t.Errorf("failed to get drift - %v", err)
+			}
+			if _, err := ipvs.Orphans(services); err != nil {
+				t.Errorf("failed to get orphans - %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if services[0].exec != nil {
+		t.Errorf("expected the caller's services to be left alone")
+	}
+}
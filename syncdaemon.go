@@ -0,0 +1,94 @@
+package lvs
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// AutoInterface as the MulticastInterface has StartDaemon use the
+	// interface of the most specific route to the first service's vip
+	AutoInterface = "auto"
+)
+
+var (
+	ErrNoSyncInterface = errors.New("no route to a vip to pick the sync daemon's interface from")
+)
+
+// syncInterface returns the interface the sync daemon multicasts on, the
+// MulticastInterface unless it is AutoInterface
+func (i Ipvs) syncInterface() (string, error) {
+	if i.MulticastInterface != AutoInterface {
+		return i.MulticastInterface, nil
+	}
+	for _, service := range i.Services {
+		if ServiceTypeFlag[service.Type] == "-f" {
+			continue
+		}
+		service.exec = i.exec
+		applied, err := service.resolve()
+		if err != nil {
+			return "", err
+		}
+		args := []string{"ip", "-o", "route", "show", "match", applied.Host}
+		if ip := net.ParseIP(applied.Host); ip != nil && ip.To4() == nil {
+			args = []string{"ip", "-6", "-o", "route", "show", "match", applied.Host}
+		}
+		out, err := i.exec.run(args)
+		if err != nil {
+			return "", err
+		}
+		if iface := routeInterface(string(out)); iface != "" {
+			return iface, nil
+		}
+	}
+	return "", ErrNoSyncInterface
+}
+
+// routeInterface returns the interface of the most specific route in the
+// output of `ip -o route show match`, leaving out the loopback the vip is
+// usually bound to
+func routeInterface(out string) string {
+	iface, longest := "", -1
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		dev := ""
+		for j := 0; j+1 < len(fields); j++ {
+			if fields[j] == "dev" {
+				dev = fields[j+1]
+			}
+		}
+		if dev == "" || dev == "lo" {
+			continue
+		}
+		prefix := 0
+		if fields[0] != "default" {
+			prefix = 128
+			if _, network, err := net.ParseCIDR(fields[0]); err == nil {
+				prefix, _ = network.Mask.Size()
+			}
+		}
+		if prefix > longest {
+			iface, longest = dev, prefix
+		}
+	}
+	return iface
+}
+
+// setSyncVersion sets the sync protocol version to SyncVersion, or to 1
+// (which syncs ipv6 and persistence templates) when the kernel has it.
+// Kernels without net.ipv4.vs.sync_version only speak version 0
+func (i Ipvs) setSyncVersion() error {
+	version := 1
+	if i.SyncVersion != nil {
+		version = *i.SyncVersion
+	} else if _, err := i.exec.run([]string{"sysctl", "-n", "net.ipv4.vs.sync_version"}); err != nil {
+		return nil
+	}
+	return i.exec.execute("sysctl", "-w", "net.ipv4.vs.sync_version="+strconv.Itoa(version))
+}
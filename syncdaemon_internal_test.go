@@ -0,0 +1,66 @@
+package lvs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRouteInterface(t *testing.T) {
+	out := `default via 10.0.0.254 dev eth0 proto dhcp metric 100
+10.0.0.0/24 dev eth1 proto kernel scope link src 10.0.0.5
+10.0.0.1 dev lo scope host
+`
+	if iface := routeInterface(out); iface != "eth1" {
+		t.Errorf("expected the most specific route's interface - %s", iface)
+	}
+	if iface := routeInterface("default via 10.0.0.254 dev eth0\n"); iface != "eth0" {
+		t.Errorf("expected the default route's interface - %s", iface)
+	}
+	if iface := routeInterface(""); iface != "" {
+		t.Errorf("expected no interface - %s", iface)
+	}
+}
+
+func TestStartDaemonAuto(t *testing.T) {
+	defer useFakeBackend()()
+	fakeRunOutput = []byte("10.0.0.0/24 dev eth1 proto kernel scope link src 10.0.0.5\n")
+	ipvs := Ipvs{MulticastInterface: AutoInterface, Syncid: 3, Services: []Service{
+		{Type: "fwmark", Host: "1"},
+		{Type: "tcp", Host: "10.0.0.1", Port: 80},
+	}}
+	if err1, err2 := ipvs.StartDaemon(); err1 != nil || err2 != nil {
+		t.Fatal(err1, err2)
+	}
+	expected := []string{
+		"sysctl -w net.ipv4.vs.sync_version=1",
+		"ipvsadm --start-daemon primary --mcast-interface eth1 --syncid 3",
+		"ipvsadm --start-daemon backup --mcast-interface eth1 --syncid 3",
+	}
+	if strings.Join(fakeExecuted, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected commands - %v", fakeExecuted)
+	}
+
+	// overridden
+	fakeExecuted = nil
+	version := 0
+	ipvs.MulticastInterface, ipvs.SyncVersion = "eth2", &version
+	ipvs.StartDaemon()
+	if len(fakeExecuted) != 3 || fakeExecuted[0] != "sysctl -w net.ipv4.vs.sync_version=0" || !strings.Contains(fakeExecuted[1], "eth2") {
+		t.Errorf("unexpected commands - %v", fakeExecuted)
+	}
+
+	// kernels without sync versions
+	fakeExecuted, fakeRunErr = nil, errors.New("exit status 255: sysctl: cannot stat /proc/sys/net/ipv4/vs/sync_version")
+	ipvs.SyncVersion = nil
+	ipvs.StartDaemon()
+	if len(fakeExecuted) != 2 {
+		t.Errorf("expected the version to be left alone - %v", fakeExecuted)
+	}
+
+	fakeRunOutput, fakeRunErr = []byte("10.0.0.1 dev lo scope host\n"), nil
+	ipvs.MulticastInterface = AutoInterface
+	if err, _ := ipvs.StartDaemon(); err != ErrNoSyncInterface {
+		t.Errorf("expected no interface to be found - %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package lvs
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// SystemdUnitOpts configures the unit generated by GenerateSystemdUnit
+	SystemdUnitOpts struct {
+		Name        string // unit file name, defaults to golang-lvs.service
+		Description string
+		RulesPath   string // where the rules file is installed, defaults to DefaultRulesPath
+		Ipvsadm     string // path to ipvsadm, defaults to /sbin/ipvsadm
+		// ExecStart optionally starts a controller once the table is
+		// restored. Without one the unit only restores the table, and
+		// clears it when stopped
+		ExecStart string
+		WantedBy  string // defaults to multi-user.target
+	}
+
+	// SystemdUnit is a unit file restoring a table at boot, and the rules
+	// file it restores
+	SystemdUnit struct {
+		Name      string
+		Unit      string
+		RulesPath string
+		Rules     string
+	}
+)
+
+// GenerateSystemdUnit returns a systemd unit restoring the table's
+// services at boot (with ipvsadm-restore, from an ExecStartPre), and the
+// rules file it restores, so the table survives reboots. Install the unit
+// in /etc/systemd/system and the rules at RulesPath. Interface names are
+// resolved to their address when the rules are generated, as they can't be
+// at boot
+func (i Ipvs) GenerateSystemdUnit(opts SystemdUnitOpts) (SystemdUnit, error) {
+	if opts.Name == "" {
+		opts.Name = "golang-lvs.service"
+	}
+	if opts.Description == "" {
+		opts.Description = "Restore the ipvs table"
+	}
+	if opts.RulesPath == "" {
+		opts.RulesPath = DefaultRulesPath
+	}
+	if opts.Ipvsadm == "" {
+		opts.Ipvsadm = "/sbin/ipvsadm"
+	}
+	if opts.WantedBy == "" {
+		opts.WantedBy = "multi-user.target"
+	}
+
+	rules, err := i.rules()
+	if err != nil {
+		return SystemdUnit{}, err
+	}
+
+	lines := []string{
+		"[Unit]",
+		"Description=" + opts.Description,
+		"Wants=network-online.target",
+		"After=network-online.target",
+		"",
+		"[Service]",
+	}
+	if opts.ExecStart == "" {
+		lines = append(lines, "Type=oneshot", "RemainAfterExit=yes")
+	}
+	if i.Tcp != 0 || i.Tcpfin != 0 || i.Udp != 0 {
+		lines = append(lines, fmt.Sprintf("ExecStartPre=%s --set %d %d %d", opts.Ipvsadm, i.Tcp, i.Tcpfin, i.Udp))
+	}
+	lines = append(lines,
+		"ExecStartPre="+opts.Ipvsadm+" -C",
+		"ExecStartPre=/bin/sh -c '"+opts.Ipvsadm+"-restore < "+opts.RulesPath+"'",
+	)
+	if opts.ExecStart != "" {
+		lines = append(lines, "ExecStart="+opts.ExecStart)
+	} else {
+		lines = append(lines, "ExecStart="+opts.Ipvsadm+" -L -n", "ExecStop="+opts.Ipvsadm+" -C")
+	}
+	lines = append(lines, "", "[Install]", "WantedBy="+opts.WantedBy)
+
+	return SystemdUnit{
+		Name:      opts.Name,
+		Unit:      strings.Join(lines, "\n") + "\n",
+		RulesPath: opts.RulesPath,
+		Rules:     rules,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package lvs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSystemdUnit(t *testing.T) {
+	defer useFakeBackend()()
+
+	ipvs := Ipvs{Tcp: 900, Services: []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{{Host: "10.0.1.1", Port: 80, Weight: 1}}}}}
+	unit, err := ipvs.GenerateSystemdUnit(SystemdUnitOpts{})
+	if err != nil {
+		t.Fatalf("failed to generate - %v", err)
+	}
+	expected := `[Unit]
+Description=Restore the ipvs table
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+ExecStartPre=/sbin/ipvsadm --set 900 0 0
+ExecStartPre=/sbin/ipvsadm -C
+ExecStartPre=/bin/sh -c '/sbin/ipvsadm-restore < /etc/ipvsadm.rules'
+ExecStart=/sbin/ipvsadm -L -n
+ExecStop=/sbin/ipvsadm -C
+
+[Install]
+WantedBy=multi-user.target
+`
+	if unit.Unit != expected {
+		t.Errorf("unexpected unit:\n%s", unit.Unit)
+	}
+	if unit.Name != "golang-lvs.service" || unit.RulesPath != DefaultRulesPath {
+		t.Errorf("unexpected defaults - %+v", unit)
+	}
+	rules, err := ParseSave(unit.Rules)
+	if err != nil || len(rules) != 1 || !rules[0].Equal(ipvs.Services[0]) {
+		t.Errorf("rules don't restore the table - %q %v", unit.Rules, err)
+	}
+
+	unit, _ = ipvs.GenerateSystemdUnit(SystemdUnitOpts{ExecStart: "/usr/local/bin/lvs-controller"})
+	if !strings.Contains(unit.Unit, "ExecStart=/usr/local/bin/lvs-controller\n") || strings.Contains(unit.Unit, "oneshot") || strings.Contains(unit.Unit, "ExecStop") {
+		t.Errorf("controller unit should run it after restoring:\n%s", unit.Unit)
+	}
+}
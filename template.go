@@ -0,0 +1,136 @@
+package lvs
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type (
+	// ServiceTemplate describes a family of similar services. Host, Ports and
+	// the server hosts may reference variables as ${name}, and a host that
+	// expands to a list (separated by commas or spaces) produces one service
+	// or server per entry
+	ServiceTemplate struct {
+		Host        string   `json:"host"`
+		Ports       string   `json:"ports"` // eg. "80,443" or "8000-8010"
+		Type        string   `json:"type"`
+		Scheduler   string   `json:"scheduler"`
+		Persistence int      `json:"persistence"`
+		Netmask     string   `json:"netmask"`
+		Servers     []Server `json:"servers"` // servers with no port use the service's port
+	}
+)
+
+var (
+	UndefinedTemplateVariable = errors.New("Undefined Template Variable")
+	InvalidPortRange          = errors.New("Invalid Port Range")
+)
+
+// Expand returns the services described by t with vars substituted, one
+// for each host and port
+func (t ServiceTemplate) Expand(vars map[string]string) ([]Service, error) {
+	hosts, err := expandList(t.Host, vars)
+	if err != nil {
+		return nil, err
+	}
+	ports := []int{0}
+	if t.Ports != "" {
+		spec, err := expandString(t.Ports, vars)
+		if err != nil {
+			return nil, err
+		}
+		if ports, err = parsePorts(spec); err != nil {
+			return nil, err
+		}
+	}
+
+	services := make([]Service, 0, len(hosts)*len(ports))
+	for _, host := range hosts {
+		for _, port := range ports {
+			service := Service{
+				Host:        host,
+				Port:        port,
+				Type:        t.Type,
+				Scheduler:   t.Scheduler,
+				Persistence: t.Persistence,
+				Netmask:     t.Netmask,
+				Servers:     make([]Server, 0, len(t.Servers)),
+			}
+			for _, server := range t.Servers {
+				serverHosts, err := expandList(server.Host, vars)
+				if err != nil {
+					return nil, err
+				}
+				if server.Port == 0 {
+					server.Port = port
+				}
+				for _, serverHost := range serverHosts {
+					server.Host = serverHost
+					service.Servers = append(service.Servers, server)
+				}
+			}
+			if err := service.Validate(); err != nil {
+				return nil, err
+			}
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+// expandString substitutes vars in s, failing on undefined variables
+func expandString(s string, vars map[string]string) (string, error) {
+	var err error
+	expanded := os.Expand(s, func(name string) string {
+		value, ok := vars[name]
+		if !ok {
+			err = UndefinedTemplateVariable
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// expandList substitutes vars in s and splits the result into a list
+func expandList(s string, vars map[string]string) ([]string, error) {
+	expanded, err := expandString(s, vars)
+	if err != nil {
+		return nil, err
+	}
+	list := strings.FieldsFunc(expanded, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(list) == 0 {
+		list = []string{""}
+	}
+	return list, nil
+}
+
+// parsePorts parses a list of ports and port ranges such as "80,443,8000-8010"
+func parsePorts(spec string) ([]int, error) {
+	ports := make([]int, 0, 0)
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		first, last := field, field
+		if dash := strings.Index(field, "-"); dash >= 0 {
+			first, last = field[:dash], field[dash+1:]
+		}
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, InvalidPortRange
+		}
+		to, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, InvalidPortRange
+		}
+		if from < 1 || to > 65535 || from > to {
+			return nil, InvalidPortRange
+		}
+		for port := from; port <= to; port++ {
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
@@ -0,0 +1,49 @@
+package lvs
+
+import (
+	"testing"
+)
+
+func TestServiceTemplateExpand(t *testing.T) {
+	template := ServiceTemplate{
+		Host:    "${vip}",
+		Ports:   "${ports}",
+		Servers: []Server{{Host: "${backends}", Weight: 1}},
+	}
+	services, err := template.Expand(map[string]string{
+		"vip":      "10.0.0.1",
+		"ports":    "80,8000-8001",
+		"backends": "10.0.1.1, 10.0.1.2",
+	})
+	if err != nil {
+		t.Fatalf("failed to expand template - %v", err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services, got %+v", services)
+	}
+	for i, port := range []int{80, 8000, 8001} {
+		service := services[i]
+		if service.Host != "10.0.0.1" || service.Port != port {
+			t.Errorf("unexpected service - %+v", service)
+		}
+		if len(service.Servers) != 2 || service.Servers[1].Host != "10.0.1.2" || service.Servers[1].Port != port {
+			t.Errorf("unexpected servers - %+v", service.Servers)
+		}
+	}
+
+	if _, err := template.Expand(map[string]string{"vip": "10.0.0.1"}); err != UndefinedTemplateVariable {
+		t.Errorf("expected UndefinedTemplateVariable, got %v", err)
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	ports, err := parsePorts("80, 443,30000-30002")
+	if err != nil || len(ports) != 5 || ports[4] != 30002 {
+		t.Errorf("unexpected ports %v - %v", ports, err)
+	}
+	for _, spec := range []string{"", "http", "10-5", "0", "70000", "1-"} {
+		if _, err := parsePorts(spec); err != InvalidPortRange {
+			t.Errorf("expected InvalidPortRange for %q, got %v", spec, err)
+		}
+	}
+}
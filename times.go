@@ -0,0 +1,92 @@
+package lvs
+
+import (
+	"time"
+)
+
+// stampNow returns the time to stamp services and servers with
+func stampNow() *time.Time {
+	now := time.Now()
+	return &now
+}
+
+// latest returns the later of a and b, either of which may be nil
+func latest(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
+
+// carryTimes copies the timestamps of the services and servers in from onto
+// the same ones in to, as timestamps are only ever set by the Ipvs keeping
+// them. The servers of to are copied rather than changed in place
+func carryTimes(from, to []Service) {
+	known := make(map[string]*Service)
+	for j := range from {
+		known[from[j].canonicalKey()] = &from[j]
+	}
+	for j := range to {
+		previous, ok := known[to[j].canonicalKey()]
+		if !ok {
+			continue
+		}
+		to[j] = previous.carryTimes(to[j])
+	}
+}
+
+// carryTimes returns next, the same service as s, with the timestamps of s
+// and its servers
+func (s Service) carryTimes(next Service) Service {
+	next.LastApplied, next.LastChecked, next.LastStateChange = s.LastApplied, s.LastChecked, s.LastStateChange
+	if next.Servers == nil {
+		return next
+	}
+	servers := make([]Server, len(next.Servers))
+	for j, server := range next.Servers {
+		if previous := s.FindServer(server.Host, server.Port); previous != nil {
+			server = previous.carryTimes(server)
+		} else {
+			server.LastApplied, server.LastChecked, server.LastStateChange = nil, nil, nil
+		}
+		servers[j] = server
+	}
+	next.Servers = servers
+	return next
+}
+
+// carryTimes returns next, the same server as s, with the timestamps of s
+func (s Server) carryTimes(next Server) Server {
+	next.LastApplied, next.LastChecked, next.LastStateChange = s.LastApplied, s.LastChecked, s.LastStateChange
+	return next
+}
+
+// stampApplied returns next with the timestamps of current (nil when next is
+// new), and applied at now when it (or, for its servers, they) differ
+func stampApplied(current *Service, next Service, now *time.Time) Service {
+	if current == nil {
+		next.LastApplied, next.LastChecked, next.LastStateChange = now, nil, nil
+		if next.Servers == nil {
+			return next
+		}
+		servers := make([]Server, len(next.Servers))
+		for j := range next.Servers {
+			servers[j] = next.Servers[j]
+			servers[j].LastApplied, servers[j].LastChecked, servers[j].LastStateChange = now, nil, nil
+		}
+		next.Servers = servers
+		return next
+	}
+	changed := !current.sameAttributes(next)
+	next = current.carryTimes(next)
+	if changed {
+		next.LastApplied = now
+	}
+	for j := range next.Servers {
+		previous := current.FindServer(next.Servers[j].Host, next.Servers[j].Port)
+		if previous == nil || !previous.sameAttributes(next.Servers[j]) {
+			next.Servers[j].LastApplied = now
+		}
+	}
+	return next
+}
@@ -0,0 +1,88 @@
+package lvs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLastApplied(t *testing.T) {
+	ipvs := NewIpvs(WithRunner(NewSimulator()))
+	err := ipvs.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := ipvs.Services[0]
+	if service.LastApplied == nil || service.Servers[0].LastApplied == nil {
+		t.Fatalf("added service should be stamped - %+v", service)
+	}
+	applied := *service.LastApplied
+
+	if err := ipvs.Services[0].AddServer(Server{Host: "10.0.1.2", Port: 80, Weight: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if ipvs.Services[0].Servers[1].LastApplied == nil {
+		t.Errorf("added server should be stamped")
+	}
+
+	// unchanged services and servers keep their times
+	desired := []Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 3},
+	}}}
+	server := *ipvs.Services[0].Servers[0].LastApplied
+	if err := ipvs.Sync(desired); err != nil {
+		t.Fatal(err)
+	}
+	service = ipvs.Services[0]
+	if service.LastApplied == nil || !service.LastApplied.Equal(applied) {
+		t.Errorf("unchanged service should keep its time - %v", service.LastApplied)
+	}
+	if service.Servers[0].LastApplied == nil || !service.Servers[0].LastApplied.Equal(server) {
+		t.Errorf("unchanged server should keep its time - %v", service.Servers[0].LastApplied)
+	}
+	if service.Servers[1].LastApplied == nil {
+		t.Errorf("edited server should be stamped")
+	}
+}
+
+func TestLastStateChange(t *testing.T) {
+	client := New(WithRunner(NewSimulator()))
+	err := client.AddService(Service{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+		{Host: "10.0.1.2", Port: 80, Weight: 1},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	down := ""
+	checker := &HealthChecker{Lvs: client, Check: checkFunc(func(service Service, server Server) error {
+		if server.Host == down {
+			return errors.New("connection refused")
+		}
+		return nil
+	})}
+	checker.CheckOnce()
+	service := client.Services()[0]
+	if service.LastChecked == nil || service.Servers[0].LastChecked == nil || service.Servers[1].LastChecked == nil {
+		t.Fatalf("checked servers should be stamped - %+v", service)
+	}
+	if service.LastStateChange != nil || service.Servers[1].LastStateChange != nil {
+		t.Errorf("healthy servers shouldn't have changed state - %+v", service)
+	}
+
+	down = "10.0.1.2"
+	checker.CheckOnce()
+	service = client.Services()[0]
+	if service.Servers[1].LastStateChange == nil || service.Servers[0].LastStateChange != nil {
+		t.Fatalf("only the failed server should have changed state - %+v", service.Servers)
+	}
+	if service.LastStateChange == nil || !service.LastStateChange.Equal(*service.Servers[1].LastStateChange) {
+		t.Errorf("service should have its server's state change - %v", service.LastStateChange)
+	}
+	if health, _ := checker.ServerHealth(service, service.Servers[1]); !health.LastStateChange.Equal(*service.Servers[1].LastStateChange) {
+		t.Errorf("health should report the state change - %v", health.LastStateChange)
+	}
+}
@@ -0,0 +1,127 @@
+package lvs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// TLSConfig configures a management listener for tls, requiring client
+	// certificates signed by ClientCAFile when it is set (mtls). The files are
+	// checked on every handshake and reloaded when they change, so
+	// certificates can be rotated without a restart
+	TLSConfig struct {
+		CertFile     string
+		KeyFile      string
+		ClientCAFile string
+	}
+
+	certReloader struct {
+		config TLSConfig
+		mu     sync.Mutex
+		mods   [3]time.Time
+		cert   *tls.Certificate
+		pool   *x509.CertPool
+	}
+)
+
+var (
+	InvalidClientCA = errors.New("No certificates found in the client ca file")
+)
+
+// Build returns a tls.Config serving the configured certificate, failing
+// early if the files can't be loaded. The certificate is picked on every
+// handshake (GetCertificate), and so are the client cas when there are some,
+// through a config for the client advertising http/2 like the server's own
+func (c TLSConfig) Build() (*tls.Config, error) {
+	reloader := &certReloader{config: c}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+	base.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := reloader.current()
+		return cert, nil
+	}
+	if c.ClientCAFile == "" {
+		return base, nil
+	}
+
+	// http.Server adds these to its own copy of the config, not to base
+	base.NextProtos = []string{"h2", "http/1.1"}
+	base.ClientAuth = tls.RequireAndVerifyClientCert
+	_, base.ClientCAs = reloader.current()
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		_, pool := reloader.current()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = pool
+		return config, nil
+	}
+	return base, nil
+}
+
+// ListenAndServeTLS serves handler on addr using config, pair with a
+// CertAuthenticator to map client certificates to roles
+func ListenAndServeTLS(addr string, handler http.Handler, config TLSConfig) error {
+	tlsConfig, err := config.Build()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	return server.ListenAndServeTLS("", "")
+}
+
+// current returns the loaded certificate and client ca pool, reloading them
+// first if any of the files changed. A failed reload keeps the old ones
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.modTimes() != r.mods {
+		r.load()
+	}
+	return r.cert, r.pool
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+func (r *certReloader) load() error {
+	mods := r.modTimes()
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return InvalidClientCA
+		}
+	}
+
+	r.cert, r.pool, r.mods = &cert, pool, mods
+	return nil
+}
+
+func (r *certReloader) modTimes() [3]time.Time {
+	var mods [3]time.Time
+	for i, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		mods[i] = statFile(path).modified
+	}
+	return mods
+}
@@ -0,0 +1,159 @@
+package lvs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert writes a certificate for cn (with its key) to dir as name.pem
+// and name.key, signed by parent or self signed as a ca
+func newTestCert(t *testing.T, dir, name, cn string, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := testCert{cert: cert, key: key, certFile: filepath.Join(dir, name+".pem"), keyFile: filepath.Join(dir, name+".key")}
+	if err = os.WriteFile(c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// so the rewritten files are seen as changed however fast
+	later := time.Now().Add(time.Duration(len(cn)) * time.Minute)
+	os.Chtimes(c.certFile, later, later)
+	os.Chtimes(c.keyFile, later, later)
+	return c
+}
+
+// serveTLS serves an ok handler with config like ListenAndServeTLS does,
+// returning its address
+func serveTLS(t *testing.T, config TLSConfig) string {
+	t.Helper()
+	tlsConfig, err := config.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}), TLSConfig: tlsConfig}
+	go server.ServeTLS(l, "", "")
+	t.Cleanup(func() { server.Close() })
+	return l.Addr().String()
+}
+
+func dialTLS(addr string, ca *testCert, client *testCert) (tls.ConnectionState, error) {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: pool, NextProtos: []string{"h2", "http/1.1"}}
+	if client != nil {
+		config.Certificates = []tls.Certificate{{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}}
+	}
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+	// a rejected client certificate only shows up on reading with tls 1.3
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err = conn.Read(make([]byte, 1)); err != nil {
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			return conn.ConnectionState(), err
+		}
+	}
+	return conn.ConnectionState(), nil
+}
+
+func TestTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", "ca", nil)
+	newTestCert(t, dir, "server", "one", &ca)
+	addr := serveTLS(t, TLSConfig{CertFile: filepath.Join(dir, "server.pem"), KeyFile: filepath.Join(dir, "server.key")})
+
+	state, err := dialTLS(addr, &ca, nil)
+	if err != nil || state.PeerCertificates[0].Subject.CommonName != "one" {
+		t.Fatalf("expected the first certificate - %v", err)
+	}
+	if state.NegotiatedProtocol != "h2" {
+		t.Errorf("expected http/2 to be negotiated, got %q", state.NegotiatedProtocol)
+	}
+
+	newTestCert(t, dir, "server", "rotated", &ca)
+	if state, err = dialTLS(addr, &ca, nil); err != nil || state.PeerCertificates[0].Subject.CommonName != "rotated" {
+		t.Errorf("expected the rotated certificate - %v", err)
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", "ca", nil)
+	server := newTestCert(t, dir, "server", "server", &ca)
+	client := newTestCert(t, dir, "client", "admin", &ca)
+	addr := serveTLS(t, TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile})
+
+	state, err := dialTLS(addr, &ca, &client)
+	if err != nil {
+		t.Fatalf("expected the client certificate to be accepted - %v", err)
+	}
+	if state.NegotiatedProtocol != "h2" {
+		t.Errorf("expected http/2 to be negotiated, got %q", state.NegotiatedProtocol)
+	}
+	if _, err = dialTLS(addr, &ca, nil); err == nil {
+		t.Errorf("expected clients without a certificate to be refused")
+	}
+
+	// clients of the old ca are refused once it is rotated
+	other := newTestCert(t, t.TempDir(), "ca", "other ca", nil)
+	if err = os.Rename(other.certFile, ca.certFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = dialTLS(addr, &ca, &client); err == nil {
+		t.Errorf("expected the rotated client ca to refuse the client")
+	}
+}
@@ -0,0 +1,55 @@
+package lvs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+)
+
+type (
+	// PeerAuthenticator maps the uid of the process connected to a unix
+	// socket served by ListenAndServeUnix to a role
+	PeerAuthenticator map[int]Role
+
+	peerKey struct{}
+)
+
+// ListenAndServeUnix serves handler on a unix socket at path so local tools
+// can manage the director without opening a tcp port. The uid of the
+// connecting process is read from the socket, pair with a PeerAuthenticator
+// to restrict access
+func ListenAndServeUnix(path string, handler http.Handler) error {
+	// clean up a socket left behind by a previous run
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if err = os.Chmod(path, 0660); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler: handler,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			if uid, ok := peerUid(conn); ok {
+				return context.WithValue(ctx, peerKey{}, uid)
+			}
+			return ctx
+		},
+	}
+	return server.Serve(listener)
+}
+
+func (p PeerAuthenticator) Authenticate(req *http.Request) (Role, bool) {
+	uid, ok := req.Context().Value(peerKey{}).(int)
+	if !ok {
+		return RoleNone, false
+	}
+	role, ok := p[uid]
+	return role, ok
+}
@@ -0,0 +1,51 @@
+package lvs
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestUnixPeerAuth(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on linux")
+	}
+
+	path := filepath.Join(t.TempDir(), "lvs.sock")
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+	go ListenAndServeUnix(path, AuthMiddleware(PeerAuthenticator{os.Getuid(): RoleReadOnly}, nil, ok))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res, err := client.Get("http://lvs/services")
+	if err != nil {
+		t.Fatalf("failed to request over the socket - %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected own uid to be allowed, got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest("DELETE", "http://lvs/services", nil)
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to request over the socket - %v", err)
+	}
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected read only uid to be forbidden, got %d", res.StatusCode)
+	}
+}
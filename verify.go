@@ -0,0 +1,216 @@
+package lvs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+type (
+	// Distribution is how the test connections of Verify were scheduled
+	Distribution struct {
+		Service string `json:"service"` // type and host:port of the service
+		Samples int    `json:"samples"`
+		// Failed are the connections that couldn't be opened, eg. direct
+		// routing servers without the vip on their loopback resetting them
+		Failed int `json:"failed"`
+		// Bypassed are the connections opened but missing from the
+		// connection table, answered by something other than ipvs such as
+		// a server answering arp for the vip
+		Bypassed int                 `json:"bypassed"`
+		Servers  []ServerObservation `json:"servers"`
+	}
+
+	// ServerObservation is the share of the scheduled connections a server
+	// got, against the share its weight gives it
+	ServerObservation struct {
+		Host     string  `json:"host"`
+		Port     int     `json:"port"`
+		Weight   int     `json:"weight"`
+		Count    int     `json:"count"`
+		Expected float64 `json:"expected"` // 0 to 1
+		Observed float64 `json:"observed"`
+	}
+
+	// verifying holds the test connections of Verify open
+	verifying struct {
+		d       Distribution
+		current Service
+		index   map[string]int // server host:port to its index in d.Servers
+		ports   map[int]bool   // source ports of the test connections
+		conns   []net.Conn
+	}
+)
+
+var (
+	// VerifyTimeout is how long Verify waits for each test connection
+	VerifyTimeout = 2 * time.Second
+
+	VerifyUnsupported = errors.New("Verify Unsupported, only tcp services can be verified")
+
+	// dialVerify opens the test connections, replaced by tests
+	dialVerify = net.DialTimeout
+)
+
+// Verify opens samples tcp connections to the applied service identified by
+// service (its type, host and port), from this host, and reports which
+// server ipvs scheduled each to, found by the connection's source port in
+// the connection table. The connections are held open until the table is
+// read, so schedulers counting them see them as they pile up. Persistent
+// services send every connection from this host to the same server
+func (i *Ipvs) Verify(service Service, samples int) (Distribution, error) {
+	if service.Type != "tcp" {
+		return Distribution{}, VerifyUnsupported
+	}
+	current, err := i.appliedService(service)
+	if err != nil {
+		return Distribution{}, err
+	}
+	v := startVerify(*current, samples)
+	defer v.close()
+	connections, err := i.Connections()
+	if err != nil {
+		return Distribution{}, err
+	}
+	return v.distribution(connections), nil
+}
+
+// appliedService returns the applied service identified by service, read
+// back from the table
+func (i *Ipvs) appliedService(service Service) (*Service, error) {
+	applied := Ipvs{exec: i.exec}
+	if err := applied.Save(); err != nil {
+		return nil, err
+	}
+	service.exec = i.exec
+	resolved, err := service.resolve()
+	if err != nil {
+		return nil, err
+	}
+	current := applied.FindService(resolved.Type, resolved.Host, resolved.Port)
+	if current == nil {
+		return nil, NotFound
+	}
+	return current, nil
+}
+
+// startVerify opens the test connections of Verify to current, close them
+// once the connection table is read
+func startVerify(current Service, samples int) *verifying {
+	v := &verifying{
+		d:       Distribution{Service: current.Type + " " + current.getHostPort(), Samples: samples},
+		current: current,
+		index:   make(map[string]int),
+		ports:   make(map[int]bool),
+		conns:   make([]net.Conn, 0, samples),
+	}
+	total := 0
+	for _, server := range current.Servers {
+		total += server.Weight
+	}
+	for j, server := range current.Servers {
+		observation := ServerObservation{Host: server.Host, Port: server.Port, Weight: server.Weight}
+		if total > 0 {
+			observation.Expected = float64(server.Weight) / float64(total)
+		}
+		v.index[net.JoinHostPort(canonicalHost(server.Host), strconv.Itoa(server.Port))] = j
+		v.d.Servers = append(v.d.Servers, observation)
+	}
+
+	for j := 0; j < samples; j++ {
+		conn, err := dialVerify("tcp", net.JoinHostPort(current.Host, strconv.Itoa(current.Port)), VerifyTimeout)
+		if err != nil {
+			v.d.Failed++
+			continue
+		}
+		v.conns = append(v.conns, conn)
+		if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+			v.ports[addr.Port] = true
+		}
+	}
+	return v
+}
+
+func (v *verifying) close() {
+	for _, conn := range v.conns {
+		conn.Close()
+	}
+}
+
+// distribution finds the test connections in connections
+func (v *verifying) distribution(connections []Connection) Distribution {
+	d, scheduled := v.d, 0
+	d.Servers = append([]ServerObservation{}, v.d.Servers...)
+	for _, c := range connections {
+		if !v.ports[c.SourcePort] || c.VirtualPort != v.current.Port || !sameHost(c.VirtualHost, v.current.Host) {
+			continue
+		}
+		delete(v.ports, c.SourcePort)
+		if j, ok := v.index[net.JoinHostPort(canonicalHost(c.DestinationHost), strconv.Itoa(c.DestinationPort))]; ok {
+			d.Servers[j].Count++
+			scheduled++
+		}
+	}
+	d.Bypassed = d.Samples - d.Failed - scheduled
+	for j := range d.Servers {
+		if scheduled > 0 {
+			d.Servers[j].Observed = float64(d.Servers[j].Count) / float64(scheduled)
+		}
+	}
+	return d
+}
+
+// Problems describes what is wrong with the distribution, such as "3 of 10
+// connections failed" or "server 10.0.1.1:80 got 0.10 of the connections
+// rather than 0.50", tolerating observed shares off by up to tolerance
+// (eg. 0.1 for 10 points), nothing when it is as configured
+func (d Distribution) Problems(tolerance float64) []string {
+	problems := make([]string, 0, 0)
+	if d.Failed > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d connections failed", d.Failed, d.Samples))
+	}
+	if d.Bypassed > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d connections bypassed ipvs", d.Bypassed, d.Samples))
+	}
+	if d.Failed+d.Bypassed == d.Samples {
+		return problems
+	}
+	for _, server := range d.Servers {
+		if math.Abs(server.Observed-server.Expected) > tolerance {
+			problems = append(problems, fmt.Sprintf("server %s got %.2f of the connections rather than %.2f", net.JoinHostPort(server.Host, strconv.Itoa(server.Port)), server.Observed, server.Expected))
+		}
+	}
+	return problems
+}
+
+// Verify verifies like Ipvs.Verify, only holding the client's lock to read
+// the table, not while the test connections are opened
+func (l *Lvs) Verify(service Service, samples int) (Distribution, error) {
+	if service.Type != "tcp" {
+		return Distribution{}, VerifyUnsupported
+	}
+	var current *Service
+	err := l.Do(func(i *Ipvs) error {
+		var err error
+		current, err = i.appliedService(service)
+		return err
+	})
+	if err != nil {
+		return Distribution{}, err
+	}
+	v := startVerify(*current, samples)
+	defer v.close()
+	var connections []Connection
+	err = l.Do(func(i *Ipvs) error {
+		var err error
+		connections, err = i.Connections()
+		return err
+	})
+	if err != nil {
+		return Distribution{}, err
+	}
+	return v.distribution(connections), nil
+}
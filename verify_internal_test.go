@@ -0,0 +1,120 @@
+package lvs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyRunner answers the connection table listing of a Simulator with
+// connections from the source ports given
+type verifyRunner struct {
+	*Simulator
+	sources map[int]string // source port to destination host:port
+}
+
+func (r verifyRunner) RunOutput(ctx context.Context, exe string, args ...string) ([]byte, []byte, error) {
+	if exe == "ipvsadm" && len(args) > 1 && args[1] == "-c" {
+		out := "IPVS connection entries\npro expire state       source             virtual            destination\n"
+		for port, destination := range r.sources {
+			out += fmt.Sprintf("TCP 14:56  ESTABLISHED 10.0.2.5:%d     10.0.0.1:80        %s\n", port, destination)
+		}
+		return []byte(out), nil, nil
+	}
+	return r.Simulator.RunOutput(ctx, exe, args...)
+}
+
+// verifyConn is a connection from the next source port
+type verifyConn struct {
+	net.Conn
+	port int
+}
+
+var verifySource = net.ParseIP("10.0.2.5")
+
+func (c verifyConn) LocalAddr() net.Addr { return &net.TCPAddr{IP: verifySource, Port: c.port} }
+func (c verifyConn) Close() error        { return nil }
+
+func TestVerify(t *testing.T) {
+	port := 40000
+	dialVerify = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		port++
+		if port > 40010 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return verifyConn{port: port}, nil
+	}
+	defer func() { dialVerify = net.DialTimeout }()
+
+	sources := make(map[int]string)
+	for j := 40001; j <= 40008; j++ {
+		sources[j] = "10.0.1.1:80"
+		if j > 40005 {
+			sources[j] = "10.0.1.2:80"
+		}
+	}
+	simulator := NewSimulator()
+	ipvs := NewIpvs(WithRunner(verifyRunner{Simulator: simulator, sources: sources}))
+	err := ipvs.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1}, {Host: "10.0.1.2", Port: 80, Weight: 1},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ipvs.Verify(Service{Type: "udp", Host: "10.0.0.1", Port: 80}, 1); err != VerifyUnsupported {
+		t.Errorf("expected udp to be unsupported - %v", err)
+	}
+	if _, err := ipvs.Verify(Service{Type: "tcp", Host: "10.0.0.2", Port: 80}, 1); err != NotFound {
+		t.Errorf("expected an unknown service to be not found - %v", err)
+	}
+
+	d, err := ipvs.Verify(Service{Type: "tcp", Host: "10.0.0.1", Port: 80}, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Failed != 2 || d.Bypassed != 2 || d.Servers[0].Count != 5 || d.Servers[1].Count != 3 || d.Servers[0].Expected != 0.5 || d.Servers[1].Observed != 0.375 {
+		t.Errorf("unexpected distribution - %+v", d)
+	}
+	expected := "2 of 12 connections failed, 2 of 12 connections bypassed ipvs, server 10.0.1.1:80 got 0.62 of the connections rather than 0.50, server 10.0.1.2:80 got 0.38 of the connections rather than 0.50"
+	if problems := strings.Join(d.Problems(0.1), ", "); problems != expected {
+		t.Errorf("unexpected problems - %s", problems)
+	}
+	if problems := d.Problems(0.2); len(problems) != 2 {
+		t.Errorf("expected the skew to be tolerated - %v", problems)
+	}
+}
+
+func TestVerifyUnlocked(t *testing.T) {
+	client := New(WithRunner(verifyRunner{Simulator: NewSimulator(), sources: map[int]string{40001: "10.0.1.1:80"}}))
+	locked := false
+	dialVerify = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		if !client.mu.TryLock() {
+			locked = true
+		} else {
+			client.mu.Unlock()
+		}
+		return verifyConn{port: 40001}, nil
+	}
+	defer func() { dialVerify = net.DialTimeout }()
+	err := client.Sync([]Service{{Type: "tcp", Host: "10.0.0.1", Port: 80, Scheduler: "rr", Servers: []Server{
+		{Host: "10.0.1.1", Port: 80, Weight: 1},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := client.Verify(Service{Type: "tcp", Host: "10.0.0.1", Port: 80}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Error("expected the client not to be locked while dialing")
+	}
+	if d.Servers[0].Count != 1 {
+		t.Errorf("unexpected distribution - %+v", d)
+	}
+}
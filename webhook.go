@@ -0,0 +1,117 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type (
+	// Webhook is an EventHandler posting events as json to URL, retrying
+	// failed deliveries
+	Webhook struct {
+		URL        string
+		Headers    map[string]string // eg. an Authorization header
+		Events     []string          // event types to send, all of them when empty
+		Retries    int               // attempts after a failure, defaults to 3 and negative disables retries
+		RetryDelay time.Duration     // doubled after every failed attempt, defaults to 1s
+		Timeout    time.Duration     // per attempt, defaults to 5s
+		OnError    func(Event, error)
+	}
+
+	// webhookRejected is a 4xx response, retrying it won't help
+	webhookRejected struct {
+		status int
+	}
+)
+
+// HandleEvent sends e in the background if the webhook wants it
+func (w *Webhook) HandleEvent(e Event) {
+	if !w.wants(e.Type) {
+		return
+	}
+	go func() {
+		if err := w.Send(e); err != nil && w.OnError != nil {
+			w.OnError(e, err)
+		}
+	}()
+}
+
+// Send posts e, retrying network errors and 5xx responses
+func (w *Webhook) Send(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return w.deliver(body)
+}
+
+// deliver posts body, retrying network errors and 5xx responses
+func (w *Webhook) deliver(body []byte) error {
+	var err error
+	retries, delay, timeout := w.Retries, w.RetryDelay, w.Timeout
+	if retries == 0 {
+		retries = 3
+	}
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	client := http.Client{Timeout: timeout}
+	for attempt := 0; ; attempt++ {
+		err = w.post(client, body)
+		if err == nil || attempt >= retries {
+			return err
+		}
+		if _, permanent := err.(webhookRejected); permanent {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (e webhookRejected) Error() string {
+	return fmt.Sprintf("webhook rejected the event with status %d", e.status)
+}
+
+func (w *Webhook) post(client http.Client, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode >= 500:
+		return fmt.Errorf("webhook failed with status %d", res.StatusCode)
+	case res.StatusCode >= 400:
+		return webhookRejected{status: res.StatusCode}
+	}
+	return nil
+}
+
+func (w *Webhook) wants(eventType string) bool {
+	return len(w.Events) == 0 || wantsEvent(w.Events, eventType)
+}
+
+func wantsEvent(events []string, eventType string) bool {
+	for _, wanted := range events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package lvs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookSend(t *testing.T) {
+	attempts := 0
+	received := Event{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		attempts++
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts < 3 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewDecoder(req.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer secret"}, RetryDelay: time.Millisecond}
+	if err := webhook.Send(Event{Type: EventServerDown, Server: &Server{Host: "10.0.1.1", Port: 80}}); err != nil {
+		t.Fatalf("failed to send - %v", err)
+	}
+	if attempts != 3 || received.Type != EventServerDown || received.Server.Host != "10.0.1.1" {
+		t.Errorf("unexpected delivery after %d attempts - %+v", attempts, received)
+	}
+
+	attempts = 0
+	webhook.Headers = nil
+	if err := webhook.Send(Event{Type: EventServerUp}); err == nil || attempts != 1 {
+		t.Errorf("expected a rejected event not to be retried, got %d attempts - %v", attempts, err)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	webhook := &Webhook{Events: []string{EventServerDown, EventServerUp}}
+	if !webhook.wants(EventServerDown) || webhook.wants(EventSyncApplied) {
+		t.Errorf("webhook events not filtered")
+	}
+	if !(&Webhook{}).wants(EventSyncApplied) {
+		t.Errorf("webhook without events should want them all")
+	}
+}
@@ -0,0 +1,112 @@
+package lvs
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// IpvsadmMaxWeight is the highest weight ipvsadm accepts
+	IpvsadmMaxWeight = 65535
+
+	InvalidWeightShare = errors.New("Invalid Weight Share, expected a share between 0 and 1")
+)
+
+// NormalizeWeights turns arbitrary weights (such as capacities, or
+// fractions of the traffic) into ipvsadm weights, preserving their ratios
+// as closely as the 0-65535 range allows. Whole weights are divided by
+// their greatest common divisor, and only scaled down if they still don't
+// fit, so weights that already fit (like 1, 2 and 3) are returned as they
+// are. Others are scaled so the highest is IpvsadmMaxWeight, then divided by
+// their greatest common divisor to keep them small. Negative weights become
+// 0 and positive ones never round down to 0, keeping their servers in
+// rotation
+func NormalizeWeights(weights []float64) []int {
+	high := 0.0
+	whole := true
+	divisor := 0
+	for _, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if weight > high {
+			high = weight
+		}
+		whole = whole && weight == math.Trunc(weight) && weight <= math.MaxInt32
+		if whole {
+			divisor = gcd(divisor, int(weight))
+		}
+	}
+	normalized := make([]int, len(weights))
+	if high <= 0 {
+		return normalized
+	}
+
+	scale := float64(IpvsadmMaxWeight) / high
+	if whole && high/float64(divisor) <= float64(IpvsadmMaxWeight) {
+		scale = 1 / float64(divisor)
+	}
+	divisor = 0
+	for j, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		normalized[j] = int(math.Round(weight * scale))
+		if normalized[j] < 1 {
+			normalized[j] = 1
+		}
+		divisor = gcd(divisor, normalized[j])
+	}
+	for j := range normalized {
+		normalized[j] /= divisor
+	}
+	return normalized
+}
+
+// NormalizeWeights returns s with its servers' weights normalized, see
+// NormalizeWeights, such as after they were set to raw capacities
+func (s Service) NormalizeWeights() Service {
+	weights := make([]float64, len(s.Servers))
+	for j := range s.Servers {
+		weights[j] = float64(s.Servers[j].Weight)
+	}
+	servers := make([]Server, len(s.Servers))
+	for j, weight := range NormalizeWeights(weights) {
+		servers[j] = s.Servers[j]
+		servers[j].Weight = weight
+	}
+	s.Servers = servers
+	return s
+}
+
+// AddServerShare returns s with server added to get share (between 0 and
+// 1) of the service's traffic, rescaling the weights so the servers already
+// in rotation keep their shares relative to each other. Servers out of
+// rotation (weight 0) stay out of it
+func (s Service) AddServerShare(server Server, share float64) (Service, error) {
+	if share <= 0 || share >= 1 {
+		return s, InvalidWeightShare
+	}
+	total := 0.0
+	weights := make([]float64, len(s.Servers), len(s.Servers)+1)
+	for j := range s.Servers {
+		weights[j] = float64(s.Servers[j].Weight)
+		if weights[j] > 0 {
+			total += weights[j]
+		}
+	}
+	if total == 0 {
+		// the server gets all the traffic anyway
+		total = 1 - share
+	}
+	weights = append(weights, total*share/(1-share))
+
+	servers := make([]Server, len(weights))
+	copy(servers, s.Servers)
+	servers[len(s.Servers)] = server
+	for j, weight := range NormalizeWeights(weights) {
+		servers[j].Weight = weight
+	}
+	s.Servers = servers
+	return s, nil
+}
@@ -0,0 +1,56 @@
+package lvs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeWeights(t *testing.T) {
+	tests := []struct {
+		weights  []float64
+		expected []int
+	}{
+		{[]float64{1, 2, 3}, []int{1, 2, 3}},
+		{[]float64{0.25, 0.75}, []int{1, 3}},
+		{[]float64{100000, 50000, 0, -3}, []int{2, 1, 0, 0}},
+		{[]float64{1000000, 1}, []int{65535, 1}},
+		{[]float64{0, 0}, []int{0, 0}},
+		{[]float64{}, []int{}},
+	}
+	for _, test := range tests {
+		if normalized := NormalizeWeights(test.weights); !reflect.DeepEqual(normalized, test.expected) {
+			t.Errorf("normalized %v to %v, expected %v", test.weights, normalized, test.expected)
+		}
+	}
+
+	service := Service{Servers: []Server{{Host: "10.0.1.1", Weight: 200000}, {Host: "10.0.1.2", Weight: 100000}}}.NormalizeWeights()
+	if service.Servers[0].Weight != 2 || service.Servers[1].Weight != 1 {
+		t.Errorf("unexpected weights - %+v", service.Servers)
+	}
+}
+
+func TestAddServerShare(t *testing.T) {
+	service := Service{Servers: []Server{{Host: "10.0.1.1", Weight: 1}, {Host: "10.0.1.2", Weight: 3}, {Host: "10.0.1.3", Weight: 0}}}
+	added, err := service.AddServerShare(Server{Host: "10.0.1.4"}, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := []int{}
+	for _, server := range added.Servers {
+		weights = append(weights, server.Weight)
+	}
+	if !reflect.DeepEqual(weights, []int{1, 3, 0, 4}) || added.Servers[3].Host != "10.0.1.4" {
+		t.Errorf("unexpected servers - %+v", added.Servers)
+	}
+	if service.Servers[0].Weight != 1 || len(service.Servers) != 3 {
+		t.Errorf("service was changed - %+v", service.Servers)
+	}
+
+	added, _ = Service{}.AddServerShare(Server{Host: "10.0.1.1"}, 0.2)
+	if len(added.Servers) != 1 || added.Servers[0].Weight != 1 {
+		t.Errorf("unexpected servers - %+v", added.Servers)
+	}
+	if _, err := service.AddServerShare(Server{}, 1); err != InvalidWeightShare {
+		t.Errorf("expected InvalidWeightShare, got %v", err)
+	}
+}
@@ -0,0 +1,638 @@
+package lvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// yamlCodec reads and writes yaml through the json encoding of values,
+	// so both have the same fields. It reads the block style configs are
+	// written in (mappings, sequences, flow collections, plain, quoted and
+	// block scalars), anchors, aliases, tags and multiple documents aren't
+	// supported
+	yamlCodec struct{}
+
+	// YamlError is a yaml document the yaml codec can't read
+	YamlError struct {
+		Line    int
+		Message string
+	}
+
+	yamlLine struct {
+		number int    // from 1, for errors
+		indent int    // spaces before text
+		text   string // without indentation, comment or trailing spaces
+		raw    string // the line as written, for block scalars
+	}
+
+	yamlParser struct {
+		lines []yamlLine
+		pos   int
+	}
+)
+
+var (
+	yamlInt   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlKey   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+	// what the escapes of double quoted scalars stand for
+	yamlEscapes = map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+		'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+		'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+	}
+)
+
+func (e YamlError) Error() string {
+	return fmt.Sprintf("yaml line %d: %s", e.Line, e.Message)
+}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	out := &strings.Builder{}
+	yamlNode(out, doc, "")
+	return []byte(out.String()), nil
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	p, err := newYamlParser(string(data))
+	if err != nil {
+		return err
+	}
+	doc, err := p.parseNode(0)
+	if err != nil {
+		return err
+	}
+	if line, ok := p.peek(); ok {
+		return YamlError{Line: line.number, Message: "unexpected indentation"}
+	}
+	data, err = json.Marshal(yamlCoerce(doc, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// yamlNode writes the block yaml of a decoded json value, each line prefixed
+// by indent
+func yamlNode(out *strings.Builder, doc interface{}, indent string) {
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !yamlKey.MatchString(key) {
+				key = strconv.Quote(key)
+			}
+			out.WriteString(indent + key + ":")
+			yamlChild(out, value[key], indent+"  ")
+		}
+	case []interface{}:
+		for _, item := range value {
+			if fields, ok := item.(map[string]interface{}); ok && len(fields) > 0 {
+				// the first field goes on the dash's line
+				item := &strings.Builder{}
+				yamlNode(item, fields, indent+"  ")
+				out.WriteString(indent + "- " + strings.TrimPrefix(item.String(), indent+"  "))
+				continue
+			}
+			out.WriteString(indent + "-")
+			yamlChild(out, item, indent+"  ")
+		}
+	default:
+		out.WriteString(indent + yamlScalar(doc) + "\n")
+	}
+}
+
+// yamlChild writes a value following a key or a list dash, inline when it
+// is a scalar or empty
+func yamlChild(out *strings.Builder, doc interface{}, indent string) {
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		if len(value) == 0 {
+			out.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			out.WriteString(" []\n")
+			return
+		}
+	default:
+		out.WriteString(" " + yamlScalar(doc) + "\n")
+		return
+	}
+	out.WriteString("\n")
+	yamlNode(out, doc, indent)
+}
+
+// yamlScalar writes a scalar, strings double quoted: yaml's escapes are a
+// superset of go's, and json has already replaced invalid utf-8
+func yamlScalar(doc interface{}) string {
+	switch value := doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		return strconv.Quote(value)
+	}
+	return fmt.Sprint(doc)
+}
+
+// yamlCoerce turns the numbers and booleans of doc decoded into strings of
+// t back into strings, as yaml doesn't tell `host: 16` apart from a number
+func yamlCoerce(doc interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return doc
+	}
+	switch value := doc.(type) {
+	case json.Number:
+		if t.Kind() == reflect.String {
+			return value.String()
+		}
+	case bool:
+		if t.Kind() == reflect.String {
+			return strconv.FormatBool(value)
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for j := range value {
+				value[j] = yamlCoerce(value[j], t.Elem())
+			}
+		}
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Map:
+			for key := range value {
+				value[key] = yamlCoerce(value[key], t.Elem())
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for key := range value {
+				if field, ok := fields[strings.ToLower(key)]; ok {
+					value[key] = yamlCoerce(value[key], field)
+				}
+			}
+		}
+	}
+	return doc
+}
+
+// yamlFields returns the types of t's fields by their lowercased json name,
+// those of embedded structs included
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for j := 0; j < t.NumField(); j++ {
+		field := t.Field(j)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, typ := range yamlFields(field.Type) {
+				if _, ok := fields[embedded]; !ok {
+					fields[embedded] = typ
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+func newYamlParser(doc string) (*yamlParser, error) {
+	p := &yamlParser{}
+	for j, raw := range strings.Split(doc, "\n") {
+		raw = strings.TrimSuffix(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		line := yamlLine{number: j + 1, indent: len(raw) - len(text), raw: raw}
+		if strings.HasPrefix(text, "\t") {
+			return nil, YamlError{Line: line.number, Message: "tabs can't indent yaml"}
+		}
+		line.text = strings.TrimRight(yamlStripComment(text), " \t")
+		if line.indent == 0 && (line.text == "---" || line.text == "..." || strings.HasPrefix(line.text, "%")) {
+			line.text = ""
+		}
+		p.lines = append(p.lines, line)
+	}
+	return p, nil
+}
+
+// yamlStripComment removes the comment at the end of text, a # starting the
+// line or following a space outside of quotes
+func yamlStripComment(text string) string {
+	var quote byte
+	for j := 0; j < len(text); j++ {
+		switch c := text[j]; {
+		case quote == '"' && c == '\\', quote == '\'' && c == '\'' && j+1 < len(text) && text[j+1] == '\'':
+			// escaped quotes
+			j++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '#' && (j == 0 || text[j-1] == ' ' || text[j-1] == '\t'):
+			return text[:j]
+		case (c == '"' || c == '\'') && yamlValueStart(text[:j]):
+			quote = c
+		}
+	}
+	return text
+}
+
+// yamlValueStart reports whether a quote following before opens a quoted
+// scalar, rather than being part of a plain one such as it's
+func yamlValueStart(before string) bool {
+	before = strings.TrimRight(before, " ")
+	return before == "" || strings.ContainsAny(before[len(before)-1:], ":-[{,")
+}
+
+// peek returns the next line with text, skipping blank ones
+func (p *yamlParser) peek() (yamlLine, bool) {
+	for p.pos < len(p.lines) && p.lines[p.pos].text == "" {
+		p.pos++
+	}
+	if p.pos == len(p.lines) {
+		return yamlLine{}, false
+	}
+	return p.lines[p.pos], true
+}
+
+// parseNode parses the node starting at the next line, nil when it isn't
+// indented by at least indent
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	line, ok := p.peek()
+	if !ok || line.indent < indent {
+		return nil, nil
+	}
+	if yamlSeqItem(line.text) {
+		return p.parseSeq(line.indent)
+	}
+	if _, _, ok, err := yamlSplitKey(line); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		return p.parseMap(line.indent)
+	}
+	p.pos++
+	return p.parseValue(line, line.text, line.indent-1)
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent < indent {
+			return m, nil
+		}
+		if line.indent > indent {
+			return nil, YamlError{Line: line.number, Message: "unexpected indentation"}
+		}
+		key, rest, ok, err := yamlSplitKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, YamlError{Line: line.number, Message: "expected a key"}
+		}
+		if _, ok := m[key]; ok {
+			return nil, YamlError{Line: line.number, Message: fmt.Sprintf("duplicate key '%s'", key)}
+		}
+		p.pos++
+		if rest != "" {
+			if m[key], err = p.parseValue(line, rest, indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// a sequence may be indented like its key
+		next, ok := p.peek()
+		switch {
+		case ok && next.indent == indent && yamlSeqItem(next.text):
+			m[key], err = p.parseSeq(indent)
+		default:
+			m[key], err = p.parseNode(indent + 1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *yamlParser) parseSeq(indent int) (interface{}, error) {
+	seq := make([]interface{}, 0, 0)
+	for {
+		line, ok := p.peek()
+		if !ok || line.indent < indent || (line.indent == indent && !yamlSeqItem(line.text)) {
+			return seq, nil
+		}
+		if line.indent > indent {
+			return nil, YamlError{Line: line.number, Message: "unexpected indentation"}
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		var item interface{}
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.parseNode(indent + 1)
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			p.pos++
+			item, err = p.parseValue(line, rest, indent)
+		default:
+			// the item's node starts after the dash, like "- key: value"
+			// followed by its other keys indented up to it
+			offset := indent + len(line.text) - len(rest)
+			p.lines[p.pos].indent, p.lines[p.pos].text = offset, rest
+			item, err = p.parseNode(offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+}
+
+// parseValue parses the value written as text on line, inside a node
+// indented by indent
+func (p *yamlParser) parseValue(line yamlLine, text string, indent int) (interface{}, error) {
+	switch text[0] {
+	case '|', '>':
+		return p.parseBlock(line, text, indent)
+	case '&', '*', '!':
+		return nil, YamlError{Line: line.number, Message: "anchors, aliases and tags aren't supported"}
+	case '[', '{':
+		value, rest, err := yamlFlow(text)
+		if err == nil && strings.TrimSpace(rest) != "" {
+			err = fmt.Errorf("unexpected '%s' after a flow collection", rest)
+		}
+		if err != nil {
+			return nil, YamlError{Line: line.number, Message: err.Error()}
+		}
+		return value, nil
+	}
+	value, rest, err := yamlFlowScalar(text, false)
+	if err == nil && strings.TrimSpace(rest) != "" {
+		err = fmt.Errorf("unexpected '%s' after a quoted scalar", rest)
+	}
+	if err != nil {
+		return nil, YamlError{Line: line.number, Message: err.Error()}
+	}
+	return value, nil
+}
+
+// parseBlock parses a literal (|) or folded (>) block scalar, its lines
+// indented more than indent
+func (p *yamlParser) parseBlock(line yamlLine, header string, indent int) (interface{}, error) {
+	chomp := strings.TrimLeft(header[1:], " ")
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, YamlError{Line: line.number, Message: fmt.Sprintf("unsupported block scalar header '%s'", header)}
+	}
+	lines, content := make([]string, 0, 0), -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		next := p.lines[p.pos]
+		if strings.TrimSpace(next.raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if next.indent <= indent {
+			break
+		}
+		if content < 0 {
+			content = next.indent
+		}
+		if next.indent < content {
+			return nil, YamlError{Line: next.number, Message: "block scalar lines must be indented alike"}
+		}
+		lines = append(lines, next.raw[content:])
+	}
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+	text := ""
+	if header[0] == '|' {
+		text = strings.Join(body, "\n")
+	} else {
+		for j, l := range body {
+			// lines are joined by spaces, blank ones are newlines
+			switch {
+			case j == 0, l != "" && body[j-1] == "":
+			case l == "":
+				text += "\n"
+			default:
+				text += " "
+			}
+			text += l
+		}
+	}
+	switch {
+	case len(body) == 0:
+		return "", nil
+	case chomp == "-":
+		return text, nil
+	case chomp == "+":
+		return text + strings.Repeat("\n", trailing+1), nil
+	}
+	return text + "\n", nil
+}
+
+func yamlSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlSplitKey splits line into the key of a mapping entry and the rest of
+// its text, ok is false when it isn't one
+func yamlSplitKey(line yamlLine) (key, rest string, ok bool, err error) {
+	text := line.text
+	if text[0] == '"' || text[0] == '\'' {
+		value, after, err := yamlFlowScalar(text, false)
+		if err != nil {
+			return "", "", false, YamlError{Line: line.number, Message: err.Error()}
+		}
+		after = strings.TrimLeft(after, " ")
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		return fmt.Sprint(value), strings.TrimSpace(after[1:]), true, nil
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false, nil
+	}
+	j := strings.Index(text, ": ")
+	if j < 0 && strings.HasSuffix(text, ":") {
+		j = len(text) - 1
+	}
+	if j <= 0 {
+		return "", "", false, nil
+	}
+	return strings.TrimRight(text[:j], " "), strings.TrimSpace(text[j+1:]), true, nil
+}
+
+// yamlFlow parses the flow collection text starts with, returning the text
+// after it
+func yamlFlow(text string) (interface{}, string, error) {
+	closing := byte(']')
+	if text[0] == '{' {
+		closing = '}'
+	}
+	seq, m := make([]interface{}, 0, 0), make(map[string]interface{})
+	rest := strings.TrimLeft(text[1:], " ")
+	for {
+		if rest == "" {
+			return nil, "", fmt.Errorf("unclosed flow collection")
+		}
+		if rest[0] == closing {
+			if closing == '}' {
+				return m, rest[1:], nil
+			}
+			return seq, rest[1:], nil
+		}
+		var item interface{}
+		var err error
+		if rest[0] == '[' || rest[0] == '{' {
+			item, rest, err = yamlFlow(rest)
+		} else {
+			item, rest, err = yamlFlowScalar(rest, true)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		rest = strings.TrimLeft(rest, " ")
+		if closing == '}' {
+			if !strings.HasPrefix(rest, ":") {
+				return nil, "", fmt.Errorf("expected ':' after the key '%v'", item)
+			}
+			var value interface{}
+			if rest = strings.TrimLeft(rest[1:], " "); rest != "" && (rest[0] == '[' || rest[0] == '{') {
+				value, rest, err = yamlFlow(rest)
+			} else {
+				value, rest, err = yamlFlowScalar(rest, true)
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			m[fmt.Sprint(item)] = value
+			rest = strings.TrimLeft(rest, " ")
+		} else {
+			seq = append(seq, item)
+		}
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimLeft(rest[1:], " ")
+		} else if rest == "" || rest[0] != closing {
+			return nil, "", fmt.Errorf("expected ',' or '%c' in a flow collection", closing)
+		}
+	}
+}
+
+// yamlFlowScalar parses the scalar text starts with, returning the text
+// after it. Plain scalars in flow collections end at , : ] or }
+func yamlFlowScalar(text string, flow bool) (interface{}, string, error) {
+	switch text[0] {
+	case '"':
+		return yamlDoubleQuoted(text)
+	case '\'':
+		out := &strings.Builder{}
+		for j := 1; j < len(text); j++ {
+			if text[j] != '\'' {
+				out.WriteByte(text[j])
+				continue
+			}
+			if j+1 < len(text) && text[j+1] == '\'' {
+				out.WriteByte('\'')
+				j++
+				continue
+			}
+			return out.String(), text[j+1:], nil
+		}
+		return nil, "", fmt.Errorf("unclosed single quoted scalar")
+	}
+	end := len(text)
+	if flow {
+		for j := 0; j < len(text); j++ {
+			if strings.IndexByte(",]}", text[j]) >= 0 || (text[j] == ':' && (j+1 == len(text) || text[j+1] == ' ')) {
+				end = j
+				break
+			}
+		}
+	}
+	return yamlPlain(strings.TrimSpace(text[:end])), text[end:], nil
+}
+
+// yamlPlain resolves a plain scalar like yaml 1.2's core schema
+func yamlPlain(text string) interface{} {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlInt.MatchString(text) || yamlFloat.MatchString(text) {
+		return json.Number(strings.TrimPrefix(text, "+"))
+	}
+	return text
+}
+
+func yamlDoubleQuoted(text string) (interface{}, string, error) {
+	out := &strings.Builder{}
+	for j := 1; j < len(text); j++ {
+		switch text[j] {
+		case '"':
+			return out.String(), text[j+1:], nil
+		case '\\':
+			if j++; j == len(text) {
+				break
+			}
+			if escaped, ok := yamlEscapes[text[j]]; ok {
+				out.WriteString(escaped)
+				continue
+			}
+			digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[text[j]]
+			if digits == 0 || j+digits >= len(text) {
+				return nil, "", fmt.Errorf("invalid escape '\\%c'", text[j])
+			}
+			code, err := strconv.ParseUint(text[j+1:j+1+digits], 16, 32)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid escape '\\%s'", text[j:j+1+digits])
+			}
+			out.WriteRune(rune(code))
+			j += digits
+		default:
+			out.WriteByte(text[j])
+		}
+	}
+	return nil, "", fmt.Errorf("unclosed double quoted scalar")
+}
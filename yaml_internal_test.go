@@ -0,0 +1,144 @@
+package lvs
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestYamlConfig(t *testing.T) {
+	doc := `# directors in dc1
+---
+mcast_interface: eth0   # for the sync daemon
+tcp_timeout: 900
+services:
+- host: 10.0.0.1
+  port: 80
+  scheduler: "wlc"
+  name: 'web: it''s # not a comment'
+  servers:
+    - {host: 10.0.1.1, port: 80, weight: 2}
+    - host: 10.0.1.2
+      port: 80
+      weight: 0
+
+- type: fwmark
+  host: 16   # a mark, which yaml reads as a number
+  servers: []
+`
+	config := &Ipvs{}
+	if err := (yamlCodec{}).Unmarshal([]byte(doc), config); err != nil {
+		t.Fatalf("failed to decode yaml - %v", err)
+	}
+	if config.MulticastInterface != "eth0" || config.Tcp != 900 || len(config.Services) != 2 {
+		t.Fatalf("unexpected config - %+v", config)
+	}
+	web := config.Services[0]
+	if web.Host != "10.0.0.1" || web.Port != 80 || web.Scheduler != "wlc" || web.Name != "web: it's # not a comment" || len(web.Servers) != 2 {
+		t.Errorf("unexpected service - %+v", web)
+	}
+	if web.Servers[0].Weight != 2 || web.Servers[1].Host != "10.0.1.2" || !web.Servers[1].WeightSet || web.Servers[1].Weight != 0 {
+		t.Errorf("unexpected servers - %+v", web.Servers)
+	}
+	if mark := config.Services[1]; mark.Type != "fwmark" || mark.Host != "16" || len(mark.Servers) != 0 {
+		t.Errorf("unexpected fwmark service - %+v", mark)
+	}
+
+	// what Marshal writes reads back the same
+	data, err := (yamlCodec{}).Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to encode yaml - %v", err)
+	}
+	decoded := &Ipvs{}
+	if err = (yamlCodec{}).Unmarshal(data, decoded); err != nil {
+		t.Fatalf("failed to decode what was encoded - %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(decoded.Services, config.Services) || decoded.Tcp != 900 {
+		t.Errorf("round trip changed the config\n%s", data)
+	}
+}
+
+func TestYamlValues(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected interface{}
+	}{
+		{"plain text", "plain text"},
+		{"~", nil},
+		{"null", nil},
+		{"true", true},
+		{"-12", float64(-12)},
+		{"1.5e3", float64(1500)},
+		{"10.0.0.1", "10.0.0.1"},
+		{`"tab\there \u00e9 \x41"`, "tab\there é A"},
+		{"'single ''quoted'''", "single 'quoted'"},
+		{"[a, 'b, c', [1, 2], {k: v}]", []interface{}{"a", "b, c", []interface{}{float64(1), float64(2)}, map[string]interface{}{"k": "v"}}},
+		{"{}", map[string]interface{}{}},
+		{"- a\n- - b\n  - c\n-\n  d: e", []interface{}{"a", []interface{}{"b", "c"}, map[string]interface{}{"d": "e"}}},
+		{"a:\n  b:\n    c: 1\n  d: [x]", map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": float64(1)}, "d": []interface{}{"x"}}}},
+		{"\"quoted key\": 1\nempty:", map[string]interface{}{"quoted key": float64(1), "empty": nil}},
+		{"literal: |\n  line one\n   indented\n\n  # kept\nnext: 1", map[string]interface{}{"literal": "line one\n indented\n\n# kept\n", "next": float64(1)}},
+		{"folded: >-\n  one\n  two\n\n  three\n", map[string]interface{}{"folded": "one two\nthree"}},
+		{"list:\n- |\n  block item\n- plain", map[string]interface{}{"list": []interface{}{"block item\n", "plain"}}},
+	}
+	for _, test := range tests {
+		var value interface{}
+		if err := (yamlCodec{}).Unmarshal([]byte(test.doc), &value); err != nil {
+			t.Errorf("failed to decode %q - %v", test.doc, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("expected %q to decode as %#v, got %#v", test.doc, test.expected, value)
+		}
+	}
+}
+
+func TestYamlErrors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		line int
+	}{
+		{"a: 1\n\tb: 2", 2},
+		{"a: 1\n   b: 2", 2},
+		{"a: 1\na: 2", 2},
+		{"a: &anchor 1", 1},
+		{"a: [1, 2", 1},
+		{"a: \"unclosed", 1},
+		{"- a\nb: 1", 2},
+		{"a: 1\njust text", 2},
+	}
+	for _, test := range tests {
+		var value interface{}
+		err := (yamlCodec{}).Unmarshal([]byte(test.doc), &value)
+		if yamlErr, ok := err.(YamlError); !ok || yamlErr.Line != test.line {
+			t.Errorf("expected %q to fail on line %d, got %v", test.doc, test.line, err)
+		}
+	}
+}
+
+func TestYamlScalar(t *testing.T) {
+	// the escapes strconv.Quote writes are ones yaml has, so strconv.Unquote
+	// reads them back the way a yaml parser would
+	escapes := regexp.MustCompile(`\\(.)`)
+	for _, value := range []string{"", "plain", "key: value", "# comment", "- dash", "line\nbreak", "tab\t", `"quoted"`, `back\slash`, "true", "null", "~", "80", "é ünïcode", "bell\a", " "} {
+		scalar := yamlScalar(value)
+		if strings.ContainsAny(scalar, "\n\r\t") || !strings.HasPrefix(scalar, `"`) || !strings.HasSuffix(scalar, `"`) {
+			t.Errorf("expected %q to be a single line double quoted scalar, got %s", value, scalar)
+			continue
+		}
+		for _, escape := range escapes.FindAllStringSubmatch(scalar, -1) {
+			if _, ok := yamlEscapes[escape[1][0]]; !ok && !strings.Contains("xuU", escape[1]) {
+				t.Errorf("%s uses the escape \\%s yaml doesn't have", scalar, escape[1])
+			}
+		}
+		if unquoted, err := strconv.Unquote(scalar); err != nil || unquoted != value {
+			t.Errorf("expected %s to read back as %q, got %q - %v", scalar, value, unquoted, err)
+		}
+		var decoded interface{}
+		if err := (yamlCodec{}).Unmarshal([]byte(scalar), &decoded); err != nil || decoded != value {
+			t.Errorf("expected %s to decode as %q, got %q - %v", scalar, value, decoded, err)
+		}
+	}
+}